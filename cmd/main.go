@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -48,6 +49,15 @@ func main() {
 	if isHelpCommand || (len(os.Args) > 1 && skipConfigCommands[os.Args[1]]) {
 		// Use default config for commands that don't need Vault
 		cfg = config.Default()
+	} else if len(os.Args) > 1 && os.Args[1] == "schedule" {
+		// Scheduled jobs need the configured Vault address and token file, but
+		// not VAULT_ADDR, which the job can take from vault.address instead
+		var err error
+		cfg, err = config.LoadFile()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
+			os.Exit(1)
+		}
 	} else {
 		// Load full configuration including Vault validation
 		var err error
@@ -68,7 +78,9 @@ func main() {
 	// Execute CLI
 	rootCmd := cmd.NewRootCommand(cfg)
 	if err := rootCmd.Execute(); err != nil {
-		log.Error().Err(err).Msg("Command execution failed")
-		os.Exit(1)
+		if !errors.Is(err, cmd.ErrNoChanges) {
+			log.Error().Err(err).Msg("Command execution failed")
+		}
+		os.Exit(cmd.ExitCode(err))
 	}
 }
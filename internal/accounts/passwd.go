@@ -0,0 +1,117 @@
+package accounts
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// PasswdFile is the account database read by Load
+const PasswdFile = "/etc/passwd"
+
+// DefaultMinUID skips system accounts, which conventionally use lower UIDs
+const DefaultMinUID = 1000
+
+// DefaultExcludes are accounts never swept by default. nobody has a high UID
+// on most distributions but no real home directory.
+var DefaultExcludes = []string{"nobody", "nfsnobody"}
+
+// Account is a login account from the passwd database
+type Account struct {
+	Name  string
+	UID   int
+	GID   int
+	Home  string
+	Shell string
+}
+
+// Load reads the accounts in a passwd file
+func Load(passwdFile string) ([]Account, error) {
+	file, err := os.Open(passwdFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read accounts: %w", err)
+	}
+	defer file.Close()
+
+	return Parse(file)
+}
+
+// Parse parses passwd(5) entries. Comments, blank lines and NIS entries
+// (+/-) are skipped; malformed lines are an error.
+func Parse(r io.Reader) ([]Account, error) {
+	var accounts []Account
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-") {
+			continue
+		}
+
+		fields := strings.Split(line, ":")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("passwd line %d: expected 7 fields, got %d", lineNumber, len(fields))
+		}
+		uid, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("passwd line %d: invalid uid %q", lineNumber, fields[2])
+		}
+		gid, err := strconv.Atoi(fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("passwd line %d: invalid gid %q", lineNumber, fields[3])
+		}
+
+		accounts = append(accounts, Account{
+			Name:  fields[0],
+			UID:   uid,
+			GID:   gid,
+			Home:  fields[5],
+			Shell: fields[6],
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read accounts: %w", err)
+	}
+
+	return accounts, nil
+}
+
+// Filter returns the accounts with a UID of at least minUID whose names match
+// none of the exclude glob patterns. Accounts without a home directory are
+// dropped, as are repeated names, keeping the first entry like getpwnam does.
+func Filter(accounts []Account, minUID int, excludes []string) ([]Account, error) {
+	for _, pattern := range excludes {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid exclusion %q: %w", pattern, err)
+		}
+	}
+
+	var selected []Account
+	seen := make(map[string]bool)
+	for _, account := range accounts {
+		if seen[account.Name] {
+			continue
+		}
+		seen[account.Name] = true
+		if account.UID < minUID || account.Home == "" || account.Home == "/" || excluded(account.Name, excludes) {
+			continue
+		}
+		selected = append(selected, account)
+	}
+	return selected, nil
+}
+
+func excluded(name string, excludes []string) bool {
+	for _, pattern := range excludes {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
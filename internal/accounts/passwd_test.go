@@ -0,0 +1,78 @@
+package accounts
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testPasswd = `root:x:0:0:root:/root:/bin/bash
+daemon:x:1:1:daemon:/usr/sbin:/usr/sbin/nologin
+# comment
+
+alice:x:1000:1000:Alice,,,:/home/alice:/bin/bash
+bob:x:1001:100::/home/bob:/bin/zsh
+ci-runner:x:1002:1002::/var/lib/ci:/bin/sh
+nobody:x:65534:65534:nobody:/nonexistent:/usr/sbin/nologin
++@netgroup::::::
+alice:x:1003:1003::/home/alice2:/bin/bash
+`
+
+func TestParse(t *testing.T) {
+	accounts, err := Parse(strings.NewReader(testPasswd))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(accounts) != 7 {
+		t.Fatalf("Parse() returned %d accounts, want 7", len(accounts))
+	}
+
+	want := Account{Name: "bob", UID: 1001, GID: 100, Home: "/home/bob", Shell: "/bin/zsh"}
+	if accounts[3] != want {
+		t.Errorf("accounts[3] = %+v, want %+v", accounts[3], want)
+	}
+
+	for _, bad := range []string{"alice:x:1000:1000", "alice:x:uid:1000::/home/alice:/bin/sh"} {
+		if _, err := Parse(strings.NewReader(bad)); err == nil {
+			t.Errorf("Parse(%q) should fail", bad)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	all, err := Parse(strings.NewReader(testPasswd))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	names := func(accounts []Account) []string {
+		var result []string
+		for _, account := range accounts {
+			result = append(result, account.Name)
+		}
+		return result
+	}
+
+	selected, err := Filter(all, DefaultMinUID, DefaultExcludes)
+	if err != nil {
+		t.Fatalf("Filter() error = %v", err)
+	}
+	if got := names(selected); !reflect.DeepEqual(got, []string{"alice", "bob", "ci-runner"}) {
+		t.Errorf("Filter() = %v", got)
+	}
+	if selected[0].Home != "/home/alice" {
+		t.Errorf("duplicate account should keep the first entry, got %s", selected[0].Home)
+	}
+
+	selected, err = Filter(all, 0, []string{"ci-*", "nobody", "daemon"})
+	if err != nil {
+		t.Fatalf("Filter() error = %v", err)
+	}
+	if got := names(selected); !reflect.DeepEqual(got, []string{"root", "alice", "bob"}) {
+		t.Errorf("Filter(min 0) = %v", got)
+	}
+
+	if _, err := Filter(all, 0, []string{"[bad"}); err == nil {
+		t.Error("Filter() should reject invalid patterns")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/rs/zerolog/log"
+	"github.com/rzago/ssh-secret-keeper/internal/filter"
 )
 
 // Analyzer analyzes SSH directories and categorizes files
@@ -15,12 +16,15 @@ type Analyzer struct {
 	detectors       []KeyDetector
 	servicePatterns map[string][]string
 	purposeRules    map[string]KeyPurpose
+	filter          *filter.Matcher
 }
 
 // New creates a new analyzer with default detectors
 func New() *Analyzer {
 	return &Analyzer{
 		detectors: []KeyDetector{
+			&HostKeyDetector{}, // Before the generic key detectors so host keys keep their purpose
+			&SSHDConfigDetector{},
 			&RSAKeyDetector{},
 			&PEMKeyDetector{},
 			&OpenSSHKeyDetector{},
@@ -48,37 +52,127 @@ func New() *Analyzer {
 	}
 }
 
-// AnalyzeDirectory analyzes an SSH directory and returns categorized results
+// NewWithDetectors creates an analyzer that tries detectors in order, with the
+// default service patterns and purpose rules. The last detector should accept
+// every file, like UniversalFileDetector, or unrecognised files are skipped.
+func NewWithDetectors(detectors ...KeyDetector) *Analyzer {
+	a := New()
+	a.detectors = detectors
+	return a
+}
+
+// SetFilter sets the include/exclude rules applied by AnalyzeDirectory
+func (a *Analyzer) SetFilter(matcher *filter.Matcher) {
+	a.filter = matcher
+}
+
+// AnalyzeDirectory analyzes an SSH directory tree and returns categorized results.
+// Files are keyed by their slash-separated path relative to sshDir. Symlinks are
+// recorded rather than followed, and sockets and other special files are skipped.
 func (a *Analyzer) AnalyzeDirectory(sshDir string) (*DetectionResult, error) {
 	log.Info().Str("dir", sshDir).Msg("Starting SSH directory analysis")
 
-	// Read directory
-	files, err := os.ReadDir(sshDir)
-	if err != nil {
-		return nil, fmt.Errorf("error reading SSH directory: %w", err)
+	// Walk the real directory when the SSH directory itself is a symlink
+	root := sshDir
+	if resolved, err := filepath.EvalSymlinks(sshDir); err == nil {
+		root = resolved
 	}
 
-	var keys []KeyInfo
-	allFilenames := make([]string, 0, len(files))
+	type regularFile struct {
+		relPath string
+		path    string
+		entry   fs.DirEntry
+	}
 
-	// First pass: collect all filenames
-	for _, file := range files {
-		if file.IsDir() {
-			continue
+	var (
+		keys         []KeyInfo
+		excluded     []ExcludedFile
+		directories  []DirectoryInfo
+		symlinks     []SymlinkInfo
+		regularFiles []regularFile
+		allFilenames []string
+	)
+
+	// First pass: walk the tree, apply filter rules and collect all filenames
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			log.Warn().Err(err).Str("path", path).Msg("Cannot read path, skipping")
+			return nil
+		}
+		if path == root {
+			return nil
 		}
-		allFilenames = append(allFilenames, file.Name())
-	}
 
-	// Second pass: analyze each file
-	for _, file := range files {
-		if file.IsDir() {
-			continue
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
 		}
+		relPath = filepath.ToSlash(relPath)
 
-		filePath := filepath.Join(sshDir, file.Name())
-		keyInfo, err := a.analyzeFile(filePath, file, allFilenames)
+		if match := a.filter.Match(relPath, entry.IsDir()); match.Excluded {
+			name := relPath
+			if entry.IsDir() {
+				name += "/"
+			}
+			log.Debug().Str("file", name).Str("rule", match.Rule.String()).Msg("File excluded")
+			excluded = append(excluded, ExcludedFile{Filename: name, Rule: match.Rule.String()})
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		info, err := entry.Info()
 		if err != nil {
-			log.Warn().Err(err).Str("file", file.Name()).Msg("Failed to analyze file")
+			log.Warn().Err(err).Str("file", relPath).Msg("Cannot stat file, skipping")
+			return nil
+		}
+
+		switch {
+		case entry.IsDir():
+			directories = append(directories, DirectoryInfo{
+				Path:        relPath,
+				Permissions: info.Mode().Perm(),
+				ModTime:     info.ModTime(),
+			})
+
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				log.Warn().Err(err).Str("file", relPath).Msg("Cannot read symlink, skipping")
+				return nil
+			}
+			symlinks = append(symlinks, SymlinkInfo{
+				Path:    relPath,
+				Target:  target,
+				ModTime: info.ModTime(),
+			})
+
+		case !info.Mode().IsRegular():
+			excluded = append(excluded, ExcludedFile{
+				Filename: relPath,
+				Rule:     fmt.Sprintf("%s (special files are never backed up)", specialFileKind(info.Mode())),
+			})
+
+		default:
+			regularFiles = append(regularFiles, regularFile{relPath: relPath, path: path, entry: entry})
+			allFilenames = append(allFilenames, relPath)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading SSH directory: %w", err)
+	}
+
+	// Second pass: analyze each file
+	for _, file := range regularFiles {
+		keyInfo, err := a.analyzeFile(file.path, file.relPath, file.entry, allFilenames)
+		if err != nil {
+			log.Warn().Err(err).Str("file", file.relPath).Msg("Failed to analyze file")
 			continue
 		}
 
@@ -89,9 +183,14 @@ func (a *Analyzer) AnalyzeDirectory(sshDir string) (*DetectionResult, error) {
 
 	// Post-process: find key pairs and categorize
 	result := a.processResults(keys)
+	result.Excluded = excluded
+	result.Directories = directories
+	result.Symlinks = symlinks
 
 	log.Info().
 		Int("total_files", result.Summary.TotalFiles).
+		Int("directories", len(directories)).
+		Int("symlinks", len(symlinks)).
 		Int("key_pairs", result.Summary.KeyPairCount).
 		Int("services", result.Summary.ServiceKeys).
 		Msg("Analysis completed")
@@ -99,8 +198,23 @@ func (a *Analyzer) AnalyzeDirectory(sshDir string) (*DetectionResult, error) {
 	return result, nil
 }
 
-// analyzeFile analyzes a single file
-func (a *Analyzer) analyzeFile(filePath string, fileInfo fs.DirEntry, allFiles []string) (*KeyInfo, error) {
+// specialFileKind names the type of a non-regular, non-directory file
+func specialFileKind(mode fs.FileMode) string {
+	switch {
+	case mode&fs.ModeSocket != 0:
+		return "socket"
+	case mode&fs.ModeNamedPipe != 0:
+		return "named pipe"
+	case mode&fs.ModeDevice != 0:
+		return "device"
+	default:
+		return "special file"
+	}
+}
+
+// analyzeFile analyzes a single file. Detectors see the base name while the
+// returned KeyInfo carries the path relative to the SSH directory.
+func (a *Analyzer) analyzeFile(filePath, relPath string, fileInfo fs.DirEntry, allFiles []string) (*KeyInfo, error) {
 	// Get file info
 	info, err := fileInfo.Info()
 	if err != nil {
@@ -115,9 +229,9 @@ func (a *Analyzer) analyzeFile(filePath string, fileInfo fs.DirEntry, allFiles [
 
 	// Try each detector
 	for _, detector := range a.detectors {
-		if keyInfo, detected := detector.Detect(fileInfo.Name(), content); detected {
+		if keyInfo, detected := detectFile(detector, fileInfo.Name(), relPath, content); detected {
 			// Fill in additional info
-			keyInfo.Filename = fileInfo.Name()
+			keyInfo.Filename = relPath
 			keyInfo.Permissions = info.Mode()
 			keyInfo.Size = info.Size()
 			keyInfo.ModTime = info.ModTime()
@@ -126,7 +240,7 @@ func (a *Analyzer) analyzeFile(filePath string, fileInfo fs.DirEntry, allFiles [
 			a.enhanceKeyInfo(keyInfo, allFiles)
 
 			log.Debug().
-				Str("file", fileInfo.Name()).
+				Str("file", relPath).
 				Str("detector", detector.Name()).
 				Str("type", string(keyInfo.Type)).
 				Msg("File detected")
@@ -136,10 +250,19 @@ func (a *Analyzer) analyzeFile(filePath string, fileInfo fs.DirEntry, allFiles [
 	}
 
 	// No detector matched
-	log.Debug().Str("file", fileInfo.Name()).Msg("File not recognized")
+	log.Debug().Str("file", relPath).Msg("File not recognized")
 	return nil, nil
 }
 
+// detectFile runs a detector on the base name of a file, or on its relative
+// path for detectors implementing PathDetector
+func detectFile(detector KeyDetector, name, relPath string, content []byte) (*KeyInfo, bool) {
+	if pathDetector, ok := detector.(PathDetector); ok {
+		return pathDetector.DetectPath(relPath, content)
+	}
+	return detector.Detect(name, content)
+}
+
 // readFileHead reads the first n bytes of a file
 func (a *Analyzer) readFileHead(filePath string, maxBytes int) ([]byte, error) {
 	file, err := os.Open(filePath)
@@ -159,12 +282,17 @@ func (a *Analyzer) readFileHead(filePath string, maxBytes int) ([]byte, error) {
 
 // enhanceKeyInfo adds service and purpose information to key info
 func (a *Analyzer) enhanceKeyInfo(keyInfo *KeyInfo, allFiles []string) {
+	// Detectors that know the purpose (such as host keys) take precedence
+	if keyInfo.Purpose != "" {
+		return
+	}
+
 	filename := strings.ToLower(keyInfo.Filename)
 
 	// Determine service
 	for service, patterns := range a.servicePatterns {
 		for _, pattern := range patterns {
-			if matched, _ := filepath.Match(strings.ToLower(pattern), filename); matched {
+			if matchPathComponent(strings.ToLower(pattern), filename) {
 				keyInfo.Service = service
 				keyInfo.Purpose = PurposeService
 				return
@@ -174,7 +302,7 @@ func (a *Analyzer) enhanceKeyInfo(keyInfo *KeyInfo, allFiles []string) {
 
 	// Determine purpose from rules
 	for pattern, purpose := range a.purposeRules {
-		if matched, _ := filepath.Match(strings.ToLower(pattern), filename); matched {
+		if matchPathComponent(strings.ToLower(pattern), filename) {
 			keyInfo.Purpose = purpose
 			return
 		}
@@ -193,6 +321,17 @@ func (a *Analyzer) enhanceKeyInfo(keyInfo *KeyInfo, allFiles []string) {
 	}
 }
 
+// matchPathComponent matches pattern against each component of a slash-separated
+// path, so keys/github/id_ed25519 is recognised as a github key
+func matchPathComponent(pattern, relPath string) bool {
+	for _, component := range strings.Split(relPath, "/") {
+		if matched, _ := filepath.Match(pattern, component); matched {
+			return true
+		}
+	}
+	return false
+}
+
 // processResults processes the analyzed keys to find pairs and categorize
 func (a *Analyzer) processResults(keys []KeyInfo) *DetectionResult {
 	keyPairs := a.findKeyPairs(keys)
@@ -299,6 +438,8 @@ func (a *Analyzer) generateSummary(keys []KeyInfo, keyPairs map[string]*KeyPairI
 			summary.WorkKeys++
 		case PurposeSystem:
 			summary.SystemFiles++
+		case PurposeHost:
+			summary.HostFiles++
 		}
 
 		if key.Type == KeyTypeUnknown {
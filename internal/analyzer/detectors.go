@@ -265,6 +265,90 @@ func (d *AuthorizedKeysDetector) GetRelatedFiles(keyInfo *KeyInfo, allFiles []st
 	return nil // authorized_keys files don't have related files
 }
 
+// HostKeyDetector detects sshd host keys (ssh_host_*_key, .pub and -cert.pub)
+type HostKeyDetector struct{}
+
+func (d *HostKeyDetector) Name() string {
+	return "host_key"
+}
+
+func (d *HostKeyDetector) Detect(filename string, content []byte) (*KeyInfo, bool) {
+	name := strings.ToLower(filename)
+	if !strings.HasPrefix(name, "ssh_host_") {
+		return nil, false
+	}
+
+	keyType := KeyTypePrivate
+	switch {
+	case strings.HasSuffix(name, "_key-cert.pub"):
+		keyType = KeyTypeCertificate
+		name = strings.TrimSuffix(name, "-cert.pub")
+	case strings.HasSuffix(name, "_key.pub"):
+		keyType = KeyTypePublic
+		name = strings.TrimSuffix(name, ".pub")
+	case !strings.HasSuffix(name, "_key"):
+		return nil, false
+	}
+
+	format := FormatOpenSSH
+	switch strings.TrimSuffix(strings.TrimPrefix(name, "ssh_host_"), "_key") {
+	case "rsa":
+		format = FormatRSA
+	case "ecdsa":
+		format = FormatECDSA
+	case "ed25519":
+		format = FormatEd25519
+	}
+
+	return &KeyInfo{
+		Type:    keyType,
+		Format:  format,
+		Purpose: PurposeHost,
+	}, true
+}
+
+func (d *HostKeyDetector) GetRelatedFiles(keyInfo *KeyInfo, allFiles []string) []string {
+	baseName := strings.TrimSuffix(strings.TrimSuffix(keyInfo.Filename, "-cert.pub"), ".pub")
+	var related []string
+
+	for _, file := range allFiles {
+		if file == baseName || file == baseName+".pub" || file == baseName+"-cert.pub" {
+			if file != keyInfo.Filename {
+				related = append(related, file)
+			}
+		}
+	}
+
+	return related
+}
+
+// SSHDConfigDetector detects sshd_config and the drop-ins in sshd_config.d
+type SSHDConfigDetector struct{}
+
+func (d *SSHDConfigDetector) Name() string {
+	return "sshd_config"
+}
+
+func (d *SSHDConfigDetector) Detect(filename string, content []byte) (*KeyInfo, bool) {
+	return d.DetectPath(filename, content)
+}
+
+func (d *SSHDConfigDetector) DetectPath(relPath string, content []byte) (*KeyInfo, bool) {
+	if relPath != "sshd_config" && !strings.HasPrefix(relPath, "sshd_config.d/") {
+		return nil, false
+	}
+
+	return &KeyInfo{
+		Type:    KeyTypeConfig,
+		Format:  FormatSSHD,
+		Purpose: PurposeHost,
+	}, true
+}
+
+func (d *SSHDConfigDetector) GetRelatedFiles(keyInfo *KeyInfo, allFiles []string) []string {
+	return nil // Drop-ins are included by sshd_config, not paired with it
+}
+
 // UniversalFileDetector detects any file that wasn't caught by other detectors
 // This ensures ALL files in .ssh folder are backed up regardless of name
 type UniversalFileDetector struct{}
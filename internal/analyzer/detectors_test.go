@@ -373,3 +373,63 @@ func TestRSAKeyDetector_ContentBasedPublicKey(t *testing.T) {
 		t.Errorf("Expected KeyTypePublic for ssh-rsa content, got %s", keyInfo.Type)
 	}
 }
+
+func TestHostKeyDetector(t *testing.T) {
+	detector := &HostKeyDetector{}
+
+	tests := []struct {
+		filename   string
+		wantType   KeyType
+		wantFormat KeyFormat
+	}{
+		{"ssh_host_ed25519_key", KeyTypePrivate, FormatEd25519},
+		{"ssh_host_ed25519_key.pub", KeyTypePublic, FormatEd25519},
+		{"ssh_host_rsa_key", KeyTypePrivate, FormatRSA},
+		{"ssh_host_ecdsa_key.pub", KeyTypePublic, FormatECDSA},
+		{"ssh_host_rsa_key-cert.pub", KeyTypeCertificate, FormatRSA},
+	}
+
+	for _, tt := range tests {
+		keyInfo, detected := detector.Detect(tt.filename, nil)
+		if !detected {
+			t.Errorf("%s not detected as a host key", tt.filename)
+			continue
+		}
+		if keyInfo.Type != tt.wantType || keyInfo.Format != tt.wantFormat || keyInfo.Purpose != PurposeHost {
+			t.Errorf("%s: got %s/%s/%s, want %s/%s/host", tt.filename, keyInfo.Type, keyInfo.Format, keyInfo.Purpose, tt.wantType, tt.wantFormat)
+		}
+	}
+
+	for _, filename := range []string{"id_ed25519", "ssh_host_keys.txt", "moduli", "ssh_config"} {
+		if _, detected := detector.Detect(filename, nil); detected {
+			t.Errorf("%s should not be detected as a host key", filename)
+		}
+	}
+
+	related := detector.GetRelatedFiles(&KeyInfo{Filename: "ssh_host_rsa_key"},
+		[]string{"ssh_host_rsa_key", "ssh_host_rsa_key.pub", "ssh_host_rsa_key-cert.pub", "ssh_host_ed25519_key"})
+	if len(related) != 2 {
+		t.Errorf("GetRelatedFiles() = %v, want the public key and certificate", related)
+	}
+}
+
+func TestSSHDConfigDetector(t *testing.T) {
+	detector := &SSHDConfigDetector{}
+
+	for _, relPath := range []string{"sshd_config", "sshd_config.d/50-cloud-init.conf"} {
+		keyInfo, detected := detector.DetectPath(relPath, []byte("PasswordAuthentication no\n"))
+		if !detected {
+			t.Errorf("%s not detected", relPath)
+			continue
+		}
+		if keyInfo.Type != KeyTypeConfig || keyInfo.Format != FormatSSHD || keyInfo.Purpose != PurposeHost {
+			t.Errorf("%s: unexpected key info %+v", relPath, keyInfo)
+		}
+	}
+
+	for _, relPath := range []string{"ssh_config", "ssh_config.d/10-defaults.conf", "50-cloud-init.conf"} {
+		if _, detected := detector.DetectPath(relPath, nil); detected {
+			t.Errorf("%s should not be detected as sshd configuration", relPath)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Names of the built-in analyzers for backup sources
+const (
+	AnalyzerSSH     = "ssh"     // The full SSH directory analyzer returned by New
+	AnalyzerGeneric = "generic" // Private keys by content; everything else is an unknown file
+)
+
+// Factory creates an analyzer for one backup source
+type Factory func() *Analyzer
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{
+		AnalyzerSSH:     New,
+		AnalyzerGeneric: NewGeneric,
+	}
+)
+
+// NewGeneric creates an analyzer for directories that are not SSH directories,
+// such as ~/.kube or ~/.gnupg. File names carry no SSH meaning there, so only
+// PEM and OpenSSH private keys are recognised by their content.
+func NewGeneric() *Analyzer {
+	return NewWithDetectors(
+		&PEMKeyDetector{},
+		&OpenSSHKeyDetector{},
+		&UniversalFileDetector{},
+	)
+}
+
+// Register makes an analyzer available to backup sources under name,
+// replacing any analyzer registered under the same name
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = factory
+}
+
+// ForName creates the analyzer registered under name. An empty name selects
+// the generic analyzer.
+func ForName(name string) (*Analyzer, error) {
+	if name == "" {
+		name = AnalyzerGeneric
+	}
+
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown analyzer %q (available: %s)", name, strings.Join(Names(), ", "))
+	}
+	return factory(), nil
+}
+
+// Names returns the registered analyzer names in sorted order
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
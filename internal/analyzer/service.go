@@ -169,6 +169,8 @@ func (s *Service) AnalyzeFile(filePath string) (*KeyInfo, error) {
 
 func (s *Service) registerDefaultDetectors() {
 	defaultDetectors := []KeyDetector{
+		&HostKeyDetector{},
+		&SSHDConfigDetector{},
 		&RSAKeyDetector{},
 		&PEMKeyDetector{},
 		&OpenSSHKeyDetector{},
@@ -225,6 +227,10 @@ func (s *Service) readFileHead(filePath string, maxBytes int) ([]byte, error) {
 }
 
 func (s *Service) enhanceKeyInfo(keyInfo *KeyInfo, allFiles []string) {
+	if keyInfo.Purpose != "" {
+		return
+	}
+
 	filename := filepath.Base(keyInfo.Filename)
 
 	// Determine service from patterns
@@ -15,6 +15,7 @@ const (
 	KeyTypeHosts       KeyType = "known_hosts"
 	KeyTypeAuthorized  KeyType = "authorized_keys"
 	KeyTypeCertificate KeyType = "certificate"
+	KeyTypeSymlink     KeyType = "symlink"
 	KeyTypeUnknown     KeyType = "unknown"
 )
 
@@ -26,6 +27,7 @@ const (
 	FormatPEM     KeyFormat = "pem"
 	FormatOpenSSH KeyFormat = "openssh"
 	FormatConfig  KeyFormat = "ssh_config"
+	FormatSSHD    KeyFormat = "sshd_config"
 	FormatHosts   KeyFormat = "hosts"
 	FormatEd25519 KeyFormat = "ed25519"
 	FormatECDSA   KeyFormat = "ecdsa"
@@ -41,6 +43,7 @@ const (
 	PurposeWork     KeyPurpose = "work"
 	PurposeCloud    KeyPurpose = "cloud"
 	PurposeSystem   KeyPurpose = "system"
+	PurposeHost     KeyPurpose = "host" // sshd host keys and server configuration
 )
 
 // KeyInfo contains detailed information about an SSH file
@@ -72,9 +75,32 @@ type DetectionResult struct {
 	Categories   map[string][]KeyInfo    `json:"categories"`
 	SystemFiles  []KeyInfo               `json:"system_files"`
 	UnknownFiles []KeyInfo               `json:"unknown_files"`
+	Excluded     []ExcludedFile          `json:"excluded,omitempty"`
+	Directories  []DirectoryInfo         `json:"directories,omitempty"`
+	Symlinks     []SymlinkInfo           `json:"symlinks,omitempty"`
 	Summary      *AnalysisSummary        `json:"summary"`
 }
 
+// DirectoryInfo describes a subdirectory of the SSH directory
+type DirectoryInfo struct {
+	Path        string      `json:"path"`
+	Permissions os.FileMode `json:"permissions"`
+	ModTime     time.Time   `json:"mod_time"`
+}
+
+// SymlinkInfo describes a symbolic link, which is recorded rather than followed
+type SymlinkInfo struct {
+	Path    string    `json:"path"`
+	Target  string    `json:"target"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// ExcludedFile is a file skipped by an include/exclude rule
+type ExcludedFile struct {
+	Filename string `json:"filename"`
+	Rule     string `json:"rule"`
+}
+
 // AnalysisSummary provides a summary of the analysis
 type AnalysisSummary struct {
 	TotalFiles       int                `json:"total_files"`
@@ -83,6 +109,7 @@ type AnalysisSummary struct {
 	PersonalKeys     int                `json:"personal_keys"`
 	WorkKeys         int                `json:"work_keys"`
 	SystemFiles      int                `json:"system_files"`
+	HostFiles        int                `json:"host_files,omitempty"`
 	UnknownFiles     int                `json:"unknown_files"`
 	FormatBreakdown  map[KeyFormat]int  `json:"format_breakdown"`
 	PurposeBreakdown map[KeyPurpose]int `json:"purpose_breakdown"`
@@ -94,3 +121,10 @@ type KeyDetector interface {
 	Detect(filename string, content []byte) (*KeyInfo, bool)
 	GetRelatedFiles(keyInfo *KeyInfo, allFiles []string) []string
 }
+
+// PathDetector is implemented by detectors that need a file's path relative to
+// the analyzed directory, such as drop-ins below sshd_config.d, instead of its
+// base name. The analyzer calls DetectPath in place of Detect.
+type PathDetector interface {
+	DetectPath(relPath string, content []byte) (*KeyInfo, bool)
+}
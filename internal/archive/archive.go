@@ -0,0 +1,409 @@
+// Package archive writes backups as tar, tar.gz or zip archives and extracts
+// such archives, so SSH material can move to and from machines without sshsk.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/rzago/ssh-secret-keeper/internal/ssh"
+	"github.com/rzago/ssh-secret-keeper/internal/utils"
+)
+
+// Format is an archive format
+type Format string
+
+const (
+	FormatTar   Format = "tar"
+	FormatTarGz Format = "tar.gz"
+	FormatZip   Format = "zip"
+)
+
+// maxFileSize bounds a single extracted file; SSH material is far smaller
+const maxFileSize = 32 << 20
+
+// ParseFormat validates a --format value
+func ParseFormat(value string) (Format, error) {
+	switch strings.ToLower(value) {
+	case "tar":
+		return FormatTar, nil
+	case "tar.gz", "tgz":
+		return FormatTarGz, nil
+	case "zip":
+		return FormatZip, nil
+	}
+	return "", fmt.Errorf("unknown archive format %q: expected tar, tar.gz or zip", value)
+}
+
+// Detect recognises the format of an archive by its leading bytes
+func Detect(data []byte) (Format, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		return FormatTarGz, nil
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")), bytes.HasPrefix(data, []byte("PK\x05\x06")):
+		return FormatZip, nil
+	case len(data) >= 262 && string(data[257:262]) == "ustar":
+		return FormatTar, nil
+	}
+	return "", fmt.Errorf("not a tar, tar.gz or zip archive")
+}
+
+// entry is one path of an archive, relative to the SSH directory
+type entry struct {
+	name       string
+	mode       os.FileMode
+	modTime    time.Time
+	content    []byte
+	linkTarget string
+	isDir      bool
+}
+
+// entries lists the directories of a backup, parents first, then its files
+func entries(backup *ssh.BackupData) []entry {
+	modTime := backup.Timestamp
+	if modTime.IsZero() {
+		modTime = time.Now()
+	}
+
+	dirs := make(map[string]os.FileMode, len(backup.Directories))
+	for dir, mode := range backup.Directories {
+		dirs[dir] = mode.Perm()
+	}
+	// Parents missing from older backups get the SSH directory's mode
+	for name := range backup.Files {
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			if _, ok := dirs[dir]; !ok {
+				dirs[dir] = 0700
+			}
+		}
+	}
+	dirNames := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		dirNames = append(dirNames, dir)
+	}
+	sort.Strings(dirNames)
+
+	list := make([]entry, 0, len(dirs)+len(backup.Files))
+	for _, dir := range dirNames {
+		list = append(list, entry{name: dir, mode: dirs[dir], modTime: modTime, isDir: true})
+	}
+	for _, name := range backup.FileNames() {
+		fileData := backup.Files[name]
+		fileTime := fileData.ModTime
+		if fileTime.IsZero() {
+			fileTime = modTime
+		}
+		list = append(list, entry{
+			name:       name,
+			mode:       fileData.Permissions.Perm(),
+			modTime:    fileTime,
+			content:    fileData.Content,
+			linkTarget: fileData.LinkTarget,
+		})
+	}
+	return list
+}
+
+// Write writes the files and directories of a backup to w, with their paths
+// relative to the SSH directory, modes and modification times
+func Write(w io.Writer, format Format, backup *ssh.BackupData) error {
+	switch format {
+	case FormatTar:
+		return writeTar(w, backup)
+	case FormatTarGz:
+		gz := gzip.NewWriter(w)
+		if err := writeTar(gz, backup); err != nil {
+			return err
+		}
+		return gz.Close()
+	case FormatZip:
+		return writeZip(w, backup)
+	}
+	return fmt.Errorf("unknown archive format %q", format)
+}
+
+func writeTar(w io.Writer, backup *ssh.BackupData) error {
+	tw := tar.NewWriter(w)
+	for _, e := range entries(backup) {
+		header := &tar.Header{
+			Name:    e.name,
+			Mode:    int64(e.mode),
+			ModTime: e.modTime,
+			Format:  tar.FormatPAX,
+		}
+		switch {
+		case e.isDir:
+			header.Typeflag = tar.TypeDir
+			header.Name += "/"
+		case e.linkTarget != "":
+			header.Typeflag = tar.TypeSymlink
+			header.Linkname = e.linkTarget
+			header.Mode = 0777
+		default:
+			header.Typeflag = tar.TypeReg
+			header.Size = int64(len(e.content))
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to write %s: %w", e.name, err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			if _, err := tw.Write(e.content); err != nil {
+				return fmt.Errorf("failed to write %s: %w", e.name, err)
+			}
+		}
+	}
+	return tw.Close()
+}
+
+func writeZip(w io.Writer, backup *ssh.BackupData) error {
+	zw := zip.NewWriter(w)
+	for _, e := range entries(backup) {
+		header := &zip.FileHeader{Name: e.name, Method: zip.Deflate, Modified: e.modTime}
+		content := e.content
+		switch {
+		case e.isDir:
+			header.Name += "/"
+			header.Method = zip.Store
+			header.SetMode(os.ModeDir | e.mode)
+			content = nil
+		case e.linkTarget != "":
+			// Zip stores a symlink as a file whose content is the target
+			header.SetMode(os.ModeSymlink | 0777)
+			content = []byte(e.linkTarget)
+		default:
+			header.SetMode(e.mode)
+		}
+		fw, err := zw.CreateHeader(header)
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", e.name, err)
+		}
+		if _, err := fw.Write(content); err != nil {
+			return fmt.Errorf("failed to write %s: %w", e.name, err)
+		}
+	}
+	return zw.Close()
+}
+
+// Extract unpacks an archive into dir, which should be empty, keeping modes,
+// modification times and symlinks. Paths escaping dir are refused; devices and
+// other special files are skipped. It returns the number of files extracted.
+func Extract(data []byte, dir string) (int, error) {
+	format, err := Detect(data)
+	if err != nil {
+		return 0, err
+	}
+
+	x := &extractor{root: dir, dirModes: make(map[string]os.FileMode)}
+	switch format {
+	case FormatTarGz:
+		gz, gzErr := gzip.NewReader(bytes.NewReader(data))
+		if gzErr != nil {
+			return 0, fmt.Errorf("invalid gzip archive: %w", gzErr)
+		}
+		defer gz.Close()
+		err = x.tar(gz)
+	case FormatTar:
+		err = x.tar(bytes.NewReader(data))
+	case FormatZip:
+		err = x.zip(data)
+	}
+	if err != nil {
+		return x.files, err
+	}
+	return x.files, x.applyDirModes()
+}
+
+type extractor struct {
+	root     string
+	files    int
+	dirModes map[string]os.FileMode
+}
+
+func (x *extractor) tar(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid tar archive: %w", err)
+		}
+
+		mode := os.FileMode(header.Mode).Perm()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = x.dir(header.Name, mode)
+		case tar.TypeReg:
+			err = x.file(header.Name, mode, header.ModTime, io.LimitReader(tr, maxFileSize+1))
+		case tar.TypeSymlink:
+			err = x.symlink(header.Name, header.Linkname)
+		default:
+			log.Warn().Str("path", header.Name).Msg("Skipping special file in archive")
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (x *extractor) zip(data []byte) error {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("invalid zip archive: %w", err)
+	}
+	for _, f := range zr.File {
+		if err := x.zipEntry(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *extractor) zipEntry(f *zip.File) error {
+	mode := f.Mode()
+	if mode.IsDir() {
+		return x.dir(f.Name, mode.Perm())
+	}
+	if !mode.IsRegular() && mode&os.ModeSymlink == 0 {
+		log.Warn().Str("path", f.Name).Msg("Skipping special file in archive")
+		return nil
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("cannot read %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+
+	if mode&os.ModeSymlink != 0 {
+		target, err := io.ReadAll(io.LimitReader(rc, 4096))
+		if err != nil {
+			return fmt.Errorf("cannot read %s: %w", f.Name, err)
+		}
+		return x.symlink(f.Name, string(target))
+	}
+	return x.file(f.Name, mode.Perm(), f.Modified, io.LimitReader(rc, maxFileSize+1))
+}
+
+// target maps an archive path onto the extraction directory. Paths escaping
+// it, directly or through a symlink extracted earlier, are refused; an
+// existing file at the path is removed so it is replaced, not written through.
+func (x *extractor) target(name string) (string, string, error) {
+	rel := strings.TrimSuffix(strings.TrimPrefix(name, "./"), "/")
+	if rel == "" || rel == "." {
+		return "", "", nil
+	}
+	target, err := utils.SafeJoin(x.root, rel)
+	if err != nil {
+		return "", "", fmt.Errorf("refusing archive entry: %w", err)
+	}
+
+	for dir := filepath.Dir(target); dir != x.root && len(dir) > len(x.root); dir = filepath.Dir(dir) {
+		if info, err := os.Lstat(dir); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return "", "", fmt.Errorf("refusing archive entry %q: it lies behind a symlink", name)
+		}
+	}
+	if info, err := os.Lstat(target); err == nil && !info.IsDir() {
+		if err := os.Remove(target); err != nil {
+			return "", "", fmt.Errorf("cannot replace %s: %w", rel, err)
+		}
+	}
+	return rel, target, nil
+}
+
+func (x *extractor) dir(name string, mode os.FileMode) error {
+	rel, target, err := x.target(name)
+	if err != nil || rel == "" {
+		return err
+	}
+	if err := os.MkdirAll(target, 0700); err != nil {
+		return fmt.Errorf("cannot create %s: %w", rel, err)
+	}
+	if mode == 0 {
+		mode = 0700
+	}
+	x.dirModes[target] = mode
+	return nil
+}
+
+func (x *extractor) file(name string, mode os.FileMode, modTime time.Time, r io.Reader) error {
+	rel, target, err := x.target(name)
+	if err != nil || rel == "" {
+		return err
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("cannot read %s: %w", rel, err)
+	}
+	if len(content) > maxFileSize {
+		return fmt.Errorf("%s is larger than %d bytes", rel, maxFileSize)
+	}
+	if mode == 0 {
+		mode = 0600
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		return fmt.Errorf("cannot create directory for %s: %w", rel, err)
+	}
+	if err := os.WriteFile(target, content, mode); err != nil {
+		return fmt.Errorf("cannot extract %s: %w", rel, err)
+	}
+	// WriteFile's mode is subject to the umask
+	if err := os.Chmod(target, mode); err != nil {
+		return fmt.Errorf("cannot set mode of %s: %w", rel, err)
+	}
+	if !modTime.IsZero() {
+		if err := os.Chtimes(target, modTime, modTime); err != nil {
+			log.Debug().Err(err).Str("path", rel).Msg("Cannot set modification time")
+		}
+	}
+	x.files++
+	return nil
+}
+
+func (x *extractor) symlink(name, linkTarget string) error {
+	rel, target, err := x.target(name)
+	if err != nil || rel == "" {
+		return err
+	}
+	if linkTarget == "" {
+		return fmt.Errorf("symlink %s has no target", rel)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		return fmt.Errorf("cannot create directory for %s: %w", rel, err)
+	}
+	// Links are kept as links and never followed, wherever they point
+	if err := os.Symlink(linkTarget, target); err != nil {
+		return fmt.Errorf("cannot extract symlink %s: %w", rel, err)
+	}
+	x.files++
+	return nil
+}
+
+// applyDirModes sets recorded directory modes once their files are written,
+// deepest first so a read-only parent does not block its children
+func (x *extractor) applyDirModes() error {
+	dirs := make([]string, 0, len(x.dirModes))
+	for dir := range x.dirModes {
+		dirs = append(dirs, dir)
+	}
+	sort.Slice(dirs, func(i, j int) bool { return len(dirs[i]) > len(dirs[j]) })
+	for _, dir := range dirs {
+		if err := os.Chmod(dir, x.dirModes[dir]); err != nil {
+			return fmt.Errorf("cannot set mode of %s: %w", dir, err)
+		}
+	}
+	return nil
+}
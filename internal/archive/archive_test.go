@@ -0,0 +1,144 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rzago/ssh-secret-keeper/internal/ssh"
+)
+
+func testBackup() *ssh.BackupData {
+	modTime := time.Date(2026, 9, 1, 12, 0, 0, 0, time.UTC)
+	return &ssh.BackupData{
+		Timestamp: modTime,
+		Files: map[string]*ssh.FileData{
+			"id_ed25519":     {Filename: "id_ed25519", Content: []byte("private"), Permissions: 0600, ModTime: modTime},
+			"id_ed25519.pub": {Filename: "id_ed25519.pub", Content: []byte("ssh-ed25519 AAAA"), Permissions: 0644, ModTime: modTime},
+			"config.d/work":  {Filename: "config.d/work", Content: []byte("Host work\n"), Permissions: 0640, ModTime: modTime},
+			"current":        {Filename: "current", LinkTarget: "id_ed25519", Permissions: 0777},
+		},
+		Directories: map[string]os.FileMode{"config.d": 0750},
+	}
+}
+
+func TestWriteExtract_RoundTrip(t *testing.T) {
+	for _, format := range []Format{FormatTar, FormatTarGz, FormatZip} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, format, testBackup()); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if detected, err := Detect(buf.Bytes()); err != nil || detected != format {
+				t.Errorf("Detect() = %s, %v, want %s", detected, err, format)
+			}
+
+			dir := t.TempDir()
+			count, err := Extract(buf.Bytes(), dir)
+			if err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			if count != 4 {
+				t.Errorf("Extract() = %d files, want 4", count)
+			}
+
+			for name, mode := range map[string]os.FileMode{"id_ed25519": 0600, "id_ed25519.pub": 0644, "config.d/work": 0640, "config.d": 0750} {
+				info, err := os.Stat(filepath.Join(dir, name))
+				if err != nil {
+					t.Errorf("%s missing: %v", name, err)
+					continue
+				}
+				if info.Mode().Perm() != mode {
+					t.Errorf("%s mode = %04o, want %04o", name, info.Mode().Perm(), mode)
+				}
+			}
+			if content, _ := os.ReadFile(filepath.Join(dir, "config.d", "work")); string(content) != "Host work\n" {
+				t.Errorf("config.d/work content = %q", content)
+			}
+			if info, _ := os.Stat(filepath.Join(dir, "id_ed25519")); !info.ModTime().Equal(testBackup().Timestamp) {
+				t.Errorf("id_ed25519 mtime = %v, want %v", info.ModTime(), testBackup().Timestamp)
+			}
+			if target, err := os.Readlink(filepath.Join(dir, "current")); err != nil || target != "id_ed25519" {
+				t.Errorf("current = %q, %v, want a symlink to id_ed25519", target, err)
+			}
+		})
+	}
+}
+
+func TestExtract_RefusesEscapingPaths(t *testing.T) {
+	tests := map[string][]*tar.Header{
+		"parent directory": {{Name: "../evil", Typeflag: tar.TypeReg, Mode: 0600}},
+		"absolute path":    {{Name: "/tmp/evil", Typeflag: tar.TypeReg, Mode: 0600}},
+		"through symlink": {
+			{Name: "escape", Typeflag: tar.TypeSymlink, Linkname: os.TempDir()},
+			{Name: "escape/evil", Typeflag: tar.TypeReg, Mode: 0600},
+		},
+	}
+
+	for name, headers := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			for _, header := range headers {
+				if err := tw.WriteHeader(header); err != nil {
+					t.Fatal(err)
+				}
+			}
+			tw.Close()
+
+			if _, err := Extract(buf.Bytes(), t.TempDir()); err == nil {
+				t.Error("Extract() should refuse the archive")
+			}
+		})
+	}
+}
+
+func TestDetect_Unknown(t *testing.T) {
+	if _, err := Detect([]byte("Host *\n")); err == nil {
+		t.Error("Detect() should reject plain text")
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for value, want := range map[string]Format{"tar": FormatTar, "TGZ": FormatTarGz, "tar.gz": FormatTarGz, "zip": FormatZip} {
+		if got, err := ParseFormat(value); err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %s, %v, want %s", value, got, err, want)
+		}
+	}
+	if _, err := ParseFormat("rar"); err == nil {
+		t.Error("ParseFormat(rar) should fail")
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatTarGz, testBackup()); err != nil {
+		t.Fatal(err)
+	}
+	if IsEncrypted(buf.Bytes()) {
+		t.Error("IsEncrypted() = true for a plain archive")
+	}
+
+	encrypted, err := Encrypt(buf.Bytes(), "correct horse")
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if !IsEncrypted(encrypted) || bytes.Contains(encrypted, []byte("private")) {
+		t.Error("encrypted archive not recognised or leaks content")
+	}
+
+	if _, err := Decrypt(encrypted, "wrong horse"); err == nil || !strings.Contains(err.Error(), "passphrase") {
+		t.Errorf("Decrypt() with a wrong passphrase error = %v", err)
+	}
+	decrypted, err := Decrypt(encrypted, "correct horse")
+	if err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	if !bytes.Equal(decrypted, buf.Bytes()) {
+		t.Error("Decrypt() did not return the original archive")
+	}
+}
@@ -0,0 +1,55 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/rzago/ssh-secret-keeper/internal/crypto"
+)
+
+// encryptedMagic identifies an archive encrypted with a passphrase
+const encryptedMagic = "sshsk-encrypted-archive"
+
+// envelope wraps an encrypted archive; the archive format is recognised again
+// after decryption
+type envelope struct {
+	Format    string                `json:"format"`
+	Encrypted *crypto.EncryptedData `json:"encrypted"`
+}
+
+// Encrypt encrypts an archive with a passphrase using AES-256-GCM. Only sshsk
+// import can read the result.
+func Encrypt(data []byte, passphrase string) ([]byte, error) {
+	encrypted, err := crypto.NewService().Encrypt(data, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	out, err := json.Marshal(envelope{Format: encryptedMagic, Encrypted: encrypted})
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode encrypted archive: %w", err)
+	}
+	return append(out, '\n'), nil
+}
+
+// IsEncrypted reports whether data is an archive written by Encrypt
+func IsEncrypted(data []byte) bool {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return false
+	}
+	var e envelope
+	return json.Unmarshal(data, &e) == nil && e.Format == encryptedMagic && e.Encrypted != nil
+}
+
+// Decrypt returns the archive inside data written by Encrypt
+func Decrypt(data []byte, passphrase string) ([]byte, error) {
+	var e envelope
+	if err := json.Unmarshal(data, &e); err != nil || e.Format != encryptedMagic || e.Encrypted == nil {
+		return nil, fmt.Errorf("not an encrypted sshsk archive")
+	}
+	archive, err := crypto.NewService().Decrypt(e.Encrypted, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt archive (wrong passphrase?): %w", err)
+	}
+	return archive, nil
+}
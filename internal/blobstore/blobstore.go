@@ -0,0 +1,450 @@
+package blobstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/rzago/ssh-secret-keeper/internal/interfaces"
+)
+
+// ManifestFormat marks backups whose file contents live in the blob store
+// instead of being embedded in the backup itself
+const ManifestFormat = "sha256-blobs-v1"
+
+// Stats reports how much content a backup uploaded versus reused
+type Stats struct {
+	NewBlobs    int
+	ReusedBlobs int
+	NewBytes    int64
+	ReusedBytes int64
+}
+
+// DefaultGracePeriod is how long an unreferenced blob is kept after it was
+// stored, so a backup still being written on another machine can reference it
+const DefaultGracePeriod = 24 * time.Hour
+
+// GCOptions controls garbage collection of unreferenced blobs
+type GCOptions struct {
+	DryRun bool
+	Ignore []string // Backups to treat as already deleted
+	// MinAge keeps unreferenced blobs stored less than this long ago. Blobs
+	// without a recorded creation time are considered old enough.
+	MinAge time.Duration
+}
+
+// GCResult reports the outcome of garbage collection
+type GCResult struct {
+	TotalBlobs   int
+	Unreferenced []string
+	Recent       []string // Unreferenced but younger than MinAge, kept for now
+	Deleted      []string
+}
+
+// Store is a content-addressed blob store on top of a StorageProvider.
+// File contents are stored once under their SHA-256 hash and backups become
+// manifests that reference them.
+type Store struct {
+	provider interfaces.StorageProvider
+	known    map[string]bool
+	reused   map[string][]byte // Content of existing blobs Put referenced again
+}
+
+// New creates a blob store backed by provider
+func New(provider interfaces.StorageProvider) *Store {
+	return &Store{provider: provider}
+}
+
+// Hash returns the hex-encoded SHA-256 of content
+func Hash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Put stores content unless a blob with the same hash already exists.
+// It returns the hash and whether anything was uploaded. Reused blobs are
+// remembered so EnsureReused can check they survived garbage collection.
+func (s *Store) Put(ctx context.Context, content []byte) (string, bool, error) {
+	if err := s.loadKnown(ctx); err != nil {
+		return "", false, err
+	}
+
+	hash := Hash(content)
+	if s.known[hash] {
+		if s.reused == nil {
+			s.reused = make(map[string][]byte)
+		}
+		s.reused[hash] = content
+		return hash, false, nil
+	}
+
+	if err := s.store(ctx, hash, content); err != nil {
+		return "", false, err
+	}
+
+	s.known[hash] = true
+	return hash, true, nil
+}
+
+// EnsureReused stores again the reused blobs that no longer exist. A blob
+// that was unreferenced when Put reused it may be removed by garbage
+// collection on another machine before the backup referencing it is stored,
+// so call this once the backup is stored. It returns the number of blobs
+// stored again.
+func (s *Store) EnsureReused(ctx context.Context) (int, error) {
+	blobs, err := s.provider.ListBlobs(ctx)
+	if err != nil {
+		return 0, err
+	}
+	exists := make(map[string]bool, len(blobs))
+	for _, hash := range blobs {
+		exists[hash] = true
+	}
+
+	restored := 0
+	for _, hash := range sortedBlobHashes(s.reused) {
+		if exists[hash] {
+			continue
+		}
+		log.Warn().Str("blob", hash).Msg("Reused blob was garbage-collected, storing it again")
+		if err := s.store(ctx, hash, s.reused[hash]); err != nil {
+			return restored, err
+		}
+		restored++
+	}
+	return restored, nil
+}
+
+// sortedBlobHashes returns the hashes of blobs in order
+func sortedBlobHashes(blobs map[string][]byte) []string {
+	hashes := make([]string, 0, len(blobs))
+	for hash := range blobs {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	return hashes
+}
+
+// store uploads content as the blob hash
+func (s *Store) store(ctx context.Context, hash string, content []byte) error {
+	data := map[string]interface{}{
+		"content":    base64.StdEncoding.EncodeToString(content),
+		"encoding":   "base64",
+		"size":       len(content),
+		"created_at": time.Now().Format(time.RFC3339),
+	}
+	return s.provider.StoreBlob(ctx, hash, data)
+}
+
+// Get retrieves a blob and verifies that its content matches its hash
+func (s *Store) Get(ctx context.Context, hash string) ([]byte, error) {
+	data, err := s.provider.GetBlob(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, ok := data["content"].(string)
+	if !ok {
+		return nil, fmt.Errorf("blob %s has no content", hash)
+	}
+
+	content, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode blob %s: %w", hash, err)
+	}
+
+	if actual := Hash(content); actual != hash {
+		return nil, fmt.Errorf("blob %s is corrupt: content hashes to %s", hash, actual)
+	}
+
+	return content, nil
+}
+
+// Deduplicate moves the file contents of backup data (as produced for
+// StoreBackup) into the blob store and replaces them with blob references
+func (s *Store) Deduplicate(ctx context.Context, data map[string]interface{}) (Stats, error) {
+	var stats Stats
+
+	for _, table := range fileTables(data) {
+		for _, name := range sortedKeys(table.files) {
+			fileEntry, ok := table.files[name].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			content, ok := fileEntry["content"].(string)
+			if !ok {
+				continue
+			}
+
+			hash, stored, err := s.Put(ctx, []byte(content))
+			if err != nil {
+				return stats, fmt.Errorf("failed to store content of %s%s: %w", table.prefix, name, err)
+			}
+
+			if stored {
+				stats.NewBlobs++
+				stats.NewBytes += int64(len(content))
+			} else {
+				stats.ReusedBlobs++
+				stats.ReusedBytes += int64(len(content))
+			}
+
+			delete(fileEntry, "content")
+			fileEntry["blob"] = hash
+		}
+	}
+
+	data["storage_format"] = ManifestFormat
+
+	log.Info().
+		Int("new_blobs", stats.NewBlobs).
+		Int("reused_blobs", stats.ReusedBlobs).
+		Int64("new_bytes", stats.NewBytes).
+		Int64("reused_bytes", stats.ReusedBytes).
+		Msg("Backup content deduplicated")
+
+	return stats, nil
+}
+
+// IsManifest reports whether backup data references blobs instead of embedding content
+func IsManifest(data map[string]interface{}) bool {
+	format, _ := data["storage_format"].(string)
+	return format == ManifestFormat
+}
+
+// Resolve replaces the blob references of a manifest with file contents so it
+// can be parsed like a backup that embeds its content. Other data is left untouched.
+func (s *Store) Resolve(ctx context.Context, data map[string]interface{}) error {
+	if !IsManifest(data) {
+		return nil
+	}
+
+	for _, table := range fileTables(data) {
+		for name, entry := range table.files {
+			fileEntry, ok := entry.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			hash, ok := fileEntry["blob"].(string)
+			if !ok {
+				continue
+			}
+
+			content, err := s.Get(ctx, hash)
+			if err != nil {
+				return fmt.Errorf("failed to load content of %s%s: %w", table.prefix, name, err)
+			}
+			fileEntry["content"] = string(content)
+		}
+	}
+
+	return nil
+}
+
+// References returns the blob hashes referenced by backup data, one per file
+// of the SSH directory and its sources
+func References(data map[string]interface{}) []string {
+	var refs []string
+
+	for _, table := range fileTables(data) {
+		for _, name := range sortedKeys(table.files) {
+			if fileEntry, ok := table.files[name].(map[string]interface{}); ok {
+				if hash, ok := fileEntry["blob"].(string); ok && hash != "" {
+					refs = append(refs, hash)
+				}
+			}
+		}
+	}
+
+	return refs
+}
+
+// RefCounts counts how many files of the stored backups reference each stored
+// blob. Backups in ignore are treated as deleted, and so are listed names
+// without data, such as KV v2 backups whose latest version was soft-deleted.
+// Any other backup that cannot be read is an error, because its blobs would
+// otherwise look unreferenced.
+func (s *Store) RefCounts(ctx context.Context, ignore []string) (map[string]int, error) {
+	blobs, err := s.provider.ListBlobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int, len(blobs))
+	for _, hash := range blobs {
+		counts[hash] = 0
+	}
+	if len(counts) == 0 {
+		return counts, nil
+	}
+
+	ignored := make(map[string]bool, len(ignore))
+	for _, name := range ignore {
+		ignored[name] = true
+	}
+
+	backups, err := s.provider.ListBackups(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range backups {
+		if ignored[name] || strings.HasSuffix(name, "/") {
+			continue
+		}
+
+		data, err := s.provider.GetBackup(ctx, name)
+		if errors.Is(err, interfaces.ErrBackupNotFound) {
+			log.Debug().Str("backup", name).Msg("Listed backup has no data, treating it as deleted")
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot count blob references of backup %s: %w", name, err)
+		}
+
+		for _, hash := range References(data) {
+			if _, exists := counts[hash]; exists {
+				counts[hash]++
+			}
+		}
+	}
+
+	return counts, nil
+}
+
+// CollectGarbage deletes blobs that no backup references any more and that
+// were stored at least opts.MinAge ago. There is no lock against backups: the
+// grace period protects blobs a backup on another machine has just uploaded
+// but not yet referenced, references are counted again before deleting, and
+// backups store again reused blobs that were deleted anyway (EnsureReused).
+func (s *Store) CollectGarbage(ctx context.Context, opts GCOptions) (*GCResult, error) {
+	counts, err := s.RefCounts(ctx, opts.Ignore)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &GCResult{TotalBlobs: len(counts)}
+	for hash, count := range counts {
+		if count == 0 {
+			result.Unreferenced = append(result.Unreferenced, hash)
+		}
+	}
+	sort.Strings(result.Unreferenced)
+
+	var expired []string
+	for _, hash := range result.Unreferenced {
+		if opts.MinAge > 0 && s.storedWithin(ctx, hash, opts.MinAge) {
+			result.Recent = append(result.Recent, hash)
+			continue
+		}
+		expired = append(expired, hash)
+	}
+
+	if opts.DryRun {
+		return result, nil
+	}
+
+	// A backup stored while references were counted may reuse expired blobs
+	if len(expired) > 0 {
+		recounted, err := s.RefCounts(ctx, opts.Ignore)
+		if err != nil {
+			return result, err
+		}
+		stillUnreferenced := expired[:0]
+		for _, hash := range expired {
+			if recounted[hash] == 0 {
+				stillUnreferenced = append(stillUnreferenced, hash)
+			}
+		}
+		expired = stillUnreferenced
+	}
+
+	for _, hash := range expired {
+		if err := s.provider.DeleteBlob(ctx, hash); err != nil {
+			return result, err
+		}
+		delete(s.known, hash)
+		result.Deleted = append(result.Deleted, hash)
+	}
+
+	log.Info().
+		Int("total_blobs", result.TotalBlobs).
+		Int("deleted_blobs", len(result.Deleted)).
+		Msg("Blob garbage collection completed")
+
+	return result, nil
+}
+
+// storedWithin reports whether a blob records being stored less than age ago.
+// Blobs that cannot be read or lack a valid created_at do not.
+func (s *Store) storedWithin(ctx context.Context, hash string, age time.Duration) bool {
+	data, err := s.provider.GetBlob(ctx, hash)
+	if err != nil {
+		return false
+	}
+	value, _ := data["created_at"].(string)
+	created, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return false
+	}
+	return time.Since(created) < age
+}
+
+// loadKnown lists the stored blobs once so Put can skip existing content
+func (s *Store) loadKnown(ctx context.Context) error {
+	if s.known != nil {
+		return nil
+	}
+
+	blobs, err := s.provider.ListBlobs(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.known = make(map[string]bool, len(blobs))
+	for _, hash := range blobs {
+		s.known[hash] = true
+	}
+	return nil
+}
+
+// fileTable is a set of file entries in backup data: the SSH directory's, or
+// those of an additional source
+type fileTable struct {
+	prefix string // Prepended to file names in messages, e.g. "kube:"
+	files  map[string]interface{}
+}
+
+// fileTables returns the file entries of the SSH directory and of every source
+func fileTables(data map[string]interface{}) []fileTable {
+	files, _ := data["files"].(map[string]interface{})
+	tables := []fileTable{{files: files}}
+
+	sources, _ := data["sources"].(map[string]interface{})
+	for _, name := range sortedKeys(sources) {
+		source, ok := sources[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if files, ok := source["files"].(map[string]interface{}); ok {
+			tables = append(tables, fileTable{prefix: name + ":", files: files})
+		}
+	}
+	return tables
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
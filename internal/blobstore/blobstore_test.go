@@ -0,0 +1,349 @@
+package blobstore
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// fakeProvider is a map-backed StorageProvider for blob store tests
+type fakeProvider struct {
+	backups map[string]map[string]interface{}
+	blobs   map[string]map[string]interface{}
+	writes  int
+	onList  func(calls int) // Called after every ListBackups, e.g. to store a backup concurrently
+	lists   int
+}
+
+func newFakeProvider() *fakeProvider {
+	return &fakeProvider{
+		backups: make(map[string]map[string]interface{}),
+		blobs:   make(map[string]map[string]interface{}),
+	}
+}
+
+func (f *fakeProvider) TestConnection(ctx context.Context) error { return nil }
+func (f *fakeProvider) Close() error                             { return nil }
+
+func (f *fakeProvider) StoreBackup(ctx context.Context, name string, data map[string]interface{}) error {
+	f.backups[name] = data
+	return nil
+}
+
+func (f *fakeProvider) GetBackup(ctx context.Context, name string) (map[string]interface{}, error) {
+	data, ok := f.backups[name]
+	if !ok {
+		return nil, fmt.Errorf("backup %s not found", name)
+	}
+	return data, nil
+}
+
+func (f *fakeProvider) ListBackups(ctx context.Context) ([]string, error) {
+	var names []string
+	for name := range f.backups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	f.lists++
+	if f.onList != nil {
+		f.onList(f.lists)
+	}
+	return names, nil
+}
+
+func (f *fakeProvider) DeleteBackup(ctx context.Context, name string) error {
+	delete(f.backups, name)
+	return nil
+}
+
+func (f *fakeProvider) StoreBlob(ctx context.Context, hash string, data map[string]interface{}) error {
+	f.writes++
+	f.blobs[hash] = data
+	return nil
+}
+
+func (f *fakeProvider) GetBlob(ctx context.Context, hash string) (map[string]interface{}, error) {
+	data, ok := f.blobs[hash]
+	if !ok {
+		return nil, fmt.Errorf("blob %s not found", hash)
+	}
+	return data, nil
+}
+
+func (f *fakeProvider) ListBlobs(ctx context.Context) ([]string, error) {
+	var hashes []string
+	for hash := range f.blobs {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	return hashes, nil
+}
+
+func (f *fakeProvider) DeleteBlob(ctx context.Context, hash string) error {
+	delete(f.blobs, hash)
+	return nil
+}
+
+func (f *fakeProvider) StoreMetadata(ctx context.Context, metadata map[string]interface{}) error {
+	return nil
+}
+
+func (f *fakeProvider) GetMetadata(ctx context.Context) (map[string]interface{}, error) {
+	return map[string]interface{}{}, nil
+}
+
+func (f *fakeProvider) UpdateMetadata(ctx context.Context, update func(metadata map[string]interface{}) error) error {
+	return update(map[string]interface{}{})
+}
+
+func (f *fakeProvider) GetProviderType() string { return "fake" }
+func (f *fakeProvider) GetBasePath() string     { return "test" }
+
+func backupData(files map[string]string) map[string]interface{} {
+	entries := make(map[string]interface{})
+	for name, content := range files {
+		entries[name] = map[string]interface{}{"filename": name, "content": content}
+	}
+	return map[string]interface{}{"files": entries}
+}
+
+func TestStore_DeduplicateAndResolve(t *testing.T) {
+	ctx := context.Background()
+	provider := newFakeProvider()
+
+	first := backupData(map[string]string{"id_ed25519": "private", "config": "Host *\n"})
+	stats, err := New(provider).Deduplicate(ctx, first)
+	if err != nil {
+		t.Fatalf("Deduplicate() error = %v", err)
+	}
+	if stats.NewBlobs != 2 || stats.ReusedBlobs != 0 || stats.NewBytes != int64(len("private")+len("Host *\n")) {
+		t.Errorf("first stats = %+v", stats)
+	}
+	if !IsManifest(first) {
+		t.Error("deduplicated data should be a manifest")
+	}
+
+	// Only the changed file should be uploaded by the second backup
+	second := backupData(map[string]string{"id_ed25519": "private", "config": "Host github.com\n"})
+	stats, err = New(provider).Deduplicate(ctx, second)
+	if err != nil {
+		t.Fatalf("Deduplicate() error = %v", err)
+	}
+	if stats.NewBlobs != 1 || stats.ReusedBlobs != 1 || stats.ReusedBytes != int64(len("private")) {
+		t.Errorf("second stats = %+v", stats)
+	}
+	if provider.writes != 3 {
+		t.Errorf("blob writes = %d, want 3", provider.writes)
+	}
+
+	entry := second["files"].(map[string]interface{})["config"].(map[string]interface{})
+	if _, hasContent := entry["content"]; hasContent || entry["blob"] != Hash([]byte("Host github.com\n")) {
+		t.Errorf("manifest entry = %+v", entry)
+	}
+
+	if err := New(provider).Resolve(ctx, second); err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if entry["content"] != "Host github.com\n" {
+		t.Errorf("resolved content = %v", entry["content"])
+	}
+}
+
+func TestStore_DeduplicateSources(t *testing.T) {
+	ctx := context.Background()
+	provider := newFakeProvider()
+
+	data := backupData(map[string]string{"config": "Host *\n"})
+	data["sources"] = map[string]interface{}{
+		"kube": backupData(map[string]string{"config": "apiVersion: v1\n"}),
+	}
+
+	stats, err := New(provider).Deduplicate(ctx, data)
+	if err != nil {
+		t.Fatalf("Deduplicate() error = %v", err)
+	}
+	if stats.NewBlobs != 2 {
+		t.Errorf("stats = %+v, want 2 new blobs", stats)
+	}
+	if refs := References(data); len(refs) != 2 {
+		t.Errorf("References() = %v, want the SSH directory's and the source's blob", refs)
+	}
+
+	source := data["sources"].(map[string]interface{})["kube"].(map[string]interface{})
+	entry := source["files"].(map[string]interface{})["config"].(map[string]interface{})
+	if _, hasContent := entry["content"]; hasContent {
+		t.Errorf("source entry still embeds content: %+v", entry)
+	}
+
+	if err := New(provider).Resolve(ctx, data); err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if entry["content"] != "apiVersion: v1\n" {
+		t.Errorf("resolved source content = %v", entry["content"])
+	}
+}
+
+func TestStore_GetDetectsCorruption(t *testing.T) {
+	ctx := context.Background()
+	provider := newFakeProvider()
+	store := New(provider)
+
+	hash, _, err := store.Put(ctx, []byte("content"))
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	provider.blobs[hash]["content"] = "dGFtcGVyZWQ=" // "tampered"
+
+	if _, err := store.Get(ctx, hash); err == nil {
+		t.Error("Get() should fail for corrupt blob")
+	}
+}
+
+func TestStore_CollectGarbage(t *testing.T) {
+	ctx := context.Background()
+	provider := newFakeProvider()
+	store := New(provider)
+
+	for name, files := range map[string]map[string]string{
+		"old": {"a": "shared", "b": "only-old"},
+		"new": {"a": "shared", "c": "only-new"},
+	} {
+		data := backupData(files)
+		if _, err := store.Deduplicate(ctx, data); err != nil {
+			t.Fatalf("Deduplicate() error = %v", err)
+		}
+		provider.StoreBackup(ctx, name, data)
+	}
+
+	counts, err := store.RefCounts(ctx, nil)
+	if err != nil {
+		t.Fatalf("RefCounts() error = %v", err)
+	}
+	if counts[Hash([]byte("shared"))] != 2 || counts[Hash([]byte("only-old"))] != 1 {
+		t.Errorf("counts = %v", counts)
+	}
+
+	// Dry run treating "old" as deleted reports its exclusive blob only
+	result, err := store.CollectGarbage(ctx, GCOptions{DryRun: true, Ignore: []string{"old"}})
+	if err != nil {
+		t.Fatalf("CollectGarbage() error = %v", err)
+	}
+	if want := []string{Hash([]byte("only-old"))}; !reflect.DeepEqual(result.Unreferenced, want) {
+		t.Errorf("unreferenced = %v, want %v", result.Unreferenced, want)
+	}
+	if len(provider.blobs) != 3 {
+		t.Errorf("dry run deleted blobs, %d left", len(provider.blobs))
+	}
+
+	provider.DeleteBackup(ctx, "old")
+	result, err = store.CollectGarbage(ctx, GCOptions{})
+	if err != nil {
+		t.Fatalf("CollectGarbage() error = %v", err)
+	}
+	if len(result.Deleted) != 1 || len(provider.blobs) != 2 {
+		t.Errorf("deleted = %v, blobs left = %d", result.Deleted, len(provider.blobs))
+	}
+	if _, err := store.Get(ctx, Hash([]byte("shared"))); err != nil {
+		t.Errorf("shared blob should survive: %v", err)
+	}
+}
+
+func TestStore_CollectGarbage_MinAge(t *testing.T) {
+	ctx := context.Background()
+	provider := newFakeProvider()
+	store := New(provider)
+
+	for name, content := range map[string]string{"old": "stale", "new": "fresh"} {
+		data := backupData(map[string]string{"a": content})
+		if _, err := store.Deduplicate(ctx, data); err != nil {
+			t.Fatalf("Deduplicate() error = %v", err)
+		}
+		provider.StoreBackup(ctx, name, data)
+	}
+	stale, fresh := Hash([]byte("stale")), Hash([]byte("fresh"))
+	provider.blobs[stale]["created_at"] = time.Now().Add(-48 * time.Hour).Format(time.RFC3339)
+	provider.DeleteBackup(ctx, "old")
+	provider.DeleteBackup(ctx, "new")
+
+	result, err := store.CollectGarbage(ctx, GCOptions{MinAge: DefaultGracePeriod})
+	if err != nil {
+		t.Fatalf("CollectGarbage() error = %v", err)
+	}
+	if want := []string{fresh}; !reflect.DeepEqual(result.Recent, want) {
+		t.Errorf("recent = %v, want %v", result.Recent, want)
+	}
+	if want := []string{stale}; !reflect.DeepEqual(result.Deleted, want) {
+		t.Errorf("deleted = %v, want %v", result.Deleted, want)
+	}
+	if _, ok := provider.blobs[fresh]; !ok {
+		t.Error("blob stored within the grace period should be kept")
+	}
+}
+
+func TestStore_EnsureReused(t *testing.T) {
+	ctx := context.Background()
+	provider := newFakeProvider()
+
+	first := backupData(map[string]string{"id_ed25519": "key"})
+	if _, err := New(provider).Deduplicate(ctx, first); err != nil {
+		t.Fatalf("Deduplicate() error = %v", err)
+	}
+
+	// A second backup reuses the blob, which garbage collection on another
+	// machine removes before the backup is stored
+	store := New(provider)
+	second := backupData(map[string]string{"id_ed25519": "key"})
+	if _, err := store.Deduplicate(ctx, second); err != nil {
+		t.Fatalf("Deduplicate() error = %v", err)
+	}
+	hash := Hash([]byte("key"))
+	delete(provider.blobs, hash)
+	provider.StoreBackup(ctx, "second", second)
+
+	restored, err := store.EnsureReused(ctx)
+	if err != nil {
+		t.Fatalf("EnsureReused() error = %v", err)
+	}
+	if restored != 1 {
+		t.Errorf("EnsureReused() = %d, want 1", restored)
+	}
+	if content, err := store.Get(ctx, hash); err != nil || string(content) != "key" {
+		t.Errorf("Get() = %q, %v after EnsureReused()", content, err)
+	}
+
+	if restored, err := store.EnsureReused(ctx); err != nil || restored != 0 {
+		t.Errorf("EnsureReused() with every blob present = %d, %v", restored, err)
+	}
+}
+
+func TestStore_CollectGarbage_RecountsBeforeDeleting(t *testing.T) {
+	ctx := context.Background()
+	provider := newFakeProvider()
+	store := New(provider)
+
+	old := backupData(map[string]string{"a": "shared"})
+	if _, err := store.Deduplicate(ctx, old); err != nil {
+		t.Fatalf("Deduplicate() error = %v", err)
+	}
+
+	// A backup reusing the unreferenced blob is stored while references are counted
+	provider.onList = func(calls int) {
+		if calls == 1 {
+			provider.StoreBackup(ctx, "new", old)
+		}
+	}
+
+	result, err := store.CollectGarbage(ctx, GCOptions{})
+	if err != nil {
+		t.Fatalf("CollectGarbage() error = %v", err)
+	}
+	if len(result.Deleted) != 0 {
+		t.Errorf("deleted = %v, want the blob the new backup references kept", result.Deleted)
+	}
+	if _, ok := provider.blobs[Hash([]byte("shared"))]; !ok {
+		t.Error("blob referenced by a backup stored during collection was deleted")
+	}
+}
@@ -0,0 +1,280 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/rzago/ssh-secret-keeper/internal/analyzer"
+	"github.com/rzago/ssh-secret-keeper/internal/config"
+	"github.com/rzago/ssh-secret-keeper/internal/ssh"
+	"github.com/rzago/ssh-secret-keeper/internal/storage"
+	"github.com/spf13/cobra"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/term"
+)
+
+// newAgentLoadCommand creates the agent-load command
+func newAgentLoadCommand(cfg *config.Config) *cobra.Command {
+	var (
+		keyPatterns []string
+		lifetime    time.Duration
+		confirm     bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "agent-load [backup-name]",
+		Short: "Load private keys from a backup into ssh-agent",
+		Long: `Load the private keys of a backup straight into the running ssh-agent
+(SSH_AUTH_SOCK) without writing anything to disk, for ephemeral machines and
+shared hosts. If no backup name is provided, the most recent backup is used.
+
+Select keys with --key glob patterns, matched against the file name and its
+path in the backup. Passphrase-protected keys are prompted for.
+
+  sshsk agent-load --key 'id_ed25519*' --lifetime 8h --confirm`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts := agentLoadOptions{
+				keyPatterns: keyPatterns,
+				lifetime:    lifetime,
+				confirm:     confirm,
+			}
+			if len(args) > 0 {
+				opts.backupName = args[0]
+			}
+			return runAgentLoad(cfg, opts)
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&keyPatterns, "key", []string{}, "Only load keys matching these glob patterns (default: all private keys)")
+	cmd.Flags().DurationVar(&lifetime, "lifetime", 0, "Remove the keys from the agent after this long, e.g. 8h (default: until the agent exits)")
+	cmd.Flags().BoolVar(&confirm, "confirm", false, "Ask the agent to confirm every use of the keys")
+
+	return cmd
+}
+
+type agentLoadOptions struct {
+	backupName  string
+	keyPatterns []string
+	lifetime    time.Duration
+	confirm     bool
+}
+
+// promptPassphrase reads a passphrase from the terminal without echoing it,
+// prompting on stderr so stdout can carry data; replaced in tests
+var promptPassphrase = func(prompt string) ([]byte, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, fmt.Errorf("stdin is not a terminal")
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return passphrase, err
+}
+
+// readPassphrase prompts for the passphrase of a key; replaced in tests
+var readPassphrase = func(filename string) ([]byte, error) {
+	passphrase, err := promptPassphrase(fmt.Sprintf("Enter passphrase for %s: ", filename))
+	if err != nil {
+		return nil, fmt.Errorf("%s is passphrase-protected: %w", filename, err)
+	}
+	return passphrase, nil
+}
+
+// errSkipKey marks keys left out because no passphrase was entered
+var errSkipKey = errors.New("no passphrase entered")
+
+func runAgentLoad(cfg *config.Config, opts agentLoadOptions) error {
+	log.Info().
+		Str("backup_name", opts.backupName).
+		Strs("keys", opts.keyPatterns).
+		Dur("lifetime", opts.lifetime).
+		Bool("confirm", opts.confirm).
+		Msg("Loading keys into ssh-agent")
+
+	if err := validateAgentLoadOptions(opts); err != nil {
+		return err
+	}
+
+	// Fail before touching storage when there is no agent to load into
+	keyring, conn, err := connectAgent()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Create storage provider via factory
+	factory := storage.NewFactory()
+	storageProvider, err := factory.CreateStorage(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create storage provider: %w", err)
+	}
+	defer storageProvider.Close()
+
+	ctx := context.Background()
+	if err := storageProvider.TestConnection(ctx); err != nil {
+		return fmt.Errorf("storage connection test failed: %w", err)
+	}
+
+	backupName := opts.backupName
+	if backupName == "" {
+		backupName, err = getLatestBackupName(ctx, storageProvider)
+		if err != nil {
+			return fmt.Errorf("failed to find latest backup: %w", err)
+		}
+		fmt.Printf("Using most recent backup: %s\n", backupName)
+	}
+
+	backupData, err := loadBackup(ctx, storageProvider, backupName)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("🔑 Loading keys from backup '%s' into ssh-agent\n", backupName)
+	return loadAgentKeys(keyring, backupData, opts)
+}
+
+// validateAgentLoadOptions checks patterns and the lifetime before connecting
+func validateAgentLoadOptions(opts agentLoadOptions) error {
+	for _, pattern := range opts.keyPatterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid --key pattern %q: %w", pattern, err)
+		}
+	}
+	if opts.lifetime < 0 {
+		return fmt.Errorf("--lifetime must not be negative")
+	}
+	if opts.lifetime > 0 && (opts.lifetime < time.Second || opts.lifetime.Seconds() > math.MaxUint32) {
+		return fmt.Errorf("--lifetime must be between 1s and %ds", uint32(math.MaxUint32))
+	}
+	return nil
+}
+
+// connectAgent connects to the agent listening on SSH_AUTH_SOCK
+func connectAgent() (agent.ExtendedAgent, net.Conn, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, nil, fmt.Errorf("SSH_AUTH_SOCK is not set: start an agent first, e.g. eval \"$(ssh-agent)\"")
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to ssh-agent at %s: %w", socket, err)
+	}
+	return agent.NewClient(conn), conn, nil
+}
+
+// agentKeyFiles returns the private keys of a backup selected by patterns, sorted by name
+func agentKeyFiles(backup *ssh.BackupData, patterns []string) []*ssh.FileData {
+	var keys []*ssh.FileData
+	for _, name := range backup.FileNames() {
+		fileData := backup.Files[name]
+		if fileData.IsSymlink() || fileData.KeyInfo == nil || fileData.KeyInfo.Type != analyzer.KeyTypePrivate {
+			continue
+		}
+		if len(patterns) > 0 && !matchesAny(name, patterns) {
+			continue
+		}
+		keys = append(keys, fileData)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Filename < keys[j].Filename })
+	return keys
+}
+
+// matchesAny matches a backup path, or its base name, against glob patterns
+func matchesAny(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+		if matched, _ := path.Match(pattern, path.Base(name)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// loadAgentKeys adds the selected private keys of a backup to the agent. A key
+// that cannot be loaded does not stop the others.
+func loadAgentKeys(keyring agent.Agent, backup *ssh.BackupData, opts agentLoadOptions) error {
+	keys := agentKeyFiles(backup, opts.keyPatterns)
+	if len(keys) == 0 {
+		if len(opts.keyPatterns) > 0 {
+			return fmt.Errorf("no private keys in the backup match %v", opts.keyPatterns)
+		}
+		return fmt.Errorf("the backup contains no private keys")
+	}
+
+	constraints := ""
+	if opts.lifetime > 0 {
+		constraints += fmt.Sprintf(", lifetime %s", opts.lifetime)
+	}
+	if opts.confirm {
+		constraints += ", confirm before use"
+	}
+
+	failed := 0
+	for _, fileData := range keys {
+		fingerprint, err := addAgentKey(keyring, fileData, opts)
+		switch {
+		case errors.Is(err, errSkipKey):
+			fmt.Printf("⏭️  %s skipped: %v\n", fileData.Filename, err)
+		case err != nil:
+			failed++
+			log.Error().Err(err).Str("file", fileData.Filename).Msg("Failed to load key into agent")
+			fmt.Printf("❌ %s: %v\n", fileData.Filename, err)
+		default:
+			fmt.Printf("✓ Added %s (%s%s)\n", fileData.Filename, fingerprint, constraints)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d keys could not be loaded", failed, len(keys))
+	}
+	return nil
+}
+
+// addAgentKey parses one private key, asking for its passphrase if needed, and
+// adds it to the agent. It returns the key's fingerprint.
+func addAgentKey(keyring agent.Agent, fileData *ssh.FileData, opts agentLoadOptions) (string, error) {
+	privateKey, err := gossh.ParseRawPrivateKey(fileData.Content)
+	var missing *gossh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		passphrase, promptErr := readPassphrase(fileData.Filename)
+		if promptErr != nil {
+			return "", promptErr
+		}
+		if len(passphrase) == 0 {
+			return "", errSkipKey
+		}
+		privateKey, err = gossh.ParseRawPrivateKeyWithPassphrase(fileData.Content, passphrase)
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot parse key: %w", err)
+	}
+
+	signer, err := gossh.NewSignerFromKey(privateKey)
+	if err != nil {
+		return "", fmt.Errorf("unsupported key: %w", err)
+	}
+
+	if err := keyring.Add(agent.AddedKey{
+		PrivateKey:       privateKey,
+		Comment:          fileData.Filename,
+		LifetimeSecs:     uint32(opts.lifetime / time.Second),
+		ConfirmBeforeUse: opts.confirm,
+	}); err != nil {
+		return "", fmt.Errorf("agent refused the key: %w", err)
+	}
+
+	return gossh.FingerprintSHA256(signer.PublicKey()), nil
+}
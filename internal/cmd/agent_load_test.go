@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"net"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rzago/ssh-secret-keeper/internal/analyzer"
+	"github.com/rzago/ssh-secret-keeper/internal/config"
+	"github.com/rzago/ssh-secret-keeper/internal/ssh"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// agentTestKey returns an OpenSSH private key, encrypted when passphrase is set
+func agentTestKey(t *testing.T, passphrase string) []byte {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var block *pem.Block
+	if passphrase == "" {
+		block, err = gossh.MarshalPrivateKey(key, "")
+	} else {
+		block, err = gossh.MarshalPrivateKeyWithPassphrase(key, "", []byte(passphrase))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(block)
+}
+
+func agentTestFile(name string, content []byte, keyType analyzer.KeyType) *ssh.FileData {
+	return &ssh.FileData{
+		Filename:    name,
+		Content:     content,
+		Permissions: 0600,
+		KeyInfo:     &analyzer.KeyInfo{Filename: name, Type: keyType},
+	}
+}
+
+// serveTestAgent serves an in-process keyring on a socket named by SSH_AUTH_SOCK
+func serveTestAgent(t *testing.T) agent.Agent {
+	t.Helper()
+	keyring := agent.NewKeyring()
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	t.Setenv("SSH_AUTH_SOCK", socket)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				agent.ServeAgent(keyring, conn)
+			}()
+		}
+	}()
+	return keyring
+}
+
+func TestNewAgentLoadCommand(t *testing.T) {
+	cmd := newAgentLoadCommand(config.Default())
+
+	if cmd.Use != "agent-load [backup-name]" {
+		t.Errorf("Use = %q", cmd.Use)
+	}
+	for _, flag := range []string{"key", "lifetime", "confirm"} {
+		if cmd.Flags().Lookup(flag) == nil {
+			t.Errorf("missing --%s flag", flag)
+		}
+	}
+}
+
+func TestLoadAgentKeys(t *testing.T) {
+	serverKeyring := serveTestAgent(t)
+	client, conn, err := connectAgent()
+	if err != nil {
+		t.Fatalf("connectAgent() error = %v", err)
+	}
+	defer conn.Close()
+
+	backup := &ssh.BackupData{
+		Files: map[string]*ssh.FileData{
+			"id_ed25519":     agentTestFile("id_ed25519", agentTestKey(t, ""), analyzer.KeyTypePrivate),
+			"work/id_work":   agentTestFile("work/id_work", agentTestKey(t, "s3cret"), analyzer.KeyTypePrivate),
+			"id_ed25519.pub": agentTestFile("id_ed25519.pub", []byte("ssh-ed25519 AAAA"), analyzer.KeyTypePublic),
+			"config":         agentTestFile("config", []byte("Host *\n"), analyzer.KeyTypeConfig),
+		},
+	}
+
+	var prompted []string
+	original := readPassphrase
+	readPassphrase = func(filename string) ([]byte, error) {
+		prompted = append(prompted, filename)
+		return []byte("s3cret"), nil
+	}
+	defer func() { readPassphrase = original }()
+
+	opts := agentLoadOptions{lifetime: 8 * time.Hour, confirm: true}
+	if err := loadAgentKeys(client, backup, opts); err != nil {
+		t.Fatalf("loadAgentKeys() error = %v", err)
+	}
+
+	if len(prompted) != 1 || prompted[0] != "work/id_work" {
+		t.Errorf("prompted for %v, want only the protected key", prompted)
+	}
+
+	keys, err := serverKeyring.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var comments []string
+	for _, key := range keys {
+		comments = append(comments, key.Comment)
+	}
+	sort.Strings(comments)
+	if strings.Join(comments, ",") != "id_ed25519,work/id_work" {
+		t.Errorf("agent holds %v, want both private keys", comments)
+	}
+}
+
+func TestLoadAgentKeys_Selection(t *testing.T) {
+	backup := &ssh.BackupData{
+		Files: map[string]*ssh.FileData{
+			"id_ed25519":   agentTestFile("id_ed25519", agentTestKey(t, ""), analyzer.KeyTypePrivate),
+			"work/id_work": agentTestFile("work/id_work", agentTestKey(t, ""), analyzer.KeyTypePrivate),
+		},
+	}
+
+	// Patterns match the base name as well as the path
+	keyring := agent.NewKeyring()
+	if err := loadAgentKeys(keyring, backup, agentLoadOptions{keyPatterns: []string{"id_work"}}); err != nil {
+		t.Fatalf("loadAgentKeys() error = %v", err)
+	}
+	if keys, _ := keyring.List(); len(keys) != 1 || keys[0].Comment != "work/id_work" {
+		t.Errorf("agent holds %v, want only work/id_work", keys)
+	}
+
+	if err := loadAgentKeys(agent.NewKeyring(), backup, agentLoadOptions{keyPatterns: []string{"github*"}}); err == nil {
+		t.Error("expected an error when no key matches")
+	}
+}
+
+func TestLoadAgentKeys_Passphrases(t *testing.T) {
+	backup := &ssh.BackupData{
+		Files: map[string]*ssh.FileData{
+			"id_a": agentTestFile("id_a", agentTestKey(t, "right"), analyzer.KeyTypePrivate),
+			"id_b": agentTestFile("id_b", agentTestKey(t, "right"), analyzer.KeyTypePrivate),
+		},
+	}
+
+	original := readPassphrase
+	defer func() { readPassphrase = original }()
+
+	// An empty passphrase skips the key without failing
+	readPassphrase = func(string) ([]byte, error) { return nil, nil }
+	keyring := agent.NewKeyring()
+	if err := loadAgentKeys(keyring, backup, agentLoadOptions{}); err != nil {
+		t.Errorf("skipping keys should not fail, got %v", err)
+	}
+	if keys, _ := keyring.List(); len(keys) != 0 {
+		t.Errorf("agent holds %d keys, want none", len(keys))
+	}
+
+	// A wrong passphrase fails that key
+	readPassphrase = func(string) ([]byte, error) { return []byte("wrong"), nil }
+	err := loadAgentKeys(agent.NewKeyring(), backup, agentLoadOptions{})
+	if err == nil || !strings.Contains(err.Error(), "2 of 2 keys") {
+		t.Errorf("expected both keys to fail, got %v", err)
+	}
+
+	// Without a terminal the prompt fails
+	readPassphrase = func(filename string) ([]byte, error) { return nil, errors.New("stdin is not a terminal") }
+	if err := loadAgentKeys(agent.NewKeyring(), backup, agentLoadOptions{}); err == nil {
+		t.Error("expected an error without a terminal")
+	}
+}
+
+func TestValidateAgentLoadOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    agentLoadOptions
+		wantErr bool
+	}{
+		{"defaults", agentLoadOptions{}, false},
+		{"lifetime", agentLoadOptions{lifetime: 8 * time.Hour}, false},
+		{"negative lifetime", agentLoadOptions{lifetime: -time.Second}, true},
+		{"sub-second lifetime", agentLoadOptions{lifetime: time.Millisecond}, true},
+		{"invalid pattern", agentLoadOptions{keyPatterns: []string{"["}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateAgentLoadOptions(tt.opts); (err != nil) != tt.wantErr {
+				t.Errorf("validateAgentLoadOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConnectAgent_NoSocket(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	if _, _, err := connectAgent(); err == nil || !strings.Contains(err.Error(), "SSH_AUTH_SOCK") {
+		t.Errorf("connectAgent() error = %v, want a hint about SSH_AUTH_SOCK", err)
+	}
+}
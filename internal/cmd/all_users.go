@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+	"github.com/rzago/ssh-secret-keeper/internal/accounts"
+	"github.com/rzago/ssh-secret-keeper/internal/config"
+	"github.com/rzago/ssh-secret-keeper/internal/naming"
+	"github.com/rzago/ssh-secret-keeper/internal/ssh"
+)
+
+// passwdFile is replaced in tests
+var passwdFile = accounts.PasswdFile
+
+// allUsersOptions selects the accounts backed up by backup --all-users
+type allUsersOptions struct {
+	minUID   int
+	excludes []string
+}
+
+// Outcomes of one account's backup in an --all-users sweep
+const (
+	userBackupStored    = "stored"
+	userBackupPlanned   = "planned" // --dry-run
+	userBackupUnchanged = "unchanged"
+	userBackupSkipped   = "skipped"
+	userBackupFailed    = "failed"
+)
+
+// userBackupResult is the outcome of backing up one account
+type userBackupResult struct {
+	account accounts.Account
+	status  string
+	backup  string
+	files   int
+	err     error
+}
+
+// errNoSSHDir marks accounts without an SSH directory, which are skipped rather than failed
+var errNoSSHDir = errors.New("no SSH directory")
+
+// runBackupAllUsers backs up the SSH directory of every account in the passwd
+// file selected by sweep, each under its own user name, and reports the outcome
+// per user. A failure for one user does not stop the others.
+func runBackupAllUsers(cfg *config.Config, opts backupOptions, sweep allUsersOptions) error {
+	switch {
+	case opts.name != "":
+		return fmt.Errorf("--all-users cannot be combined with an explicit backup name; use --name-template")
+	case opts.system:
+		return fmt.Errorf("--all-users cannot be combined with --system")
+	case opts.interactive:
+		return fmt.Errorf("--all-users cannot be combined with --interactive")
+	}
+	if err := requireRoot("--all-users", "read other users' SSH directories"); err != nil {
+		return err
+	}
+
+	// Every user's backups need distinct names
+	tmpl, err := naming.Parse(opts.nameTemplate)
+	if err != nil {
+		return err
+	}
+	if !tmpl.UsesUsername() {
+		opts.nameTemplate = "{{.Username}}-" + opts.nameTemplate
+		fmt.Printf("ℹ️  Prefixing backup names with the user name: %s\n", opts.nameTemplate)
+	}
+
+	all, err := accounts.Load(passwdFile)
+	if err != nil {
+		return err
+	}
+	selected, err := accounts.Filter(all, sweep.minUID, sweep.excludes)
+	if err != nil {
+		return err
+	}
+	if len(selected) == 0 {
+		fmt.Printf("No accounts with UID >= %d found in %s\n", sweep.minUID, passwdFile)
+		return nil
+	}
+
+	log.Info().
+		Int("accounts", len(selected)).
+		Int("min_uid", sweep.minUID).
+		Strs("excludes", sweep.excludes).
+		Msg("Starting all-users backup")
+	fmt.Printf("👥 Backing up SSH directories of %d account(s)\n", len(selected))
+
+	results := make([]userBackupResult, 0, len(selected))
+	for _, account := range selected {
+		results = append(results, backupAccount(cfg, opts, account))
+	}
+
+	return reportUserBackups(results)
+}
+
+// backupAccount backs up one account's ~/.ssh under the account's name
+func backupAccount(cfg *config.Config, opts backupOptions, account accounts.Account) userBackupResult {
+	result := userBackupResult{account: account}
+	sshDir := filepath.Join(account.Home, ".ssh")
+
+	if err := checkAccountSSHDir(sshDir, account); err != nil {
+		result.err = err
+		result.status = userBackupFailed
+		if errors.Is(err, errNoSSHDir) {
+			result.status = userBackupSkipped
+		}
+		log.Info().Err(err).Str("user", account.Name).Msg("Not backing up account")
+		return result
+	}
+
+	fmt.Printf("\n━━━ %s (uid %d) ━━━\n", account.Name, account.UID)
+
+	// Store under the account's name in the configured storage strategy
+	userCfg := *cfg
+	userCfg.Vault.Username = account.Name
+
+	report := &backupReport{}
+	opts.sshDir = sshDir
+	opts.account = &account
+	opts.report = report
+
+	err := runBackup(&userCfg, opts)
+	switch {
+	case errors.Is(err, ErrNoChanges):
+		result.status = userBackupUnchanged
+	case err != nil:
+		result.status = userBackupFailed
+		result.err = err
+		log.Error().Err(err).Str("user", account.Name).Msg("Backup failed")
+		fmt.Printf("❌ Backup of %s failed: %v\n", account.Name, err)
+	default:
+		result.status = userBackupStored
+		if opts.dryRun {
+			result.status = userBackupPlanned
+		}
+		result.backup = report.name
+		result.files = report.files
+	}
+	return result
+}
+
+// checkAccountSSHDir refuses SSH directories root should not read on the
+// account's behalf: a symlink, or a directory owned by someone else, could make
+// another account's keys end up in this user's backup
+func checkAccountSSHDir(sshDir string, account accounts.Account) error {
+	info, err := os.Lstat(sshDir)
+	if os.IsNotExist(err) {
+		return errNoSSHDir
+	}
+	if err != nil {
+		return fmt.Errorf("cannot inspect %s: %w", sshDir, err)
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("%s is a symlink; refusing to follow it", sshDir)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", sshDir)
+	}
+	if owner := ssh.DirectoryOwner(info); owner != nil && owner.UID != account.UID {
+		return fmt.Errorf("%s is owned by uid %d, not %s (uid %d)", sshDir, owner.UID, account.Name, account.UID)
+	}
+	return nil
+}
+
+// reportUserBackups prints the outcome for every account and fails if any backup failed
+func reportUserBackups(results []userBackupResult) error {
+	counts := make(map[string]int)
+
+	fmt.Printf("\n📋 All-Users Backup Report\n")
+	fmt.Printf("═══════════════════════════\n")
+	for _, result := range results {
+		counts[result.status]++
+		name := result.account.Name
+		switch result.status {
+		case userBackupStored:
+			fmt.Printf("✅ %-16s backup '%s' (%d files)\n", name, result.backup, result.files)
+		case userBackupPlanned:
+			fmt.Printf("🔍 %-16s would store '%s' (%d files)\n", name, result.backup, result.files)
+		case userBackupUnchanged:
+			fmt.Printf("✅ %-16s unchanged since the latest backup\n", name)
+		case userBackupSkipped:
+			fmt.Printf("⏭️  %-16s skipped: %v\n", name, result.err)
+		default:
+			fmt.Printf("❌ %-16s failed: %v\n", name, result.err)
+		}
+	}
+	if planned := counts[userBackupPlanned]; planned > 0 {
+		fmt.Printf("\n[DRY RUN] %d backup(s) would be stored\n", planned)
+	}
+	fmt.Printf("\nStored: %d, unchanged: %d, skipped: %d, failed: %d\n",
+		counts[userBackupStored], counts[userBackupUnchanged], counts[userBackupSkipped], counts[userBackupFailed])
+
+	if failed := counts[userBackupFailed]; failed > 0 {
+		return fmt.Errorf("%d of %d user backups failed", failed, len(results))
+	}
+	return nil
+}
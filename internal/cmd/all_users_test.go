@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rzago/ssh-secret-keeper/internal/accounts"
+	"github.com/rzago/ssh-secret-keeper/internal/config"
+)
+
+// writePasswd creates a passwd file and points passwdFile at it for the test
+func writePasswd(t *testing.T, lines ...string) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "passwd")
+	if err := os.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	original := passwdFile
+	passwdFile = file
+	t.Cleanup(func() { passwdFile = original })
+}
+
+// makeHome creates a home directory, with an SSH directory holding a key if withSSH is set
+func makeHome(t *testing.T, root, name string, withSSH bool) string {
+	t.Helper()
+	home := filepath.Join(root, name)
+	if err := os.MkdirAll(home, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if withSSH {
+		sshDir := filepath.Join(home, ".ssh")
+		if err := os.Mkdir(sshDir, 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(sshDir, "id_ed25519.pub"), []byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5 "+name+"@host\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return home
+}
+
+func TestRunBackupAllUsers_Refusals(t *testing.T) {
+	defer func(original func() int) { geteuid = original }(geteuid)
+	geteuid = func() int { return 0 }
+
+	cfg := config.Default()
+	tests := []struct {
+		name string
+		opts backupOptions
+		want string
+	}{
+		{"explicit name", backupOptions{name: "mine"}, "explicit backup name"},
+		{"system", backupOptions{system: true}, "--system"},
+		{"interactive", backupOptions{interactive: true}, "--interactive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := runBackupAllUsers(cfg, tt.opts, allUsersOptions{})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("runBackupAllUsers() error = %v, want mention of %q", err, tt.want)
+			}
+		})
+	}
+
+	geteuid = func() int { return 1000 }
+	err := runBackupAllUsers(cfg, backupOptions{nameTemplate: cfg.Backup.NameTemplate}, allUsersOptions{})
+	if err == nil || !strings.Contains(err.Error(), "--all-users must run as root") {
+		t.Errorf("runBackupAllUsers() as non-root error = %v, want root requirement", err)
+	}
+}
+
+func TestRunBackupAllUsers_Report(t *testing.T) {
+	defer func(original func() int) { geteuid = original }(geteuid)
+	geteuid = func() int { return 0 }
+
+	uid := os.Getuid()
+	root := t.TempDir()
+	alice := makeHome(t, root, "alice", true)
+	bob := makeHome(t, root, "bob", false)
+	carol := makeHome(t, root, "carol", true)
+	dave := makeHome(t, root, "dave", false)
+	if err := os.Symlink(filepath.Join(alice, ".ssh"), filepath.Join(dave, ".ssh")); err != nil {
+		t.Fatal(err)
+	}
+
+	writePasswd(t,
+		"root:x:0:0:root:/root:/bin/bash",
+		fmt.Sprintf("alice:x:%d:%d::%s:/bin/bash", uid, uid, alice),
+		fmt.Sprintf("bob:x:%d:%d::%s:/bin/bash", uid, uid, bob),
+		fmt.Sprintf("carol:x:%d:%d::%s:/bin/bash", uid+4242, uid, carol),
+		fmt.Sprintf("dave:x:%d:%d::%s:/bin/bash", uid, uid, dave),
+		fmt.Sprintf("nobody:x:%d:%d::%s:/bin/false", uid, uid, alice),
+	)
+
+	cfg := config.Default()
+	opts := backupOptions{nameTemplate: "{{.Username}}-{{.Seq}}", dryRun: true}
+	sweep := allUsersOptions{minUID: 1, excludes: accounts.DefaultExcludes}
+	if uid == 0 {
+		sweep.minUID = 0
+		sweep.excludes = append(sweep.excludes, "root")
+	}
+
+	var results []userBackupResult
+	for _, account := range mustSelect(t, sweep) {
+		results = append(results, backupAccount(cfg, opts, account))
+	}
+
+	want := map[string]string{
+		"alice": userBackupPlanned,
+		"bob":   userBackupSkipped,
+		"carol": userBackupFailed,
+		"dave":  userBackupFailed,
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d: %+v", len(results), len(want), results)
+	}
+	for _, result := range results {
+		if result.status != want[result.account.Name] {
+			t.Errorf("%s: status = %s (%v), want %s", result.account.Name, result.status, result.err, want[result.account.Name])
+		}
+	}
+	if results[0].backup != "alice-1" || results[0].files != 1 {
+		t.Errorf("alice: backup = %q with %d files, want alice-1 with 1 file", results[0].backup, results[0].files)
+	}
+
+	// Templates without the user name are prefixed with it
+	opts.nameTemplate = cfg.Backup.NameTemplate
+	err := runBackupAllUsers(cfg, opts, sweep)
+	if err == nil || !strings.Contains(err.Error(), "2 of 4 user backups failed") {
+		t.Errorf("runBackupAllUsers() error = %v, want 2 of 4 failures", err)
+	}
+}
+
+func mustSelect(t *testing.T, sweep allUsersOptions) []accounts.Account {
+	t.Helper()
+	all, err := accounts.Load(passwdFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	selected, err := accounts.Filter(all, sweep.minUID, sweep.excludes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return selected
+}
@@ -17,6 +17,8 @@ func newAnalyzeCommand(cfg *config.Config) *cobra.Command {
 		sshDir     string
 		outputJSON bool
 		verbose    bool
+		includes   []string
+		excludes   []string
 	)
 
 	cmd := &cobra.Command{
@@ -29,6 +31,8 @@ This command helps you understand what will be backed up before running a backup
 				sshDir:     sshDir,
 				outputJSON: outputJSON,
 				verbose:    verbose,
+				includes:   includes,
+				excludes:   excludes,
 			})
 		},
 	}
@@ -37,6 +41,8 @@ This command helps you understand what will be backed up before running a backup
 	cmd.Flags().StringVar(&sshDir, "ssh-dir", cfg.Backup.SSHDir, "SSH directory to analyze")
 	cmd.Flags().BoolVar(&outputJSON, "json", false, "Output results in JSON format")
 	cmd.Flags().BoolVar(&verbose, "verbose", false, "Show detailed information about each file")
+	cmd.Flags().StringSliceVar(&includes, "include", nil, "Re-include files matching this gitignore-style pattern (repeatable)")
+	cmd.Flags().StringSliceVar(&excludes, "exclude", nil, "Exclude files matching this gitignore-style pattern (repeatable)")
 
 	return cmd
 }
@@ -45,6 +51,8 @@ type analyzeOptions struct {
 	sshDir     string
 	outputJSON bool
 	verbose    bool
+	includes   []string
+	excludes   []string
 }
 
 func runAnalyze(cfg *config.Config, opts analyzeOptions) error {
@@ -53,8 +61,13 @@ func runAnalyze(cfg *config.Config, opts analyzeOptions) error {
 		Bool("json_output", opts.outputJSON).
 		Msg("Starting SSH directory analysis")
 
-	// Initialize analyzer
+	// Initialize analyzer with the same filter rules as backup
+	fileFilter, err := buildFileFilter(cfg, opts.sshDir, opts.includes, opts.excludes)
+	if err != nil {
+		return err
+	}
 	analyzer := analyzer.New()
+	analyzer.SetFilter(fileFilter)
 
 	// Analyze directory
 	result, err := analyzer.AnalyzeDirectory(opts.sshDir)
@@ -175,6 +188,9 @@ func outputHuman(result *analyzer.DetectionResult, verbose bool) error {
 		}
 	}
 
+	// Excluded files
+	displayExcludedFiles(result.Excluded)
+
 	// Recommendations
 	fmt.Printf("\n💡 Recommendations:\n")
 	if summary.UnknownFiles > 0 {
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rzago/ssh-secret-keeper/internal/interfaces"
+	"github.com/spf13/cobra"
+)
+
+// asOfLayouts are the absolute --as-of formats; those without a zone are in
+// local time
+var asOfLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// asOfTimeFormat is how --as-of times and backup timestamps are explained
+const asOfTimeFormat = "2006-01-02 15:04:05 MST"
+
+// parseAsOf parses an --as-of time: a timestamp such as 2026-09-30T12:00, a
+// date, meaning the end of that day, or a relative time such as "3 days ago"
+// or "yesterday"
+func parseAsOf(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range asOfLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if day, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return day.AddDate(0, 0, 1).Add(-time.Second), nil
+	}
+
+	switch strings.ToLower(value) {
+	case "now":
+		return now, nil
+	case "yesterday":
+		return now.AddDate(0, 0, -1), nil
+	}
+
+	fields := strings.Fields(strings.ToLower(value))
+	if len(fields) == 3 && fields[2] == "ago" {
+		n, err := strconv.Atoi(fields[0])
+		if err == nil && n >= 0 {
+			switch strings.TrimSuffix(fields[1], "s") {
+			case "minute", "min":
+				return now.Add(-time.Duration(n) * time.Minute), nil
+			case "hour":
+				return now.Add(-time.Duration(n) * time.Hour), nil
+			case "day":
+				return now.AddDate(0, 0, -n), nil
+			case "week":
+				return now.AddDate(0, 0, -7*n), nil
+			case "month":
+				return now.AddDate(0, -n, 0), nil
+			case "year":
+				return now.AddDate(-n, 0, 0), nil
+			}
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid --as-of %q: expected a time such as 2026-09-30T12:00, a date, or \"3 days ago\"", value)
+}
+
+// addAsOfFlag registers --as-of on a command
+func addAsOfFlag(cmd *cobra.Command, asOf *string) {
+	cmd.Flags().StringVar(asOf, "as-of", "", "Use the newest backup taken at or before this time, e.g. 2026-09-30T12:00 or \"3 days ago\"")
+}
+
+// chooseBackup picks the backup a command uses when none is named: the newest
+// backup matching sel or, with asOf, the newest taken at or before that time
+// according to the metadata index. It returns the backup name and a short
+// explanation of the choice.
+func chooseBackup(ctx context.Context, provider interfaces.StorageProvider, sel *backupSelector, asOf string) (string, string, error) {
+	if asOf == "" {
+		matches, err := selectBackups(ctx, provider, sel)
+		if err != nil {
+			return "", "", err
+		}
+		if sel.IsEmpty() {
+			if len(matches) == 0 {
+				return "", "", fmt.Errorf("no backups found")
+			}
+			return matches[0].Name, fmt.Sprintf("Using most recent backup: %s", matches[0].Name), nil
+		}
+		if len(matches) == 0 {
+			return "", "", fmt.Errorf("no backups match %s", sel)
+		}
+		return matches[0].Name, fmt.Sprintf("Using most recent backup matching %s: %s", sel, matches[0].Name), nil
+	}
+
+	at, err := parseAsOf(asOf, time.Now())
+	if err != nil {
+		return "", "", err
+	}
+	matches, err := selectBackups(ctx, provider, sel)
+	if err != nil {
+		return "", "", err
+	}
+	return chooseBackupAsOf(matches, sel, at)
+}
+
+// chooseBackupAsOf picks the newest of backups, sorted most recent first,
+// taken at or before at. Backups without a known timestamp are ignored.
+func chooseBackupAsOf(backups []backupInfo, sel *backupSelector, at time.Time) (string, string, error) {
+	scope := "backups"
+	if !sel.IsEmpty() {
+		scope = fmt.Sprintf("backups matching %s", sel)
+	}
+
+	var newer []backupInfo
+	undated := 0
+	for i, info := range backups {
+		if info.Timestamp.IsZero() {
+			undated++
+			continue
+		}
+		if info.Timestamp.After(at) {
+			newer = append(newer, info)
+			continue
+		}
+
+		older := 0
+		for _, rest := range backups[i+1:] {
+			if !rest.Timestamp.IsZero() {
+				older++
+			}
+		}
+
+		var explanation strings.Builder
+		fmt.Fprintf(&explanation, "Using backup as of %s: %s, taken %s", at.Format(asOfTimeFormat), info.Name, info.Timestamp.Local().Format(asOfTimeFormat))
+		fmt.Fprintf(&explanation, "\n  the newest of %d %s at or before that time", older+1, scope)
+		if len(newer) > 0 {
+			next := newer[len(newer)-1]
+			fmt.Fprintf(&explanation, "\n  %d newer skipped; the next is %s, taken %s", len(newer), next.Name, next.Timestamp.Local().Format(asOfTimeFormat))
+		}
+		if undated > 0 {
+			fmt.Fprintf(&explanation, "\n  %d without a recorded timestamp ignored", undated)
+		}
+		return info.Name, explanation.String(), nil
+	}
+
+	if len(newer) > 0 {
+		oldest := newer[len(newer)-1]
+		return "", "", fmt.Errorf("no %s taken at or before %s; the oldest is %s, taken %s",
+			scope, at.Format(asOfTimeFormat), oldest.Name, oldest.Timestamp.Local().Format(asOfTimeFormat))
+	}
+	return "", "", fmt.Errorf("no %s with a recorded timestamp found", scope)
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAsOf(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 30, 0, 0, time.Local)
+
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"2026-09-30T12:00", time.Date(2026, 9, 30, 12, 0, 0, 0, time.Local)},
+		{"2026-09-30 12:00:30", time.Date(2026, 9, 30, 12, 0, 30, 0, time.Local)},
+		{"2026-09-30T12:00:00Z", time.Date(2026, 9, 30, 12, 0, 0, 0, time.UTC)},
+		{"2026-09-30", time.Date(2026, 9, 30, 23, 59, 59, 0, time.Local)},
+		{"3 days ago", now.AddDate(0, 0, -3)},
+		{"1 week ago", now.AddDate(0, 0, -7)},
+		{"2 Hours ago", now.Add(-2 * time.Hour)},
+		{"6 months ago", now.AddDate(0, -6, 0)},
+		{"yesterday", now.AddDate(0, 0, -1)},
+	}
+	for _, tt := range tests {
+		got, err := parseAsOf(tt.value, now)
+		if err != nil {
+			t.Errorf("parseAsOf(%q) error = %v", tt.value, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseAsOf(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+
+	for _, value := range []string{"", "soon", "3 fortnights ago", "-1 days ago", "2026-13-01"} {
+		if _, err := parseAsOf(value, now); err == nil {
+			t.Errorf("parseAsOf(%q) expected an error", value)
+		}
+	}
+}
+
+func TestChooseBackup(t *testing.T) {
+	ctx := context.Background()
+	provider := newMemoryStorage()
+	base := time.Date(2026, 9, 29, 12, 0, 0, 0, time.UTC)
+
+	index := map[string]interface{}{}
+	for i, backup := range []struct{ name, hostname string }{
+		{"laptop-1", "laptop"}, {"server-1", "server"}, {"laptop-2", "laptop"}, {"laptop-3", "laptop"},
+	} {
+		provider.backups[backup.name] = map[string]interface{}{}
+		index[backup.name] = map[string]interface{}{
+			"timestamp": base.Add(time.Duration(i) * 24 * time.Hour).Format(time.RFC3339),
+			"hostname":  backup.hostname,
+		}
+	}
+	provider.metadata["backups"] = index
+
+	laptop, err := newBackupSelector(nil, []string{"hostname=laptop"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// server-1 is the newest of all at or before the 30th, laptop-1 of the laptop's
+	name, explanation, err := chooseBackup(ctx, provider, nil, "2026-09-30T18:00:00Z")
+	if err != nil || name != "server-1" {
+		t.Fatalf("chooseBackup() = %q, %v, want server-1", name, err)
+	}
+	if !strings.Contains(explanation, "newest of 2 backups") || !strings.Contains(explanation, "2 newer skipped; the next is laptop-2") {
+		t.Errorf("explanation = %q", explanation)
+	}
+
+	name, explanation, err = chooseBackup(ctx, provider, laptop, "2026-09-30T18:00:00Z")
+	if err != nil || name != "laptop-1" {
+		t.Fatalf("chooseBackup() with selector = %q, %v, want laptop-1", name, err)
+	}
+	if !strings.Contains(explanation, "matching hostname=laptop") {
+		t.Errorf("explanation = %q", explanation)
+	}
+
+	// A backup taken exactly at the time is used
+	if name, _, _ := chooseBackup(ctx, provider, nil, "2026-10-01T12:00:00Z"); name != "laptop-2" {
+		t.Errorf("chooseBackup() at a backup's time = %q, want laptop-2", name)
+	}
+
+	// Without --as-of the newest matching backup is used
+	if name, _, _ := chooseBackup(ctx, provider, laptop, ""); name != "laptop-3" {
+		t.Errorf("chooseBackup() without --as-of = %q, want laptop-3", name)
+	}
+
+	// The newest backup is chosen by timestamp, not by name
+	if name, _, _ := chooseBackup(ctx, provider, nil, ""); name != "laptop-3" {
+		t.Errorf("chooseBackup() without selector or --as-of = %q, want laptop-3", name)
+	}
+
+	_, _, err = chooseBackup(ctx, provider, nil, "2026-09-01")
+	if err == nil || !strings.Contains(err.Error(), "the oldest is laptop-1") {
+		t.Errorf("chooseBackup() before every backup error = %v", err)
+	}
+}
@@ -2,15 +2,24 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/rs/zerolog/log"
+	"github.com/rzago/ssh-secret-keeper/internal/accounts"
+	"github.com/rzago/ssh-secret-keeper/internal/analyzer"
+	"github.com/rzago/ssh-secret-keeper/internal/blobstore"
 	"github.com/rzago/ssh-secret-keeper/internal/config"
+	"github.com/rzago/ssh-secret-keeper/internal/filter"
+	"github.com/rzago/ssh-secret-keeper/internal/hooks"
 	"github.com/rzago/ssh-secret-keeper/internal/interfaces"
+	"github.com/rzago/ssh-secret-keeper/internal/naming"
 	"github.com/rzago/ssh-secret-keeper/internal/ssh"
-	"github.com/rzago/ssh-secret-keeper/internal/storage"
 	"github.com/spf13/cobra"
 )
 
@@ -21,58 +30,225 @@ func newBackupCommand(cfg *config.Config) *cobra.Command {
 		sshDir      string
 		dryRun      bool
 		interactive bool
+		force       bool
+		overwrite   bool
+		system      bool
+		allUsers    bool
+		minUID      int
+		skipUsers   []string
+		noSources   bool
+		nameTmpl    string
+		description string
+		tags        []string
+		includes    []string
+		excludes    []string
 	)
 
 	cmd := &cobra.Command{
 		Use:   "backup [name]",
 		Short: "Backup SSH directory to Vault",
 		Long: `Backup your SSH directory to Vault.
-The backup includes all SSH keys, configuration files, and metadata.`,
+The backup includes all SSH keys, configuration files, and metadata.
+
+Files are filtered with gitignore semantics. Rules are applied in this order and
+the last matching rule wins: backup.exclude_patterns, backup.include_patterns
+(which re-include), the .sshskignore file in the SSH directory, --exclude and
+--include. For example, back up only ed25519 keys with:
+
+  sshsk backup --exclude '*' --include 'id_ed25519*'
+
+Backups without an explicit name are named from --name-template
+(backup.name_template), e.g. '{{.Hostname}}-{{.Date}}-{{.Seq}}'. Available
+variables are .Hostname, .Username, .Date, .Time, .Timestamp and .Seq, which
+counts up until the name is free. An existing backup is never replaced unless
+--overwrite is given.
+
+If nothing changed since the latest backup of this host and user, no backup is
+stored and the command exits with status 3. Use --force to back up anyway;
+a backup given --tag or --description is always stored.
+
+With --system (as root) the host keys and sshd configuration in /etc/ssh are
+backed up instead, under systems/<hostname> whatever storage strategy is
+configured, so a rebuilt server can restore its host identity:
+
+  sudo sshsk backup --system
+
+With --all-users (as root) the ~/.ssh directory of every account in /etc/passwd
+with a UID of at least --min-uid is backed up, each under the account's user
+name, skipping accounts matching --exclude-user. The command ends with a
+per-user report and fails if any user's backup failed:
+
+  sudo sshsk backup --all-users --exclude-user 'svc-*'
+
+Directories configured in backup.sources, such as ~/.kube, are backed up with
+the SSH directory unless --no-sources is given. They are not part of --system
+or --all-users backups.`,
 		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			// Use provided name or generate timestamp-based name
+			// Use provided name; otherwise the name template is rendered once storage is known
 			name := backupName
 			if len(args) > 0 {
 				name = args[0]
 			}
-			if name == "" {
-				name = fmt.Sprintf("backup-%s", time.Now().Format("20060102-150405"))
+			if system && !cmd.Flags().Changed("ssh-dir") {
+				sshDir = ssh.SystemSSHDir
+			}
+
+			opts := backupOptions{
+				name:         name,
+				nameTemplate: nameTmpl,
+				sshDir:       sshDir,
+				dryRun:       dryRun,
+				interactive:  interactive,
+				force:        force,
+				overwrite:    overwrite,
+				system:       system,
+				tags:         tags,
+				description:  description,
+				includes:     includes,
+				excludes:     excludes,
+				noSources:    noSources,
 			}
 
-			return runBackup(cfg, backupOptions{
-				name:        name,
-				sshDir:      sshDir,
-				dryRun:      dryRun,
-				interactive: interactive,
-			})
+			var err error
+			if allUsers {
+				err = runBackupAllUsers(cfg, opts, allUsersOptions{minUID: minUID, excludes: skipUsers})
+			} else {
+				err = runBackup(cfg, opts)
+			}
+			if errors.Is(err, ErrNoChanges) {
+				// Already reported; only the exit status should signal it
+				cmd.SilenceErrors = true
+			}
+			return err
 		},
 	}
 
 	// Command-specific flags
-	cmd.Flags().StringVar(&backupName, "name", "", "Custom backup name (default: backup-YYYYMMDD-HHMMSS)")
+	cmd.Flags().StringVar(&backupName, "name", "", "Custom backup name (default: rendered from --name-template)")
+	cmd.Flags().StringVar(&nameTmpl, "name-template", cfg.Backup.NameTemplate, "Template for generated backup names")
 	cmd.Flags().StringVar(&sshDir, "ssh-dir", cfg.Backup.SSHDir, "SSH directory to backup")
+	cmd.Flags().StringSliceVar(&tags, "tag", nil, "Tag the backup (repeatable), e.g. --tag laptop --tag pre-rotation")
+	cmd.Flags().StringVar(&description, "description", "", "Free-form description stored with the backup")
 	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be backed up without actually doing it")
 	cmd.Flags().BoolVar(&interactive, "interactive", false, "Interactively select files to backup")
+	cmd.Flags().BoolVar(&force, "force", false, "Store a backup even if nothing changed since the latest one")
+	cmd.Flags().BoolVar(&overwrite, "overwrite", false, "Replace an existing backup with the same name")
+	cmd.Flags().BoolVar(&system, "system", false, "Back up host keys and sshd configuration from /etc/ssh (requires root)")
+	cmd.Flags().BoolVar(&noSources, "no-sources", false, "Back up only the SSH directory, not the directories in backup.sources")
+	cmd.Flags().BoolVar(&allUsers, "all-users", false, "Back up the SSH directory of every account in /etc/passwd (requires root)")
+	cmd.Flags().IntVar(&minUID, "min-uid", accounts.DefaultMinUID, "Lowest UID backed up by --all-users")
+	cmd.Flags().StringSliceVar(&skipUsers, "exclude-user", accounts.DefaultExcludes, "Account name pattern skipped by --all-users (repeatable)")
+	cmd.Flags().StringSliceVar(&includes, "include", nil, "Re-include files matching this gitignore-style pattern (repeatable)")
+	cmd.Flags().StringSliceVar(&excludes, "exclude", nil, "Exclude files matching this gitignore-style pattern (repeatable)")
 
 	return cmd
 }
 
 type backupOptions struct {
-	name        string
-	sshDir      string
-	dryRun      bool
-	interactive bool
+	name         string // Explicit name; empty renders nameTemplate
+	nameTemplate string
+	sshDir       string
+	dryRun       bool
+	interactive  bool
+	force        bool
+	overwrite    bool
+	system       bool // Host keys in /etc/ssh, stored by hostname
+	tags         []string
+	description  string
+	includes     []string
+	excludes     []string
+	noSources    bool // Skip backup.sources
+	// applyRetention enforces the retention policy after the backup even when
+	// backup.retention.auto_prune is off
+	applyRetention bool
+	// account is set by --all-users: the backup is stored under this account
+	// instead of the user running the command
+	account *accounts.Account
+	// report, if set, receives the name and file count of a stored backup
+	report *backupReport
+}
+
+// skipsUnchanged reports whether a backup is skipped when nothing changed since
+// the latest one. Tags and a description ask for a new, labelled backup.
+func (o backupOptions) skipsUnchanged() bool {
+	return !o.force && len(o.tags) == 0 && strings.TrimSpace(o.description) == ""
 }
 
-func runBackup(cfg *config.Config, opts backupOptions) error {
+// backupReport describes a stored backup for callers of runBackup
+type backupReport struct {
+	name  string
+	files int
+}
+
+// ErrNoChanges is returned by runBackup when the SSH directory is identical to
+// the latest backup of the same host and user
+var ErrNoChanges = errors.New("no changes since the latest backup")
+
+func runBackup(cfg *config.Config, opts backupOptions) (err error) {
 	log.Info().
 		Str("backup_name", opts.name).
+		Str("name_template", opts.nameTemplate).
 		Str("ssh_dir", opts.sshDir).
 		Bool("dry_run", opts.dryRun).
+		Bool("system", opts.system).
 		Msg("Starting backup process")
 
+	// Reject bad names and templates before reading anything
+	nameTemplate, err := naming.Parse(opts.nameTemplate)
+	if err != nil {
+		return err
+	}
+	if opts.name != "" {
+		if err := naming.ValidateName(opts.name); err != nil {
+			return err
+		}
+	}
+	tags, err := normalizeTags(opts.tags)
+	if err != nil {
+		return err
+	}
+	readSourceDirs := !opts.noSources && !opts.system && opts.account == nil && len(cfg.Backup.Sources) > 0
+	if readSourceDirs {
+		if err := validateSources(cfg.Backup.Sources); err != nil {
+			return err
+		}
+	}
+	if opts.system {
+		// Private host keys are only readable by root and would be silently skipped
+		if err := requireRoot("--system", "read host keys"); err != nil {
+			return err
+		}
+	}
+
+	// Hooks do not run for dry runs. The pre hook runs once the files are read
+	// and the name is chosen, so its event describes the backup being stored.
+	var hookRunner *hooks.Runner
+	if !opts.dryRun {
+		hookRunner = hooks.New(cfg.Hooks)
+	}
+	ctx := context.Background()
+	event := hooks.NewEvent(hooks.OperationBackup, opts.name)
+	event.Directory = opts.sshDir
+	if opts.account != nil {
+		event.Username = opts.account.Name
+	}
+	defer func() {
+		if errors.Is(err, ErrNoChanges) {
+			event.Result = hooks.ResultUnchanged
+			hookRunner.After(ctx, event, nil)
+			return
+		}
+		hookRunner.After(ctx, event, err)
+	}()
+
 	// Initialize SSH handler
+	fileFilter, err := buildFileFilter(cfg, opts.sshDir, opts.includes, opts.excludes)
+	if err != nil {
+		return err
+	}
 	sshHandler := ssh.New()
+	sshHandler.SetFilter(fileFilter)
 
 	// Read and analyze SSH directory
 	fmt.Printf("Analyzing SSH directory: %s\n", opts.sshDir)
@@ -81,11 +257,49 @@ func runBackup(cfg *config.Config, opts backupOptions) error {
 		return fmt.Errorf("failed to read SSH directory: %w", err)
 	}
 
+	if readSourceDirs {
+		if backupData.Sources, err = readSources(cfg.Backup.Sources); err != nil {
+			return fmt.Errorf("failed to read sources: %w", err)
+		}
+		backupData.Metadata["source_count"] = len(backupData.Sources)
+	}
+	if opts.account != nil {
+		// Stored under the account, not the root user running the sweep
+		backupData.Username = opts.account.Name
+		backupData.OriginalUser = opts.account.Name
+		backupData.Owner = &ssh.Owner{User: opts.account.Name, UID: opts.account.UID, GID: opts.account.GID}
+	}
+	backupData.Tags = tags
+	backupData.Description = strings.TrimSpace(opts.description)
+	if opts.system {
+		backupData.Metadata["system"] = true
+		if backupData.Analysis.Summary.HostFiles == 0 {
+			fmt.Printf("⚠️  No host keys or sshd configuration found in %s\n", opts.sshDir)
+		}
+	}
+
 	// Display analysis summary
 	displayBackupSummary(backupData)
 
+	nameVars := naming.Vars{
+		Hostname: backupData.Hostname,
+		Username: backupData.Username,
+		Now:      time.Now(),
+	}
+
 	if opts.dryRun {
-		fmt.Printf("\n[DRY RUN] Backup '%s' would include %d files\n", opts.name, len(backupData.Files))
+		displayExcludedFiles(backupData.Analysis.Excluded)
+		name := opts.name
+		if name == "" {
+			if name, err = nameTemplate.Render(nameVars, 1); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("\n[DRY RUN] Backup '%s' would include %d files\n", name, len(backupData.Files))
+		if opts.report != nil {
+			opts.report.name = name
+			opts.report.files = len(backupData.Files)
+		}
 		return nil
 	}
 
@@ -96,48 +310,79 @@ func runBackup(cfg *config.Config, opts backupOptions) error {
 		}
 	}
 
+	event.Files = backupData.FileNames()
+
+	// Fingerprint the final file selection so unchanged directories can be skipped
+	fingerprint := backupData.Fingerprint()
+	backupData.Metadata["fingerprint"] = fingerprint
+
 	// No passphrase needed - the storage provider is responsible for security
 
 	// Backup data is ready for storage (no encryption needed)
 	fmt.Printf("✓ Backup data prepared successfully\n")
 
 	// Create storage provider via factory
-	factory := storage.NewFactory()
-	storageProvider, err := factory.CreateStorage(cfg)
+	storageProvider, err := createStorage(cfg, opts.system)
 	if err != nil {
 		return fmt.Errorf("failed to create storage provider: %w", err)
 	}
 	defer storageProvider.Close()
 
 	// Test connection
-	ctx := context.Background()
 	fmt.Printf("Connecting to %s storage...\n", storageProvider.GetProviderType())
 	if err := storageProvider.TestConnection(ctx); err != nil {
 		return fmt.Errorf("storage connection test failed: %w", err)
 	}
 
-	// Prepare data for storage
-	vaultData := prepareVaultData(backupData)
+	if opts.skipsUnchanged() {
+		previous, err := findUnchangedBackup(ctx, storageProvider, backupData.Hostname, backupData.Username, fingerprint)
+		if err != nil {
+			log.Warn().Err(err).Msg("Could not compare with the latest backup")
+		} else if previous != "" {
+			log.Info().
+				Str("latest_backup", previous).
+				Str("fingerprint", fingerprint).
+				Msg("No changes since the latest backup, skipping")
+			// The hooks see the skip under the backup that is still current
+			event.Backup = previous
+			if err := hookRunner.Before(ctx, event); err != nil {
+				return err
+			}
+			fmt.Printf("✓ No changes since backup '%s'; nothing to do (use --force to back up anyway)\n", previous)
+			return ErrNoChanges
+		}
+	}
 
-	// Store backup using abstraction
-	fmt.Printf("Storing backup in %s...\n", storageProvider.GetProviderType())
-	if err := storageProvider.StoreBackup(ctx, opts.name, vaultData); err != nil {
-		return fmt.Errorf("failed to store backup: %w", err)
+	name, err := resolveBackupName(ctx, storageProvider, opts.name, opts.overwrite, nameTemplate, nameVars)
+	if err != nil {
+		return err
+	}
+	log.Info().Str("backup_name", name).Msg("Using backup name")
+	event.Backup = name
+	if err := hookRunner.Before(ctx, event); err != nil {
+		return err
 	}
 
-	// Update metadata
-	if err := updateBackupMetadata(storageProvider, opts.name, backupData); err != nil {
-		log.Warn().Err(err).Msg("Failed to update metadata")
+	dedupStats, err := storeBackup(ctx, cfg, storageProvider, name, backupData)
+	if err != nil {
+		return err
 	}
 
-	fmt.Printf("✓ Backup '%s' completed successfully\n", opts.name)
+	fmt.Printf("✓ Backup '%s' completed successfully\n", name)
 	fmt.Printf("Files backed up: %d\n", len(backupData.Files))
 	fmt.Printf("Total size: %d bytes\n", backupData.Metadata["total_size"])
+	if dedupStats != nil {
+		fmt.Printf("New content: %d bytes in %d file(s)\n", dedupStats.NewBytes, dedupStats.NewBlobs)
+		fmt.Printf("Reused content: %d bytes in %d file(s)\n", dedupStats.ReusedBytes, dedupStats.ReusedBlobs)
+	}
 
 	// Show permission preservation summary
 	fmt.Printf("\nPermission Preservation:\n")
 	permissionMap := make(map[string]int)
 	for _, fileData := range backupData.Files {
+		if fileData.IsSymlink() {
+			continue
+		}
 		permStr := fmt.Sprintf("%04o", fileData.Permissions&os.ModePerm)
 		permissionMap[permStr]++
 	}
@@ -152,11 +397,122 @@ func runBackup(cfg *config.Config, opts backupOptions) error {
 	fmt.Printf("\n🔐 MD5 Integrity Protection:\n")
 	fmt.Printf("• All %d files protected with MD5 checksums\n", len(backupData.Files))
 	fmt.Printf("• Use 'ssh-secret-keeper status --checksums' to view file hashes\n")
-	fmt.Printf("• Use 'ssh-secret-keeper status %s --checksums' for detailed view\n", opts.name)
+	fmt.Printf("• Use 'ssh-secret-keeper status %s --checksums' for detailed view\n", name)
+
+	if opts.report != nil {
+		opts.report.name = name
+		opts.report.files = len(backupData.Files)
+	}
+
+	// Enforce retention for this host, and for --all-users only this account's
+	// backups; the backup itself already succeeded
+	scope := hostnameSelector(backupData.Hostname)
+	if opts.account != nil {
+		scope = scope.addUsername(opts.account.Name)
+	}
+	prune := autoPrune
+	if opts.applyRetention {
+		prune = applyRetention
+	}
+	if err := prune(ctx, cfg, storageProvider, scope); err != nil {
+		log.Warn().Err(err).Msg("Failed to apply retention policy")
+		fmt.Printf("⚠️  Retention policy could not be applied: %v\n", err)
+	}
 
 	return nil
 }
 
+// storeBackup stores a backup under name, uploading only file contents the
+// blob store does not have yet when deduplication is on, and records it in the
+// metadata index. The returned stats are nil without deduplication.
+func storeBackup(ctx context.Context, cfg *config.Config, provider interfaces.StorageProvider, name string, backupData *ssh.BackupData) (*blobstore.Stats, error) {
+	// Prepare data for storage
+	vaultData := prepareVaultData(backupData)
+
+	// Upload only content the blob store does not have yet
+	var dedupStats *blobstore.Stats
+	store := blobstore.New(provider)
+	if cfg.Backup.Deduplicate {
+		stats, err := store.Deduplicate(ctx, vaultData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store file contents: %w", err)
+		}
+		dedupStats = &stats
+	}
+
+	// Store backup using abstraction
+	fmt.Printf("Storing backup in %s...\n", provider.GetProviderType())
+	if err := provider.StoreBackup(ctx, name, vaultData); err != nil {
+		return nil, fmt.Errorf("failed to store backup: %w", err)
+	}
+
+	// Garbage collection elsewhere may have removed contents reused above
+	// before this backup referenced them
+	if cfg.Backup.Deduplicate {
+		if _, err := store.EnsureReused(ctx); err != nil {
+			return nil, fmt.Errorf("failed to check reused file contents: %w", err)
+		}
+	}
+
+	// Update metadata
+	if err := updateBackupMetadata(provider, name, backupData); err != nil {
+		log.Warn().Err(err).Msg("Failed to update metadata")
+	}
+	return dedupStats, nil
+}
+
+// normalizeTags trims tags, drops duplicates and rejects tags that cannot be selected on
+func normalizeTags(tags []string) ([]string, error) {
+	var normalized []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			return nil, fmt.Errorf("tag cannot be empty")
+		}
+		if strings.ContainsAny(tag, "=*?[]") || strings.IndexFunc(tag, unicode.IsSpace) >= 0 {
+			return nil, fmt.Errorf("invalid tag %q: tags cannot contain whitespace, '=' or glob characters", tag)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized, nil
+}
+
+// resolveBackupName returns the name to store the backup under. Explicit names
+// and templates without {{.Seq}} must not collide with an existing backup unless
+// overwrite is set; templates with {{.Seq}} pick the next free sequence number.
+func resolveBackupName(ctx context.Context, provider interfaces.StorageProvider, explicit string, overwrite bool, tmpl *naming.Template, vars naming.Vars) (string, error) {
+	backups, err := provider.ListBackups(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to list existing backups: %w", err)
+	}
+	existing := make(map[string]bool, len(backups))
+	for _, backup := range backups {
+		existing[backup] = true
+	}
+
+	name := explicit
+	if name == "" {
+		name, err = tmpl.Generate(vars, func(candidate string) bool { return existing[candidate] })
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if existing[name] {
+		if !overwrite {
+			return "", fmt.Errorf("backup '%s' already exists; use --overwrite to replace it or choose another name", name)
+		}
+		log.Warn().Str("backup_name", name).Msg("Overwriting existing backup")
+		fmt.Printf("⚠️  Overwriting existing backup '%s'\n", name)
+	}
+
+	return name, nil
+}
+
 // displayBackupSummary shows a summary of what will be backed up
 func displayBackupSummary(backup *ssh.BackupData) {
 	fmt.Printf("\n📋 Backup Analysis Summary\n")
@@ -167,6 +523,25 @@ func displayBackupSummary(backup *ssh.BackupData) {
 	fmt.Printf("Personal keys: %d\n", backup.Analysis.Summary.PersonalKeys)
 	fmt.Printf("Work keys: %d\n", backup.Analysis.Summary.WorkKeys)
 	fmt.Printf("System files: %d\n", backup.Analysis.Summary.SystemFiles)
+	if backup.Analysis.Summary.HostFiles > 0 {
+		fmt.Printf("Host keys and sshd files: %d\n", backup.Analysis.Summary.HostFiles)
+	}
+	if len(backup.Directories) > 0 {
+		fmt.Printf("Subdirectories: %d\n", len(backup.Directories))
+	}
+	if len(backup.Analysis.Symlinks) > 0 {
+		fmt.Printf("Symlinks: %d\n", len(backup.Analysis.Symlinks))
+	}
+	if len(backup.Analysis.Excluded) > 0 {
+		fmt.Printf("Excluded files: %d\n", len(backup.Analysis.Excluded))
+	}
+	if len(backup.Tags) > 0 {
+		fmt.Printf("Tags: %s\n", strings.Join(backup.Tags, ", "))
+	}
+	for _, name := range backup.SourceNames() {
+		source := backup.Sources[name]
+		fmt.Printf("Source %s: %d files from %s\n", name, len(source.Files), source.Dir)
+	}
 
 	// Show key pairs
 	if len(backup.Analysis.KeyPairs) > 0 {
@@ -193,6 +568,36 @@ func displayBackupSummary(backup *ssh.BackupData) {
 	}
 }
 
+// buildFileFilter assembles the include/exclude rules for a backup of sshDir.
+// Later rules take precedence, so command-line patterns override the ignore file
+// and the ignore file overrides the configuration.
+func buildFileFilter(cfg *config.Config, sshDir string, includes, excludes []string) (*filter.Matcher, error) {
+	matcher := filter.New()
+	matcher.AddExcludes(cfg.Backup.ExcludePatterns, "exclude_patterns")
+	matcher.AddIncludes(cfg.Backup.IncludePatterns, "include_patterns")
+
+	if err := matcher.LoadIgnoreFile(filepath.Join(sshDir, filter.IgnoreFileName)); err != nil {
+		return nil, err
+	}
+
+	matcher.AddExcludes(excludes, "--exclude")
+	matcher.AddIncludes(includes, "--include")
+
+	return matcher, nil
+}
+
+// displayExcludedFiles lists the files skipped by filter rules and the rule responsible
+func displayExcludedFiles(excluded []analyzer.ExcludedFile) {
+	if len(excluded) == 0 {
+		return
+	}
+
+	fmt.Printf("\n🚫 Excluded Files:\n")
+	for _, file := range excluded {
+		fmt.Printf("  • %s - %s\n", file.Filename, file.Rule)
+	}
+}
+
 // interactiveFileSelection allows user to select which files to backup
 func interactiveFileSelection(backup *ssh.BackupData) error {
 	fmt.Printf("\n🎯 Interactive File Selection\n")
@@ -233,12 +638,38 @@ func prepareVaultData(backup *ssh.BackupData) map[string]interface{} {
 		"username":  backup.Username,
 		"ssh_dir":   backup.SSHDir,
 		"metadata":  backup.Metadata,
-		"files":     make(map[string]interface{}),
+		"files":     encodeFiles(backup.Files),
 	}
 
-	// Convert file data for storage (with plain content)
-	files := make(map[string]interface{})
-	for filename, fileData := range backup.Files {
+	if len(backup.Tags) > 0 {
+		data["tags"] = backup.Tags
+	}
+	if backup.Description != "" {
+		data["description"] = backup.Description
+	}
+	if backup.Owner != nil {
+		data["owner"] = map[string]interface{}{
+			"user": backup.Owner.User,
+			"uid":  backup.Owner.UID,
+			"gid":  backup.Owner.GID,
+		}
+	}
+
+	// Record subdirectory modes so restore can recreate the tree
+	if len(backup.Directories) > 0 {
+		data["directories"] = encodeDirectories(backup.Directories)
+	}
+	if len(backup.Sources) > 0 {
+		data["sources"] = encodeSources(backup.Sources)
+	}
+
+	return data
+}
+
+// encodeFiles converts file data for storage (with plain content)
+func encodeFiles(backupFiles map[string]*ssh.FileData) map[string]interface{} {
+	files := make(map[string]interface{}, len(backupFiles))
+	for filename, fileData := range backupFiles {
 		// Validate and log the permissions being stored
 		permissionsToStore := int(fileData.Permissions & os.ModePerm)
 
@@ -257,7 +688,7 @@ func prepareVaultData(backup *ssh.BackupData) map[string]interface{} {
 			Int("stored_perms", permissionsToStore).
 			Msg("Storing file permissions in backup")
 
-		files[filename] = map[string]interface{}{
+		fileEntry := map[string]interface{}{
 			"filename":    fileData.Filename,
 			"content":     string(fileData.Content), // Store as base64 or plain text
 			"permissions": permissionsToStore,       // Store only permission bits, not file type
@@ -266,34 +697,91 @@ func prepareVaultData(backup *ssh.BackupData) map[string]interface{} {
 			"checksum":    fileData.Checksum,
 			"key_info":    fileData.KeyInfo,
 		}
+		if fileData.IsSymlink() {
+			fileEntry["link_target"] = fileData.LinkTarget
+		}
+		files[filename] = fileEntry
 	}
-	data["files"] = files
+	return files
+}
 
-	return data
+// encodeDirectories converts subdirectory modes for storage
+func encodeDirectories(dirs map[string]os.FileMode) map[string]interface{} {
+	directories := make(map[string]interface{}, len(dirs))
+	for dir, mode := range dirs {
+		directories[dir] = int(mode.Perm())
+	}
+	return directories
 }
 
-// updateBackupMetadata updates the backup metadata using storage provider
+// updateBackupMetadata records the backup in the metadata index using storage provider
 func updateBackupMetadata(provider interfaces.StorageProvider, backupName string, backup *ssh.BackupData) error {
 	ctx := context.Background()
 
+	return provider.UpdateMetadata(ctx, func(metadata map[string]interface{}) error {
+		backups, ok := metadata["backups"].(map[string]interface{})
+		if !ok {
+			backups = make(map[string]interface{})
+			metadata["backups"] = backups
+		}
+
+		backups[backupName] = buildBackupMetadataEntry(backup)
+		return nil
+	})
+}
+
+// buildBackupMetadataEntry creates the metadata index entry describing a backup
+func buildBackupMetadataEntry(backup *ssh.BackupData) map[string]interface{} {
+	entry := map[string]interface{}{
+		"timestamp":   backup.Timestamp.Format(time.RFC3339),
+		"file_count":  len(backup.Files),
+		"total_size":  backup.Metadata["total_size"],
+		"hostname":    backup.Hostname,
+		"username":    backup.Username,
+		"fingerprint": backup.Metadata["fingerprint"],
+	}
+	if len(backup.Tags) > 0 {
+		entry["tags"] = backup.Tags
+	}
+	if backup.Description != "" {
+		entry["description"] = backup.Description
+	}
+	return entry
+}
+
+// findUnchangedBackup returns the latest backup of hostname and username when
+// its stored fingerprint equals fingerprint, or "" when a new backup is needed
+func findUnchangedBackup(ctx context.Context, provider interfaces.StorageProvider, hostname, username, fingerprint string) (string, error) {
 	metadata, err := provider.GetMetadata(ctx)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	// Initialize backups list if it doesn't exist
-	if metadata["backups"] == nil {
-		metadata["backups"] = make(map[string]interface{})
-	}
+	backups, _ := metadata["backups"].(map[string]interface{})
+
+	var latestName, latestFingerprint string
+	var latestTime time.Time
+	for name, entry := range backups {
+		info, ok := entry.(map[string]interface{})
+		if !ok || info["hostname"] != hostname || info["username"] != username {
+			continue
+		}
+
+		timestampStr, _ := info["timestamp"].(string)
+		timestamp, err := time.Parse(time.RFC3339, timestampStr)
+		if err != nil {
+			continue
+		}
 
-	backups := metadata["backups"].(map[string]interface{})
-	backups[backupName] = map[string]interface{}{
-		"timestamp":  backup.Timestamp.Format(time.RFC3339),
-		"file_count": len(backup.Files),
-		"total_size": backup.Metadata["total_size"],
-		"hostname":   backup.Hostname,
-		"username":   backup.Username,
+		if latestName == "" || timestamp.After(latestTime) {
+			latestName = name
+			latestTime = timestamp
+			latestFingerprint, _ = info["fingerprint"].(string)
+		}
 	}
 
-	return provider.StoreMetadata(ctx, metadata)
+	if latestName == "" || latestFingerprint != fingerprint {
+		return "", nil
+	}
+	return latestName, nil
 }
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/rzago/ssh-secret-keeper/internal/analyzer"
 	"github.com/rzago/ssh-secret-keeper/internal/config"
+	"github.com/rzago/ssh-secret-keeper/internal/naming"
 	"github.com/rzago/ssh-secret-keeper/internal/ssh"
 )
 
@@ -40,7 +42,7 @@ func TestBackupCommand_Flags(t *testing.T) {
 	cfg := config.Default()
 	cmd := newBackupCommand(cfg)
 
-	expectedFlags := []string{"name", "ssh-dir", "dry-run", "interactive"}
+	expectedFlags := []string{"name", "ssh-dir", "dry-run", "interactive", "force", "overwrite", "name-template", "tag", "description", "include", "exclude", "system", "all-users", "min-uid", "exclude-user", "no-sources"}
 
 	for _, flagName := range expectedFlags {
 		if cmd.Flag(flagName) == nil {
@@ -758,3 +760,158 @@ func createTestBackupData(t *testing.T) *ssh.BackupData {
 		},
 	}
 }
+
+func TestBuildFileFilter(t *testing.T) {
+	sshDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(sshDir, ".sshskignore"), []byte("*.swp\nid_test*\n"), 0600); err != nil {
+		t.Fatalf("Failed to write ignore file: %v", err)
+	}
+
+	cfg := config.Default()
+	matcher, err := buildFileFilter(cfg, sshDir, []string{"id_test_keep"}, []string{"secret*"})
+	if err != nil {
+		t.Fatalf("buildFileFilter() error = %v", err)
+	}
+
+	tests := []struct {
+		file     string
+		excluded bool
+		rule     string
+	}{
+		{"id_ed25519", false, ""},
+		{"agent.tmp", true, "*.tmp (exclude_patterns)"},
+		{"known_hosts.tmp", false, "known_hosts* (include_patterns)"},
+		{"notes.swp", true, "*.swp (.sshskignore:1)"},
+		{"id_test", true, "id_test* (.sshskignore:2)"},
+		{"id_test_keep", false, "id_test_keep (--include)"},
+		{"secret.key", true, "secret* (--exclude)"},
+	}
+
+	for _, tt := range tests {
+		result := matcher.Match(tt.file, false)
+		if result.Excluded != tt.excluded {
+			t.Errorf("%s excluded = %v, want %v", tt.file, result.Excluded, tt.excluded)
+		}
+		rule := ""
+		if result.Rule != nil {
+			rule = result.Rule.String()
+		}
+		if rule != tt.rule {
+			t.Errorf("%s rule = %q, want %q", tt.file, rule, tt.rule)
+		}
+	}
+}
+
+func TestFindUnchangedBackup(t *testing.T) {
+	ctx := context.Background()
+	provider := newMemoryStorage()
+	base := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	provider.metadata["backups"] = map[string]interface{}{
+		"old": map[string]interface{}{
+			"timestamp": base.Add(-time.Hour).Format(time.RFC3339), "hostname": "laptop", "username": "me", "fingerprint": "sha256:old",
+		},
+		"latest": map[string]interface{}{
+			"timestamp": base.Format(time.RFC3339), "hostname": "laptop", "username": "me", "fingerprint": "sha256:same",
+		},
+		"server": map[string]interface{}{
+			"timestamp": base.Add(time.Hour).Format(time.RFC3339), "hostname": "server", "username": "me", "fingerprint": "sha256:other",
+		},
+	}
+
+	tests := []struct {
+		name        string
+		hostname    string
+		fingerprint string
+		want        string
+	}{
+		{"unchanged since latest", "laptop", "sha256:same", "latest"},
+		{"matches only an older backup", "laptop", "sha256:old", ""},
+		{"changed", "laptop", "sha256:new", ""},
+		{"other hosts are ignored", "desktop", "sha256:other", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findUnchangedBackup(ctx, provider, tt.hostname, "me", tt.fingerprint)
+			if err != nil {
+				t.Fatalf("findUnchangedBackup() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("findUnchangedBackup() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if code := ExitCode(fmt.Errorf("backup: %w", ErrNoChanges)); code != ExitCodeNoChanges {
+		t.Errorf("ExitCode() = %d, want %d", code, ExitCodeNoChanges)
+	}
+}
+
+func TestBackupOptions_SkipsUnchanged(t *testing.T) {
+	tests := []struct {
+		name string
+		opts backupOptions
+		want bool
+	}{
+		{"plain backup", backupOptions{}, true},
+		{"forced", backupOptions{force: true}, false},
+		{"tagged", backupOptions{tags: []string{"pre-rotation"}}, false},
+		{"described", backupOptions{description: "before rotating keys"}, false},
+		{"blank description", backupOptions{description: "  "}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.skipsUnchanged(); got != tt.want {
+				t.Errorf("skipsUnchanged() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveBackupName(t *testing.T) {
+	ctx := context.Background()
+	provider := newMemoryStorage()
+	for _, name := range []string{"laptop-20261018-1", "laptop-20261018-2", "manual"} {
+		provider.backups[name] = map[string]interface{}{}
+	}
+
+	vars := naming.Vars{Hostname: "laptop", Username: "me", Now: time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)}
+	seqTemplate, err := naming.Parse("{{.Hostname}}-{{.Date}}-{{.Seq}}")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	dateTemplate, err := naming.Parse("{{.Hostname}}-{{.Date}}-1")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		explicit  string
+		overwrite bool
+		tmpl      *naming.Template
+		want      string
+		wantErr   bool
+	}{
+		{"next free sequence number", "", false, seqTemplate, "laptop-20261018-3", false},
+		{"explicit new name", "fresh", false, seqTemplate, "fresh", false},
+		{"explicit name collides", "manual", false, seqTemplate, "", true},
+		{"explicit name overwritten", "manual", true, seqTemplate, "manual", false},
+		{"template without Seq collides", "", false, dateTemplate, "", true},
+		{"template without Seq overwritten", "", true, dateTemplate, "laptop-20261018-1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveBackupName(ctx, provider, tt.explicit, tt.overwrite, tt.tmpl, vars)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveBackupName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resolveBackupName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
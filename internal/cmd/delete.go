@@ -7,6 +7,7 @@ import (
 
 	"github.com/rs/zerolog/log"
 	"github.com/rzago/ssh-secret-keeper/internal/config"
+	"github.com/rzago/ssh-secret-keeper/internal/hooks"
 	"github.com/rzago/ssh-secret-keeper/internal/interfaces"
 	"github.com/rzago/ssh-secret-keeper/internal/storage"
 	"github.com/spf13/cobra"
@@ -17,20 +18,34 @@ func newDeleteCommand(cfg *config.Config) *cobra.Command {
 	var (
 		force       bool
 		interactive bool
+		tags        []string
+		selectors   []string
 	)
 
 	cmd := &cobra.Command{
-		Use:   "delete <backup-name>",
+		Use:   "delete [backup-name]",
 		Short: "Delete a backup from Vault",
 		Long: `Delete a backup from Vault storage. This operation is irreversible.
-You can delete a specific backup by name, or use interactive mode to select from available backups.`,
-		Args: cobra.ExactArgs(1),
+You can delete a specific backup by name, or use interactive mode to select from available backups.
+
+With --tag or --selector, every matching backup is deleted:
+
+  sshsk delete --tag ci --selector hostname='runner-*'`,
+		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			backupName := args[0]
+			var backupName string
+			if len(args) > 0 {
+				backupName = args[0]
+			}
+			if backupName == "" && !interactive && len(tags) == 0 && len(selectors) == 0 {
+				return fmt.Errorf("specify a backup name, --interactive, --tag or --selector")
+			}
 			return runDelete(cfg, deleteOptions{
 				backupName:  backupName,
 				force:       force,
 				interactive: interactive,
+				tags:        tags,
+				selectors:   selectors,
 			})
 		},
 	}
@@ -38,6 +53,7 @@ You can delete a specific backup by name, or use interactive mode to select from
 	// Command-specific flags
 	cmd.Flags().BoolVar(&force, "force", false, "Skip confirmation prompt")
 	cmd.Flags().BoolVar(&interactive, "interactive", false, "Interactive backup selection")
+	addSelectorFlags(cmd, &tags, &selectors)
 
 	return cmd
 }
@@ -46,15 +62,27 @@ type deleteOptions struct {
 	backupName  string
 	force       bool
 	interactive bool
+	tags        []string
+	selectors   []string
 }
 
-func runDelete(cfg *config.Config, opts deleteOptions) error {
+func runDelete(cfg *config.Config, opts deleteOptions) (err error) {
 	log.Info().
 		Str("backup_name", opts.backupName).
 		Bool("force", opts.force).
 		Bool("interactive", opts.interactive).
+		Strs("tags", opts.tags).
+		Strs("selectors", opts.selectors).
 		Msg("Starting backup deletion")
 
+	selector, err := newBackupSelector(opts.tags, opts.selectors)
+	if err != nil {
+		return err
+	}
+	if !selector.IsEmpty() && (opts.backupName != "" || opts.interactive) {
+		return fmt.Errorf("--tag and --selector cannot be combined with a backup name or --interactive")
+	}
+
 	// Create storage provider via factory
 	factory := storage.NewFactory()
 	storageProvider, err := factory.CreateStorage(cfg)
@@ -64,6 +92,9 @@ func runDelete(cfg *config.Config, opts deleteOptions) error {
 	defer storageProvider.Close()
 
 	ctx := context.Background()
+	hookRunner := hooks.New(cfg.Hooks)
+	event := hooks.NewEvent(hooks.OperationDelete, opts.backupName)
+	defer func() { hookRunner.After(ctx, event, err) }()
 
 	// Test connection
 	fmt.Printf("Connecting to %s storage...\n", storageProvider.GetProviderType())
@@ -72,6 +103,10 @@ func runDelete(cfg *config.Config, opts deleteOptions) error {
 	}
 	fmt.Printf("✓ Connected to %s\n", storageProvider.GetProviderType())
 
+	if !selector.IsEmpty() {
+		return deleteSelectedBackups(ctx, storageProvider, selector, opts.force, hookRunner, event)
+	}
+
 	// Handle interactive mode
 	if opts.interactive {
 		backupName, err := interactiveBackupSelection(storageProvider)
@@ -128,6 +163,11 @@ func runDelete(cfg *config.Config, opts deleteOptions) error {
 		}
 	}
 
+	event.Backup = opts.backupName
+	if err := hookRunner.Before(ctx, event); err != nil {
+		return err
+	}
+
 	// Delete the backup
 	fmt.Printf("Deleting backup '%s'...\n", opts.backupName)
 	if err := storageProvider.DeleteBackup(ctx, opts.backupName); err != nil {
@@ -148,6 +188,54 @@ func runDelete(cfg *config.Config, opts deleteOptions) error {
 	return nil
 }
 
+// deleteSelectedBackups deletes every backup matching the selector after one confirmation
+func deleteSelectedBackups(ctx context.Context, provider interfaces.StorageProvider, selector *backupSelector, force bool, hookRunner *hooks.Runner, event *hooks.Event) error {
+	matches, err := selectBackups(ctx, provider, selector)
+	if err != nil {
+		return err
+	}
+	if len(matches) == 0 {
+		fmt.Printf("No backups match %s\n", selector)
+		return nil
+	}
+
+	fmt.Printf("\n%d backup(s) match %s:\n", len(matches), selector)
+	names := make([]string, 0, len(matches))
+	for _, match := range matches {
+		names = append(names, match.Name)
+		fmt.Printf("  • %s", match.Name)
+		if !match.Timestamp.IsZero() {
+			fmt.Printf(" (%s, %s@%s)", match.Timestamp.Format("2006-01-02 15:04:05"), match.Username, match.Hostname)
+		}
+		fmt.Printf("\n")
+	}
+
+	// Confirmation prompt (unless --force is used)
+	if !force {
+		fmt.Printf("\n⚠️  WARNING: This will permanently delete %d backup(s)\n", len(names))
+		fmt.Printf("This operation cannot be undone!\n")
+		fmt.Printf("\nContinue? [y/N]: ")
+
+		var response string
+		fmt.Scanln(&response)
+		response = strings.ToLower(strings.TrimSpace(response))
+
+		if response != "y" && response != "yes" {
+			fmt.Printf("Deletion cancelled\n")
+			return nil
+		}
+	}
+
+	event.Backups = names
+	if err := hookRunner.Before(ctx, event); err != nil {
+		return err
+	}
+
+	deleted, err := deleteBackups(ctx, provider, names)
+	fmt.Printf("\n✓ Deleted %d backup(s)\n", len(deleted))
+	return err
+}
+
 // interactiveBackupSelection allows user to select a backup to delete
 func interactiveBackupSelection(provider interfaces.StorageProvider) (string, error) {
 	ctx := context.Background()
@@ -272,23 +360,17 @@ func showBackupDetailsForDeletion(provider interfaces.StorageProvider, backupNam
 // updateMetadataAfterDeletion removes the backup from metadata
 func updateMetadataAfterDeletion(provider interfaces.StorageProvider, backupName string) error {
 	ctx := context.Background()
-	metadata, err := provider.GetMetadata(ctx)
-	if err != nil {
-		return err
-	}
-
-	// Remove backup from metadata
-	if backups, ok := metadata["backups"].(map[string]interface{}); ok {
-		delete(backups, backupName)
-		metadata["backups"] = backups
 
-		// Store updated metadata
-		if err := provider.StoreMetadata(ctx, metadata); err != nil {
-			return err
+	err := provider.UpdateMetadata(ctx, func(metadata map[string]interface{}) error {
+		if backups, ok := metadata["backups"].(map[string]interface{}); ok {
+			delete(backups, backupName)
 		}
-
-		log.Debug().Str("backup", backupName).Msg("Removed backup from metadata")
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
+	log.Debug().Str("backup", backupName).Msg("Removed backup from metadata")
 	return nil
 }
@@ -0,0 +1,263 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/rzago/ssh-secret-keeper/internal/config"
+	"github.com/rzago/ssh-secret-keeper/internal/diff"
+	"github.com/rzago/ssh-secret-keeper/internal/interfaces"
+	"github.com/rzago/ssh-secret-keeper/internal/ssh"
+	"github.com/rzago/ssh-secret-keeper/internal/storage"
+	"github.com/rzago/ssh-secret-keeper/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// newDiffCommand creates the diff command
+func newDiffCommand(cfg *config.Config) *cobra.Command {
+	var (
+		targetDir  string
+		outputJSON bool
+		tags       []string
+		selectors  []string
+		asOf       string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "diff [backup-name]",
+		Short: "Compare a backup with the local SSH directory",
+		Long: `Compare a stored backup with the local SSH directory before restoring it.
+If no backup name is provided, the most recent backup is used.
+
+Files are listed as added (only present locally), removed (only in the
+backup), modified or mode changed. Changes to config, known_hosts and
+authorized_keys are shown as unified diffs from the backup to the local file.
+Private key content is never printed; keys are identified by their SHA256
+fingerprint instead.
+
+Local files are read with the same filter rules as a backup, so excluded
+files are not reported as added.
+
+Without a backup name, --tag and --selector pick the most recent matching
+backup, and --as-of the newest taken at or before a time, as with restore:
+
+  sshsk diff --as-of "3 days ago" --selector hostname=laptop`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts := diffOptions{
+				targetDir:  targetDir,
+				outputJSON: outputJSON,
+				tags:       tags,
+				selectors:  selectors,
+				asOf:       asOf,
+			}
+			if len(args) > 0 {
+				opts.backupName = args[0]
+			}
+			return runDiff(cfg, opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&targetDir, "target-dir", cfg.Backup.SSHDir, "Local directory to compare the backup with")
+	cmd.Flags().BoolVar(&outputJSON, "json", false, "Output results in JSON format")
+	addSelectorFlags(cmd, &tags, &selectors)
+	addAsOfFlag(cmd, &asOf)
+
+	return cmd
+}
+
+type diffOptions struct {
+	backupName string
+	targetDir  string
+	outputJSON bool
+	tags       []string
+	selectors  []string
+	asOf       string
+}
+
+// diffReport is the JSON output of the diff command
+type diffReport struct {
+	Backup    string        `json:"backup"`
+	TargetDir string        `json:"target_dir"`
+	Changes   []diff.Change `json:"changes"`
+	Unchanged int           `json:"unchanged"`
+}
+
+func runDiff(cfg *config.Config, opts diffOptions) error {
+	log.Info().
+		Str("backup_name", opts.backupName).
+		Str("target_dir", opts.targetDir).
+		Bool("json_output", opts.outputJSON).
+		Msg("Comparing backup with local directory")
+
+	selector, err := newBackupSelector(opts.tags, opts.selectors)
+	if err != nil {
+		return err
+	}
+	if (!selector.IsEmpty() || opts.asOf != "") && opts.backupName != "" {
+		return fmt.Errorf("--tag, --selector and --as-of cannot be combined with a backup name")
+	}
+	if opts.asOf != "" {
+		if _, err := parseAsOf(opts.asOf, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	targetDir, err := utils.NewPathNormalizer().ResolvePath(opts.targetDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve target directory: %w", err)
+	}
+
+	// Create storage provider via factory
+	factory := storage.NewFactory()
+	storageProvider, err := factory.CreateStorage(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create storage provider: %w", err)
+	}
+	defer storageProvider.Close()
+
+	ctx := context.Background()
+	if err := storageProvider.TestConnection(ctx); err != nil {
+		return fmt.Errorf("storage connection test failed: %w", err)
+	}
+
+	backupName := opts.backupName
+	if backupName == "" {
+		var explanation string
+		backupName, explanation, err = chooseBackup(ctx, storageProvider, selector, opts.asOf)
+		if err != nil {
+			return err
+		}
+		// stdout may be carrying the JSON report
+		if opts.outputJSON {
+			fmt.Fprintln(os.Stderr, explanation)
+		} else {
+			fmt.Println(explanation)
+		}
+	}
+
+	report, err := diffBackup(ctx, cfg, storageProvider, backupName, targetDir)
+	if err != nil {
+		return err
+	}
+
+	if opts.outputJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	displayDiff(report)
+	return nil
+}
+
+// diffBackup compares a stored backup with the files in targetDir
+func diffBackup(ctx context.Context, cfg *config.Config, provider interfaces.StorageProvider, backupName, targetDir string) (*diffReport, error) {
+	backup, err := loadBackup(ctx, provider, backupName)
+	if err != nil {
+		return nil, err
+	}
+
+	local, err := readLocalFiles(cfg, targetDir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := diff.Compare(backup.Files, local)
+	return &diffReport{
+		Backup:    backupName,
+		TargetDir: targetDir,
+		Changes:   result.Changes,
+		Unchanged: result.Unchanged,
+	}, nil
+}
+
+// readLocalFiles reads targetDir with the backup's filter rules. A missing
+// directory has no files, so everything in the backup is reported as removed.
+func readLocalFiles(cfg *config.Config, targetDir string) (map[string]*ssh.FileData, error) {
+	if _, err := os.Stat(targetDir); os.IsNotExist(err) {
+		return map[string]*ssh.FileData{}, nil
+	}
+
+	fileFilter, err := buildFileFilter(cfg, targetDir, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build file filter: %w", err)
+	}
+
+	sshHandler := ssh.New()
+	sshHandler.SetFilter(fileFilter)
+	local, err := sshHandler.ReadDirectory(targetDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", targetDir, err)
+	}
+	return local.Files, nil
+}
+
+// displayDiff prints the changes in human-readable form
+func displayDiff(report *diffReport) {
+	fmt.Printf("🔍 Backup '%s' compared with %s\n", report.Backup, report.TargetDir)
+	fmt.Printf("═══════════════════════════════════\n\n")
+
+	if len(report.Changes) == 0 {
+		fmt.Printf("✅ No differences (%d files)\n", report.Unchanged)
+		return
+	}
+
+	counts := make(map[diff.ChangeType]int)
+	for _, change := range report.Changes {
+		counts[change.Change]++
+
+		switch change.Change {
+		case diff.Added:
+			fmt.Printf("➕ added         %s%s\n", change.Path, describeSide(change.LocalMode, change.LocalLinkTarget))
+		case diff.Removed:
+			fmt.Printf("➖ removed       %s%s\n", change.Path, describeSide(change.BackupMode, change.BackupLinkTarget))
+		case diff.ModeChanged:
+			fmt.Printf("🔒 mode changed  %s (%s → %s)\n", change.Path, change.BackupMode, change.LocalMode)
+		default:
+			fmt.Printf("✏️  modified      %s", change.Path)
+			if change.BackupMode != "" && change.LocalMode != "" && change.BackupMode != change.LocalMode {
+				fmt.Printf(" (%s → %s)", change.BackupMode, change.LocalMode)
+			}
+			fmt.Println()
+			if change.BackupLinkTarget != "" || change.LocalLinkTarget != "" {
+				fmt.Printf("   link: %s → %s\n", orNone(change.BackupLinkTarget), orNone(change.LocalLinkTarget))
+			}
+		}
+
+		if change.BackupFingerprint != "" || change.LocalFingerprint != "" {
+			fmt.Printf("   🔑 fingerprint: %s → %s\n", orNone(change.BackupFingerprint), orNone(change.LocalFingerprint))
+		}
+		if change.Diff != "" {
+			for _, line := range strings.Split(strings.TrimSuffix(change.Diff, "\n"), "\n") {
+				fmt.Printf("   %s\n", line)
+			}
+		}
+	}
+
+	fmt.Printf("\n%d added, %d removed, %d modified, %d mode changed, %d unchanged\n",
+		counts[diff.Added], counts[diff.Removed], counts[diff.Modified], counts[diff.ModeChanged], report.Unchanged)
+}
+
+// describeSide formats the mode or symlink target of a file present on one side only
+func describeSide(mode, linkTarget string) string {
+	if linkTarget != "" {
+		return " → " + linkTarget
+	}
+	if mode != "" {
+		return " (" + mode + ")"
+	}
+	return ""
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rzago/ssh-secret-keeper/internal/config"
+	"github.com/rzago/ssh-secret-keeper/internal/diff"
+	"github.com/rzago/ssh-secret-keeper/internal/ssh"
+)
+
+func TestNewDiffCommand(t *testing.T) {
+	cmd := newDiffCommand(config.Default())
+
+	if cmd.Use != "diff [backup-name]" {
+		t.Errorf("Use = %q", cmd.Use)
+	}
+	for _, flag := range []string{"target-dir", "json", "tag", "selector", "as-of"} {
+		if cmd.Flags().Lookup(flag) == nil {
+			t.Errorf("missing --%s flag", flag)
+		}
+	}
+	if err := cmd.Args(cmd, []string{"a", "b"}); err == nil {
+		t.Error("expected an error for two backup names")
+	}
+}
+
+func TestDiffBackup(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	write := func(name, content string, mode os.FileMode) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("config", "Host github.com\n  User git\n", 0600)
+	write("known_hosts", "github.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl\n", 0644)
+	write("notes.txt", "remove me\n", 0600)
+
+	backup, err := ssh.New().ReadDirectory(dir)
+	if err != nil {
+		t.Fatalf("ReadDirectory() error = %v", err)
+	}
+	provider := newMemoryStorage()
+	provider.StoreBackup(ctx, "before", prepareVaultData(backup))
+
+	write("config", "Host github.com\n  User git\n  IdentityFile ~/.ssh/id_github\n", 0600)
+	if err := os.Chmod(filepath.Join(dir, "known_hosts"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Fatal(err)
+	}
+	write("extra.txt", "new\n", 0600)
+
+	report, err := diffBackup(ctx, config.Default(), provider, "before", dir)
+	if err != nil {
+		t.Fatalf("diffBackup() error = %v", err)
+	}
+
+	got := make(map[string]diff.Change)
+	for _, change := range report.Changes {
+		got[change.Path] = change
+	}
+	want := map[string]diff.ChangeType{
+		"config":      diff.Modified,
+		"known_hosts": diff.ModeChanged,
+		"notes.txt":   diff.Removed,
+		"extra.txt":   diff.Added,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("changes = %+v, want %v", report.Changes, want)
+	}
+	for path, changeType := range want {
+		if got[path].Change != changeType {
+			t.Errorf("%s: change = %q, want %q", path, got[path].Change, changeType)
+		}
+	}
+	if !strings.Contains(got["config"].Diff, "+  IdentityFile ~/.ssh/id_github\n") {
+		t.Errorf("config diff = %q", got["config"].Diff)
+	}
+
+	// A missing directory reports every backed-up file as removed
+	report, err = diffBackup(ctx, config.Default(), provider, "before", filepath.Join(dir, "missing"))
+	if err != nil {
+		t.Fatalf("diffBackup() error = %v", err)
+	}
+	if len(report.Changes) != 3 || report.Unchanged != 0 {
+		t.Errorf("missing directory: %+v", report)
+	}
+}
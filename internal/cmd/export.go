@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/rzago/ssh-secret-keeper/internal/archive"
+	"github.com/rzago/ssh-secret-keeper/internal/config"
+	"github.com/rzago/ssh-secret-keeper/internal/ssh"
+	"github.com/rzago/ssh-secret-keeper/internal/storage"
+	"github.com/rzago/ssh-secret-keeper/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// newExportCommand creates the export command
+func newExportCommand(cfg *config.Config) *cobra.Command {
+	var (
+		format         string
+		output         string
+		encrypt        bool
+		passphraseFile string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export [backup-name]",
+		Short: "Export a backup as a tar, tar.gz or zip archive",
+		Long: `Export the SSH directory of a backup as an archive, to move it into a
+container build, onto a machine without sshsk, or into another tool. Paths
+are relative to the SSH directory, and modes, modification times and symlinks
+are kept. If no backup name is provided, the most recent backup is used.
+
+Use -o - to write the archive to stdout. With --encrypt the archive is
+encrypted with a passphrase (AES-256-GCM), prompted for or read from
+--passphrase-file; only 'sshsk import' can read it.
+
+  sshsk export laptop-1 --format tar.gz -o ssh.tar.gz
+  sshsk export -o - --format tar | docker build -`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts := exportOptions{
+				format:         format,
+				output:         output,
+				encrypt:        encrypt || passphraseFile != "",
+				passphraseFile: passphraseFile,
+			}
+			if len(args) > 0 {
+				opts.backupName = args[0]
+			}
+			return runExport(cfg, opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", string(archive.FormatTarGz), "Archive format: tar, tar.gz or zip")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "File to write the archive to, or - for stdout (required)")
+	cmd.Flags().BoolVar(&encrypt, "encrypt", false, "Encrypt the archive with a passphrase")
+	cmd.Flags().StringVar(&passphraseFile, "passphrase-file", "", "Read the encryption passphrase from this file (implies --encrypt)")
+
+	return cmd
+}
+
+type exportOptions struct {
+	backupName     string
+	format         string
+	output         string // File path, or - for stdout
+	encrypt        bool
+	passphraseFile string
+}
+
+func runExport(cfg *config.Config, opts exportOptions) error {
+	log.Info().
+		Str("backup_name", opts.backupName).
+		Str("format", opts.format).
+		Str("output", opts.output).
+		Bool("encrypt", opts.encrypt).
+		Msg("Starting export")
+
+	format, err := archive.ParseFormat(opts.format)
+	if err != nil {
+		return err
+	}
+	if opts.output == "" {
+		return fmt.Errorf("--output is required (use - for stdout)")
+	}
+
+	var passphrase string
+	if opts.encrypt {
+		if passphrase, err = archivePassphrase(opts.passphraseFile, true); err != nil {
+			return err
+		}
+	}
+
+	// Create storage provider via factory
+	factory := storage.NewFactory()
+	storageProvider, err := factory.CreateStorage(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create storage provider: %w", err)
+	}
+	defer storageProvider.Close()
+
+	ctx := context.Background()
+	if err := storageProvider.TestConnection(ctx); err != nil {
+		return fmt.Errorf("storage connection test failed: %w", err)
+	}
+
+	backupName := opts.backupName
+	if backupName == "" {
+		backupName, err = getLatestBackupName(ctx, storageProvider)
+		if err != nil {
+			return fmt.Errorf("failed to find latest backup: %w", err)
+		}
+		// stdout may be carrying the archive
+		fmt.Fprintf(os.Stderr, "Using most recent backup: %s\n", backupName)
+	}
+
+	backupData, err := loadBackup(ctx, storageProvider, backupName)
+	if err != nil {
+		return err
+	}
+
+	data, err := exportArchive(backupData, format, passphrase)
+	if err != nil {
+		return err
+	}
+
+	if opts.output == "-" {
+		if _, err := os.Stdout.Write(data); err != nil {
+			return fmt.Errorf("failed to write archive: %w", err)
+		}
+	} else if err := utils.WriteFileAtomic(opts.output, data, 0600); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	encryption := ""
+	if passphrase != "" {
+		encryption = ", encrypted"
+	}
+	fmt.Fprintf(os.Stderr, "✓ Exported %d files of backup '%s' to %s (%s%s)\n",
+		len(backupData.Files), backupName, opts.output, format, encryption)
+	return nil
+}
+
+// exportArchive builds the archive of a backup, encrypted when a passphrase is given
+func exportArchive(backupData *ssh.BackupData, format archive.Format, passphrase string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := archive.Write(&buf, format, backupData); err != nil {
+		return nil, fmt.Errorf("failed to build archive: %w", err)
+	}
+	if passphrase == "" {
+		return buf.Bytes(), nil
+	}
+	data, err := archive.Encrypt(buf.Bytes(), passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt archive: %w", err)
+	}
+	return data, nil
+}
+
+// archivePassphrase reads the passphrase of an encrypted archive from
+// passphraseFile, or prompts for it, twice when confirm is set
+func archivePassphrase(passphraseFile string, confirm bool) (string, error) {
+	if passphraseFile != "" {
+		content, err := os.ReadFile(passphraseFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read passphrase file: %w", err)
+		}
+		passphrase := strings.TrimRight(string(content), "\r\n")
+		if passphrase == "" {
+			return "", fmt.Errorf("passphrase file %s is empty", passphraseFile)
+		}
+		return passphrase, nil
+	}
+
+	passphrase, err := promptPassphrase("Archive passphrase: ")
+	if err != nil {
+		return "", fmt.Errorf("cannot prompt for the archive passphrase: %w", err)
+	}
+	if len(passphrase) == 0 {
+		return "", fmt.Errorf("no passphrase entered")
+	}
+	if confirm {
+		again, err := promptPassphrase("Repeat passphrase: ")
+		if err != nil {
+			return "", fmt.Errorf("cannot prompt for the archive passphrase: %w", err)
+		}
+		if !bytes.Equal(passphrase, again) {
+			return "", fmt.Errorf("passphrases do not match")
+		}
+	}
+	return string(passphrase), nil
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rzago/ssh-secret-keeper/internal/archive"
+	"github.com/rzago/ssh-secret-keeper/internal/config"
+	"github.com/rzago/ssh-secret-keeper/internal/ssh"
+	"github.com/spf13/cobra"
+)
+
+func TestNewExportAndImportCommands(t *testing.T) {
+	cfg := config.Default()
+	tests := []struct {
+		cmd   *cobra.Command
+		use   string
+		flags []string
+	}{
+		{newExportCommand(cfg), "export [backup-name]", []string{"format", "output", "encrypt", "passphrase-file"}},
+		{newImportCommand(cfg), "import <archive>", []string{"name", "dry-run", "overwrite", "passphrase-file", "description", "tag"}},
+	}
+	for _, tt := range tests {
+		if tt.cmd.Use != tt.use {
+			t.Errorf("Use = %q, want %q", tt.cmd.Use, tt.use)
+		}
+		for _, flag := range tt.flags {
+			if tt.cmd.Flags().Lookup(flag) == nil {
+				t.Errorf("%s: missing --%s flag", tt.cmd.Name(), flag)
+			}
+		}
+	}
+}
+
+func TestExportImport_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Default()
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "config.d"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"config":        "Host *\n  Include config.d/*\n",
+		"config.d/work": "Host work\n  User deploy\n",
+		"known_hosts":   "github.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	original, err := ssh.New().ReadDirectory(dir)
+	if err != nil {
+		t.Fatalf("ReadDirectory() error = %v", err)
+	}
+
+	passphraseFile := filepath.Join(t.TempDir(), "passphrase")
+	if err := os.WriteFile(passphraseFile, []byte("correct horse\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	passphrase, err := archivePassphrase(passphraseFile, true)
+	if err != nil || passphrase != "correct horse" {
+		t.Fatalf("archivePassphrase() = %q, %v", passphrase, err)
+	}
+
+	data, err := exportArchive(original, archive.FormatZip, passphrase)
+	if err != nil {
+		t.Fatalf("exportArchive() error = %v", err)
+	}
+	archivePath := filepath.Join(t.TempDir(), "ssh.zip")
+	if err := os.WriteFile(archivePath, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	imported, err := readArchiveBackup(cfg, importOptions{archivePath: archivePath, name: "imported", passphraseFile: passphraseFile})
+	if err != nil {
+		t.Fatalf("readArchiveBackup() error = %v", err)
+	}
+	if imported.Owner != nil || imported.Metadata["imported_from"] != "ssh.zip" {
+		t.Errorf("owner = %+v, imported_from = %v", imported.Owner, imported.Metadata["imported_from"])
+	}
+
+	provider := newMemoryStorage()
+	if _, err := storeBackup(ctx, cfg, provider, "imported", imported); err != nil {
+		t.Fatalf("storeBackup() error = %v", err)
+	}
+	restored, err := loadBackup(ctx, provider, "imported")
+	if err != nil {
+		t.Fatalf("loadBackup() error = %v", err)
+	}
+
+	for _, name := range original.FileNames() {
+		got, ok := restored.Files[name]
+		if !ok {
+			t.Errorf("%s missing from the imported backup", name)
+			continue
+		}
+		if string(got.Content) != string(original.Files[name].Content) || got.Permissions.Perm() != original.Files[name].Permissions.Perm() {
+			t.Errorf("%s = %q (%04o), want %q (%04o)", name, got.Content, got.Permissions.Perm(),
+				original.Files[name].Content, original.Files[name].Permissions.Perm())
+		}
+	}
+	if restored.Directories["config.d"] != 0750 {
+		t.Errorf("config.d mode = %04o, want 0750", restored.Directories["config.d"])
+	}
+}
+
+func TestArchivePassphrase_Prompt(t *testing.T) {
+	original := promptPassphrase
+	defer func() { promptPassphrase = original }()
+
+	answers := []string{"first one", "second one"}
+	promptPassphrase = func(string) ([]byte, error) {
+		answer := answers[0]
+		answers = answers[1:]
+		return []byte(answer), nil
+	}
+	if _, err := archivePassphrase("", true); err == nil {
+		t.Error("expected an error when the passphrases differ")
+	}
+
+	promptPassphrase = func(string) ([]byte, error) { return []byte("same"), nil }
+	if passphrase, err := archivePassphrase("", true); err != nil || passphrase != "same" {
+		t.Errorf("archivePassphrase() = %q, %v", passphrase, err)
+	}
+}
@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/rzago/ssh-secret-keeper/internal/archive"
+	"github.com/rzago/ssh-secret-keeper/internal/config"
+	"github.com/rzago/ssh-secret-keeper/internal/naming"
+	"github.com/rzago/ssh-secret-keeper/internal/ssh"
+	"github.com/rzago/ssh-secret-keeper/internal/storage"
+	"github.com/rzago/ssh-secret-keeper/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// newImportCommand creates the import command
+func newImportCommand(cfg *config.Config) *cobra.Command {
+	var (
+		name           string
+		dryRun         bool
+		overwrite      bool
+		passphraseFile string
+		description    string
+		tags           []string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "import <archive>",
+		Short: "Create a backup from a tar, tar.gz or zip archive",
+		Long: `Create a backup from an archive of an SSH directory, such as one written by
+'sshsk export' or 'tar -C ~/.ssh -czf ssh.tar.gz .', without needing a real
+~/.ssh. The format is detected from the content; use - to read from stdin.
+
+The archive is unpacked into a private temporary directory and analyzed like
+a backup of ~/.ssh, so backup.include_patterns and backup.exclude_patterns
+apply. Paths escaping the archive are refused. Encrypted exports are
+recognised and their passphrase is prompted for or read from --passphrase-file.
+
+  sshsk import ssh.tar.gz --name laptop-import --tag migrated`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runImport(cfg, importOptions{
+				archivePath:    args[0],
+				name:           name,
+				dryRun:         dryRun,
+				overwrite:      overwrite,
+				passphraseFile: passphraseFile,
+				description:    description,
+				tags:           tags,
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&name, "name", "", "Name of the backup to create (required)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be imported without storing it")
+	cmd.Flags().BoolVar(&overwrite, "overwrite", false, "Replace an existing backup with the same name")
+	cmd.Flags().StringVar(&passphraseFile, "passphrase-file", "", "Read the passphrase of an encrypted archive from this file")
+	cmd.Flags().StringVar(&description, "description", "", "Free-text description stored with the backup")
+	cmd.Flags().StringSliceVar(&tags, "tag", []string{}, "Tag the backup (repeatable)")
+
+	return cmd
+}
+
+type importOptions struct {
+	archivePath    string // File path, or - for stdin
+	name           string
+	dryRun         bool
+	overwrite      bool
+	passphraseFile string
+	description    string
+	tags           []string
+}
+
+func runImport(cfg *config.Config, opts importOptions) error {
+	log.Info().
+		Str("archive", opts.archivePath).
+		Str("backup_name", opts.name).
+		Bool("dry_run", opts.dryRun).
+		Msg("Starting import")
+
+	if opts.name == "" {
+		return fmt.Errorf("--name is required")
+	}
+	if err := naming.ValidateName(opts.name); err != nil {
+		return err
+	}
+	tags, err := normalizeTags(opts.tags)
+	if err != nil {
+		return err
+	}
+
+	backupData, err := readArchiveBackup(cfg, opts)
+	if err != nil {
+		return err
+	}
+	backupData.Tags = tags
+	backupData.Description = strings.TrimSpace(opts.description)
+
+	displayBackupSummary(backupData)
+
+	if opts.dryRun {
+		fmt.Printf("\n[DRY RUN] Backup '%s' would include %d files\n", opts.name, len(backupData.Files))
+		return nil
+	}
+	backupData.Metadata["fingerprint"] = backupData.Fingerprint()
+
+	// Create storage provider via factory
+	factory := storage.NewFactory()
+	storageProvider, err := factory.CreateStorage(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create storage provider: %w", err)
+	}
+	defer storageProvider.Close()
+
+	ctx := context.Background()
+	fmt.Printf("Connecting to %s storage...\n", storageProvider.GetProviderType())
+	if err := storageProvider.TestConnection(ctx); err != nil {
+		return fmt.Errorf("storage connection test failed: %w", err)
+	}
+
+	name, err := resolveBackupName(ctx, storageProvider, opts.name, opts.overwrite, nil, naming.Vars{})
+	if err != nil {
+		return err
+	}
+	if _, err := storeBackup(ctx, cfg, storageProvider, name, backupData); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Imported %d files from %s as backup '%s'\n", len(backupData.Files), opts.archivePath, name)
+	return nil
+}
+
+// readArchiveBackup unpacks an archive into a private temporary directory and
+// reads it like an SSH directory. The backup records the configured SSH
+// directory, since the temporary one is gone by the time it is restored.
+func readArchiveBackup(cfg *config.Config, opts importOptions) (*ssh.BackupData, error) {
+	var data []byte
+	var err error
+	if opts.archivePath == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(opts.archivePath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	if archive.IsEncrypted(data) {
+		passphrase, err := archivePassphrase(opts.passphraseFile, false)
+		if err != nil {
+			return nil, err
+		}
+		if data, err = archive.Decrypt(data, passphrase); err != nil {
+			return nil, err
+		}
+	}
+
+	tmpDir, err := os.MkdirTemp("", "sshsk-import-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create import directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	count, err := archive.Extract(data, tmpDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpack archive: %w", err)
+	}
+	log.Info().Int("files", count).Str("dir", tmpDir).Msg("Archive unpacked")
+
+	fileFilter, err := buildFileFilter(cfg, tmpDir, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	sshHandler := ssh.New()
+	sshHandler.SetFilter(fileFilter)
+
+	fmt.Printf("Analyzing archive: %s\n", opts.archivePath)
+	backupData, err := sshHandler.ReadDirectory(tmpDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive contents: %w", err)
+	}
+
+	pathNormalizer := utils.NewPathNormalizer()
+	if sshDir, err := pathNormalizer.ResolvePath(cfg.Backup.SSHDir); err == nil {
+		backupData.SSHDir = sshDir
+	}
+	if normalized, err := pathNormalizer.NormalizePath(backupData.SSHDir); err == nil {
+		backupData.SSHDirNorm = normalized
+		backupData.Metadata["normalized_path"] = normalized
+	}
+	// An archive says nothing about who owned the files
+	backupData.Owner = nil
+	backupData.Metadata["imported_from"] = filepath.Base(opts.archivePath)
+	return backupData, nil
+}
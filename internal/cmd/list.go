@@ -22,17 +22,26 @@ func newListCommand(cfg *config.Config) *cobra.Command {
 	var (
 		outputJSON bool
 		detailed   bool
+		tags       []string
+		selectors  []string
 	)
 
 	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "List available backups in Vault",
 		Long: `List all SSH backups stored in Vault with their metadata.
-Shows backup names, timestamps, file counts, and other useful information.`,
+Shows backup names, timestamps, file counts, and other useful information.
+
+Filter with --tag and --selector key=value (name, hostname, username, tag);
+values may be glob patterns and all filters must match:
+
+  sshsk list --tag laptop --selector hostname='web-*'`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return runList(cfg, listOptions{
 				outputJSON: outputJSON,
 				detailed:   detailed,
+				tags:       tags,
+				selectors:  selectors,
 			})
 		},
 	}
@@ -40,6 +49,7 @@ Shows backup names, timestamps, file counts, and other useful information.`,
 	// Command-specific flags
 	cmd.Flags().BoolVar(&outputJSON, "json", false, "Output results in JSON format")
 	cmd.Flags().BoolVar(&detailed, "detailed", false, "Show detailed information about each backup")
+	addSelectorFlags(cmd, &tags, &selectors)
 
 	return cmd
 }
@@ -47,23 +57,34 @@ Shows backup names, timestamps, file counts, and other useful information.`,
 type listOptions struct {
 	outputJSON bool
 	detailed   bool
+	tags       []string
+	selectors  []string
 }
 
 type backupInfo struct {
-	Name      string    `json:"name"`
-	Timestamp time.Time `json:"timestamp"`
-	FileCount int       `json:"file_count"`
-	TotalSize int64     `json:"total_size"`
-	Hostname  string    `json:"hostname"`
-	Username  string    `json:"username"`
+	Name        string    `json:"name"`
+	Timestamp   time.Time `json:"timestamp"`
+	FileCount   int       `json:"file_count"`
+	TotalSize   int64     `json:"total_size"`
+	Hostname    string    `json:"hostname"`
+	Username    string    `json:"username"`
+	Tags        []string  `json:"tags,omitempty"`
+	Description string    `json:"description,omitempty"`
 }
 
 func runList(cfg *config.Config, opts listOptions) error {
 	log.Info().
 		Bool("json_output", opts.outputJSON).
 		Bool("detailed", opts.detailed).
+		Strs("tags", opts.tags).
+		Strs("selectors", opts.selectors).
 		Msg("Listing backups")
 
+	selector, err := newBackupSelector(opts.tags, opts.selectors)
+	if err != nil {
+		return err
+	}
+
 	// Create storage provider via factory
 	factory := storage.NewFactory()
 	storageProvider, err := factory.CreateStorage(cfg)
@@ -88,49 +109,25 @@ func runList(cfg *config.Config, opts listOptions) error {
 		return nil
 	}
 
-	// Get detailed information if requested
+	// Get detailed information if requested or needed for filtering
 	var backups []backupInfo
-	if opts.detailed {
-		metadata, err := storageProvider.GetMetadata(ctx)
+	if opts.detailed || !selector.IsEmpty() {
+		// Sorted by timestamp (most recent first)
+		backups, err = selectBackups(ctx, storageProvider, selector)
 		if err != nil {
-			log.Warn().Err(err).Msg("Failed to get metadata, using basic info")
+			return err
 		}
 
-		for _, name := range backupNames {
-			backup := backupInfo{Name: name}
-
-			// Try to get metadata for this backup
-			if metadata != nil {
-				if backupsData, ok := metadata["backups"].(map[string]interface{}); ok {
-					if backupData, ok := backupsData[name].(map[string]interface{}); ok {
-						if timestampStr, ok := backupData["timestamp"].(string); ok {
-							if timestamp, err := time.Parse(time.RFC3339, timestampStr); err == nil {
-								backup.Timestamp = timestamp
-							}
-						}
-						if fileCount, ok := backupData["file_count"].(float64); ok {
-							backup.FileCount = int(fileCount)
-						}
-						if totalSize, ok := backupData["total_size"].(float64); ok {
-							backup.TotalSize = int64(totalSize)
-						}
-						if hostname, ok := backupData["hostname"].(string); ok {
-							backup.Hostname = hostname
-						}
-						if username, ok := backupData["username"].(string); ok {
-							backup.Username = username
-						}
-					}
-				}
-			}
-
-			backups = append(backups, backup)
+		if !opts.detailed {
+			sort.Slice(backups, func(i, j int) bool {
+				return backups[i].Name < backups[j].Name
+			})
 		}
 
-		// Sort by timestamp (most recent first)
-		sort.Slice(backups, func(i, j int) bool {
-			return backups[i].Timestamp.After(backups[j].Timestamp)
-		})
+		if len(backups) == 0 && !selector.IsEmpty() && !opts.outputJSON {
+			fmt.Printf("No backups match %s\n", selector)
+			return nil
+		}
 	} else {
 		// Basic listing
 		for _, name := range backupNames {
@@ -150,6 +147,80 @@ func runList(cfg *config.Config, opts listOptions) error {
 	return outputHumanList(backups, opts.detailed)
 }
 
+// backupInfosFromMetadata builds backup information for the given names from the
+// metadata index. Backups missing from the index only carry their name.
+func backupInfosFromMetadata(metadata map[string]interface{}, names []string) []backupInfo {
+	index, _ := metadata["backups"].(map[string]interface{})
+
+	backups := make([]backupInfo, 0, len(names))
+	for _, name := range names {
+		backup := backupInfo{Name: name}
+
+		if entry, ok := index[name].(map[string]interface{}); ok {
+			if timestampStr, ok := entry["timestamp"].(string); ok {
+				if timestamp, err := time.Parse(time.RFC3339, timestampStr); err == nil {
+					backup.Timestamp = timestamp
+				}
+			}
+			if fileCount, ok := metadataNumber(entry["file_count"]); ok {
+				backup.FileCount = int(fileCount)
+			}
+			if totalSize, ok := metadataNumber(entry["total_size"]); ok {
+				backup.TotalSize = totalSize
+			}
+			if hostname, ok := entry["hostname"].(string); ok {
+				backup.Hostname = hostname
+			}
+			if username, ok := entry["username"].(string); ok {
+				backup.Username = username
+			}
+			backup.Tags = metadataStrings(entry["tags"])
+			if description, ok := entry["description"].(string); ok {
+				backup.Description = description
+			}
+		}
+
+		backups = append(backups, backup)
+	}
+
+	return backups
+}
+
+// metadataNumber converts a numeric index value; Vault returns json.Number
+func metadataNumber(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		n, err := v.Int64()
+		return n, err == nil
+	case float64:
+		return int64(v), true
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// metadataStrings converts a string list from the index; Vault returns []interface{}
+func metadataStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
 // outputJSONList outputs the backup list in JSON format
 func outputJSONList(backups []backupInfo) error {
 	encoder := json.NewEncoder(os.Stdout)
@@ -198,6 +269,14 @@ func outputHumanList(backups []backupInfo, detailed bool) error {
 			fmt.Printf("   💻 Source: %s@%s\n", backup.Username, backup.Hostname)
 		}
 
+		if len(backup.Tags) > 0 {
+			fmt.Printf("   🏷️  Tags: %s\n", strings.Join(backup.Tags, ", "))
+		}
+
+		if backup.Description != "" {
+			fmt.Printf("   📝 %s\n", backup.Description)
+		}
+
 		if i < len(backups)-1 {
 			fmt.Printf("\n")
 		}
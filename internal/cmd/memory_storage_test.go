@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/rzago/ssh-secret-keeper/internal/interfaces"
+)
+
+// memoryStorage is an in-memory StorageProvider used by command tests.
+// Reads return JSON round-tripped copies so values look like Vault responses.
+type memoryStorage struct {
+	backups  map[string]map[string]interface{}
+	blobs    map[string]map[string]interface{}
+	metadata map[string]interface{}
+
+	// softDeletes makes DeleteBackup behave like deleting a KV v2 data path:
+	// the name stays listed but has no data to read
+	softDeletes bool
+	deleted     map[string]bool
+}
+
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{
+		backups:  make(map[string]map[string]interface{}),
+		blobs:    make(map[string]map[string]interface{}),
+		metadata: make(map[string]interface{}),
+		deleted:  make(map[string]bool),
+	}
+}
+
+func (m *memoryStorage) TestConnection(ctx context.Context) error { return nil }
+func (m *memoryStorage) Close() error                             { return nil }
+
+func (m *memoryStorage) StoreBackup(ctx context.Context, backupName string, data map[string]interface{}) error {
+	m.backups[backupName] = data
+	delete(m.deleted, backupName)
+	return nil
+}
+
+func (m *memoryStorage) GetBackup(ctx context.Context, backupName string) (map[string]interface{}, error) {
+	data, ok := m.backups[backupName]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", interfaces.ErrBackupNotFound, backupName)
+	}
+	return roundTrip(data), nil
+}
+
+func (m *memoryStorage) ListBackups(ctx context.Context) ([]string, error) {
+	names := make([]string, 0, len(m.backups)+len(m.deleted))
+	for name := range m.backups {
+		names = append(names, name)
+	}
+	for name := range m.deleted {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (m *memoryStorage) DeleteBackup(ctx context.Context, backupName string) error {
+	if _, ok := m.backups[backupName]; ok && m.softDeletes {
+		m.deleted[backupName] = true
+	}
+	delete(m.backups, backupName)
+	return nil
+}
+
+func (m *memoryStorage) StoreBlob(ctx context.Context, hash string, data map[string]interface{}) error {
+	m.blobs[hash] = data
+	return nil
+}
+
+func (m *memoryStorage) GetBlob(ctx context.Context, hash string) (map[string]interface{}, error) {
+	data, ok := m.blobs[hash]
+	if !ok {
+		return nil, fmt.Errorf("blob %s not found", hash)
+	}
+	return roundTrip(data), nil
+}
+
+func (m *memoryStorage) ListBlobs(ctx context.Context) ([]string, error) {
+	hashes := make([]string, 0, len(m.blobs))
+	for hash := range m.blobs {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	return hashes, nil
+}
+
+func (m *memoryStorage) DeleteBlob(ctx context.Context, hash string) error {
+	delete(m.blobs, hash)
+	return nil
+}
+
+func (m *memoryStorage) StoreMetadata(ctx context.Context, metadata map[string]interface{}) error {
+	m.metadata = metadata
+	return nil
+}
+
+func (m *memoryStorage) GetMetadata(ctx context.Context) (map[string]interface{}, error) {
+	return roundTrip(m.metadata), nil
+}
+
+func (m *memoryStorage) UpdateMetadata(ctx context.Context, update func(metadata map[string]interface{}) error) error {
+	metadata := roundTrip(m.metadata)
+	if err := update(metadata); err != nil {
+		return err
+	}
+	m.metadata = metadata
+	return nil
+}
+
+func (m *memoryStorage) GetProviderType() string { return "memory" }
+func (m *memoryStorage) GetBasePath() string     { return "shared" }
+
+// roundTrip copies data through JSON, decoding numbers as json.Number like the Vault client
+func roundTrip(data map[string]interface{}) map[string]interface{} {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		panic(err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+
+	result := make(map[string]interface{})
+	if err := decoder.Decode(&result); err != nil {
+		panic(err)
+	}
+	return result
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/rzago/ssh-secret-keeper/internal/ssh"
+)
+
+// lookupOwner resolves a user[:group] spec, by name or numeric id, to the owner
+// of restored files and that user's home directory. Without a group, the
+// user's primary group is used.
+func lookupOwner(spec string) (*ssh.Owner, string, error) {
+	userSpec, groupSpec, hasGroup := strings.Cut(spec, ":")
+	if userSpec == "" || (hasGroup && groupSpec == "") {
+		return nil, "", fmt.Errorf("invalid owner %q: expected user[:group]", spec)
+	}
+
+	account, err := lookupUser(userSpec)
+	if err != nil {
+		return nil, "", fmt.Errorf("unknown user %q: %w", userSpec, err)
+	}
+	uid, err := strconv.Atoi(account.Uid)
+	if err != nil {
+		return nil, "", fmt.Errorf("user %s has a non-numeric uid %q", account.Username, account.Uid)
+	}
+
+	gidValue := account.Gid
+	if hasGroup {
+		group, err := lookupGroup(groupSpec)
+		if err != nil {
+			return nil, "", fmt.Errorf("unknown group %q: %w", groupSpec, err)
+		}
+		gidValue = group.Gid
+	}
+	gid, err := strconv.Atoi(gidValue)
+	if err != nil {
+		return nil, "", fmt.Errorf("group of %s has a non-numeric gid %q", account.Username, gidValue)
+	}
+
+	return &ssh.Owner{User: account.Username, UID: uid, GID: gid}, account.HomeDir, nil
+}
+
+// lookupUser looks up a user by uid when spec is numeric, by name otherwise
+func lookupUser(spec string) (*user.User, error) {
+	if _, err := strconv.Atoi(spec); err == nil {
+		return user.LookupId(spec)
+	}
+	return user.Lookup(spec)
+}
+
+// lookupGroup looks up a group by gid when spec is numeric, by name otherwise
+func lookupGroup(spec string) (*user.Group, error) {
+	if _, err := strconv.Atoi(spec); err == nil {
+		return user.LookupGroupId(spec)
+	}
+	return user.LookupGroup(spec)
+}
+
+// resolveRestoreOwner decides who restored files belong to: the --owner
+// account or, when root restores without one, the account recorded in the
+// backup, such as one taken with backup --all-users. A nil owner leaves files
+// to the restoring user.
+func resolveRestoreOwner(spec string, backup *ssh.BackupData) (*ssh.Owner, string, error) {
+	if spec != "" {
+		owner, homeDir, err := lookupOwner(spec)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid --owner: %w", err)
+		}
+		return owner, homeDir, nil
+	}
+
+	if geteuid() != 0 || backup.Owner == nil || backup.Owner.User == "" {
+		return nil, "", nil
+	}
+	owner, homeDir, err := lookupOwner(backup.Owner.User)
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Str("recorded_owner", backup.Owner.User).
+			Msg("Recorded owner of the backup does not exist here, restoring as root")
+		return nil, "", nil
+	}
+	return owner, homeDir, nil
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/rzago/ssh-secret-keeper/internal/ssh"
+)
+
+func TestLookupOwner(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{"root", false},
+		{"0", false},
+		{"root:0", false},
+		{"0:root", false},
+		{"", true},
+		{":root", true},
+		{"root:", true},
+		{"no-such-user-sshsk", true},
+		{"root:no-such-group-sshsk", true},
+	}
+
+	for _, tt := range tests {
+		owner, homeDir, err := lookupOwner(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("lookupOwner(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (owner.User != "root" || owner.UID != 0 || owner.GID != 0 || homeDir == "") {
+			t.Errorf("lookupOwner(%q) = %+v, %q", tt.spec, owner, homeDir)
+		}
+	}
+}
+
+func TestResolveRestoreOwner(t *testing.T) {
+	defer func(original func() int) { geteuid = original }(geteuid)
+
+	recorded := &ssh.BackupData{Owner: &ssh.Owner{User: "root", UID: 0, GID: 0}}
+	unknown := &ssh.BackupData{Owner: &ssh.Owner{User: "no-such-user-sshsk", UID: 4242, GID: 4242}}
+
+	// Other users keep their own ownership unless they ask for one
+	geteuid = func() int { return 1000 }
+	if owner, _, err := resolveRestoreOwner("", recorded); err != nil || owner != nil {
+		t.Errorf("non-root restore = %+v, %v, want no owner", owner, err)
+	}
+
+	// Root defaults to the recorded owner
+	geteuid = func() int { return 0 }
+	owner, homeDir, err := resolveRestoreOwner("", recorded)
+	if err != nil || owner == nil || owner.User != "root" || homeDir == "" {
+		t.Errorf("root restore = %+v, %q, %v, want the recorded owner", owner, homeDir, err)
+	}
+
+	// An owner that does not exist here falls back to root's ownership
+	if owner, _, err := resolveRestoreOwner("", unknown); err != nil || owner != nil {
+		t.Errorf("unknown recorded owner = %+v, %v, want no owner", owner, err)
+	}
+
+	// --owner wins, and must exist
+	if _, _, err := resolveRestoreOwner("no-such-user-sshsk", recorded); err == nil {
+		t.Error("expected an error for an unknown --owner")
+	}
+}
@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/rzago/ssh-secret-keeper/internal/config"
+	"github.com/rzago/ssh-secret-keeper/internal/vault"
+	"github.com/spf13/cobra"
+)
+
+// newPolicyCommand creates the policy command with its subcommands
+func newPolicyCommand(cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "policy",
+		Short: "Generate and check least-privilege Vault policies",
+		Long: `Generate Vault ACL policies granting only the paths and capabilities
+SSH Secret Keeper needs for a storage strategy, and check whether the current
+token holds them.`,
+	}
+
+	cmd.AddCommand(
+		newPolicyGenerateCommand(cfg),
+		newPolicyCheckCommand(cfg),
+	)
+
+	return cmd
+}
+
+type policyOptions struct {
+	strategy  string
+	mountPath string
+	username  string
+	hostname  string
+	readOnly  bool
+	output    string
+}
+
+// newPolicyGenerateCommand creates the policy generate command
+func newPolicyGenerateCommand(cfg *config.Config) *cobra.Command {
+	var opts policyOptions
+
+	cmd := &cobra.Command{
+		Use:   "generate",
+		Short: "Print an HCL policy for a storage strategy",
+		Long: `Print a least-privilege HCL policy for a storage strategy.
+
+For the user strategy the policy uses the {{identity.entity.name}} template
+unless --user is given, so a single policy can be attached to every user.
+
+Examples:
+  # Policy for the configured strategy
+  sshsk policy generate
+
+  # Policy for host key backups taken with --system
+  sshsk policy generate --strategy system --hostname web-01
+
+  # Read-only policy for restore-only machines
+  sshsk policy generate --strategy universal --read-only
+
+  # Write the policy and load it into Vault
+  sshsk policy generate --strategy user -o sshsk.hcl
+  vault policy write sshsk sshsk.hcl`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPolicyGenerate(cfg, opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.strategy, "strategy", cfg.Vault.StorageStrategy, "Storage strategy (universal, user, machine-user, custom, or system for --system backups)")
+	cmd.Flags().StringVar(&opts.mountPath, "mount-path", cfg.Vault.MountPath, "Vault mount path for SSH backups")
+	cmd.Flags().StringVar(&opts.username, "user", "", "Username for user/machine-user strategies (default: identity template or current user)")
+	cmd.Flags().StringVar(&opts.hostname, "hostname", "", "Hostname for the machine-user and system strategies (default: current hostname)")
+	cmd.Flags().BoolVar(&opts.readOnly, "read-only", false, "Grant only read and list capabilities")
+	cmd.Flags().StringVarP(&opts.output, "output", "o", "", "Write the policy to a file instead of stdout")
+
+	return cmd
+}
+
+// newPolicyCheckCommand creates the policy check command
+func newPolicyCheckCommand(cfg *config.Config) *cobra.Command {
+	var readOnly bool
+
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Check the current token's capabilities against the required policy",
+		Long: `Query sys/capabilities-self for every path SSH Secret Keeper uses with the
+configured storage strategy and report missing capabilities.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPolicyCheck(cfg, readOnly)
+		},
+	}
+
+	cmd.Flags().BoolVar(&readOnly, "read-only", false, "Only require read and list capabilities")
+
+	return cmd
+}
+
+func runPolicyGenerate(cfg *config.Config, opts policyOptions) error {
+	// The system strategy cannot be configured, but its policy can be generated
+	strategy := vault.StrategySystem
+	if opts.strategy != string(vault.StrategySystem) {
+		var err error
+		strategy, err = vault.ParseStrategy(opts.strategy)
+		if err != nil {
+			return err
+		}
+	}
+
+	policy, err := vault.GeneratePolicy(vault.PolicyOptions{
+		Strategy:     strategy,
+		MountPath:    opts.mountPath,
+		CustomPrefix: cfg.Vault.CustomPrefix,
+		Namespace:    cfg.Vault.BackupNamespace,
+		Username:     opts.username,
+		Hostname:     opts.hostname,
+		ReadOnly:     opts.readOnly,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to generate policy: %w", err)
+	}
+
+	if opts.output == "" {
+		fmt.Print(policy)
+		return nil
+	}
+
+	if err := os.WriteFile(opts.output, []byte(policy), 0644); err != nil {
+		return fmt.Errorf("failed to write policy file: %w", err)
+	}
+
+	fmt.Printf("✓ Policy written to %s\n", opts.output)
+	return nil
+}
+
+func runPolicyCheck(cfg *config.Config, readOnly bool) error {
+	log.Info().
+		Str("strategy", cfg.Vault.StorageStrategy).
+		Bool("read_only", readOnly).
+		Msg("Checking Vault token capabilities")
+
+	strategy, err := vault.ParseStrategy(cfg.Vault.StorageStrategy)
+	if err != nil {
+		return err
+	}
+
+	// Check the concrete paths this machine uses, never the identity template
+	pathGenerator := vault.NewPathGenerator(strategy, cfg.Vault.CustomPrefix, cfg.Vault.BackupNamespace)
+	if err := pathGenerator.ValidateStrategy(); err != nil {
+		return fmt.Errorf("invalid path strategy configuration: %w", err)
+	}
+	basePath, err := pathGenerator.GenerateBasePath()
+	if err != nil {
+		return fmt.Errorf("failed to generate base path: %w", err)
+	}
+
+	checker, err := vault.NewPolicyChecker(&cfg.Vault)
+	if err != nil {
+		return err
+	}
+	defer checker.Close()
+
+	checks, err := checker.Check(context.Background(), vault.PolicyRules(cfg.Vault.MountPath, basePath, readOnly))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("🔐 Vault Token Capabilities\n")
+	fmt.Printf("═══════════════════════════\n")
+	fmt.Printf("Strategy: %s\n", pathGenerator.GetStrategyDescription())
+	fmt.Printf("Base path: %s/%s\n\n", cfg.Vault.MountPath, basePath)
+
+	failed := 0
+	for _, check := range checks {
+		if check.OK() {
+			fmt.Printf("✅ %s\n", check.Rule.Path)
+		} else {
+			failed++
+			fmt.Printf("❌ %s\n", check.Rule.Path)
+			fmt.Printf("   Missing: %s\n", strings.Join(check.Missing, ", "))
+		}
+		fmt.Printf("   %s (granted: %s)\n", check.Rule.Description, strings.Join(check.Granted, ", "))
+	}
+
+	if failed > 0 {
+		fmt.Printf("\n💡 Generate a matching policy with:\n")
+		fmt.Printf("   sshsk policy generate --strategy %s", strategy)
+		if readOnly {
+			fmt.Printf(" --read-only")
+		}
+		fmt.Printf("\n")
+		return fmt.Errorf("token is missing capabilities on %d path(s)", failed)
+	}
+
+	fmt.Printf("\n✓ Token has all required capabilities\n")
+	return nil
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/rzago/ssh-secret-keeper/internal/config"
+)
+
+func TestNewPolicyCommand(t *testing.T) {
+	cfg := config.Default()
+	cmd := newPolicyCommand(cfg)
+
+	subcommands := map[string][]string{
+		"generate": {"strategy", "mount-path", "user", "hostname", "read-only", "output"},
+		"check":    {"read-only"},
+	}
+
+	for name, flags := range subcommands {
+		sub, _, err := cmd.Find([]string{name})
+		if err != nil || sub.Name() != name {
+			t.Fatalf("Expected policy subcommand '%s'", name)
+		}
+		for _, flag := range flags {
+			if sub.Flag(flag) == nil {
+				t.Errorf("Expected --%s flag on 'policy %s'", flag, name)
+			}
+		}
+	}
+}
+
+func TestPolicyGenerate_DefaultsFromConfig(t *testing.T) {
+	cfg := config.Default()
+	cfg.Vault.StorageStrategy = "user"
+	cfg.Vault.MountPath = "team-kv"
+	cmd := newPolicyGenerateCommand(cfg)
+
+	if got := cmd.Flag("strategy").DefValue; got != "user" {
+		t.Errorf("--strategy default = %q, want %q", got, "user")
+	}
+	if got := cmd.Flag("mount-path").DefValue; got != "team-kv" {
+		t.Errorf("--mount-path default = %q, want %q", got, "team-kv")
+	}
+}
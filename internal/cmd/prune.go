@@ -0,0 +1,364 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/rzago/ssh-secret-keeper/internal/blobstore"
+	"github.com/rzago/ssh-secret-keeper/internal/config"
+	"github.com/rzago/ssh-secret-keeper/internal/interfaces"
+	"github.com/rzago/ssh-secret-keeper/internal/retention"
+	"github.com/rzago/ssh-secret-keeper/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// newPruneCommand creates the prune command
+func newPruneCommand(cfg *config.Config) *cobra.Command {
+	var opts pruneOptions
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete backups not covered by the retention policy",
+		Long: `Delete backups that are not kept by the retention policy.
+
+Backups are grouped by the hostname they were taken on and each group is
+evaluated on its own, newest first. A backup is kept when any rule selects it:
+
+  --keep-last N     the N most recent backups
+  --keep-daily N    the newest backup of each of the last N days with backups
+  --keep-weekly N   the newest backup of each of the last N ISO weeks
+  --keep-monthly N  the newest backup of each of the last N months
+  --keep-yearly N   the newest backup of each of the last N years
+
+Defaults come from backup.retention_count and backup.retention in the config.
+
+File contents no remaining backup references are removed afterwards, as they
+also are after each backup. Contents stored within the last 24 hours are kept,
+because a backup running on another machine at the same time may have uploaded
+them without having written its backup yet. There is no lock between machines:
+references are counted again just before deleting, and a backup checks after
+storing itself that the older contents it reused still exist, uploading any
+that were removed meanwhile.
+
+Examples:
+  # Show what would be deleted and why
+  sshsk prune --dry-run
+
+  # Keep 3 recent backups plus 7 daily and 4 weekly ones
+  sshsk prune --keep-last 3 --keep-daily 7 --keep-weekly 4
+
+  # Only prune backups taken on one machine
+  sshsk prune --hostname laptop --force
+
+  # Only prune backups tagged "ci"
+  sshsk prune --tag ci --keep-last 5`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPrune(cfg, opts)
+		},
+	}
+
+	// Command-specific flags
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "Show which backups would be kept or deleted and why")
+	cmd.Flags().BoolVar(&opts.force, "force", false, "Skip confirmation prompt")
+	cmd.Flags().StringVar(&opts.hostname, "hostname", "", "Only prune backups taken on this hostname")
+	addSelectorFlags(cmd, &opts.tags, &opts.selectors)
+	cmd.Flags().IntVar(&opts.policy.KeepLast, "keep-last", cfg.Backup.RetentionCount, "Keep the N most recent backups")
+	cmd.Flags().IntVar(&opts.policy.KeepDaily, "keep-daily", cfg.Backup.Retention.KeepDaily, "Keep the newest backup of each of the last N days")
+	cmd.Flags().IntVar(&opts.policy.KeepWeekly, "keep-weekly", cfg.Backup.Retention.KeepWeekly, "Keep the newest backup of each of the last N weeks")
+	cmd.Flags().IntVar(&opts.policy.KeepMonthly, "keep-monthly", cfg.Backup.Retention.KeepMonthly, "Keep the newest backup of each of the last N months")
+	cmd.Flags().IntVar(&opts.policy.KeepYearly, "keep-yearly", cfg.Backup.Retention.KeepYearly, "Keep the newest backup of each of the last N years")
+
+	return cmd
+}
+
+type pruneOptions struct {
+	policy    retention.Policy
+	hostname  string
+	tags      []string
+	selectors []string
+	dryRun    bool
+	force     bool
+}
+
+func runPrune(cfg *config.Config, opts pruneOptions) error {
+	log.Info().
+		Str("policy", opts.policy.String()).
+		Str("hostname", opts.hostname).
+		Bool("dry_run", opts.dryRun).
+		Msg("Starting prune")
+
+	if err := opts.policy.Validate(); err != nil {
+		return fmt.Errorf("invalid retention policy: %w", err)
+	}
+	if opts.policy.IsEmpty() {
+		return fmt.Errorf("no retention rules configured; set --keep-last or a --keep-* option")
+	}
+
+	selector, err := newBackupSelector(opts.tags, opts.selectors)
+	if err != nil {
+		return err
+	}
+	selector = selector.addHostname(opts.hostname)
+
+	// Create storage provider via factory
+	factory := storage.NewFactory()
+	storageProvider, err := factory.CreateStorage(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create storage provider: %w", err)
+	}
+	defer storageProvider.Close()
+
+	// Test connection
+	ctx := context.Background()
+	fmt.Printf("Connecting to %s storage...\n", storageProvider.GetProviderType())
+	if err := storageProvider.TestConnection(ctx); err != nil {
+		return fmt.Errorf("storage connection test failed: %w", err)
+	}
+
+	decisions, err := planPrune(ctx, storageProvider, opts.policy, selector)
+	if err != nil {
+		return err
+	}
+
+	displayPrunePlan(decisions, opts.policy, opts.dryRun)
+
+	toDelete := prunableBackups(decisions)
+	if len(toDelete) == 0 {
+		fmt.Printf("\n✓ Nothing to prune\n")
+		pruneBlobs(ctx, storageProvider, nil, opts.dryRun)
+		return nil
+	}
+
+	if opts.dryRun {
+		fmt.Printf("\n[DRY RUN] %d backup(s) would be deleted\n", len(toDelete))
+		pruneBlobs(ctx, storageProvider, toDelete, true)
+		return nil
+	}
+
+	// Confirmation prompt (unless --force is used)
+	if !opts.force {
+		fmt.Printf("\n⚠️  WARNING: This will permanently delete %d backup(s)\n", len(toDelete))
+		fmt.Printf("This operation cannot be undone!\n")
+		fmt.Printf("\nContinue? [y/N]: ")
+
+		var response string
+		fmt.Scanln(&response)
+		response = strings.ToLower(strings.TrimSpace(response))
+
+		if response != "y" && response != "yes" {
+			fmt.Printf("Prune cancelled\n")
+			return nil
+		}
+	}
+
+	deleted, err := deleteBackups(ctx, storageProvider, toDelete)
+	fmt.Printf("\n✓ Pruned %d backup(s)\n", len(deleted))
+	if err != nil {
+		return err
+	}
+
+	pruneBlobs(ctx, storageProvider, nil, false)
+	return nil
+}
+
+// planPrune evaluates the retention policy against the stored backups that
+// match the selector; a nil selector considers every backup.
+func planPrune(ctx context.Context, provider interfaces.StorageProvider, policy retention.Policy, selector *backupSelector) ([]retention.Decision, error) {
+	infos, err := loadBackupInfos(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []retention.Backup
+	for _, info := range infos {
+		if !selector.Matches(info) {
+			continue
+		}
+
+		candidates = append(candidates, retention.Backup{
+			Name:      info.Name,
+			Timestamp: info.Timestamp,
+			Hostname:  info.Hostname,
+		})
+	}
+
+	return policy.Apply(candidates), nil
+}
+
+// prunableBackups returns the names of backups the policy does not keep
+func prunableBackups(decisions []retention.Decision) []string {
+	var names []string
+	for _, decision := range decisions {
+		if !decision.Keep {
+			names = append(names, decision.Backup.Name)
+		}
+	}
+	return names
+}
+
+// deleteBackups deletes the named backups and removes them from the metadata index
+func deleteBackups(ctx context.Context, provider interfaces.StorageProvider, names []string) ([]string, error) {
+	var deleted, failed []string
+
+	for _, name := range names {
+		if err := provider.DeleteBackup(ctx, name); err != nil {
+			log.Warn().Err(err).Str("backup", name).Msg("Failed to delete backup")
+			failed = append(failed, name)
+			continue
+		}
+		log.Info().Str("backup", name).Msg("Pruned backup")
+		fmt.Printf("🗑️  Deleted %s\n", name)
+		deleted = append(deleted, name)
+	}
+
+	if len(deleted) > 0 {
+		err := provider.UpdateMetadata(ctx, func(metadata map[string]interface{}) error {
+			if backups, ok := metadata["backups"].(map[string]interface{}); ok {
+				for _, name := range deleted {
+					delete(backups, name)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to update metadata after pruning")
+		}
+	}
+
+	if len(failed) > 0 {
+		return deleted, fmt.Errorf("failed to delete %d backup(s): %s", len(failed), strings.Join(failed, ", "))
+	}
+	return deleted, nil
+}
+
+// autoPrune applies the configured retention policy to backups taken on hostname.
+// It never prompts and is a no-op unless backup.retention.auto_prune is enabled.
+func autoPrune(ctx context.Context, cfg *config.Config, provider interfaces.StorageProvider, scope *backupSelector) error {
+	if !cfg.Backup.Retention.AutoPrune {
+		return nil
+	}
+	return applyRetention(ctx, cfg, provider, scope)
+}
+
+// applyRetention applies the configured retention policy to the backups in
+// scope, normally those taken on one host, without prompting. An empty policy
+// keeps everything.
+func applyRetention(ctx context.Context, cfg *config.Config, provider interfaces.StorageProvider, scope *backupSelector) error {
+	policy := retentionPolicyFromConfig(cfg)
+	if err := policy.Validate(); err != nil {
+		return fmt.Errorf("invalid retention policy: %w", err)
+	}
+	if policy.IsEmpty() {
+		return nil
+	}
+
+	decisions, err := planPrune(ctx, provider, policy, scope)
+	if err != nil {
+		return err
+	}
+
+	toDelete := prunableBackups(decisions)
+	if len(toDelete) == 0 {
+		log.Debug().Str("scope", scope.String()).Msg("Retention policy: nothing to prune")
+		return nil
+	}
+
+	fmt.Printf("\n🧹 Applying retention policy (%s) for %s\n", policy, scope)
+	if _, err := deleteBackups(ctx, provider, toDelete); err != nil {
+		return err
+	}
+
+	pruneBlobs(ctx, provider, nil, false)
+	return nil
+}
+
+// blobGracePeriod is how long unreferenced file contents are kept after they
+// were stored, since a backup running elsewhere may not have referenced them yet
+var blobGracePeriod = blobstore.DefaultGracePeriod
+
+// pruneBlobs garbage-collects file contents that no backup references any more.
+// With dryRun, backups in pending are treated as deleted to show what would be freed.
+// Failures are reported but never fail the prune, since the backups are already gone.
+func pruneBlobs(ctx context.Context, provider interfaces.StorageProvider, pending []string, dryRun bool) {
+	result, err := blobstore.New(provider).CollectGarbage(ctx, blobstore.GCOptions{
+		DryRun: dryRun,
+		Ignore: pending,
+		MinAge: blobGracePeriod,
+	})
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to garbage-collect unreferenced blobs")
+		fmt.Printf("⚠️  Unreferenced file contents were not removed: %v\n", err)
+		return
+	}
+
+	switch {
+	case len(result.Unreferenced) == 0:
+		return
+	case dryRun:
+		fmt.Printf("[DRY RUN] %d of %d stored file content blob(s) would no longer be referenced and be removed\n",
+			len(result.Unreferenced)-len(result.Recent), result.TotalBlobs)
+	default:
+		fmt.Printf("🧹 Removed %d unreferenced file content blob(s)\n", len(result.Deleted))
+	}
+	if len(result.Recent) > 0 {
+		fmt.Printf("⏳ Kept %d unreferenced file content blob(s) stored within the last %s\n",
+			len(result.Recent), blobGracePeriod)
+	}
+}
+
+// retentionPolicyFromConfig builds the retention policy from the backup configuration
+func retentionPolicyFromConfig(cfg *config.Config) retention.Policy {
+	return retention.Policy{
+		KeepLast:    cfg.Backup.RetentionCount,
+		KeepDaily:   cfg.Backup.Retention.KeepDaily,
+		KeepWeekly:  cfg.Backup.Retention.KeepWeekly,
+		KeepMonthly: cfg.Backup.Retention.KeepMonthly,
+		KeepYearly:  cfg.Backup.Retention.KeepYearly,
+	}
+}
+
+// displayPrunePlan prints the keep/delete decision and its reasons for every backup
+func displayPrunePlan(decisions []retention.Decision, policy retention.Policy, dryRun bool) {
+	prefix := ""
+	if dryRun {
+		prefix = "[DRY RUN] "
+	}
+
+	fmt.Printf("\n🧹 %sRetention Plan\n", prefix)
+	fmt.Printf("═══════════════════════════\n")
+	fmt.Printf("Policy: %s (per hostname)\n", policy)
+
+	if len(decisions) == 0 {
+		fmt.Printf("\nNo backups found\n")
+		return
+	}
+
+	currentHost := ""
+	for i, decision := range decisions {
+		if i == 0 || decision.Backup.Hostname != currentHost {
+			currentHost = decision.Backup.Hostname
+			host := currentHost
+			if host == "" {
+				host = "(unknown host)"
+			}
+			fmt.Printf("\n💻 %s\n", host)
+		}
+
+		action := "✓ keep  "
+		if !decision.Keep {
+			action = "✗ delete"
+		}
+
+		created := "unknown time"
+		if !decision.Backup.Timestamp.IsZero() {
+			created = decision.Backup.Timestamp.Format("2006-01-02 15:04")
+		}
+
+		fmt.Printf("  %s %s (%s): %s\n", action, decision.Backup.Name, created, strings.Join(decision.Reasons, ", "))
+	}
+
+	kept := len(decisions) - len(prunableBackups(decisions))
+	fmt.Printf("\nKeep: %d, delete: %d\n", kept, len(decisions)-kept)
+}
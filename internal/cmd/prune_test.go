@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rzago/ssh-secret-keeper/internal/blobstore"
+	"github.com/rzago/ssh-secret-keeper/internal/config"
+	"github.com/rzago/ssh-secret-keeper/internal/retention"
+	"github.com/rzago/ssh-secret-keeper/internal/ssh"
+)
+
+func TestNewPruneCommand(t *testing.T) {
+	cfg := config.Default()
+	cfg.Backup.Retention.KeepDaily = 7
+	cmd := newPruneCommand(cfg)
+
+	if cmd.Use != "prune" {
+		t.Errorf("Expected command use 'prune', got '%s'", cmd.Use)
+	}
+
+	for _, flag := range []string{"dry-run", "force", "hostname", "keep-last", "keep-daily", "keep-weekly", "keep-monthly", "keep-yearly"} {
+		if cmd.Flag(flag) == nil {
+			t.Errorf("Expected --%s flag to be present", flag)
+		}
+	}
+
+	if got := cmd.Flag("keep-last").DefValue; got != "10" {
+		t.Errorf("keep-last default = %s, want retention_count 10", got)
+	}
+	if got := cmd.Flag("keep-daily").DefValue; got != "7" {
+		t.Errorf("keep-daily default = %s, want 7", got)
+	}
+}
+
+func seedPruneStorage(base time.Time) *memoryStorage {
+	provider := newMemoryStorage()
+	index := make(map[string]interface{})
+
+	add := func(name, hostname string, ts time.Time, indexed bool) {
+		provider.backups[name] = storedBackup(hostname, ts)
+		if indexed {
+			index[name] = map[string]interface{}{
+				"timestamp": ts.Format(time.RFC3339),
+				"hostname":  hostname,
+			}
+		}
+	}
+
+	add("laptop-3", "laptop", base, true)
+	add("laptop-2", "laptop", base.Add(-time.Hour), true)
+	add("laptop-1", "laptop", base.Add(-2*time.Hour), false) // Missing from the index
+	add("server-1", "server", base.Add(-48*time.Hour), true)
+
+	provider.metadata["backups"] = index
+	return provider
+}
+
+func TestPlanPrune(t *testing.T) {
+	ctx := context.Background()
+	provider := seedPruneStorage(time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC))
+
+	decisions, err := planPrune(ctx, provider, retention.Policy{KeepLast: 1}, nil)
+	if err != nil {
+		t.Fatalf("planPrune() error = %v", err)
+	}
+
+	if got := prunableBackups(decisions); !reflect.DeepEqual(got, []string{"laptop-2", "laptop-1"}) {
+		t.Errorf("prunable = %v, want [laptop-2 laptop-1]", got)
+	}
+
+	decisions, err = planPrune(ctx, provider, retention.Policy{KeepLast: 1}, hostnameSelector("server"))
+	if err != nil {
+		t.Fatalf("planPrune() error = %v", err)
+	}
+	if len(decisions) != 1 || !decisions[0].Keep {
+		t.Errorf("hostname filter decisions = %+v, want only server-1 kept", decisions)
+	}
+}
+
+func TestAutoPrune(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	cfg := config.Default()
+	cfg.Backup.RetentionCount = 1
+
+	t.Run("disabled by default", func(t *testing.T) {
+		provider := seedPruneStorage(base)
+		if err := autoPrune(ctx, cfg, provider, hostnameSelector("laptop")); err != nil {
+			t.Fatalf("autoPrune() error = %v", err)
+		}
+		if len(provider.backups) != 4 {
+			t.Errorf("backups = %d, want 4 when auto_prune is off", len(provider.backups))
+		}
+	})
+
+	t.Run("prunes only the current host", func(t *testing.T) {
+		cfg.Backup.Retention.AutoPrune = true
+		provider := seedPruneStorage(base)
+
+		if err := autoPrune(ctx, cfg, provider, hostnameSelector("laptop")); err != nil {
+			t.Fatalf("autoPrune() error = %v", err)
+		}
+
+		names, _ := provider.ListBackups(ctx)
+		if !reflect.DeepEqual(names, []string{"laptop-3", "server-1"}) {
+			t.Errorf("remaining backups = %v, want [laptop-3 server-1]", names)
+		}
+
+		index := provider.metadata["backups"].(map[string]interface{})
+		if _, ok := index["laptop-2"]; ok {
+			t.Error("pruned backup should be removed from the metadata index")
+		}
+	})
+}
+
+func TestAutoPrune_CollectsUnreferencedBlobs(t *testing.T) {
+	ctx := context.Background()
+	provider := newMemoryStorage()
+	store := blobstore.New(provider)
+	base := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	for i, content := range []string{"old-key", "new-key"} {
+		backup := &ssh.BackupData{
+			Version:   "1.0",
+			Timestamp: base.Add(time.Duration(i) * time.Hour),
+			Hostname:  "laptop",
+			Files: map[string]*ssh.FileData{
+				"id_ed25519": {Filename: "id_ed25519", Content: []byte(content), Permissions: 0600},
+				"config":     {Filename: "config", Content: []byte("Host *\n"), Permissions: 0600},
+			},
+		}
+		data := prepareVaultData(backup)
+		if _, err := store.Deduplicate(ctx, data); err != nil {
+			t.Fatalf("Deduplicate() error = %v", err)
+		}
+		name := fmt.Sprintf("backup-%d", i)
+		provider.StoreBackup(ctx, name, data)
+		if err := updateBackupMetadata(provider, name, backup); err != nil {
+			t.Fatalf("updateBackupMetadata() error = %v", err)
+		}
+	}
+
+	cfg := config.Default()
+	cfg.Backup.RetentionCount = 1
+	cfg.Backup.Retention.AutoPrune = true
+
+	// Contents just stored are within the grace period and survive
+	if err := autoPrune(ctx, cfg, provider, hostnameSelector("laptop")); err != nil {
+		t.Fatalf("autoPrune() error = %v", err)
+	}
+	if len(provider.blobs) != 3 {
+		t.Fatalf("blobs = %d, want 3 within the grace period", len(provider.blobs))
+	}
+
+	ageBlobs(provider, blobGracePeriod+time.Hour)
+	pruneBlobs(ctx, provider, nil, false)
+
+	if _, ok := provider.blobs[blobstore.Hash([]byte("old-key"))]; ok {
+		t.Error("blob only referenced by the pruned backup should be removed")
+	}
+	if len(provider.blobs) != 2 {
+		t.Errorf("blobs = %d, want 2", len(provider.blobs))
+	}
+
+	backup, err := loadBackup(ctx, provider, "backup-1")
+	if err != nil {
+		t.Fatalf("loadBackup() error = %v", err)
+	}
+	if got := string(backup.Files["id_ed25519"].Content); got != "new-key" {
+		t.Errorf("restored content = %q, want new-key", got)
+	}
+}
+
+func TestPruneBlobs_SkipsSoftDeletedBackups(t *testing.T) {
+	ctx := context.Background()
+	provider := newMemoryStorage()
+	provider.softDeletes = true
+	store := blobstore.New(provider)
+
+	for i, content := range []string{"old-key", "new-key"} {
+		backup := &ssh.BackupData{
+			Version:  "1.0",
+			Hostname: "laptop",
+			Files: map[string]*ssh.FileData{
+				"id_ed25519": {Filename: "id_ed25519", Content: []byte(content), Permissions: 0600},
+			},
+		}
+		data := prepareVaultData(backup)
+		if _, err := store.Deduplicate(ctx, data); err != nil {
+			t.Fatalf("Deduplicate() error = %v", err)
+		}
+		provider.StoreBackup(ctx, fmt.Sprintf("backup-%d", i), data)
+	}
+
+	if _, err := deleteBackups(ctx, provider, []string{"backup-0"}); err != nil {
+		t.Fatalf("deleteBackups() error = %v", err)
+	}
+
+	// The deleted name is still listed but cannot be read, as with Vault
+	names, _ := provider.ListBackups(ctx)
+	if len(names) != 2 {
+		t.Fatalf("ListBackups() = %v, want the soft-deleted name kept", names)
+	}
+
+	result, err := store.CollectGarbage(ctx, blobstore.GCOptions{})
+	if err != nil {
+		t.Fatalf("CollectGarbage() after a soft delete error = %v", err)
+	}
+	if len(result.Deleted) != 1 || result.Deleted[0] != blobstore.Hash([]byte("old-key")) {
+		t.Errorf("deleted blobs = %v, want only the old key", result.Deleted)
+	}
+}
+
+// ageBlobs backdates the stored time of every blob by age
+func ageBlobs(provider *memoryStorage, age time.Duration) {
+	for _, blob := range provider.blobs {
+		blob["created_at"] = time.Now().Add(-age).Format(time.RFC3339)
+	}
+}
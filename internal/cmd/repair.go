@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/rs/zerolog/log"
+	"github.com/rzago/ssh-secret-keeper/internal/config"
+	"github.com/rzago/ssh-secret-keeper/internal/interfaces"
+	"github.com/rzago/ssh-secret-keeper/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// newRepairCommand creates the repair command
+func newRepairCommand(cfg *config.Config) *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "repair",
+		Short: "Rebuild the backup metadata index from stored backups",
+		Long: `Rebuild the backup metadata index by reading every stored backup.
+
+Use this when 'sshsk list --detailed' shows missing or stale entries, for example
+after older versions wrote the index concurrently from several machines or when
+backups were removed outside of sshsk.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRepair(cfg, repairOptions{
+				dryRun: dryRun,
+			})
+		},
+	}
+
+	// Command-specific flags
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would change without updating the index")
+
+	return cmd
+}
+
+type repairOptions struct {
+	dryRun bool
+}
+
+// repairResult describes how the metadata index was changed by a repair
+type repairResult struct {
+	Scanned    int
+	Added      []string
+	Updated    []string
+	Removed    []string
+	Unreadable []string
+}
+
+func runRepair(cfg *config.Config, opts repairOptions) error {
+	log.Info().
+		Bool("dry_run", opts.dryRun).
+		Msg("Starting metadata index repair")
+
+	// Create storage provider via factory
+	factory := storage.NewFactory()
+	storageProvider, err := factory.CreateStorage(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create storage provider: %w", err)
+	}
+	defer storageProvider.Close()
+
+	// Test connection
+	ctx := context.Background()
+	fmt.Printf("Connecting to %s storage...\n", storageProvider.GetProviderType())
+	if err := storageProvider.TestConnection(ctx); err != nil {
+		return fmt.Errorf("storage connection test failed: %w", err)
+	}
+
+	fmt.Printf("Scanning stored backups...\n")
+	result, err := rebuildMetadataIndex(ctx, storageProvider, opts.dryRun)
+	if err != nil {
+		return fmt.Errorf("failed to rebuild metadata index: %w", err)
+	}
+
+	displayRepairResult(result, opts.dryRun)
+	return nil
+}
+
+// rebuildMetadataIndex reads every backup returned by ListBackups and rewrites the
+// metadata index to match. Entries for backups that could not be read are kept as-is;
+// listed backups without data were deleted and lose their entries.
+func rebuildMetadataIndex(ctx context.Context, provider interfaces.StorageProvider, dryRun bool) (*repairResult, error) {
+	names, err := provider.ListBackups(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	scanned := make(map[string]map[string]interface{})
+	deleted := make(map[string]bool)
+	var unreadable []string
+
+	for _, name := range names {
+		vaultData, err := provider.GetBackup(ctx, name)
+		if errors.Is(err, interfaces.ErrBackupNotFound) {
+			log.Debug().Str("backup", name).Msg("Listed backup has no data, treating it as deleted")
+			deleted[name] = true
+			continue
+		}
+		if err != nil {
+			log.Warn().Err(err).Str("backup", name).Msg("Cannot read backup, keeping existing index entry")
+			unreadable = append(unreadable, name)
+			continue
+		}
+
+		backup, err := parseVaultBackup(vaultData)
+		if err != nil {
+			log.Warn().Err(err).Str("backup", name).Msg("Cannot parse backup, keeping existing index entry")
+			unreadable = append(unreadable, name)
+			continue
+		}
+
+		scanned[name] = buildBackupMetadataEntry(backup)
+	}
+
+	var result *repairResult
+	apply := func(metadata map[string]interface{}) error {
+		// Recomputed on every attempt because UpdateMetadata may retry
+		result = &repairResult{
+			Scanned:    len(names),
+			Unreadable: unreadable,
+		}
+
+		existing, _ := metadata["backups"].(map[string]interface{})
+		rebuilt := make(map[string]interface{}, len(scanned))
+
+		for name, entry := range scanned {
+			previous, found := existing[name].(map[string]interface{})
+			switch {
+			case !found:
+				result.Added = append(result.Added, name)
+			case !sameMetadataEntry(previous, entry):
+				result.Updated = append(result.Updated, name)
+			}
+			rebuilt[name] = entry
+		}
+
+		for _, name := range unreadable {
+			if entry, ok := existing[name]; ok {
+				rebuilt[name] = entry
+			}
+		}
+
+		// Backups stored after our listing must not be dropped from the index
+		current, err := provider.ListBackups(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list backups: %w", err)
+		}
+		stillStored := make(map[string]bool, len(current))
+		for _, name := range current {
+			stillStored[name] = true
+		}
+
+		for name, entry := range existing {
+			if _, kept := rebuilt[name]; kept {
+				continue
+			}
+			if stillStored[name] && !deleted[name] {
+				rebuilt[name] = entry
+				continue
+			}
+			result.Removed = append(result.Removed, name)
+		}
+
+		sort.Strings(result.Added)
+		sort.Strings(result.Updated)
+		sort.Strings(result.Removed)
+
+		metadata["backups"] = rebuilt
+		return nil
+	}
+
+	if dryRun {
+		metadata, err := provider.GetMetadata(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read metadata: %w", err)
+		}
+		if err := apply(metadata); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	if err := provider.UpdateMetadata(ctx, apply); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// sameMetadataEntry reports whether two index entries hold the same fields
+// and values. Values are compared as printed, since an entry read back from
+// storage has json.Number counts and []interface{} tags.
+func sameMetadataEntry(a, b map[string]interface{}) bool {
+	for field := range a {
+		if fmt.Sprint(a[field]) != fmt.Sprint(b[field]) {
+			return false
+		}
+	}
+	for field := range b {
+		if _, ok := a[field]; !ok && b[field] != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// displayRepairResult prints a summary of the repair
+func displayRepairResult(result *repairResult, dryRun bool) {
+	prefix := ""
+	if dryRun {
+		prefix = "[DRY RUN] "
+	}
+
+	fmt.Printf("\n🛠️  %sMetadata Index Repair\n", prefix)
+	fmt.Printf("═══════════════════════════\n")
+	fmt.Printf("Backups scanned: %d\n", result.Scanned)
+
+	printNames := func(label string, names []string) {
+		fmt.Printf("%s: %d\n", label, len(names))
+		for _, name := range names {
+			fmt.Printf("  • %s\n", name)
+		}
+	}
+
+	printNames("Entries added", result.Added)
+	printNames("Entries updated", result.Updated)
+	printNames("Stale entries removed", result.Removed)
+	if len(result.Unreadable) > 0 {
+		printNames("⚠️  Unreadable backups (entries kept)", result.Unreadable)
+	}
+
+	if dryRun {
+		fmt.Printf("\n[DRY RUN] Metadata index was not modified\n")
+		return
+	}
+
+	fmt.Printf("\n✓ Metadata index rebuilt\n")
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rzago/ssh-secret-keeper/internal/config"
+	"github.com/rzago/ssh-secret-keeper/internal/ssh"
+)
+
+func TestNewRepairCommand(t *testing.T) {
+	cfg := config.Default()
+	cmd := newRepairCommand(cfg)
+
+	if cmd.Use != "repair" {
+		t.Errorf("Expected command use 'repair', got '%s'", cmd.Use)
+	}
+
+	if cmd.Flag("dry-run") == nil {
+		t.Error("Expected --dry-run flag to be present")
+	}
+}
+
+func storedBackup(hostname string, timestamp time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"version":   "1.0",
+		"timestamp": timestamp.Format(time.RFC3339),
+		"hostname":  hostname,
+		"username":  "alice",
+		"metadata":  map[string]interface{}{"total_size": float64(12)},
+		"files": map[string]interface{}{
+			"config": map[string]interface{}{
+				"content":     "Host *\n",
+				"permissions": float64(0600),
+				"size":        float64(7),
+			},
+		},
+	}
+}
+
+func TestRebuildMetadataIndex(t *testing.T) {
+	ctx := context.Background()
+	ts := time.Date(2026, 9, 30, 12, 0, 0, 0, time.UTC)
+
+	provider := newMemoryStorage()
+	provider.backups["laptop-1"] = storedBackup("laptop", ts)
+	provider.backups["desktop-1"] = storedBackup("desktop", ts.Add(time.Hour))
+	provider.metadata["backups"] = map[string]interface{}{
+		"desktop-1": map[string]interface{}{
+			"timestamp":  ts.Format(time.RFC3339),
+			"file_count": 1,
+			"total_size": float64(12),
+			"hostname":   "desktop",
+			"username":   "alice",
+		},
+		"deleted-elsewhere": map[string]interface{}{"hostname": "old"},
+		"soft-deleted":      map[string]interface{}{"hostname": "old"},
+	}
+	// A soft-deleted backup is still listed but has no data
+	provider.deleted["soft-deleted"] = true
+
+	t.Run("dry run leaves index untouched", func(t *testing.T) {
+		result, err := rebuildMetadataIndex(ctx, provider, true)
+		if err != nil {
+			t.Fatalf("rebuildMetadataIndex() error = %v", err)
+		}
+
+		if !reflect.DeepEqual(result.Added, []string{"laptop-1"}) {
+			t.Errorf("Added = %v, want [laptop-1]", result.Added)
+		}
+		if _, ok := provider.metadata["backups"].(map[string]interface{})["laptop-1"]; ok {
+			t.Error("dry run must not modify the stored index")
+		}
+	})
+
+	t.Run("rebuild", func(t *testing.T) {
+		result, err := rebuildMetadataIndex(ctx, provider, false)
+		if err != nil {
+			t.Fatalf("rebuildMetadataIndex() error = %v", err)
+		}
+
+		if result.Scanned != 3 {
+			t.Errorf("Scanned = %d, want 3", result.Scanned)
+		}
+		if !reflect.DeepEqual(result.Added, []string{"laptop-1"}) {
+			t.Errorf("Added = %v, want [laptop-1]", result.Added)
+		}
+		if !reflect.DeepEqual(result.Updated, []string{"desktop-1"}) {
+			t.Errorf("Updated = %v, want [desktop-1] (timestamp changed)", result.Updated)
+		}
+		if !reflect.DeepEqual(result.Removed, []string{"deleted-elsewhere", "soft-deleted"}) {
+			t.Errorf("Removed = %v, want [deleted-elsewhere soft-deleted]", result.Removed)
+		}
+		if len(result.Unreadable) != 0 {
+			t.Errorf("Unreadable = %v, want none", result.Unreadable)
+		}
+
+		index := provider.metadata["backups"].(map[string]interface{})
+		if len(index) != 2 {
+			t.Fatalf("index has %d entries, want 2", len(index))
+		}
+		entry := index["laptop-1"].(map[string]interface{})
+		if entry["hostname"] != "laptop" || fmt.Sprint(entry["file_count"]) != "1" {
+			t.Errorf("unexpected rebuilt entry: %v", entry)
+		}
+	})
+}
+
+func TestSameMetadataEntry(t *testing.T) {
+	backup := &ssh.BackupData{
+		Timestamp:   time.Date(2026, 9, 30, 12, 0, 0, 0, time.UTC),
+		Hostname:    "laptop",
+		Username:    "alice",
+		Tags:        []string{"ci", "nightly"},
+		Description: "before upgrade",
+		Files:       map[string]*ssh.FileData{"config": {Filename: "config"}},
+		Metadata:    map[string]interface{}{"total_size": 7, "fingerprint": "abc"},
+	}
+	rebuilt := buildBackupMetadataEntry(backup)
+
+	stored := func(change func(entry map[string]interface{})) map[string]interface{} {
+		// As read back from Vault
+		entry := map[string]interface{}{
+			"timestamp":   "2026-09-30T12:00:00Z",
+			"file_count":  json.Number("1"),
+			"total_size":  json.Number("7"),
+			"hostname":    "laptop",
+			"username":    "alice",
+			"fingerprint": "abc",
+			"tags":        []interface{}{"ci", "nightly"},
+			"description": "before upgrade",
+		}
+		if change != nil {
+			change(entry)
+		}
+		return entry
+	}
+
+	tests := []struct {
+		name   string
+		change func(entry map[string]interface{})
+		want   bool
+	}{
+		{"unchanged", nil, true},
+		{"fingerprint", func(e map[string]interface{}) { e["fingerprint"] = "def" }, false},
+		{"tags", func(e map[string]interface{}) { e["tags"] = []interface{}{"ci"} }, false},
+		{"description missing", func(e map[string]interface{}) { delete(e, "description") }, false},
+		{"extra field", func(e map[string]interface{}) { e["legacy"] = true }, false},
+		{"file count", func(e map[string]interface{}) { e["file_count"] = json.Number("2") }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameMetadataEntry(stored(tt.change), rebuilt); got != tt.want {
+				t.Errorf("sameMetadataEntry() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
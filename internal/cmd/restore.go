@@ -3,19 +3,25 @@ package cmd
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path"
 	"sort"
 	"strings"
 	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/rzago/ssh-secret-keeper/internal/analyzer"
+	"github.com/rzago/ssh-secret-keeper/internal/blobstore"
 	"github.com/rzago/ssh-secret-keeper/internal/config"
 	"github.com/rzago/ssh-secret-keeper/internal/files"
+	"github.com/rzago/ssh-secret-keeper/internal/hooks"
 	"github.com/rzago/ssh-secret-keeper/internal/interfaces"
 	"github.com/rzago/ssh-secret-keeper/internal/ssh"
-	"github.com/rzago/ssh-secret-keeper/internal/storage"
+	"github.com/rzago/ssh-secret-keeper/internal/undo"
+	"github.com/rzago/ssh-secret-keeper/internal/utils"
 	"github.com/spf13/cobra"
 )
 
@@ -26,31 +32,120 @@ func newRestoreCommand(cfg *config.Config) *cobra.Command {
 		targetDir    string
 		dryRun       bool
 		overwrite    bool
+		merge        bool
+		undoRestore  bool
 		interactive  bool
 		selectBackup bool
+		system       bool
+		noSources    bool
+		noRewrite    bool
+		outputJSON   bool
+		owner        string
 		fileFilter   []string
+		types        []string
+		purposes     []string
+		services     []string
+		pairs        []string
+		tags         []string
+		selectors    []string
+		asOf         string
 	)
 
 	cmd := &cobra.Command{
 		Use:   "restore [backup-name]",
 		Short: "Restore SSH backup from Vault",
 		Long: `Restore SSH files from a Vault backup to your SSH directory.
-If no backup name is provided, the most recent backup will be used.`,
+If no backup name is provided, the most recent backup will be used; with
+--tag or --selector, the most recent backup matching them:
+
+  sshsk restore --tag pre-rotation --selector hostname=laptop
+
+With --as-of, the newest backup taken at or before a time is used instead,
+according to the timestamps in the metadata index; --tag and --selector
+narrow it down, e.g. to one host. The choice is explained before restoring:
+
+  sshsk restore --as-of 2026-09-30T12:00 --selector hostname=laptop
+  sshsk restore --as-of "3 days ago"
+
+With --system (as root) a backup taken with 'backup --system' on a server with
+the same hostname is restored to /etc/ssh. Modes are checked against what sshd
+accepts before anything is written, and restored files are owned by root. Use
+--target-dir to restore into a mounted root filesystem, e.g. /mnt/etc/ssh.
+
+Additional sources stored with the backup, such as ~/.kube, are restored to
+their directories in the current user's home unless --no-sources is given.
+--files, the key filters below and --target-dir only apply to the SSH directory.
+
+Select files by their analysis with --type (e.g. private_key,config),
+--purpose (e.g. work), --service (e.g. github) and --pair, which restores the
+private key, public key and certificate of a key pair together. A file must
+match every filter given; --dry-run lists what each filter matched:
+
+  sshsk restore --pair id_work --dry-run
+
+Existing files are skipped unless --overwrite is given. With --merge,
+known_hosts and authorized_keys get the backup's entries they lack
+(deduplicated by host and key), and ssh config gets the Host and Match blocks
+it lacks while local blocks are kept unchanged; other files follow
+--overwrite. Combine with --dry-run to preview the entries that would be added.
+
+Before a file is replaced, it is saved to ~/.ssh-secret-keeper/undo (mode 0700)
+and files are written via a temporary file and a rename. If the restore fails,
+every change is rolled back; --undo reverts the last restore afterwards,
+including the modes of existing directories. Restores that changed nothing are
+not recorded, and the last 5 restores are kept.
+
+With --owner user[:group] (as root), restored files and directories are given
+to that user and ~ in --target-dir and source directories expands to their
+home directory. When root restores without --owner, the owner recorded in the
+backup is used, so backups taken with 'backup --all-users' go back to their
+users:
+
+  sudo sshsk restore alice-laptop --owner alice
+
+When a backup is restored for another user or on a machine with a different
+home directory, absolute paths into the original home in ssh config
+directives such as IdentityFile, CertificateFile, ControlPath, Include and
+UserKnownHostsFile are rewritten to ~/ and each rewrite is reported. Use
+--no-rewrite to restore the config as it was backed up.
+
+After restoring, every private key is parsed and the public key it derives is
+compared with the restored .pub and -cert.pub of its pair; mismatched, corrupt
+or truncated pairs are flagged. Passphrase-protected keys are not decrypted,
+only their public parts are compared. With --json, a summary including this
+check is printed to stdout while progress and hook output go to stderr.`,
 		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			name := backupName
 			if len(args) > 0 {
 				name = args[0]
 			}
+			if system && !cmd.Flags().Changed("target-dir") {
+				targetDir = ssh.SystemSSHDir
+			}
 
 			return runRestore(cfg, restoreOptions{
 				backupName:   name,
 				targetDir:    targetDir,
 				dryRun:       dryRun,
 				overwrite:    overwrite,
+				merge:        merge,
+				undo:         undoRestore,
 				interactive:  interactive,
 				selectBackup: selectBackup,
+				system:       system,
+				noSources:    noSources,
+				noRewrite:    noRewrite,
+				outputJSON:   outputJSON,
+				owner:        owner,
 				fileFilter:   fileFilter,
+				types:        types,
+				purposes:     purposes,
+				services:     services,
+				pairs:        pairs,
+				tags:         tags,
+				selectors:    selectors,
+				asOf:         asOf,
 			})
 		},
 	}
@@ -60,9 +155,22 @@ If no backup name is provided, the most recent backup will be used.`,
 	cmd.Flags().StringVar(&targetDir, "target-dir", cfg.Backup.SSHDir, "Target directory for restored files")
 	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be restored without actually doing it")
 	cmd.Flags().BoolVar(&overwrite, "overwrite", false, "Overwrite existing files without asking")
+	cmd.Flags().BoolVar(&merge, "merge", false, "Merge known_hosts, authorized_keys and ssh config into existing files instead of skipping or overwriting them")
+	cmd.Flags().BoolVar(&undoRestore, "undo", false, "Revert the last restore, putting back the files it replaced")
 	cmd.Flags().BoolVar(&interactive, "interactive", false, "Interactively select files to restore")
 	cmd.Flags().BoolVar(&selectBackup, "select", false, "Interactively select which backup to restore")
+	cmd.Flags().BoolVar(&system, "system", false, "Restore host keys and sshd configuration to /etc/ssh with root ownership (requires root)")
+	cmd.Flags().BoolVar(&noSources, "no-sources", false, "Restore only the SSH directory, not the additional sources stored with the backup")
+	cmd.Flags().BoolVar(&noRewrite, "no-rewrite", false, "Keep home directory paths in ssh config as backed up instead of rewriting them to ~/")
+	cmd.Flags().BoolVar(&outputJSON, "json", false, "Print a JSON summary of the restore, including key pair verification, to stdout")
+	cmd.Flags().StringVar(&owner, "owner", "", "Give restored files to user[:group] and expand ~ to their home (requires root; default for root: the backup's recorded owner)")
 	cmd.Flags().StringSliceVar(&fileFilter, "files", []string{}, "Only restore specific files (glob patterns)")
+	cmd.Flags().StringSliceVar(&types, "type", []string{}, "Only restore files of these types (private_key, public_key, certificate, config, known_hosts, authorized_keys, symlink, unknown)")
+	cmd.Flags().StringSliceVar(&purposes, "purpose", []string{}, "Only restore files with these purposes (personal, work, service, cloud, system, host)")
+	cmd.Flags().StringSliceVar(&services, "service", []string{}, "Only restore files belonging to these services, e.g. github")
+	cmd.Flags().StringSliceVar(&pairs, "pair", []string{}, "Only restore the private key, public key and certificate of these key pairs (base names or glob patterns)")
+	addSelectorFlags(cmd, &tags, &selectors)
+	addAsOfFlag(cmd, &asOf)
 
 	return cmd
 }
@@ -72,29 +180,107 @@ type restoreOptions struct {
 	targetDir    string
 	dryRun       bool
 	overwrite    bool
+	merge        bool // Merge line-based files into existing local copies
+	undo         bool // Revert the last restore instead of restoring
 	interactive  bool
 	selectBackup bool
+	system       bool   // Host keys from systems/<hostname>, restored with root ownership
+	noSources    bool   // Skip the backup's additional sources
+	noRewrite    bool   // Keep the original home in ssh config paths
+	outputJSON   bool   // JSON summary on stdout, progress on stderr
+	owner        string // user[:group] given the restored files
 	fileFilter   []string
+	types        []string // Key filters, matched against each file's KeyInfo
+	purposes     []string
+	services     []string
+	pairs        []string
+	tags         []string
+	selectors    []string
+	asOf         string // Pick the newest backup at or before this time
+}
+
+// filterOptions returns restore options carrying the file and key filters
+func (o restoreOptions) filterOptions() ssh.RestoreOptions {
+	return ssh.RestoreOptions{
+		FileFilter:    o.fileFilter,
+		TypeFilter:    o.types,
+		PurposeFilter: o.purposes,
+		ServiceFilter: o.services,
+		PairFilter:    o.pairs,
+	}
 }
 
-func runRestore(cfg *config.Config, opts restoreOptions) error {
+func runRestore(cfg *config.Config, opts restoreOptions) (err error) {
 	log.Info().
 		Str("backup_name", opts.backupName).
 		Str("target_dir", opts.targetDir).
 		Bool("dry_run", opts.dryRun).
+		Bool("system", opts.system).
 		Msg("Starting restore process")
 
+	// Progress goes to stderr with --json so stdout carries only the summary
+	var out io.Writer = os.Stdout
+	if opts.outputJSON {
+		if opts.dryRun || opts.undo || opts.interactive || opts.selectBackup {
+			return fmt.Errorf("--json cannot be combined with --dry-run, --undo, --interactive or --select")
+		}
+		out = os.Stderr
+	}
+
+	// Hooks do not run for dry runs
+	var hookRunner *hooks.Runner
+	if !opts.dryRun {
+		hookRunner = hooks.New(cfg.Hooks)
+		if opts.outputJSON {
+			hookRunner.SetOutput(os.Stderr, os.Stderr)
+		}
+	}
+	ctx := context.Background()
+	event := hooks.NewEvent(hooks.OperationRestore, opts.backupName)
+	event.Directory = opts.targetDir
+	defer func() { hookRunner.After(ctx, event, err) }()
+
+	selector, err := newBackupSelector(opts.tags, opts.selectors)
+	if err != nil {
+		return err
+	}
+	if opts.undo {
+		if opts.backupName != "" || opts.selectBackup || !selector.IsEmpty() || opts.asOf != "" {
+			return fmt.Errorf("--undo reverts the last restore and cannot be combined with a backup name, --select, --tag, --selector or --as-of")
+		}
+		return runRestoreUndo(opts.dryRun)
+	}
+	if (!selector.IsEmpty() || opts.asOf != "") && (opts.backupName != "" || opts.selectBackup) {
+		return fmt.Errorf("--tag, --selector and --as-of cannot be combined with a backup name or --select")
+	}
+	if opts.asOf != "" {
+		if _, err := parseAsOf(opts.asOf, time.Now()); err != nil {
+			return err
+		}
+	}
+	if err := validateRestoreFilters(opts); err != nil {
+		return err
+	}
+
+	if opts.system && opts.owner != "" {
+		return fmt.Errorf("--owner cannot be combined with --system, which restores files owned by root")
+	}
+
+	if opts.system && !opts.dryRun {
+		if err := requireRoot("--system", "give restored files root ownership"); err != nil {
+			return err
+		}
+	}
+
 	// Create storage provider via factory
-	factory := storage.NewFactory()
-	storageProvider, err := factory.CreateStorage(cfg)
+	storageProvider, err := createStorage(cfg, opts.system)
 	if err != nil {
 		return fmt.Errorf("failed to create storage provider: %w", err)
 	}
 	defer storageProvider.Close()
 
 	// Test connection
-	ctx := context.Background()
-	fmt.Printf("Connecting to %s storage...\n", storageProvider.GetProviderType())
+	fmt.Fprintf(out, "Connecting to %s storage...\n", storageProvider.GetProviderType())
 	if err := storageProvider.TestConnection(ctx); err != nil {
 		return fmt.Errorf("storage connection test failed: %w", err)
 	}
@@ -108,32 +294,70 @@ func runRestore(cfg *config.Config, opts restoreOptions) error {
 				return fmt.Errorf("failed to select backup: %w", err)
 			}
 		} else {
-			backupName, err = getLatestBackupName(storageProvider)
+			var explanation string
+			backupName, explanation, err = chooseBackup(ctx, storageProvider, selector, opts.asOf)
 			if err != nil {
-				return fmt.Errorf("failed to find latest backup: %w", err)
+				return err
 			}
-			fmt.Printf("Using most recent backup: %s\n", backupName)
+			fmt.Fprintln(out, explanation)
 		}
 	}
 
 	// Retrieve backup from storage
-	fmt.Printf("Retrieving backup '%s' from %s...\n", backupName, storageProvider.GetProviderType())
-	vaultData, err := storageProvider.GetBackup(ctx, backupName)
+	fmt.Fprintf(out, "Retrieving backup '%s' from %s...\n", backupName, storageProvider.GetProviderType())
+	backupData, err := loadBackup(ctx, storageProvider, backupName)
 	if err != nil {
-		return fmt.Errorf("failed to retrieve backup: %w", err)
+		return err
 	}
 
-	// Convert vault data back to backup structure
-	backupData, err := parseVaultBackup(vaultData)
-	if err != nil {
-		return fmt.Errorf("failed to parse backup data: %w", err)
+	if opts.noSources {
+		backupData.Sources = nil
+	}
+
+	var owner *ssh.Owner
+	var homeDir string
+	if !opts.system {
+		owner, homeDir, err = resolveRestoreOwner(opts.owner, backupData)
+		if err != nil {
+			return err
+		}
+	}
+	if owner != nil && owner.UID != geteuid() && !opts.dryRun {
+		if err := requireRoot("--owner", "give restored files to another user"); err != nil {
+			return err
+		}
 	}
 
 	// Display restore summary
-	displayRestoreSummary(backupData, opts.targetDir)
+	displayRestoreSummary(out, backupData, opts.targetDir)
+	if owner != nil {
+		fmt.Fprintf(out, "Owner: %s (uid %d, gid %d), home %s\n", owner.User, owner.UID, owner.GID, homeDir)
+	}
+
+	// Refuse host keys sshd would reject before writing anything
+	if opts.system {
+		if err := ssh.CheckSystemPermissions(backupData); err != nil {
+			return err
+		}
+	}
 
 	if opts.dryRun {
-		fmt.Printf("\n[DRY RUN] Would restore %d files to %s\n", len(backupData.Files), opts.targetDir)
+		restoreService := files.NewRestoreService()
+		filterOpts := opts.filterOptions()
+		displayFilterMatches(restoreService.FilterMatches(backupData, filterOpts))
+		fmt.Fprintf(out, "\n[DRY RUN] Would restore %d files to %s\n", len(restoreService.SelectedFiles(backupData, filterOpts)), opts.targetDir)
+		for _, name := range backupData.SourceNames() {
+			source := backupData.Sources[name]
+			fmt.Fprintf(out, "[DRY RUN] Would restore %d files of source '%s' to %s\n", len(source.Files), name, source.Dir)
+		}
+		if !opts.system && !opts.noRewrite {
+			if err := rewriteConfigHomes(out, backupData, homeDir, true); err != nil {
+				return err
+			}
+		}
+		if opts.merge {
+			return previewMerges(backupData, opts, homeDir)
+		}
 		return nil
 	}
 
@@ -144,15 +368,15 @@ func runRestore(cfg *config.Config, opts restoreOptions) error {
 	restoreService := files.NewRestoreService()
 
 	// Backup data is ready for restore (no decryption needed)
-	fmt.Printf("✓ Backup data loaded successfully\n")
+	fmt.Fprintf(out, "✓ Backup data loaded successfully\n")
 
 	// Verify backup integrity
 	if cfg.Security.VerifyIntegrity {
-		fmt.Printf("Verifying backup integrity...\n")
+		fmt.Fprintf(out, "Verifying backup integrity...\n")
 		if err := sshHandler.VerifyBackup(backupData); err != nil {
 			return fmt.Errorf("backup integrity check failed: %w", err)
 		}
-		fmt.Printf("✓ Backup integrity verified\n")
+		fmt.Fprintf(out, "✓ Backup integrity verified\n")
 	}
 
 	// Interactive file selection
@@ -162,41 +386,135 @@ func runRestore(cfg *config.Config, opts restoreOptions) error {
 		}
 	}
 
+	// Rewrite after the integrity check, which compares the backed-up content
+	if !opts.system && !opts.noRewrite {
+		if err := rewriteConfigHomes(out, backupData, homeDir, false); err != nil {
+			return err
+		}
+	}
+
 	// Set up restore options
-	restoreOpts := ssh.RestoreOptions{
-		DryRun:      opts.dryRun,
-		Overwrite:   opts.overwrite,
-		Interactive: opts.interactive && !opts.overwrite,
-		FileFilter:  opts.fileFilter,
+	restoreOpts := opts.filterOptions()
+	restoreOpts.DryRun = opts.dryRun
+	restoreOpts.Overwrite = opts.overwrite
+	restoreOpts.Merge = opts.merge
+	restoreOpts.Interactive = opts.interactive && !opts.overwrite
+	restoreOpts.System = opts.system
+	restoreOpts.Owner = owner
+	restoreOpts.HomeDir = homeDir
+
+	event.Backup = backupName
+	event.Files = backupData.FileNames()
+	if err := hookRunner.Before(ctx, event); err != nil {
+		return err
 	}
 
+	// Save what the restore replaces, rolling back on any error below
+	undoDir, err := undo.DefaultDir()
+	if err != nil {
+		return err
+	}
+	tx, err := undo.Begin(undoDir, backupName)
+	if err != nil {
+		return err
+	}
+	restoreOpts.Undo = tx
+
+	// The --json summary is written after the restore is committed below, so
+	// failing to write it cannot roll back a completed restore
+	var summary *restoreSummary
+	defer func() {
+		if err == nil && summary != nil {
+			if writeErr := writeRestoreSummary(os.Stdout, summary); writeErr != nil {
+				err = fmt.Errorf("failed to write restore summary: %w", writeErr)
+			}
+		}
+	}()
+	defer func() {
+		if err != nil {
+			err = files.RollbackOnError(tx, err)
+			return
+		}
+		if commitErr := tx.Commit(); commitErr != nil {
+			log.Warn().Err(commitErr).Msg("Failed to record restore for --undo")
+		}
+	}()
+
 	// Restore files using the dedicated restore service (which handles path expansion)
-	fmt.Printf("Restoring files to %s...\n", opts.targetDir)
+	fmt.Fprintf(out, "Restoring files to %s...\n", opts.targetDir)
 	if err := restoreService.RestoreFiles(backupData, opts.targetDir, restoreOpts); err != nil {
 		return fmt.Errorf("failed to restore files: %w", err)
 	}
+	// Only what the filters selected is verified and counted
+	restored := restoreService.SelectedBackup(backupData, restoreOpts)
 
 	// Verify restored permissions
+	if opts.system {
+		fmt.Fprintf(out, "Verifying ownership and permissions...\n")
+		if err := ssh.VerifySystemFiles(restored, opts.targetDir); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "✓ Files are owned by root with modes sshd accepts\n")
+		keyPairs := verifyKeyPairs(out, sshHandler, restored, opts.targetDir)
+		fmt.Fprintf(out, "✓ Restore completed successfully\n")
+		fmt.Fprintf(out, "Files restored: %d\n", len(restored.Files))
+		if opts.outputJSON {
+			summary = &restoreSummary{
+				Backup:              backupName,
+				TargetDir:           opts.targetDir,
+				FilesRestored:       len(restored.Files),
+				PermissionsVerified: true,
+				KeyPairs:            keyPairs,
+			}
+			return nil
+		}
+		fmt.Fprintf(out, "\n💡 Next steps:\n")
+		fmt.Fprintf(out, "  sshd -t                  # Check the restored configuration\n")
+		fmt.Fprintf(out, "  systemctl restart sshd   # Serve the restored host keys\n")
+		fmt.Fprintf(out, "  sshsk restore --undo     # Put back the files this restore replaced\n")
+		return nil
+	}
+	if err := restoreSources(out, backupData, restoreService, restoreOpts); err != nil {
+		return err
+	}
+	var keyPairs *ssh.KeyPairReport
+	permissionsVerified := false
 	if !opts.dryRun {
-		fmt.Printf("Verifying file permissions...\n")
-		if err := sshHandler.VerifyRestorePermissions(backupData, opts.targetDir); err != nil {
+		fmt.Fprintf(out, "Verifying file permissions...\n")
+		verifyDir, err := utils.NewPathNormalizer().ResolvePathWithHome(opts.targetDir, homeDir)
+		if err != nil {
+			return fmt.Errorf("failed to resolve target directory: %w", err)
+		}
+		if err := sshHandler.VerifyRestorePermissions(restored, verifyDir, owner); err != nil {
 			log.Warn().Err(err).Msg("Permission verification completed with warnings")
-			fmt.Printf("⚠️  Permission verification completed with warnings (check logs)\n")
+			fmt.Fprintf(out, "⚠️  Permission verification completed with warnings (check logs)\n")
 		} else {
-			fmt.Printf("✓ All file permissions verified\n")
+			permissionsVerified = true
+			fmt.Fprintf(out, "✓ All file permissions verified\n")
 		}
+		keyPairs = verifyKeyPairs(out, sshHandler, restored, verifyDir)
 	}
 
-	fmt.Printf("✓ Restore completed successfully\n")
-	fmt.Printf("Files restored: %d\n", len(backupData.Files))
+	fmt.Fprintf(out, "✓ Restore completed successfully\n")
+	fmt.Fprintf(out, "Files restored: %d\n", len(restored.Files))
+	if opts.outputJSON {
+		summary = &restoreSummary{
+			Backup:              backupName,
+			TargetDir:           opts.targetDir,
+			FilesRestored:       len(restored.Files),
+			PermissionsVerified: permissionsVerified,
+			KeyPairs:            keyPairs,
+		}
+		return nil
+	}
 
 	// Show permission summary
-	fmt.Printf("\n📋 Permission Summary:\n")
-	fmt.Printf("• SSH directory: %s (0700)\n", opts.targetDir)
+	fmt.Fprintf(out, "\n📋 Permission Summary:\n")
+	fmt.Fprintf(out, "• SSH directory: %s (0700)\n", opts.targetDir)
 
 	privateKeyCount := 0
 	publicKeyCount := 0
-	for _, fileData := range backupData.Files {
+	for _, fileData := range restored.Files {
 		if fileData.KeyInfo != nil {
 			switch fileData.KeyInfo.Type {
 			case analyzer.KeyTypePrivate:
@@ -208,23 +526,249 @@ func runRestore(cfg *config.Config, opts restoreOptions) error {
 	}
 
 	if privateKeyCount > 0 {
-		fmt.Printf("• Private keys: %d files (0600)\n", privateKeyCount)
+		fmt.Fprintf(out, "• Private keys: %d files (0600)\n", privateKeyCount)
 	}
 	if publicKeyCount > 0 {
-		fmt.Printf("• Public keys: %d files (0644/0600)\n", publicKeyCount)
+		fmt.Fprintf(out, "• Public keys: %d files (0644/0600)\n", publicKeyCount)
 	}
 
-	fmt.Printf("\n💡 Next steps:\n")
-	fmt.Printf("  ssh-add -l    # Check SSH agent\n")
-	fmt.Printf("  ssh-add %s/id_rsa  # Add key to agent\n", opts.targetDir)
+	fmt.Fprintf(out, "\n💡 Next steps:\n")
+	fmt.Fprintf(out, "  ssh-add -l    # Check SSH agent\n")
+	fmt.Fprintf(out, "  ssh-add %s/id_rsa  # Add key to agent\n", opts.targetDir)
+	fmt.Fprintf(out, "  sshsk restore --undo  # Put back the files this restore replaced\n")
 
 	return nil
 }
 
-// getLatestBackupName finds the most recent backup
-func getLatestBackupName(provider interfaces.StorageProvider) (string, error) {
-	ctx := context.Background()
-	backups, err := provider.ListBackups(ctx)
+// verifyKeyPairs checks the restored key pairs in dir and reports the result
+func verifyKeyPairs(out io.Writer, handler *ssh.Handler, backup *ssh.BackupData, dir string) *ssh.KeyPairReport {
+	report := handler.VerifyKeyPairs(backup, dir)
+	if len(report.Pairs) == 0 {
+		return report
+	}
+
+	fmt.Fprintf(out, "Verifying key pairs...\n")
+	for _, pair := range report.Pairs {
+		switch pair.Status {
+		case ssh.KeyPairValid:
+			fmt.Fprintf(out, "  ✓ %s (%s)\n", pair.BaseName, pair.Fingerprint)
+		case ssh.KeyPairUnverified:
+			fmt.Fprintf(out, "  ⚠️  %s: passphrase-protected, only its public parts were compared\n", pair.BaseName)
+		default:
+			fmt.Fprintf(out, "  ❌ %s: %s\n", pair.BaseName, pair.Status)
+		}
+		for _, problem := range pair.Problems {
+			fmt.Fprintf(out, "      %s\n", problem)
+		}
+	}
+	if report.OK() {
+		fmt.Fprintf(out, "✓ Key pairs verified: %d valid, %d unverified\n", report.Valid, report.Unverified)
+	} else {
+		fmt.Fprintf(out, "⚠️  Key pair verification found %d mismatched or corrupt pairs\n", report.Invalid)
+	}
+	return report
+}
+
+// restoreSummary is the --json output of a restore
+type restoreSummary struct {
+	Backup              string             `json:"backup"`
+	TargetDir           string             `json:"target_dir"`
+	FilesRestored       int                `json:"files_restored"`
+	PermissionsVerified bool               `json:"permissions_verified"`
+	KeyPairs            *ssh.KeyPairReport `json:"key_pairs"`
+}
+
+// writeRestoreSummary writes the --json summary of a completed restore
+func writeRestoreSummary(w io.Writer, summary *restoreSummary) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(summary)
+}
+
+// runRestoreUndo reverts the most recent restore from the undo area
+func runRestoreUndo(dryRun bool) error {
+	undoDir, err := undo.DefaultDir()
+	if err != nil {
+		return err
+	}
+	tx, err := undo.Latest(undoDir)
+	if errors.Is(err, undo.ErrNothingToUndo) {
+		fmt.Printf("Nothing to undo: no restore recorded in %s\n", undoDir)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	state := "completed"
+	if !tx.Completed() {
+		state = "interrupted"
+	}
+	fmt.Printf("↩️  Last restore: '%s' at %s (%s, %d files changed)\n",
+		tx.Backup(), tx.Timestamp().Format("2006-01-02 15:04:05"), state, len(tx.Entries()))
+
+	if dryRun {
+		for _, entry := range tx.Entries() {
+			if entry.Existed {
+				fmt.Printf("[DRY RUN] Would put back the previous %s\n", entry.Path)
+			} else {
+				fmt.Printf("[DRY RUN] Would remove %s\n", entry.Path)
+			}
+		}
+		return nil
+	}
+
+	log.Info().Str("backup", tx.Backup()).Time("restored_at", tx.Timestamp()).Msg("Reverting last restore")
+	reverted, err := tx.Rollback()
+	if err != nil {
+		return fmt.Errorf("failed to undo restore: %w", err)
+	}
+	fmt.Printf("✓ Reverted %d files\n", len(reverted))
+	return nil
+}
+
+// previewMerges lists the entries a --merge restore would add to existing
+// files of the SSH directory and of the backup's sources
+func previewMerges(backup *ssh.BackupData, opts restoreOptions, homeDir string) error {
+	restoreService := files.NewRestoreService()
+	options := opts.filterOptions()
+	options.Merge = true
+	options.HomeDir = homeDir
+
+	plans, err := restoreService.PlanMerges(backup, opts.targetDir, options)
+	if err != nil {
+		return err
+	}
+	displayMergePlans("", plans)
+
+	options = options.WithoutFilters()
+	for _, name := range backup.SourceNames() {
+		source := backup.Sources[name]
+		dir, err := utils.NewPathNormalizer().ResolvePathWithHome(source.Dir, homeDir)
+		if err != nil {
+			return fmt.Errorf("failed to resolve source %s: %w", name, err)
+		}
+		plans, err := restoreService.PlanMerges(source.AsBackup(), dir, options)
+		if err != nil {
+			return err
+		}
+		displayMergePlans(name+": ", plans)
+	}
+	return nil
+}
+
+// rewriteConfigHomes rewrites paths into the backed-up account's home in the
+// backup's ssh config to ~/, so they follow the user the files are restored
+// for, and reports each rewrite
+func rewriteConfigHomes(out io.Writer, backup *ssh.BackupData, homeDir string, dryRun bool) error {
+	newHome, err := utils.NewPathNormalizer().ResolvePathWithHome("~", homeDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve home directory: %w", err)
+	}
+
+	rewrites := files.RewriteConfigHomes(backup, files.BackupHomes(backup, newHome))
+	if len(rewrites) == 0 {
+		return nil
+	}
+
+	verb, prefix := "Rewriting", ""
+	if dryRun {
+		verb, prefix = "Would rewrite", "[DRY RUN] "
+	}
+	fmt.Fprintf(out, "%s%s %d home directory paths in ssh config for %s:\n", prefix, verb, len(rewrites), newHome)
+	for _, rewrite := range rewrites {
+		log.Info().
+			Str("file", rewrite.File).
+			Int("line", rewrite.Line).
+			Str("from", rewrite.From).
+			Str("to", rewrite.To).
+			Msg("Rewrote home directory path in ssh config")
+		fmt.Fprintf(out, "    %s:%d %s %s -> %s\n", rewrite.File, rewrite.Line, rewrite.Directive, rewrite.From, rewrite.To)
+	}
+	return nil
+}
+
+// validateRestoreFilters rejects key types and purposes the analyzer never assigns
+func validateRestoreFilters(opts restoreOptions) error {
+	keyTypes := []string{
+		string(analyzer.KeyTypePrivate), string(analyzer.KeyTypePublic), string(analyzer.KeyTypeCertificate),
+		string(analyzer.KeyTypeConfig), string(analyzer.KeyTypeHosts), string(analyzer.KeyTypeAuthorized),
+		string(analyzer.KeyTypeSymlink), string(analyzer.KeyTypeUnknown),
+	}
+	for _, value := range opts.types {
+		if !containsString(keyTypes, value) {
+			return fmt.Errorf("unknown --type %q: expected one of %s", value, strings.Join(keyTypes, ", "))
+		}
+	}
+
+	purposes := []string{
+		string(analyzer.PurposePersonal), string(analyzer.PurposeWork), string(analyzer.PurposeService),
+		string(analyzer.PurposeCloud), string(analyzer.PurposeSystem), string(analyzer.PurposeHost),
+	}
+	for _, value := range opts.purposes {
+		if !containsString(purposes, strings.ToLower(value)) {
+			return fmt.Errorf("unknown --purpose %q: expected one of %s", value, strings.Join(purposes, ", "))
+		}
+	}
+
+	for _, pattern := range append(append([]string{}, opts.fileFilter...), opts.pairs...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// containsString reports whether value is in values
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// displayFilterMatches lists what each restore filter matched on a dry run
+func displayFilterMatches(matches []files.FilterMatch) {
+	for _, match := range matches {
+		values := strings.Join(match.Values, ",")
+		if len(match.Files) == 0 {
+			fmt.Printf("[DRY RUN] --%s %s matched no files\n", match.Filter, values)
+			continue
+		}
+		fmt.Printf("[DRY RUN] --%s %s matched %d files:\n", match.Filter, values, len(match.Files))
+		for _, filename := range match.Files {
+			fmt.Printf("    %s\n", filename)
+		}
+	}
+}
+
+// displayMergePlans prints the merge preview of one directory
+func displayMergePlans(prefix string, plans map[string]*files.MergeResult) {
+	names := make([]string, 0, len(plans))
+	for name := range plans {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		plan := plans[name]
+		if !plan.Changed() {
+			fmt.Printf("[DRY RUN] %s%s already has every entry of the backup\n", prefix, name)
+			continue
+		}
+		fmt.Printf("[DRY RUN] Would merge %d entries into %s%s:\n", len(plan.Added), prefix, name)
+		for _, entry := range plan.Added {
+			fmt.Printf("    + %s\n", entry)
+		}
+	}
+}
+
+// getLatestBackupName finds the most recent backup by the timestamp recorded
+// in the metadata index, reading backups missing from the index directly
+func getLatestBackupName(ctx context.Context, provider interfaces.StorageProvider) (string, error) {
+	backups, err := selectBackups(ctx, provider, nil)
 	if err != nil {
 		return "", err
 	}
@@ -232,17 +776,44 @@ func getLatestBackupName(provider interfaces.StorageProvider) (string, error) {
 	if len(backups) == 0 {
 		return "", fmt.Errorf("no backups found")
 	}
+	return backups[0].Name, nil
+}
 
-	// For simplicity, assume backup names contain timestamps
-	// In a real implementation, you'd parse metadata to find the latest
-	var latest string
-	for _, backup := range backups {
-		if latest == "" || backup > latest {
-			latest = backup
-		}
+// loadBackup retrieves a backup, loads its contents from the blob store when it
+// is a manifest, and converts it back to the backup structure
+func loadBackup(ctx context.Context, provider interfaces.StorageProvider, backupName string) (*ssh.BackupData, error) {
+	vaultData, err := provider.GetBackup(ctx, backupName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve backup: %w", err)
+	}
+
+	if err := blobstore.New(provider).Resolve(ctx, vaultData); err != nil {
+		return nil, fmt.Errorf("failed to load backup contents: %w", err)
 	}
 
-	return latest, nil
+	// Convert vault data back to backup structure
+	backupData, err := parseVaultBackup(vaultData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse backup data: %w", err)
+	}
+	return backupData, nil
+}
+
+// parseBackupOwner reads the recorded SSH directory owner; backups taken before
+// owners were recorded have none
+func parseBackupOwner(value interface{}) *ssh.Owner {
+	ownerData, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	uid, uidOK := metadataNumber(ownerData["uid"])
+	gid, gidOK := metadataNumber(ownerData["gid"])
+	if !uidOK || !gidOK {
+		return nil
+	}
+	owner := &ssh.Owner{UID: int(uid), GID: int(gid)}
+	owner.User, _ = ownerData["user"].(string)
+	return owner
 }
 
 // parseVaultBackup converts Vault data back to backup structure
@@ -269,6 +840,12 @@ func parseVaultBackup(vaultData map[string]interface{}) (*ssh.BackupData, error)
 		backup.SSHDir = sshDir
 	}
 
+	backup.Tags = metadataStrings(vaultData["tags"])
+	if description, ok := vaultData["description"].(string); ok {
+		backup.Description = description
+	}
+	backup.Owner = parseBackupOwner(vaultData["owner"])
+
 	// Parse timestamp
 	if timestampStr, ok := vaultData["timestamp"].(string); ok {
 		if timestamp, err := time.Parse(time.RFC3339, timestampStr); err == nil {
@@ -283,182 +860,238 @@ func parseVaultBackup(vaultData map[string]interface{}) (*ssh.BackupData, error)
 
 	// Parse files
 	if filesData, ok := vaultData["files"].(map[string]interface{}); ok {
-		for filename, fileDataInterface := range filesData {
-			fileDataMap, ok := fileDataInterface.(map[string]interface{})
-			if !ok {
-				continue
-			}
+		backup.Files = parseBackupFiles(filesData, 0600)
+	}
 
-			fileData := &ssh.FileData{
-				Filename: filename,
-			}
+	// Parse subdirectory modes
+	backup.Directories = parseBackupDirectories(vaultData["directories"])
+
+	// Parse additional sources
+	backup.Sources = parseBackupSources(vaultData["sources"])
+
+	return backup, nil
+}
+
+// parseBackupFiles converts the stored file entries of a backup or source.
+// Files without valid recorded permissions get fallbackMode.
+func parseBackupFiles(filesData map[string]interface{}, fallbackMode os.FileMode) map[string]*ssh.FileData {
+	files := make(map[string]*ssh.FileData, len(filesData))
+	for filename, fileDataInterface := range filesData {
+		fileDataMap, ok := fileDataInterface.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		fileData := &ssh.FileData{
+			Filename: filename,
+		}
 
-			// Parse file metadata - handle both float64 and int64 from JSON
-			// Note: stored permissions are just the permission bits (e.g., 420 for 0644)
+		// Parse file metadata - handle both float64 and int64 from JSON
+		// Note: stored permissions are just the permission bits (e.g., 420 for 0644)
 
-			// DEBUG: Log the raw permission data to diagnose the issue
+		// DEBUG: Log the raw permission data to diagnose the issue
+		log.Info().
+			Str("file", filename).
+			Interface("raw_permissions_value", fileDataMap["permissions"]).
+			Str("raw_permissions_type", fmt.Sprintf("%T", fileDataMap["permissions"])).
+			Msg("DEBUG: Raw permission data from Vault")
+
+		if perms, ok := fileDataMap["permissions"].(float64); ok {
+			fileData.Permissions = os.FileMode(int(perms))
 			log.Info().
 				Str("file", filename).
-				Interface("raw_permissions_value", fileDataMap["permissions"]).
-				Str("raw_permissions_type", fmt.Sprintf("%T", fileDataMap["permissions"])).
-				Msg("DEBUG: Raw permission data from Vault")
-
-			if perms, ok := fileDataMap["permissions"].(float64); ok {
-				fileData.Permissions = os.FileMode(int(perms))
-				log.Info().
-					Str("file", filename).
-					Float64("raw_perms", perms).
-					Str("parsed_perms", fmt.Sprintf("%04o", fileData.Permissions&os.ModePerm)).
-					Msg("✓ Parsed permissions from float64")
-			} else if perms, ok := fileDataMap["permissions"].(int64); ok {
-				fileData.Permissions = os.FileMode(perms)
-				log.Info().
-					Str("file", filename).
-					Int64("raw_perms", perms).
-					Str("parsed_perms", fmt.Sprintf("%04o", fileData.Permissions&os.ModePerm)).
-					Msg("✓ Parsed permissions from int64")
-			} else if perms, ok := fileDataMap["permissions"].(int); ok {
-				fileData.Permissions = os.FileMode(perms)
+				Float64("raw_perms", perms).
+				Str("parsed_perms", fmt.Sprintf("%04o", fileData.Permissions&os.ModePerm)).
+				Msg("✓ Parsed permissions from float64")
+		} else if perms, ok := fileDataMap["permissions"].(int64); ok {
+			fileData.Permissions = os.FileMode(perms)
+			log.Info().
+				Str("file", filename).
+				Int64("raw_perms", perms).
+				Str("parsed_perms", fmt.Sprintf("%04o", fileData.Permissions&os.ModePerm)).
+				Msg("✓ Parsed permissions from int64")
+		} else if perms, ok := fileDataMap["permissions"].(int); ok {
+			fileData.Permissions = os.FileMode(perms)
+			log.Info().
+				Str("file", filename).
+				Int("raw_perms", perms).
+				Str("parsed_perms", fmt.Sprintf("%04o", fileData.Permissions&os.ModePerm)).
+				Msg("✓ Parsed permissions from int")
+		} else if permsNumber, ok := fileDataMap["permissions"].(json.Number); ok {
+			// Handle json.Number type (what Vault actually returns)
+			if permsInt64, err := permsNumber.Int64(); err == nil {
+				fileData.Permissions = os.FileMode(permsInt64)
 				log.Info().
 					Str("file", filename).
-					Int("raw_perms", perms).
+					Int64("raw_perms", permsInt64).
 					Str("parsed_perms", fmt.Sprintf("%04o", fileData.Permissions&os.ModePerm)).
-					Msg("✓ Parsed permissions from int")
-			} else if permsNumber, ok := fileDataMap["permissions"].(json.Number); ok {
-				// Handle json.Number type (what Vault actually returns)
-				if permsInt64, err := permsNumber.Int64(); err == nil {
-					fileData.Permissions = os.FileMode(permsInt64)
-					log.Info().
-						Str("file", filename).
-						Int64("raw_perms", permsInt64).
-						Str("parsed_perms", fmt.Sprintf("%04o", fileData.Permissions&os.ModePerm)).
-						Msg("✓ Parsed permissions from json.Number")
-				} else {
-					log.Error().
-						Str("file", filename).
-						Interface("permissions", permsNumber).
-						Err(err).
-						Msg("❌ CRITICAL: Failed to parse json.Number permissions; using fallback permission 0600")
-					fileData.Permissions = os.FileMode(0600)
-				}
+					Msg("✓ Parsed permissions from json.Number")
 			} else {
-				// If permissions are missing or invalid, use a safe fallback (0600) and log a warning
 				log.Error().
 					Str("file", filename).
-					Interface("permissions", fileDataMap["permissions"]).
-					Str("permissions_type", fmt.Sprintf("%T", fileDataMap["permissions"])).
-					Msg("❌ CRITICAL: Missing or invalid permissions in backup data; using fallback permission 0600")
-				fileData.Permissions = os.FileMode(0600)
+					Interface("permissions", permsNumber).
+					Err(err).
+					Msgf("❌ CRITICAL: Failed to parse json.Number permissions; using fallback permission %04o", fallbackMode)
+				fileData.Permissions = fallbackMode
 			}
+		} else {
+			// If permissions are missing or invalid, use a safe fallback and log a warning
+			log.Error().
+				Str("file", filename).
+				Interface("permissions", fileDataMap["permissions"]).
+				Str("permissions_type", fmt.Sprintf("%T", fileDataMap["permissions"])).
+				Msgf("❌ CRITICAL: Missing or invalid permissions in backup data; using fallback permission %04o", fallbackMode)
+			fileData.Permissions = fallbackMode
+		}
 
-			// Parse file size - handle multiple numeric types from JSON
-			if size, ok := fileDataMap["size"].(float64); ok {
-				fileData.Size = int64(size)
-				log.Debug().Str("file", filename).Float64("size_float64", size).Msg("Parsed size from float64")
-			} else if size, ok := fileDataMap["size"].(int64); ok {
-				fileData.Size = size
-				log.Debug().Str("file", filename).Int64("size_int64", size).Msg("Parsed size from int64")
-			} else if size, ok := fileDataMap["size"].(int); ok {
-				fileData.Size = int64(size)
-				log.Debug().Str("file", filename).Int("size_int", size).Msg("Parsed size from int")
-			} else if sizeInterface := fileDataMap["size"]; sizeInterface != nil {
-				log.Warn().
-					Str("file", filename).
-					Interface("size_value", sizeInterface).
-					Str("size_type", fmt.Sprintf("%T", sizeInterface)).
-					Msg("Unknown size type in backup data - file size will be 0")
-			}
+		// Parse file size - handle multiple numeric types from JSON
+		if size, ok := fileDataMap["size"].(float64); ok {
+			fileData.Size = int64(size)
+			log.Debug().Str("file", filename).Float64("size_float64", size).Msg("Parsed size from float64")
+		} else if size, ok := fileDataMap["size"].(int64); ok {
+			fileData.Size = size
+			log.Debug().Str("file", filename).Int64("size_int64", size).Msg("Parsed size from int64")
+		} else if size, ok := fileDataMap["size"].(int); ok {
+			fileData.Size = int64(size)
+			log.Debug().Str("file", filename).Int("size_int", size).Msg("Parsed size from int")
+		} else if sizeInterface := fileDataMap["size"]; sizeInterface != nil {
+			log.Warn().
+				Str("file", filename).
+				Interface("size_value", sizeInterface).
+				Str("size_type", fmt.Sprintf("%T", sizeInterface)).
+				Msg("Unknown size type in backup data - file size will be 0")
+		}
 
-			if checksum, ok := fileDataMap["checksum"].(string); ok {
-				fileData.Checksum = checksum
-			}
+		if checksum, ok := fileDataMap["checksum"].(string); ok {
+			fileData.Checksum = checksum
+		}
 
-			if modTimeStr, ok := fileDataMap["mod_time"].(string); ok {
-				if modTime, err := time.Parse(time.RFC3339, modTimeStr); err == nil {
-					fileData.ModTime = modTime
-				}
+		if modTimeStr, ok := fileDataMap["mod_time"].(string); ok {
+			if modTime, err := time.Parse(time.RFC3339, modTimeStr); err == nil {
+				fileData.ModTime = modTime
 			}
+		}
 
-			// Parse file content
-			if content, ok := fileDataMap["content"].(string); ok {
-				fileData.Content = []byte(content)
-
-				// Update size to match actual content length for consistency
-				actualSize := int64(len(fileData.Content))
-				if fileData.Size != actualSize {
-					log.Debug().
-						Str("file", filename).
-						Int64("stored_size", fileData.Size).
-						Int64("actual_size", actualSize).
-						Msg("Updating file size to match actual content length")
-					fileData.Size = actualSize
-				}
+		// Symlinks are stored with their target instead of content
+		if linkTarget, ok := fileDataMap["link_target"].(string); ok && linkTarget != "" {
+			fileData.LinkTarget = linkTarget
+			fileData.Permissions = os.ModeSymlink | (fileData.Permissions & os.ModePerm)
+		}
 
+		// Parse file content
+		if content, ok := fileDataMap["content"].(string); ok {
+			fileData.Content = []byte(content)
+
+			// Update size to match actual content length for consistency
+			actualSize := int64(len(fileData.Content))
+			if fileData.Size != actualSize {
 				log.Debug().
 					Str("file", filename).
-					Int64("content_length", actualSize).
-					Msg("Parsed file content successfully")
+					Int64("stored_size", fileData.Size).
+					Int64("actual_size", actualSize).
+					Msg("Updating file size to match actual content length")
+				fileData.Size = actualSize
 			}
 
-			// Parse key info if available
-			if keyInfoData, ok := fileDataMap["key_info"].(map[string]interface{}); ok {
-				keyInfo := &analyzer.KeyInfo{}
+			log.Debug().
+				Str("file", filename).
+				Int64("content_length", actualSize).
+				Msg("Parsed file content successfully")
+		}
 
-				if filename, ok := keyInfoData["filename"].(string); ok {
-					keyInfo.Filename = filename
-				}
-				if keyType, ok := keyInfoData["type"].(string); ok {
-					keyInfo.Type = analyzer.KeyType(keyType)
-				}
-				if format, ok := keyInfoData["format"].(string); ok {
-					keyInfo.Format = analyzer.KeyFormat(format)
-				}
-				if size, ok := keyInfoData["size"].(float64); ok {
-					keyInfo.Size = int64(size)
-				}
-				if perms, ok := keyInfoData["permissions"].(float64); ok {
-					keyInfo.Permissions = os.FileMode(int(perms))
-				} else if perms, ok := keyInfoData["permissions"].(int64); ok {
-					keyInfo.Permissions = os.FileMode(perms)
-				} else if perms, ok := keyInfoData["permissions"].(int); ok {
-					keyInfo.Permissions = os.FileMode(perms)
-				}
-				if modTimeStr, ok := keyInfoData["mod_time"].(string); ok {
-					if modTime, err := time.Parse(time.RFC3339, modTimeStr); err == nil {
-						keyInfo.ModTime = modTime
-					}
-				}
+		// Parse key info if available
+		if keyInfoData, ok := fileDataMap["key_info"].(map[string]interface{}); ok {
+			keyInfo := &analyzer.KeyInfo{}
 
-				fileData.KeyInfo = keyInfo
-				log.Debug().
-					Str("file", filename).
-					Str("key_type", string(keyInfo.Type)).
-					Msg("Parsed key info successfully")
+			if filename, ok := keyInfoData["filename"].(string); ok {
+				keyInfo.Filename = filename
+			}
+			if keyType, ok := keyInfoData["type"].(string); ok {
+				keyInfo.Type = analyzer.KeyType(keyType)
+			}
+			if format, ok := keyInfoData["format"].(string); ok {
+				keyInfo.Format = analyzer.KeyFormat(format)
+			}
+			if service, ok := keyInfoData["service"].(string); ok {
+				keyInfo.Service = service
+			}
+			if purpose, ok := keyInfoData["purpose"].(string); ok {
+				keyInfo.Purpose = analyzer.KeyPurpose(purpose)
+			}
+			if size, ok := keyInfoData["size"].(float64); ok {
+				keyInfo.Size = int64(size)
+			}
+			if perms, ok := keyInfoData["permissions"].(float64); ok {
+				keyInfo.Permissions = os.FileMode(int(perms))
+			} else if perms, ok := keyInfoData["permissions"].(int64); ok {
+				keyInfo.Permissions = os.FileMode(perms)
+			} else if perms, ok := keyInfoData["permissions"].(int); ok {
+				keyInfo.Permissions = os.FileMode(perms)
+			}
+			if modTimeStr, ok := keyInfoData["mod_time"].(string); ok {
+				if modTime, err := time.Parse(time.RFC3339, modTimeStr); err == nil {
+					keyInfo.ModTime = modTime
+				}
 			}
 
-			backup.Files[filename] = fileData
+			fileData.KeyInfo = keyInfo
+			log.Debug().
+				Str("file", filename).
+				Str("key_type", string(keyInfo.Type)).
+				Msg("Parsed key info successfully")
 		}
+
+		files[filename] = fileData
 	}
+	return files
+}
 
-	return backup, nil
+// parseBackupDirectories converts the recorded subdirectory modes, which are
+// absent in backups taken before recursive support
+func parseBackupDirectories(value interface{}) map[string]os.FileMode {
+	directoriesData, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	directories := make(map[string]os.FileMode, len(directoriesData))
+	for dir, modeValue := range directoriesData {
+		mode, ok := metadataNumber(modeValue)
+		if !ok {
+			log.Warn().Str("directory", dir).Msg("Invalid directory permissions in backup data; using 0700")
+			mode = 0700
+		}
+		directories[dir] = os.FileMode(mode).Perm()
+	}
+	return directories
 }
 
 // displayRestoreSummary shows what will be restored
-func displayRestoreSummary(backup *ssh.BackupData, targetDir string) {
-	fmt.Printf("\n📥 Restore Summary\n")
-	fmt.Printf("═════════════════\n")
-	fmt.Printf("Backup from: %s (%s@%s)\n",
+func displayRestoreSummary(out io.Writer, backup *ssh.BackupData, targetDir string) {
+	fmt.Fprintf(out, "\n📥 Restore Summary\n")
+	fmt.Fprintf(out, "═════════════════\n")
+	fmt.Fprintf(out, "Backup from: %s (%s@%s)\n",
 		backup.Timestamp.Format("2006-01-02 15:04:05"),
 		backup.Username,
 		backup.Hostname)
-	fmt.Printf("Source SSH dir: %s\n", backup.SSHDir)
-	fmt.Printf("Target dir: %s\n", targetDir)
-	fmt.Printf("Files to restore: %d\n", len(backup.Files))
+	fmt.Fprintf(out, "Source SSH dir: %s\n", backup.SSHDir)
+	fmt.Fprintf(out, "Target dir: %s\n", targetDir)
+	fmt.Fprintf(out, "Files to restore: %d\n", len(backup.Files))
+	if len(backup.Directories) > 0 {
+		fmt.Fprintf(out, "Directories to recreate: %d\n", len(backup.Directories))
+	}
+	for _, name := range backup.SourceNames() {
+		source := backup.Sources[name]
+		fmt.Fprintf(out, "Source %s: %d files to %s\n", name, len(source.Files), source.Dir)
+	}
 
 	// Show file list
-	fmt.Printf("\n📄 Files:\n")
+	fmt.Fprintf(out, "\n📄 Files:\n")
 	for filename, fileData := range backup.Files {
-		fmt.Printf("  • %s (%d bytes, %s)\n",
+		if fileData.IsSymlink() {
+			fmt.Fprintf(out, "  • %s -> %s (symlink)\n", filename, fileData.LinkTarget)
+			continue
+		}
+		fmt.Fprintf(out, "  • %s (%d bytes, %s)\n",
 			filename,
 			fileData.Size,
 			fileData.Permissions.String())
@@ -2,11 +2,15 @@ package cmd
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/rzago/ssh-secret-keeper/internal/config"
+	"github.com/rzago/ssh-secret-keeper/internal/ssh"
 	"github.com/rzago/ssh-secret-keeper/internal/vault"
 )
 
@@ -35,7 +39,7 @@ func TestRestoreCommand_Flags(t *testing.T) {
 	cfg := config.Default()
 	cmd := newRestoreCommand(cfg)
 
-	expectedFlags := []string{"backup", "target-dir", "dry-run", "overwrite", "interactive", "select", "files"}
+	expectedFlags := []string{"backup", "target-dir", "dry-run", "overwrite", "merge", "undo", "interactive", "select", "files", "system", "no-sources", "no-rewrite", "json", "owner", "type", "purpose", "service", "pair", "tag", "selector", "as-of"}
 
 	for _, flagName := range expectedFlags {
 		if cmd.Flag(flagName) == nil {
@@ -403,6 +407,53 @@ func TestParseVaultBackup_MultipleFilesWithMixedPermissions(t *testing.T) {
 	}
 }
 
+func TestParseVaultBackup_TreeRoundTrip(t *testing.T) {
+	original := &ssh.BackupData{
+		Version:   "1.0",
+		Timestamp: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		Files: map[string]*ssh.FileData{
+			"config.d/work": {Filename: "config.d/work", Content: []byte("Host work\n"), Permissions: 0600, Size: 10},
+			"config":        {Filename: "config", Content: []byte{}, Permissions: os.ModeSymlink | 0777, LinkTarget: "config.d/work"},
+		},
+		Directories: map[string]os.FileMode{"config.d": 0750},
+		Owner:       &ssh.Owner{User: "alice", UID: 1001, GID: 1002},
+	}
+
+	// Simulate the JSON round trip through Vault
+	encoded, err := json.Marshal(prepareVaultData(original))
+	if err != nil {
+		t.Fatalf("Failed to encode backup: %v", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var vaultData map[string]interface{}
+	if err := decoder.Decode(&vaultData); err != nil {
+		t.Fatalf("Failed to decode backup: %v", err)
+	}
+
+	backup, err := parseVaultBackup(vaultData)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if owner := backup.Owner; owner == nil || *owner != *original.Owner {
+		t.Errorf("Owner = %+v, want %+v", owner, original.Owner)
+	}
+
+	if mode := backup.Directories["config.d"]; mode != 0750 {
+		t.Errorf("config.d mode = %04o, want 0750", mode)
+	}
+
+	link := backup.Files["config"]
+	if link == nil || !link.IsSymlink() || link.LinkTarget != "config.d/work" {
+		t.Errorf("config = %+v, want symlink to config.d/work", link)
+	}
+
+	if nested := backup.Files["config.d/work"]; nested == nil || nested.IsSymlink() || string(nested.Content) != "Host work\n" {
+		t.Errorf("config.d/work = %+v", nested)
+	}
+}
+
 func TestCrossStrategyRestore(t *testing.T) {
 	// Test that backups created with one strategy can be restored with another
 	// This is critical for cross-machine and cross-user restore scenarios
@@ -768,3 +819,53 @@ func TestRestoreCommandCrossStrategyScenarios(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateRestoreFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    restoreOptions
+		wantErr bool
+	}{
+		{"none", restoreOptions{}, false},
+		{"known values", restoreOptions{types: []string{"private_key", "config"}, purposes: []string{"Work"}, services: []string{"github"}, pairs: []string{"id_*"}}, false},
+		{"unknown type", restoreOptions{types: []string{"private"}}, true},
+		{"unknown purpose", restoreOptions{purposes: []string{"hobby"}}, true},
+		{"invalid pair pattern", restoreOptions{pairs: []string{"["}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateRestoreFilters(tt.opts); (err != nil) != tt.wantErr {
+				t.Errorf("validateRestoreFilters() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetLatestBackupName(t *testing.T) {
+	ctx := context.Background()
+	provider := newMemoryStorage()
+
+	if _, err := getLatestBackupName(ctx, provider); err == nil {
+		t.Error("getLatestBackupName() without backups expected an error")
+	}
+
+	// Names do not sort by age; the index timestamps decide
+	base := time.Date(2026, 9, 29, 12, 0, 0, 0, time.UTC)
+	index := map[string]interface{}{}
+	for i, name := range []string{"zeta", "alpha", "work-laptop"} {
+		provider.backups[name] = map[string]interface{}{}
+		index[name] = map[string]interface{}{
+			"timestamp": base.Add(time.Duration(i) * time.Hour).Format(time.RFC3339),
+			"hostname":  "laptop",
+		}
+	}
+	provider.metadata["backups"] = index
+
+	name, err := getLatestBackupName(ctx, provider)
+	if err != nil {
+		t.Fatalf("getLatestBackupName() error = %v", err)
+	}
+	if name != "work-laptop" {
+		t.Errorf("getLatestBackupName() = %q, want work-laptop", name)
+	}
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"os"
 
 	"github.com/rs/zerolog/log"
@@ -15,6 +16,20 @@ var (
 	GitHash   = "unknown"
 )
 
+// Exit codes returned by the sshsk binary
+const (
+	ExitCodeError     = 1
+	ExitCodeNoChanges = 3 // 'sshsk backup' found nothing new to back up
+)
+
+// ExitCode maps an error returned by the root command to the process exit code
+func ExitCode(err error) int {
+	if errors.Is(err, ErrNoChanges) {
+		return ExitCodeNoChanges
+	}
+	return ExitCodeError
+}
+
 // NewRootCommand creates the root command
 func NewRootCommand(cfg *config.Config) *cobra.Command {
 	var rootCmd = &cobra.Command{
@@ -44,12 +59,21 @@ flexible backup/restore operations.`,
 		newInitCommand(cfg),
 		newBackupCommand(cfg),
 		newRestoreCommand(cfg),
+		newDiffCommand(cfg),
+		newAgentLoadCommand(cfg),
+		newExportCommand(cfg),
+		newImportCommand(cfg),
 		newListCommand(cfg),
 		newDeleteCommand(cfg),
 		newAnalyzeCommand(cfg),
 		newStatusCommand(cfg),
 		newMigrateCommand(cfg),
 		newMigrateStatusCommand(cfg),
+		newRepairCommand(cfg),
+		newPolicyCommand(cfg),
+		newPruneCommand(cfg),
+		newWatchCommand(cfg),
+		newScheduleCommand(cfg),
 		newVersionCommand(),
 		newUpdateCommand(cfg),
 	)
@@ -53,7 +53,7 @@ func TestRootCommand_Subcommands(t *testing.T) {
 	cmd := NewRootCommand(cfg)
 
 	expectedCommands := []string{
-		"init", "backup", "restore", "list", "delete", "analyze", "status", "version",
+		"init", "backup", "restore", "diff", "agent-load", "export", "import", "list", "delete", "analyze", "status", "version", "repair", "prune", "watch", "schedule",
 	}
 
 	for _, expectedCmd := range expectedCommands {
@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+	"github.com/rzago/ssh-secret-keeper/internal/config"
+	"github.com/rzago/ssh-secret-keeper/internal/schedule"
+	"github.com/spf13/cobra"
+)
+
+// newScheduleCommand creates the schedule command with its subcommands
+func newScheduleCommand(cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "schedule",
+		Short: "Run backups periodically with a systemd timer or cron",
+		Long: `Install, inspect and remove a periodic 'sshsk backup' job.
+
+A systemd user service and timer are used when a systemd user manager is
+available; otherwise a crontab entry is installed. Each run creates a
+timestamp-named backup, and runs with no changes since the latest backup are
+skipped.`,
+	}
+
+	cmd.AddCommand(
+		newScheduleInstallCommand(cfg),
+		newScheduleStatusCommand(),
+		newScheduleRemoveCommand(),
+	)
+
+	return cmd
+}
+
+type scheduleOptions struct {
+	every string
+	cron  bool
+}
+
+// newScheduleInstallCommand creates the schedule install command
+func newScheduleInstallCommand(cfg *config.Config) *cobra.Command {
+	var opts scheduleOptions
+
+	cmd := &cobra.Command{
+		Use:   "install",
+		Short: "Install a periodic backup job",
+		Long: `Install a periodic backup job for the current user.
+
+VAULT_ADDR and the other Vault connection variables (VAULT_NAMESPACE,
+VAULT_CACERT, VAULT_CAPATH, SSHSK_VAULT_TOKEN_FILE) are copied from the
+current environment into the job; without VAULT_ADDR, vault.address from the
+configuration is used. VAULT_TOKEN is never copied; the job reads the token
+from the configured token file written by 'sshsk init'.
+
+Cron can only run intervals that divide an hour or a day evenly.
+
+Examples:
+  # Back up every 6 hours
+  sshsk schedule install --every 6h
+
+  # Back up daily using cron even if systemd is available
+  sshsk schedule install --every 1d --cron`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runScheduleInstall(cfg, opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.every, "every", "6h", "Backup interval, e.g. 30m, 6h or 1d")
+	cmd.Flags().BoolVar(&opts.cron, "cron", false, "Install a crontab entry instead of a systemd timer")
+
+	return cmd
+}
+
+// newScheduleStatusCommand creates the schedule status command
+func newScheduleStatusCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show the installed backup job",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runScheduleStatus()
+		},
+	}
+}
+
+// newScheduleRemoveCommand creates the schedule remove command
+func newScheduleRemoveCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove",
+		Short: "Remove the installed backup job",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runScheduleRemove()
+		},
+	}
+}
+
+func runScheduleInstall(cfg *config.Config, opts scheduleOptions) error {
+	every, err := schedule.ParseInterval(opts.every)
+	if err != nil {
+		return err
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("cannot determine sshsk binary path: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+		executable = resolved
+	}
+
+	job := schedule.Job{
+		Every:   every,
+		Command: []string{executable, "backup"},
+		Env:     scheduleEnv(cfg, os.Getenv),
+	}
+
+	installer, err := schedule.NewInstaller()
+	if err != nil {
+		return err
+	}
+
+	backend := schedule.BackendCron
+	if !opts.cron {
+		backend = installer.DetectBackend()
+	}
+
+	log.Info().
+		Str("backend", string(backend)).
+		Dur("every", every).
+		Str("command", executable).
+		Msg("Installing backup schedule")
+
+	if err := installer.Install(job, backend); err != nil {
+		return fmt.Errorf("failed to install %s schedule: %w", backend, err)
+	}
+
+	fmt.Printf("✅ Scheduled backups every %s using %s\n", opts.every, backend)
+	if backend == schedule.BackendSystemd {
+		fmt.Printf("  Units: %s/%s.{service,timer}\n", installer.UnitDir(), schedule.UnitName)
+		fmt.Printf("  Logs:  journalctl --user -u %s.service\n", schedule.UnitName)
+	} else {
+		fmt.Printf("  Entry added to your crontab (crontab -l)\n")
+	}
+
+	warnMissingTokenFile(cfg)
+	return nil
+}
+
+// scheduleEnv returns the environment of a scheduled job: the Vault
+// connection variables that are set, with VAULT_ADDR defaulting to the
+// configured vault.address, since scheduled backups require it
+func scheduleEnv(cfg *config.Config, getenv func(string) string) map[string]string {
+	env := schedule.EnvFromEnvironment(getenv)
+	if env["VAULT_ADDR"] == "" && cfg.Vault.Address != "" {
+		env["VAULT_ADDR"] = cfg.Vault.Address
+	}
+	return env
+}
+
+// warnMissingTokenFile points out that scheduled jobs cannot use VAULT_TOKEN
+func warnMissingTokenFile(cfg *config.Config) {
+	tokenFile := cfg.Vault.TokenFile
+	if envFile := os.Getenv("SSHSK_VAULT_TOKEN_FILE"); envFile != "" {
+		tokenFile = envFile
+	}
+
+	if _, err := os.Stat(tokenFile); err == nil {
+		return
+	}
+
+	fmt.Printf("\n⚠️  Token file %s does not exist.\n", tokenFile)
+	if os.Getenv("VAULT_TOKEN") != "" {
+		fmt.Printf("   VAULT_TOKEN is not copied into scheduled jobs, so they cannot authenticate.\n")
+	}
+	fmt.Printf("   Run 'sshsk init' to store a token for scheduled backups.\n")
+}
+
+func runScheduleStatus() error {
+	installer, err := schedule.NewInstaller()
+	if err != nil {
+		return err
+	}
+
+	status, err := installer.Status()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("⏰ Backup Schedule\n")
+	fmt.Printf("══════════════════\n")
+
+	if !status.Installed() {
+		fmt.Printf("No backup schedule installed.\n")
+		fmt.Printf("\n💡 Install one with: sshsk schedule install --every 6h\n")
+		return nil
+	}
+
+	if status.Systemd {
+		fmt.Printf("systemd timer: %s\n", status.TimerState)
+		fmt.Printf("  Service: %s\n", status.ServicePath)
+		fmt.Printf("  Timer:   %s\n", status.TimerPath)
+		if status.NextRun != "" {
+			fmt.Printf("\n%s\n", status.NextRun)
+		}
+	}
+
+	if status.Cron {
+		if status.Systemd {
+			fmt.Printf("\n")
+		}
+		fmt.Printf("crontab entry:\n  %s\n", status.CronLine)
+	}
+
+	return nil
+}
+
+func runScheduleRemove() error {
+	installer, err := schedule.NewInstaller()
+	if err != nil {
+		return err
+	}
+
+	removed, err := installer.Remove()
+	for _, item := range removed {
+		fmt.Printf("🗑️  Removed %s\n", item)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(removed) == 0 {
+		fmt.Printf("No backup schedule installed.\n")
+		return nil
+	}
+
+	fmt.Printf("✅ Backup schedule removed\n")
+	return nil
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/rzago/ssh-secret-keeper/internal/config"
+)
+
+func TestNewScheduleCommand(t *testing.T) {
+	cmd := newScheduleCommand(config.Default())
+
+	if cmd.Use != "schedule" {
+		t.Errorf("Expected command use 'schedule', got '%s'", cmd.Use)
+	}
+
+	subcommands := make(map[string]bool)
+	for _, sub := range cmd.Commands() {
+		subcommands[sub.Name()] = true
+	}
+	for _, name := range []string{"install", "status", "remove"} {
+		if !subcommands[name] {
+			t.Errorf("Expected subcommand '%s' to be present", name)
+		}
+	}
+
+	install, _, err := cmd.Find([]string{"install"})
+	if err != nil {
+		t.Fatalf("Find(install) error = %v", err)
+	}
+	if got := install.Flag("every").DefValue; got != "6h" {
+		t.Errorf("every default = %s, want 6h", got)
+	}
+	if install.Flag("cron") == nil {
+		t.Error("Expected --cron flag to be present")
+	}
+}
+
+func TestScheduleEnv(t *testing.T) {
+	cfg := config.Default()
+	cfg.Vault.Address = "https://vault.example.com:8200"
+
+	env := scheduleEnv(cfg, func(string) string { return "" })
+	if got := env["VAULT_ADDR"]; got != cfg.Vault.Address {
+		t.Errorf("VAULT_ADDR = %q, want configured address %q", got, cfg.Vault.Address)
+	}
+
+	env = scheduleEnv(cfg, func(key string) string {
+		if key == "VAULT_ADDR" {
+			return "https://other.example.com:8200"
+		}
+		return ""
+	})
+	if got := env["VAULT_ADDR"]; got != "https://other.example.com:8200" {
+		t.Errorf("VAULT_ADDR = %q, want the environment value", got)
+	}
+}
@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/rzago/ssh-secret-keeper/internal/interfaces"
+	"github.com/spf13/cobra"
+)
+
+// selectorKeys are the backup attributes a selector can match on
+var selectorKeys = []string{"name", "hostname", "username", "tag"}
+
+// selectorRequirement matches one attribute against a glob pattern
+type selectorRequirement struct {
+	key     string
+	pattern string
+}
+
+// backupSelector filters backups by tags and key=value selectors. Every
+// requirement must match; a nil selector matches every backup.
+type backupSelector struct {
+	requirements []selectorRequirement
+}
+
+// newBackupSelector builds a selector from --tag and --selector values.
+// --tag x is shorthand for --selector tag=x. Values may use glob patterns.
+func newBackupSelector(tags, selectors []string) (*backupSelector, error) {
+	sel := &backupSelector{}
+
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			return nil, fmt.Errorf("tag cannot be empty")
+		}
+		sel.requirements = append(sel.requirements, selectorRequirement{key: "tag", pattern: tag})
+	}
+
+	for _, selector := range selectors {
+		key, pattern, ok := strings.Cut(selector, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || pattern == "" {
+			return nil, fmt.Errorf("invalid selector %q: expected key=value", selector)
+		}
+		if !isSelectorKey(key) {
+			return nil, fmt.Errorf("invalid selector %q: key must be one of %s", selector, strings.Join(selectorKeys, ", "))
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %w", selector, err)
+		}
+		sel.requirements = append(sel.requirements, selectorRequirement{key: key, pattern: pattern})
+	}
+
+	return sel, nil
+}
+
+// hostnameSelector selects the backups taken on hostname, or every backup if hostname is empty
+func hostnameSelector(hostname string) *backupSelector {
+	if hostname == "" {
+		return nil
+	}
+	return &backupSelector{requirements: []selectorRequirement{{key: "hostname", pattern: hostname}}}
+}
+
+// addHostname narrows the selector to backups taken on hostname
+func (s *backupSelector) addHostname(hostname string) *backupSelector {
+	if hostname == "" {
+		return s
+	}
+	if s == nil {
+		return hostnameSelector(hostname)
+	}
+	s.requirements = append(s.requirements, selectorRequirement{key: "hostname", pattern: hostname})
+	return s
+}
+
+// addUsername narrows the selector to backups of username
+func (s *backupSelector) addUsername(username string) *backupSelector {
+	if username == "" {
+		return s
+	}
+	if s == nil {
+		s = &backupSelector{}
+	}
+	s.requirements = append(s.requirements, selectorRequirement{key: "username", pattern: username})
+	return s
+}
+
+// IsEmpty reports whether the selector matches every backup
+func (s *backupSelector) IsEmpty() bool {
+	return s == nil || len(s.requirements) == 0
+}
+
+// Matches reports whether a backup satisfies every requirement
+func (s *backupSelector) Matches(info backupInfo) bool {
+	if s == nil {
+		return true
+	}
+
+	for _, req := range s.requirements {
+		var values []string
+		switch req.key {
+		case "name":
+			values = []string{info.Name}
+		case "hostname":
+			values = []string{info.Hostname}
+		case "username":
+			values = []string{info.Username}
+		case "tag":
+			values = info.Tags
+		}
+
+		matched := false
+		for _, value := range values {
+			if ok, _ := path.Match(req.pattern, value); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the selector in key=value form
+func (s *backupSelector) String() string {
+	if s.IsEmpty() {
+		return ""
+	}
+	parts := make([]string, len(s.requirements))
+	for i, req := range s.requirements {
+		parts[i] = req.key + "=" + req.pattern
+	}
+	return strings.Join(parts, ",")
+}
+
+func isSelectorKey(key string) bool {
+	for _, k := range selectorKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// addSelectorFlags registers --tag and --selector on a command
+func addSelectorFlags(cmd *cobra.Command, tags, selectors *[]string) {
+	cmd.Flags().StringSliceVar(tags, "tag", nil, "Only backups with this tag (repeatable, glob patterns allowed)")
+	cmd.Flags().StringSliceVar(selectors, "selector", nil, "Only backups matching key=value, where key is name, hostname, username or tag (repeatable)")
+}
+
+// loadBackupInfos returns every stored backup with its index information.
+// Backups missing from the index, or with incomplete entries, are read directly.
+func loadBackupInfos(ctx context.Context, provider interfaces.StorageProvider) ([]backupInfo, error) {
+	names, err := provider.ListBackups(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	metadata, err := provider.GetMetadata(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to read metadata index, reading backups directly")
+	}
+
+	infos := backupInfosFromMetadata(metadata, names)
+	for i := range infos {
+		if !infos[i].Timestamp.IsZero() && infos[i].Hostname != "" {
+			continue
+		}
+
+		vaultData, err := provider.GetBackup(ctx, infos[i].Name)
+		if err != nil {
+			log.Debug().Err(err).Str("backup", infos[i].Name).Msg("Cannot read backup details")
+			continue
+		}
+		backup, err := parseVaultBackup(vaultData)
+		if err != nil {
+			log.Debug().Err(err).Str("backup", infos[i].Name).Msg("Cannot parse backup details")
+			continue
+		}
+
+		infos[i].Timestamp = backup.Timestamp
+		infos[i].Hostname = backup.Hostname
+		infos[i].Username = backup.Username
+		infos[i].FileCount = len(backup.Files)
+		infos[i].Tags = backup.Tags
+		infos[i].Description = backup.Description
+	}
+
+	return infos, nil
+}
+
+// selectBackups returns the backups matching sel, most recent first
+func selectBackups(ctx context.Context, provider interfaces.StorageProvider, sel *backupSelector) ([]backupInfo, error) {
+	infos, err := loadBackupInfos(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []backupInfo
+	for _, info := range infos {
+		if sel.Matches(info) {
+			selected = append(selected, info)
+		}
+	}
+
+	sort.SliceStable(selected, func(i, j int) bool {
+		return selected[i].Timestamp.After(selected[j].Timestamp)
+	})
+	return selected, nil
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewBackupSelector(t *testing.T) {
+	valid := []struct {
+		tags      []string
+		selectors []string
+		want      string
+	}{
+		{nil, nil, ""},
+		{[]string{"laptop"}, nil, "tag=laptop"},
+		{[]string{"laptop"}, []string{"hostname=web-*", "username=alice"}, "tag=laptop,hostname=web-*,username=alice"},
+		{nil, []string{"name=backup-2026*"}, "name=backup-2026*"},
+	}
+	for _, tt := range valid {
+		sel, err := newBackupSelector(tt.tags, tt.selectors)
+		if err != nil {
+			t.Errorf("newBackupSelector(%v, %v) error = %v", tt.tags, tt.selectors, err)
+			continue
+		}
+		if got := sel.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+
+	invalid := [][]string{{"hostname"}, {"=laptop"}, {"color=red"}, {"hostname="}, {"tag=[abc"}}
+	for _, selectors := range invalid {
+		if _, err := newBackupSelector(nil, selectors); err == nil {
+			t.Errorf("newBackupSelector(%v) should fail", selectors)
+		}
+	}
+}
+
+func TestBackupSelector_Matches(t *testing.T) {
+	info := backupInfo{
+		Name:     "backup-20261018-090000",
+		Hostname: "web-01",
+		Username: "alice",
+		Tags:     []string{"laptop", "pre-rotation"},
+	}
+
+	tests := []struct {
+		tags      []string
+		selectors []string
+		want      bool
+	}{
+		{nil, nil, true},
+		{[]string{"laptop"}, nil, true},
+		{[]string{"laptop", "pre-rotation"}, nil, true},
+		{[]string{"laptop", "ci"}, nil, false},
+		{[]string{"pre-*"}, []string{"hostname=web-*"}, true},
+		{nil, []string{"username=bob"}, false},
+		{nil, []string{"name=backup-2026*"}, true},
+	}
+
+	for _, tt := range tests {
+		sel, err := newBackupSelector(tt.tags, tt.selectors)
+		if err != nil {
+			t.Fatalf("newBackupSelector() error = %v", err)
+		}
+		if got := sel.Matches(info); got != tt.want {
+			t.Errorf("Matches(tags=%v, selectors=%v) = %v, want %v", tt.tags, tt.selectors, got, tt.want)
+		}
+	}
+
+	var nilSelector *backupSelector
+	if !nilSelector.Matches(info) || !nilSelector.IsEmpty() {
+		t.Error("nil selector should match everything")
+	}
+}
+
+func TestSelectBackups(t *testing.T) {
+	ctx := context.Background()
+	provider := newMemoryStorage()
+	base := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	index := map[string]interface{}{}
+	for i, tags := range [][]interface{}{{"laptop"}, {"laptop", "ci"}, {"ci"}} {
+		name := []string{"old", "newer", "other"}[i]
+		provider.backups[name] = map[string]interface{}{}
+		index[name] = map[string]interface{}{
+			"timestamp": base.Add(time.Duration(i) * time.Hour).Format(time.RFC3339),
+			"hostname":  "laptop",
+			"tags":      tags, // Vault returns lists as []interface{}
+		}
+	}
+	provider.metadata["backups"] = index
+
+	// Unindexed backups are read directly
+	unindexed := storedBackup("laptop", base.Add(3*time.Hour))
+	unindexed["tags"] = []interface{}{"laptop"}
+	provider.backups["unindexed"] = unindexed
+
+	sel, err := newBackupSelector([]string{"laptop"}, nil)
+	if err != nil {
+		t.Fatalf("newBackupSelector() error = %v", err)
+	}
+
+	matches, err := selectBackups(ctx, provider, sel)
+	if err != nil {
+		t.Fatalf("selectBackups() error = %v", err)
+	}
+
+	var names []string
+	for _, match := range matches {
+		names = append(names, match.Name)
+	}
+	if want := []string{"unindexed", "newer", "old"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("selectBackups() = %v, want %v", names, want)
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	tags, err := normalizeTags([]string{" laptop", "pre-rotation", "laptop"})
+	if err != nil {
+		t.Fatalf("normalizeTags() error = %v", err)
+	}
+	if want := []string{"laptop", "pre-rotation"}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("normalizeTags() = %v, want %v", tags, want)
+	}
+
+	for _, bad := range []string{"", "two words", "a=b", "glob*"} {
+		if _, err := normalizeTags([]string{bad}); err == nil {
+			t.Errorf("normalizeTags(%q) should fail", bad)
+		}
+	}
+}
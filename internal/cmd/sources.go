@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+	"github.com/rzago/ssh-secret-keeper/internal/analyzer"
+	"github.com/rzago/ssh-secret-keeper/internal/config"
+	"github.com/rzago/ssh-secret-keeper/internal/files"
+	"github.com/rzago/ssh-secret-keeper/internal/filter"
+	"github.com/rzago/ssh-secret-keeper/internal/ssh"
+	"github.com/rzago/ssh-secret-keeper/internal/utils"
+)
+
+// defaultSourceMode is used for source files without recorded permissions
+// when the source configures no mode
+const defaultSourceMode os.FileMode = 0600
+
+// sourceNamePattern restricts source names to what is safe in messages and keys
+var sourceNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// validateSources checks backup.sources before anything is read
+func validateSources(sources []config.SourceConfig) error {
+	seen := make(map[string]bool, len(sources))
+	for i, source := range sources {
+		if !sourceNamePattern.MatchString(source.Name) {
+			return fmt.Errorf("backup.sources[%d]: invalid name %q: use letters, digits, '.', '_' and '-'", i, source.Name)
+		}
+		if seen[source.Name] {
+			return fmt.Errorf("backup.sources: duplicate source name %q", source.Name)
+		}
+		seen[source.Name] = true
+
+		if source.Dir == "" {
+			return fmt.Errorf("backup.sources %s: dir is required", source.Name)
+		}
+		if _, err := sourceFileMode(source); err != nil {
+			return fmt.Errorf("backup.sources %s: %w", source.Name, err)
+		}
+		if _, err := analyzer.ForName(source.Analyzer); err != nil {
+			return fmt.Errorf("backup.sources %s: %w", source.Name, err)
+		}
+	}
+	return nil
+}
+
+// sourceFileMode parses the octal default file mode of a source
+func sourceFileMode(source config.SourceConfig) (os.FileMode, error) {
+	if source.Mode == "" {
+		return defaultSourceMode, nil
+	}
+	mode, err := strconv.ParseUint(source.Mode, 8, 32)
+	if err != nil || mode == 0 || mode > 0777 {
+		return 0, fmt.Errorf("invalid mode %q: expected an octal permission such as 0600", source.Mode)
+	}
+	return os.FileMode(mode), nil
+}
+
+// sourceDirMode derives a directory mode from a file mode, adding search
+// permission wherever read permission is granted (0600 becomes 0700)
+func sourceDirMode(fileMode os.FileMode) os.FileMode {
+	perm := fileMode.Perm()
+	return perm | (perm&0444)>>2
+}
+
+// buildSourceFilter assembles the rules for a source. Without include patterns
+// everything is backed up; with them only matching files are, in any
+// subdirectory. The source's own ignore file applies last.
+func buildSourceFilter(source config.SourceConfig, dir string) (*filter.Matcher, error) {
+	matcher := filter.New()
+	if len(source.Include) > 0 {
+		origin := "sources." + source.Name
+		matcher.AddExcludes([]string{"*", "!*/"}, origin)
+		matcher.AddIncludes(source.Include, origin+".include")
+	}
+	if err := matcher.LoadIgnoreFile(filepath.Join(dir, filter.IgnoreFileName)); err != nil {
+		return nil, err
+	}
+	return matcher, nil
+}
+
+// readSources reads the configured sources with each source's analyzer.
+// Sources whose directory does not exist on this machine are skipped.
+func readSources(sources []config.SourceConfig) (map[string]*ssh.SourceData, error) {
+	if len(sources) == 0 {
+		return nil, nil
+	}
+
+	result := make(map[string]*ssh.SourceData, len(sources))
+	for _, source := range sources {
+		dir, err := utils.NewPathNormalizer().ResolvePath(source.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve source %s: %w", source.Name, err)
+		}
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			log.Info().Str("source", source.Name).Str("dir", dir).Msg("Source directory does not exist, skipping")
+			fmt.Printf("⏭️  Source '%s' skipped: %s does not exist\n", source.Name, dir)
+			continue
+		}
+
+		sourceAnalyzer, err := analyzer.ForName(source.Analyzer)
+		if err != nil {
+			return nil, err
+		}
+		matcher, err := buildSourceFilter(source, dir)
+		if err != nil {
+			return nil, err
+		}
+		mode, err := sourceFileMode(source)
+		if err != nil {
+			return nil, err
+		}
+
+		handler := ssh.NewWithAnalyzer(sourceAnalyzer)
+		handler.SetFilter(matcher)
+
+		fmt.Printf("Analyzing source '%s': %s\n", source.Name, dir)
+		data, err := handler.ReadSource(source.Name, source.Dir)
+		if err != nil {
+			return nil, err
+		}
+		data.DefaultMode = mode
+		data.Analyzer = source.Analyzer
+		result[source.Name] = data
+	}
+	return result, nil
+}
+
+// encodeSources converts sources for storage, using the same file and
+// directory encoding as the SSH directory
+func encodeSources(sources map[string]*ssh.SourceData) map[string]interface{} {
+	encoded := make(map[string]interface{}, len(sources))
+	for name, source := range sources {
+		entry := map[string]interface{}{
+			"dir":          source.Dir,
+			"dir_mode":     int(source.DirMode.Perm()),
+			"default_mode": int(source.DefaultMode.Perm()),
+			"files":        encodeFiles(source.Files),
+		}
+		if source.Analyzer != "" {
+			entry["analyzer"] = source.Analyzer
+		}
+		if len(source.Directories) > 0 {
+			entry["directories"] = encodeDirectories(source.Directories)
+		}
+		encoded[name] = entry
+	}
+	return encoded
+}
+
+// parseBackupSources converts stored sources back; backups taken before
+// sources were supported have none
+func parseBackupSources(value interface{}) map[string]*ssh.SourceData {
+	sourcesData, ok := value.(map[string]interface{})
+	if !ok || len(sourcesData) == 0 {
+		return nil
+	}
+
+	sources := make(map[string]*ssh.SourceData, len(sourcesData))
+	for name, entry := range sourcesData {
+		sourceData, ok := entry.(map[string]interface{})
+		if !ok {
+			log.Warn().Str("source", name).Msg("Invalid source in backup data, skipping")
+			continue
+		}
+
+		source := &ssh.SourceData{Name: name, DefaultMode: defaultSourceMode}
+		source.Dir, _ = sourceData["dir"].(string)
+		source.Analyzer, _ = sourceData["analyzer"].(string)
+		if mode, ok := metadataNumber(sourceData["default_mode"]); ok && mode != 0 {
+			source.DefaultMode = os.FileMode(mode).Perm()
+		}
+		source.DirMode = sourceDirMode(source.DefaultMode)
+		if mode, ok := metadataNumber(sourceData["dir_mode"]); ok && mode != 0 {
+			source.DirMode = os.FileMode(mode).Perm()
+		}
+
+		filesData, _ := sourceData["files"].(map[string]interface{})
+		source.Files = parseBackupFiles(filesData, source.DefaultMode)
+		source.Directories = parseBackupDirectories(sourceData["directories"])
+		sources[name] = source
+	}
+	return sources
+}
+
+// restoreSources restores every source of a backup to its directory, resolved
+// for the current user, with the options of the SSH directory restore.
+// --files only applies to the SSH directory.
+func restoreSources(out io.Writer, backup *ssh.BackupData, restoreService *files.RestoreService, options ssh.RestoreOptions) error {
+	for _, name := range backup.SourceNames() {
+		source := backup.Sources[name]
+		dir, err := utils.NewPathNormalizer().ResolvePathWithHome(source.Dir, options.HomeDir)
+		if err != nil {
+			return fmt.Errorf("failed to resolve source %s: %w", name, err)
+		}
+
+		sourceOptions := options.WithoutFilters()
+		sourceOptions.DirMode = source.DirMode
+
+		fmt.Fprintf(out, "Restoring source '%s' to %s...\n", name, dir)
+		if err := restoreService.RestoreFiles(source.AsBackup(), dir, sourceOptions); err != nil {
+			return fmt.Errorf("failed to restore source %s: %w", name, err)
+		}
+	}
+	return nil
+}
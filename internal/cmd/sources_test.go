@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rzago/ssh-secret-keeper/internal/config"
+	"github.com/rzago/ssh-secret-keeper/internal/files"
+	"github.com/rzago/ssh-secret-keeper/internal/ssh"
+)
+
+func TestValidateSources(t *testing.T) {
+	tests := []struct {
+		name    string
+		sources []config.SourceConfig
+		wantErr string
+	}{
+		{"valid", []config.SourceConfig{{Name: "kube", Dir: "~/.kube", Mode: "0600", Analyzer: "generic"}}, ""},
+		{"invalid name", []config.SourceConfig{{Name: "../kube", Dir: "~/.kube"}}, "invalid name"},
+		{"duplicate", []config.SourceConfig{{Name: "kube", Dir: "~/.kube"}, {Name: "kube", Dir: "~/.kube2"}}, "duplicate"},
+		{"missing dir", []config.SourceConfig{{Name: "kube"}}, "dir is required"},
+		{"bad mode", []config.SourceConfig{{Name: "kube", Dir: "~/.kube", Mode: "rw"}}, "invalid mode"},
+		{"unknown analyzer", []config.SourceConfig{{Name: "kube", Dir: "~/.kube", Analyzer: "yaml"}}, "unknown analyzer"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSources(tt.sources)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateSources() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateSources() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSourceDirMode(t *testing.T) {
+	for fileMode, want := range map[os.FileMode]os.FileMode{0600: 0700, 0644: 0755, 0640: 0750, 0400: 0500} {
+		if got := sourceDirMode(fileMode); got != want {
+			t.Errorf("sourceDirMode(%04o) = %04o, want %04o", fileMode, got, want)
+		}
+	}
+}
+
+func TestReadSources(t *testing.T) {
+	dir := t.TempDir()
+	gh := filepath.Join(dir, "gh")
+	if err := os.MkdirAll(filepath.Join(gh, "cache"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"hosts.yml":       "github.com:\n  oauth_token: secret\n",
+		"config.yml":      "git_protocol: ssh\n",
+		"cache/hosts.yml": "nested\n",
+		"cache/blob":      "cache\n",
+	} {
+		if err := os.WriteFile(filepath.Join(gh, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sources, err := readSources([]config.SourceConfig{
+		{Name: "gh", Dir: gh, Include: []string{"hosts.yml"}, Mode: "0640"},
+		{Name: "kube", Dir: filepath.Join(dir, "missing")},
+	})
+	if err != nil {
+		t.Fatalf("readSources() error = %v", err)
+	}
+
+	if _, ok := sources["kube"]; ok {
+		t.Error("a missing source directory should be skipped")
+	}
+	source := sources["gh"]
+	if source == nil {
+		t.Fatal("source gh was not read")
+	}
+	if len(source.Files) != 2 || source.Files["hosts.yml"] == nil || source.Files["cache/hosts.yml"] == nil {
+		t.Errorf("files = %v, want only hosts.yml in any directory", source.AsBackup().FileNames())
+	}
+	if source.DefaultMode != 0640 || source.DirMode != 0700 || source.Dir != gh {
+		t.Errorf("source = %+v", source)
+	}
+}
+
+func TestSources_RoundTripAndRestore(t *testing.T) {
+	target := filepath.Join(t.TempDir(), ".kube")
+	original := &ssh.BackupData{
+		Files: map[string]*ssh.FileData{},
+		Sources: map[string]*ssh.SourceData{
+			"kube": {
+				Name:        "kube",
+				Dir:         target,
+				DirMode:     0750,
+				DefaultMode: 0640,
+				Analyzer:    "generic",
+				Files: map[string]*ssh.FileData{
+					"config":       {Filename: "config", Content: []byte("apiVersion: v1\n"), Permissions: 0600, Checksum: "x"},
+					"cache/tokens": {Filename: "cache/tokens", Content: []byte("t\n"), Permissions: 0600},
+				},
+				Directories: map[string]os.FileMode{"cache": 0700},
+			},
+		},
+	}
+
+	// Simulate the JSON round trip through Vault
+	encoded, err := json.Marshal(prepareVaultData(original))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var vaultData map[string]interface{}
+	if err := decoder.Decode(&vaultData); err != nil {
+		t.Fatal(err)
+	}
+
+	backup, err := parseVaultBackup(vaultData)
+	if err != nil {
+		t.Fatalf("parseVaultBackup() error = %v", err)
+	}
+	source := backup.Sources["kube"]
+	if source == nil {
+		t.Fatal("source kube missing after round trip")
+	}
+	if source.Dir != target || source.DirMode != 0750 || source.DefaultMode != 0640 || source.Analyzer != "generic" {
+		t.Errorf("source = %+v", source)
+	}
+	if source.Directories["cache"] != 0700 || string(source.Files["config"].Content) != "apiVersion: v1\n" {
+		t.Errorf("source tree = %+v, %+v", source.Directories, source.Files)
+	}
+
+	// --files only narrows the SSH directory
+	options := ssh.RestoreOptions{Overwrite: true, FileFilter: []string{"id_*"}}
+	if err := restoreSources(io.Discard, backup, files.NewRestoreService(), options); err != nil {
+		t.Fatalf("restoreSources() error = %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(target, "cache", "tokens"))
+	if err != nil || string(content) != "t\n" {
+		t.Errorf("restored cache/tokens = %q, %v", content, err)
+	}
+	if stat, err := os.Stat(target); err != nil || stat.Mode().Perm() != 0750 {
+		t.Errorf("restored source directory = %v, %v; want mode 0750", stat, err)
+	}
+}
+
+func TestParseBackupSources_MissingPermissions(t *testing.T) {
+	sources := parseBackupSources(map[string]interface{}{
+		"gnupg": map[string]interface{}{
+			"dir":          "~/.gnupg",
+			"default_mode": json.Number("384"), // 0600
+			"files": map[string]interface{}{
+				"pubring.kbx": map[string]interface{}{"content": "ring"},
+			},
+		},
+	})
+
+	source := sources["gnupg"]
+	if source == nil {
+		t.Fatal("source gnupg missing")
+	}
+	if mode := source.Files["pubring.kbx"].Permissions; mode != 0600 {
+		t.Errorf("fallback permissions = %04o, want the source default 0600", mode)
+	}
+	if source.DirMode != 0700 {
+		t.Errorf("DirMode = %04o, want 0700 derived from the default mode", source.DirMode)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"sort"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/rzago/ssh-secret-keeper/internal/config"
@@ -22,6 +23,9 @@ func newStatusCommand(cfg *config.Config) *cobra.Command {
 		checkSSH      bool
 		showChecksums bool
 		backupName    string
+		tags          []string
+		selectors     []string
+		asOf          string
 	)
 
 	cmd := &cobra.Command{
@@ -32,7 +36,12 @@ func newStatusCommand(cfg *config.Config) *cobra.Command {
 - Vault connection
 - SSH directory analysis
 - Recent backup information
-- File MD5 checksums (with --checksums flag)`,
+- File MD5 checksums (with --checksums flag)
+
+--tag, --selector and --as-of show the details of the most recent matching
+backup, or of the newest taken at or before a time, as chosen by restore:
+
+  sshsk status --as-of 2026-09-30T12:00 --tag laptop`,
 		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if len(args) > 0 {
@@ -43,6 +52,9 @@ func newStatusCommand(cfg *config.Config) *cobra.Command {
 				checkSSH:      checkSSH,
 				showChecksums: showChecksums,
 				backupName:    backupName,
+				tags:          tags,
+				selectors:     selectors,
+				asOf:          asOf,
 			})
 		},
 	}
@@ -52,6 +64,8 @@ func newStatusCommand(cfg *config.Config) *cobra.Command {
 	cmd.Flags().BoolVar(&checkSSH, "ssh", true, "Check SSH directory status")
 	cmd.Flags().BoolVar(&showChecksums, "checksums", false, "Show MD5 checksums for backup files")
 	cmd.Flags().StringVar(&backupName, "backup", "", "Show detailed info for specific backup")
+	addSelectorFlags(cmd, &tags, &selectors)
+	addAsOfFlag(cmd, &asOf)
 
 	return cmd
 }
@@ -61,6 +75,9 @@ type statusOptions struct {
 	checkSSH      bool
 	showChecksums bool
 	backupName    string
+	tags          []string
+	selectors     []string
+	asOf          string
 }
 
 func runStatus(cfg *config.Config, opts statusOptions) error {
@@ -71,6 +88,19 @@ func runStatus(cfg *config.Config, opts statusOptions) error {
 		Str("backup_name", opts.backupName).
 		Msg("Checking SSH Secret Keeper status")
 
+	selector, err := newBackupSelector(opts.tags, opts.selectors)
+	if err != nil {
+		return err
+	}
+	if (!selector.IsEmpty() || opts.asOf != "") && opts.backupName != "" {
+		return fmt.Errorf("--tag, --selector and --as-of cannot be combined with a backup name")
+	}
+	if opts.asOf != "" {
+		if _, err := parseAsOf(opts.asOf, time.Now()); err != nil {
+			return err
+		}
+	}
+
 	fmt.Printf("🔍 SSH Secret Keeper Status\n")
 	fmt.Printf("═══════════════════════════\n\n")
 
@@ -131,6 +161,16 @@ func runStatus(cfg *config.Config, opts statusOptions) error {
 						if err := showBackupDetails(storageProvider, opts.backupName, opts.showChecksums); err != nil {
 							fmt.Printf("  ❌ Failed to get backup details: %v\n", err)
 						}
+					} else if !selector.IsEmpty() || opts.asOf != "" {
+						name, explanation, err := chooseBackup(ctx, storageProvider, selector, opts.asOf)
+						if err != nil {
+							fmt.Printf("  ❌ %v\n", err)
+						} else {
+							fmt.Printf("\n%s\n", explanation)
+							if err := showBackupDetails(storageProvider, name, opts.showChecksums); err != nil {
+								fmt.Printf("  ❌ Failed to get backup details: %v\n", err)
+							}
+						}
 					} else if opts.showChecksums && len(backups) > 0 {
 						// Show checksums for most recent backup
 						mostRecent := backups[len(backups)-1]
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rzago/ssh-secret-keeper/internal/config"
+	"github.com/rzago/ssh-secret-keeper/internal/interfaces"
+	"github.com/rzago/ssh-secret-keeper/internal/storage"
+)
+
+// geteuid is replaced in tests
+var geteuid = os.Geteuid
+
+// createStorage creates the storage provider for cfg or, with system, the one
+// storing backups under systems/<hostname> whatever storage strategy is
+// configured, so a rebuilt server finds its host keys by hostname alone
+func createStorage(cfg *config.Config, system bool) (interfaces.StorageProvider, error) {
+	factory := storage.NewFactory()
+	if system {
+		return factory.CreateSystemStorage(cfg)
+	}
+	return factory.CreateStorage(cfg)
+}
+
+// requireRoot fails unless running as root, which flag needs for action, such
+// as --system reading private host keys or --all-users reading other users' keys
+func requireRoot(flag, action string) error {
+	if geteuid() != 0 {
+		return fmt.Errorf("%s must run as root to %s (try sudo)", flag, action)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rzago/ssh-secret-keeper/internal/config"
+)
+
+func TestRequireRoot(t *testing.T) {
+	defer func(original func() int) { geteuid = original }(geteuid)
+
+	geteuid = func() int { return 1000 }
+	if err := requireRoot("--system", "read host keys"); err == nil || !strings.Contains(err.Error(), "root") {
+		t.Errorf("requireRoot() error = %v, want root requirement", err)
+	}
+
+	// Refused before storage or the SSH directory are touched
+	err := runBackup(config.Default(), backupOptions{sshDir: t.TempDir(), system: true, dryRun: true})
+	if err == nil || !strings.Contains(err.Error(), "--system must run as root") {
+		t.Errorf("runBackup(--system) error = %v, want root requirement", err)
+	}
+
+	geteuid = func() int { return 0 }
+	if err := requireRoot("--system", "read host keys"); err != nil {
+		t.Errorf("requireRoot() as root error = %v", err)
+	}
+}
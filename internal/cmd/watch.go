@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/rzago/ssh-secret-keeper/internal/config"
+	"github.com/rzago/ssh-secret-keeper/internal/naming"
+	"github.com/rzago/ssh-secret-keeper/internal/utils"
+	"github.com/rzago/ssh-secret-keeper/internal/watch"
+	"github.com/spf13/cobra"
+)
+
+// newWatchCommand creates the watch command
+func newWatchCommand(cfg *config.Config) *cobra.Command {
+	var opts watchOptions
+
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Back up the SSH directory automatically whenever it changes",
+		Long: `Watch the SSH directory and back it up automatically when it changes.
+
+Bursts of changes are debounced into a single backup. Each backup is named
+from --name-template (backup.name_template), goes through the same steps as 'sshsk backup' (filters,
+no-op detection, deduplication) and is followed by the retention policy for
+this host. One backup is attempted on startup to catch changes made while the
+watcher was not running.
+
+The command runs in the foreground until interrupted, which makes it suitable
+for a systemd user service:
+
+  [Service]
+  ExecStart=/usr/local/bin/sshsk watch
+  Restart=on-failure`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runWatch(cfg, opts)
+		},
+	}
+
+	// Command-specific flags
+	cmd.Flags().StringVar(&opts.sshDir, "ssh-dir", cfg.Backup.SSHDir, "SSH directory to watch")
+	cmd.Flags().StringVar(&opts.nameTemplate, "name-template", cfg.Backup.NameTemplate, "Template for backup names")
+	cmd.Flags().DurationVar(&opts.debounce, "debounce", watch.DefaultDebounce, "Wait this long after the last change before backing up")
+	cmd.Flags().StringSliceVar(&opts.includes, "include", nil, "Re-include files matching this gitignore-style pattern (repeatable)")
+	cmd.Flags().StringSliceVar(&opts.excludes, "exclude", nil, "Exclude files matching this gitignore-style pattern (repeatable)")
+
+	return cmd
+}
+
+type watchOptions struct {
+	sshDir       string
+	nameTemplate string
+	debounce     time.Duration
+	includes     []string
+	excludes     []string
+}
+
+func runWatch(cfg *config.Config, opts watchOptions) error {
+	sshDir, err := utils.NewPathNormalizer().ResolvePath(opts.sshDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve SSH directory: %w", err)
+	}
+
+	// Fail now rather than on the first change
+	if _, err := naming.Parse(opts.nameTemplate); err != nil {
+		return err
+	}
+
+	fileFilter, err := buildFileFilter(cfg, sshDir, opts.includes, opts.excludes)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	backup := func(ctx context.Context, changed []string) error {
+		return watchBackup(cfg, sshDir, opts, changed)
+	}
+
+	// Catch up on changes made while nobody was watching
+	if err := backup(ctx, nil); err != nil {
+		log.Error().Err(err).Str("event", "backup_failed").Msg("Initial backup failed")
+	}
+
+	watcher := watch.New(sshDir, opts.debounce, backup)
+	watcher.SetFilter(fileFilter)
+	return watcher.Run(ctx)
+}
+
+// watchBackup runs one automatic backup and logs its outcome. Unchanged
+// directories are not an error in watch mode.
+func watchBackup(cfg *config.Config, sshDir string, opts watchOptions, changed []string) error {
+	log.Info().
+		Str("event", "backup_started").
+		Strs("changed", changed).
+		Msg("Starting automatic backup")
+
+	err := runBackup(cfg, backupOptions{
+		nameTemplate:   opts.nameTemplate,
+		sshDir:         sshDir,
+		includes:       opts.includes,
+		excludes:       opts.excludes,
+		applyRetention: true,
+	})
+
+	switch {
+	case errors.Is(err, ErrNoChanges):
+		log.Info().Str("event", "backup_skipped").Msg("No changes since the latest backup")
+		return nil
+	case err != nil:
+		return err
+	}
+
+	log.Info().Str("event", "backup_completed").Msg("Automatic backup completed")
+	return nil
+}
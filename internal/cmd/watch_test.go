@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/rzago/ssh-secret-keeper/internal/config"
+)
+
+func TestNewWatchCommand(t *testing.T) {
+	cfg := config.Default()
+	cmd := newWatchCommand(cfg)
+
+	if cmd.Use != "watch" {
+		t.Errorf("Expected command use 'watch', got '%s'", cmd.Use)
+	}
+
+	for _, flag := range []string{"ssh-dir", "name-template", "debounce", "include", "exclude"} {
+		if cmd.Flag(flag) == nil {
+			t.Errorf("Expected --%s flag to be present", flag)
+		}
+	}
+
+	if got := cmd.Flag("debounce").DefValue; got != "5s" {
+		t.Errorf("debounce default = %s, want 5s", got)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/rzago/ssh-secret-keeper/internal/update"
 	"github.com/spf13/viper"
@@ -19,6 +20,7 @@ type Config struct {
 	Backup    BackupConfig   `yaml:"backup" mapstructure:"backup"`
 	Security  SecurityConfig `yaml:"security" mapstructure:"security"`
 	Logging   LoggingConfig  `yaml:"logging" mapstructure:"logging"`
+	Hooks     HooksConfig    `yaml:"hooks" mapstructure:"hooks"`
 	Detectors DetectorConfig        `yaml:"detectors" mapstructure:"detectors"`
 	Update    *update.UpdateConfig `yaml:"update,omitempty" mapstructure:"update"`
 }
@@ -35,6 +37,10 @@ type VaultConfig struct {
 	StorageStrategy string `yaml:"storage_strategy" mapstructure:"storage_strategy"`           // "universal", "user", "machine-user", "custom"
 	CustomPrefix    string `yaml:"custom_prefix,omitempty" mapstructure:"custom_prefix"`       // For custom strategy
 	BackupNamespace string `yaml:"backup_namespace,omitempty" mapstructure:"backup_namespace"` // Optional namespace for universal strategy
+
+	// Username overrides $USER in user-scoped storage paths. It is set per
+	// account by backup --all-users and cannot be configured.
+	Username string `yaml:"-" mapstructure:"-"`
 }
 
 // BackupConfig holds backup behavior settings
@@ -49,6 +55,49 @@ type BackupConfig struct {
 	// New path normalization and cross-machine compatibility options
 	NormalizePaths      bool `yaml:"normalize_paths" mapstructure:"normalize_paths"`             // Enable path normalization (~/ssh vs /home/user/.ssh)
 	CrossMachineRestore bool `yaml:"cross_machine_restore" mapstructure:"cross_machine_restore"` // Allow restoring backups across different machines
+
+	// Retention rules applied by 'sshsk prune'; RetentionCount is the keep-last rule
+	Retention RetentionConfig `yaml:"retention" mapstructure:"retention"`
+
+	// Store file contents once by SHA-256 and reference them from backups
+	Deduplicate bool `yaml:"deduplicate" mapstructure:"deduplicate"`
+
+	// Template for backups created without an explicit name, e.g. "{{.Hostname}}-{{.Date}}-{{.Seq}}"
+	NameTemplate string `yaml:"name_template" mapstructure:"name_template"`
+
+	// Additional directories backed up and restored alongside the SSH directory
+	Sources []SourceConfig `yaml:"sources,omitempty" mapstructure:"sources"`
+}
+
+// SourceConfig defines an additional directory to back up, such as ~/.kube
+type SourceConfig struct {
+	Name     string   `yaml:"name" mapstructure:"name"`
+	Dir      string   `yaml:"dir" mapstructure:"dir"`                     // e.g. "~/.kube"
+	Include  []string `yaml:"include,omitempty" mapstructure:"include"`   // Gitignore-style patterns; empty backs up everything
+	Mode     string   `yaml:"mode,omitempty" mapstructure:"mode"`         // Octal mode for files without recorded permissions, e.g. "0600"
+	Analyzer string   `yaml:"analyzer,omitempty" mapstructure:"analyzer"` // "generic" (default) or "ssh"
+}
+
+// RetentionConfig holds grandfather-father-son retention settings
+type RetentionConfig struct {
+	KeepDaily   int  `yaml:"keep_daily" mapstructure:"keep_daily"`     // Newest backup of each of the last N days
+	KeepWeekly  int  `yaml:"keep_weekly" mapstructure:"keep_weekly"`   // Newest backup of each of the last N ISO weeks
+	KeepMonthly int  `yaml:"keep_monthly" mapstructure:"keep_monthly"` // Newest backup of each of the last N months
+	KeepYearly  int  `yaml:"keep_yearly" mapstructure:"keep_yearly"`   // Newest backup of each of the last N years
+	AutoPrune   bool `yaml:"auto_prune" mapstructure:"auto_prune"`     // Prune this host's backups after every backup
+}
+
+// HooksConfig holds shell commands run around backup, restore and delete.
+// Each command receives a JSON event on stdin; a failing pre hook aborts the operation.
+type HooksConfig struct {
+	PreBackup   []string      `yaml:"pre_backup,omitempty" mapstructure:"pre_backup"`
+	PostBackup  []string      `yaml:"post_backup,omitempty" mapstructure:"post_backup"`
+	PreRestore  []string      `yaml:"pre_restore,omitempty" mapstructure:"pre_restore"`
+	PostRestore []string      `yaml:"post_restore,omitempty" mapstructure:"post_restore"`
+	PreDelete   []string      `yaml:"pre_delete,omitempty" mapstructure:"pre_delete"`
+	PostDelete  []string      `yaml:"post_delete,omitempty" mapstructure:"post_delete"`
+	OnFailure   []string      `yaml:"on_failure,omitempty" mapstructure:"on_failure"`
+	Timeout     time.Duration `yaml:"timeout" mapstructure:"timeout"` // Per command
 }
 
 // SecurityConfig holds encryption and security settings
@@ -103,6 +152,8 @@ func Default() *Config {
 			RetentionCount:      10,
 			NormalizePaths:      true, // Enable path normalization
 			CrossMachineRestore: true, // Enable cross-machine restore
+			Deduplicate:         true, // Upload only content that changed since earlier backups
+			NameTemplate:        "backup-{{.Date}}-{{.Time}}",
 			IncludePatterns: []string{
 				"*.rsa", "*.pem", "*.pub", "id_rsa*",
 				"config", "known_hosts*", "authorized_keys",
@@ -125,6 +176,9 @@ func Default() *Config {
 			Level:  "info",
 			Format: "console",
 		},
+		Hooks: HooksConfig{
+			Timeout: 60 * time.Second,
+		},
 		Detectors: DetectorConfig{
 			Enabled: []string{"rsa", "pem", "openssh", "config", "hosts"},
 			ServiceMapping: map[string]string{
@@ -144,6 +198,26 @@ func Default() *Config {
 
 // Load loads configuration from file and environment
 func Load() (*Config, error) {
+	cfg, err := LoadFile()
+	if err != nil {
+		return nil, err
+	}
+
+	// Require VAULT_ADDR environment variable to be set
+	// This follows HashiCorp Vault's standard environment variable convention
+	vaultAddr := os.Getenv("VAULT_ADDR")
+	if vaultAddr == "" {
+		return nil, fmt.Errorf("VAULT_ADDR environment variable is required but not set")
+	}
+	cfg.Vault.Address = vaultAddr
+
+	return cfg, nil
+}
+
+// LoadFile loads configuration from file and environment like Load, but
+// without requiring VAULT_ADDR: vault.address keeps its configured value
+// unless the variable is set
+func LoadFile() (*Config, error) {
 	cfg := Default()
 
 	// Setup viper
@@ -171,13 +245,9 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("error unmarshaling config: %w", err)
 	}
 
-	// Require VAULT_ADDR environment variable to be set
-	// This follows HashiCorp Vault's standard environment variable convention
-	vaultAddr := os.Getenv("VAULT_ADDR")
-	if vaultAddr == "" {
-		return nil, fmt.Errorf("VAULT_ADDR environment variable is required but not set")
+	if vaultAddr := os.Getenv("VAULT_ADDR"); vaultAddr != "" {
+		cfg.Vault.Address = vaultAddr
 	}
-	cfg.Vault.Address = vaultAddr
 
 	// Override token file path if SSHSK_VAULT_TOKEN_FILE is set
 	if tokenFileEnv := os.Getenv("SSHSK_VAULT_TOKEN_FILE"); tokenFileEnv != "" {
@@ -0,0 +1,178 @@
+// Package diff compares a backup with the files in a local directory.
+package diff
+
+import (
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/rzago/ssh-secret-keeper/internal/analyzer"
+	"github.com/rzago/ssh-secret-keeper/internal/ssh"
+)
+
+// ChangeType describes how a local file differs from the backup
+type ChangeType string
+
+const (
+	Added       ChangeType = "added"        // Only in the local directory
+	Removed     ChangeType = "removed"      // Only in the backup
+	Modified    ChangeType = "modified"     // Content or symlink target differs
+	ModeChanged ChangeType = "mode_changed" // Same content, different permissions
+)
+
+// textTypes are the file types whose content is shown as a unified diff.
+// Everything else may hold secrets and is only compared by checksum.
+var textTypes = map[analyzer.KeyType]bool{
+	analyzer.KeyTypeConfig:     true,
+	analyzer.KeyTypeHosts:      true,
+	analyzer.KeyTypeAuthorized: true,
+}
+
+// textNames identify the same files when no analysis is available
+var textNames = map[string]bool{
+	"config":          true,
+	"known_hosts":     true,
+	"authorized_keys": true,
+}
+
+// Change is one file that differs between the backup and the local directory.
+// Private keys carry fingerprints instead of content.
+type Change struct {
+	Path              string           `json:"path"`
+	Change            ChangeType       `json:"change"`
+	Type              analyzer.KeyType `json:"type,omitempty"`
+	BackupMode        string           `json:"backup_mode,omitempty"`
+	LocalMode         string           `json:"local_mode,omitempty"`
+	BackupFingerprint string           `json:"backup_fingerprint,omitempty"`
+	LocalFingerprint  string           `json:"local_fingerprint,omitempty"`
+	BackupLinkTarget  string           `json:"backup_link_target,omitempty"`
+	LocalLinkTarget   string           `json:"local_link_target,omitempty"`
+	Diff              string           `json:"diff,omitempty"`
+}
+
+// Result lists the changes from a backup to a local directory, sorted by path
+type Result struct {
+	Changes   []Change `json:"changes"`
+	Unchanged int      `json:"unchanged"`
+}
+
+// Counts returns the number of changes of each type
+func (r *Result) Counts() map[ChangeType]int {
+	counts := make(map[ChangeType]int)
+	for _, change := range r.Changes {
+		counts[change.Change]++
+	}
+	return counts
+}
+
+// Compare lists how the local files differ from the backed-up files. Content
+// is compared by checksum; unified diffs are only produced for ssh config,
+// known_hosts and authorized_keys.
+func Compare(backup, local map[string]*ssh.FileData) *Result {
+	paths := make(map[string]bool, len(backup)+len(local))
+	for name := range backup {
+		paths[name] = true
+	}
+	for name := range local {
+		paths[name] = true
+	}
+	sorted := make([]string, 0, len(paths))
+	for name := range paths {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	result := &Result{Changes: []Change{}}
+	for _, name := range sorted {
+		change, changed := compareFile(name, backup[name], local[name])
+		if !changed {
+			result.Unchanged++
+			continue
+		}
+		result.Changes = append(result.Changes, change)
+	}
+	return result
+}
+
+// compareFile compares one path, either side of which may be missing
+func compareFile(name string, stored, current *ssh.FileData) (Change, bool) {
+	change := Change{Path: name, Type: fileType(stored, current)}
+	if stored != nil {
+		describe(&change.BackupMode, &change.BackupFingerprint, &change.BackupLinkTarget, stored, change.Type)
+	}
+	if current != nil {
+		describe(&change.LocalMode, &change.LocalFingerprint, &change.LocalLinkTarget, current, change.Type)
+	}
+
+	switch {
+	case stored == nil:
+		change.Change = Added
+	case current == nil:
+		change.Change = Removed
+	case stored.LinkTarget != current.LinkTarget || checksum(stored) != checksum(current):
+		change.Change = Modified
+	case stored.Permissions.Perm() != current.Permissions.Perm():
+		change.Change = ModeChanged
+		return change, true
+	default:
+		return change, false
+	}
+
+	if isText(name, change.Type) && !isSecret(stored) && !isSecret(current) {
+		change.Diff = Unified("backup/"+name, "local/"+name, content(stored), content(current))
+	}
+	return change, true
+}
+
+// describe records the mode, and for private keys the fingerprint, of one side
+func describe(mode, fingerprint, linkTarget *string, file *ssh.FileData, keyType analyzer.KeyType) {
+	if file.IsSymlink() {
+		*linkTarget = file.LinkTarget
+		return
+	}
+	*mode = fmt.Sprintf("%04o", file.Permissions.Perm())
+	if keyType == analyzer.KeyTypePrivate || isSecret(file) {
+		*fingerprint = ssh.KeyFingerprint(file.Content)
+	}
+}
+
+// fileType prefers the local analysis, which reflects the current analyzer
+func fileType(stored, current *ssh.FileData) analyzer.KeyType {
+	for _, file := range []*ssh.FileData{current, stored} {
+		if file != nil && file.KeyInfo != nil && file.KeyInfo.Type != "" {
+			return file.KeyInfo.Type
+		}
+	}
+	return ""
+}
+
+func isText(name string, keyType analyzer.KeyType) bool {
+	if keyType != "" {
+		return textTypes[keyType]
+	}
+	return textNames[path.Base(name)]
+}
+
+// isSecret reports whether either side rules out showing content: symlinks
+// have none, and a file analyzed as a private key is never printed
+func isSecret(file *ssh.FileData) bool {
+	if file == nil {
+		return false
+	}
+	return file.IsSymlink() || (file.KeyInfo != nil && file.KeyInfo.Type == analyzer.KeyTypePrivate)
+}
+
+// checksum uses the recorded checksum, computing it for files without one
+func checksum(file *ssh.FileData) string {
+	if file.Checksum != "" {
+		return file.Checksum
+	}
+	return ssh.Checksum(file.Content)
+}
+
+func content(file *ssh.FileData) string {
+	if file == nil {
+		return ""
+	}
+	return string(file.Content)
+}
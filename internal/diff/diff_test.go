@@ -0,0 +1,181 @@
+package diff
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rzago/ssh-secret-keeper/internal/analyzer"
+	"github.com/rzago/ssh-secret-keeper/internal/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func file(name, content string, mode os.FileMode, keyType analyzer.KeyType) *ssh.FileData {
+	return &ssh.FileData{
+		Filename:    name,
+		Content:     []byte(content),
+		Permissions: mode,
+		Checksum:    ssh.Checksum([]byte(content)),
+		KeyInfo:     &analyzer.KeyInfo{Filename: name, Type: keyType},
+	}
+}
+
+func privateKey(t *testing.T) string {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := gossh.MarshalPrivateKey(key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(block))
+}
+
+func TestCompare(t *testing.T) {
+	oldKey, newKey := privateKey(t), privateKey(t)
+
+	backup := map[string]*ssh.FileData{
+		"config":      file("config", "Host a\n  User x\n", 0600, analyzer.KeyTypeConfig),
+		"id_ed25519":  file("id_ed25519", oldKey, 0600, analyzer.KeyTypePrivate),
+		"known_hosts": file("known_hosts", "a ssh-ed25519 AAAA\n", 0644, analyzer.KeyTypeHosts),
+		"old_key":     file("old_key", oldKey, 0600, analyzer.KeyTypePrivate),
+		"same":        file("same", "unchanged\n", 0644, analyzer.KeyTypeUnknown),
+		"link":        {Filename: "link", Permissions: os.ModeSymlink | 0777, LinkTarget: "a"},
+	}
+	local := map[string]*ssh.FileData{
+		"config":      file("config", "Host a\n  User y\n", 0600, analyzer.KeyTypeConfig),
+		"id_ed25519":  file("id_ed25519", newKey, 0600, analyzer.KeyTypePrivate),
+		"known_hosts": file("known_hosts", "a ssh-ed25519 AAAA\n", 0600, analyzer.KeyTypeHosts),
+		"new.pub":     file("new.pub", "ssh-ed25519 AAAA\n", 0644, analyzer.KeyTypePublic),
+		"same":        file("same", "unchanged\n", 0644, analyzer.KeyTypeUnknown),
+		"link":        {Filename: "link", Permissions: os.ModeSymlink | 0777, LinkTarget: "b"},
+	}
+
+	result := Compare(backup, local)
+
+	want := map[string]ChangeType{
+		"config":      Modified,
+		"id_ed25519":  Modified,
+		"known_hosts": ModeChanged,
+		"link":        Modified,
+		"new.pub":     Added,
+		"old_key":     Removed,
+	}
+	if len(result.Changes) != len(want) {
+		t.Fatalf("got %d changes, want %d: %+v", len(result.Changes), len(want), result.Changes)
+	}
+	if result.Unchanged != 1 {
+		t.Errorf("unchanged = %d, want 1", result.Unchanged)
+	}
+
+	changes := make(map[string]Change)
+	for i, change := range result.Changes {
+		if i > 0 && result.Changes[i-1].Path >= change.Path {
+			t.Errorf("changes not sorted: %s before %s", result.Changes[i-1].Path, change.Path)
+		}
+		if change.Change != want[change.Path] {
+			t.Errorf("%s: change = %s, want %s", change.Path, change.Change, want[change.Path])
+		}
+		changes[change.Path] = change
+	}
+
+	if diff := changes["config"].Diff; !strings.Contains(diff, "-  User x\n+  User y\n") {
+		t.Errorf("config diff missing the change:\n%s", diff)
+	}
+
+	key := changes["id_ed25519"]
+	if key.Diff != "" {
+		t.Errorf("private key content must not be diffed, got:\n%s", key.Diff)
+	}
+	if !strings.HasPrefix(key.BackupFingerprint, "SHA256:") || !strings.HasPrefix(key.LocalFingerprint, "SHA256:") {
+		t.Errorf("expected SSH fingerprints, got %q and %q", key.BackupFingerprint, key.LocalFingerprint)
+	}
+	if key.BackupFingerprint == key.LocalFingerprint {
+		t.Error("different keys should have different fingerprints")
+	}
+	if removed := changes["old_key"]; removed.BackupFingerprint != key.BackupFingerprint || removed.LocalMode != "" {
+		t.Errorf("removed key: %+v", removed)
+	}
+
+	hosts := changes["known_hosts"]
+	if hosts.BackupMode != "0644" || hosts.LocalMode != "0600" || hosts.Diff != "" {
+		t.Errorf("known_hosts mode change: %+v", hosts)
+	}
+
+	link := changes["link"]
+	if link.BackupLinkTarget != "a" || link.LocalLinkTarget != "b" || link.Diff != "" {
+		t.Errorf("symlink change: %+v", link)
+	}
+}
+
+func TestCompare_NeverPrintsPrivateKeys(t *testing.T) {
+	// A private key named like a text file is still only fingerprinted
+	backup := map[string]*ssh.FileData{"config": file("config", "secret-one", 0600, analyzer.KeyTypePrivate)}
+	local := map[string]*ssh.FileData{"config": file("config", "secret-two", 0600, analyzer.KeyTypeConfig)}
+
+	change := Compare(backup, local).Changes[0]
+	if change.Diff != "" {
+		t.Fatalf("diff printed private key content:\n%s", change.Diff)
+	}
+	if !strings.HasPrefix(change.BackupFingerprint, "content:") {
+		t.Errorf("unparsable key should get a content fingerprint, got %q", change.BackupFingerprint)
+	}
+}
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		want     string
+	}{
+		{
+			name: "equal",
+			from: "a\nb\n",
+			to:   "a\nb\n",
+			want: "",
+		},
+		{
+			name: "change with context",
+			from: "1\n2\n3\n4\n5\n6\n7\n8\n",
+			to:   "1\n2\n3\n4\nfive\n6\n7\n8\n",
+			want: "--- old\n+++ new\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "separate hunks",
+			from: "a\n1\n2\n3\n4\n5\n6\n7\nb\n",
+			to:   "A\n1\n2\n3\n4\n5\n6\n7\nB\n",
+			want: "--- old\n+++ new\n@@ -1,4 +1,4 @@\n-a\n+A\n 1\n 2\n 3\n@@ -6,4 +6,4 @@\n 5\n 6\n 7\n-b\n+B\n",
+		},
+		{
+			name: "new file",
+			from: "",
+			to:   "x\ny\n",
+			want: "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+x\n+y\n",
+		},
+		{
+			name: "deleted file",
+			from: "x\n",
+			to:   "",
+			want: "--- old\n+++ new\n@@ -1 +0,0 @@\n-x\n",
+		},
+		{
+			name: "insertion",
+			from: "a\nc\n",
+			to:   "a\nb\nc\n",
+			want: "--- old\n+++ new\n@@ -1,2 +1,3 @@\n a\n+b\n c\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Unified("old", "new", tt.from, tt.to); got != tt.want {
+				t.Errorf("Unified() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines shown around each change
+const contextLines = 3
+
+// maxLCSCells bounds the memory of the line matching; larger inputs are shown
+// as a single replacement after their common prefix and suffix
+const maxLCSCells = 4 << 20
+
+// lineOp is one line of an edit script: ' ' kept, '-' deleted, '+' inserted.
+// from and to are the line indexes in each input before the line.
+type lineOp struct {
+	kind byte
+	line string
+	from int
+	to   int
+}
+
+// Unified returns a unified diff of two texts with three lines of context,
+// or "" when they are equal
+func Unified(fromName, toName, from, to string) string {
+	ops := editScript(splitLines(from), splitLines(to))
+
+	var changes []int
+	for i, op := range ops {
+		if op.kind != ' ' {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+
+	for first := 0; first < len(changes); {
+		// Join changes whose context would overlap into one hunk
+		last := first
+		for last+1 < len(changes) && changes[last+1]-changes[last] <= 2*contextLines+1 {
+			last++
+		}
+		start := max(changes[first]-contextLines, 0)
+		end := min(changes[last]+contextLines+1, len(ops))
+		writeHunk(&out, ops[start:end])
+		first = last + 1
+	}
+	return out.String()
+}
+
+// writeHunk writes a hunk header followed by its lines
+func writeHunk(out *strings.Builder, ops []lineOp) {
+	fromCount, toCount := 0, 0
+	for _, op := range ops {
+		if op.kind != '+' {
+			fromCount++
+		}
+		if op.kind != '-' {
+			toCount++
+		}
+	}
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(ops[0].from, fromCount), hunkRange(ops[0].to, toCount))
+	for _, op := range ops {
+		out.WriteByte(op.kind)
+		out.WriteString(op.line)
+		out.WriteByte('\n')
+	}
+}
+
+// hunkRange formats a line range like diff -u: empty ranges name the line before them
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// splitLines splits text into lines without their terminators
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// editScript computes a shortest edit script turning a into b from the
+// longest common subsequence of their lines
+func editScript(a, b []string) []lineOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]lineOp, 0, len(a)+len(b))
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, lineOp{kind: ' ', line: a[i], from: i, to: i})
+	}
+	ops = append(ops, middleScript(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix], prefix, prefix)...)
+	for i := 0; i < suffix; i++ {
+		from, to := len(a)-suffix+i, len(b)-suffix+i
+		ops = append(ops, lineOp{kind: ' ', line: a[from], from: from, to: to})
+	}
+	return ops
+}
+
+// middleScript diffs the differing middle of two inputs, offset by their common prefix
+func middleScript(a, b []string, fromOffset, toOffset int) []lineOp {
+	n, m := len(a), len(b)
+	var ops []lineOp
+
+	if (n+1)*(m+1) > maxLCSCells {
+		for i, line := range a {
+			ops = append(ops, lineOp{kind: '-', line: line, from: fromOffset + i, to: toOffset})
+		}
+		for j, line := range b {
+			ops = append(ops, lineOp{kind: '+', line: line, from: fromOffset + n, to: toOffset + j})
+		}
+		return ops
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[i] == b[j]:
+			ops = append(ops, lineOp{kind: ' ', line: a[i], from: fromOffset + i, to: toOffset + j})
+			i++
+			j++
+		case j == m || (i < n && lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, lineOp{kind: '-', line: a[i], from: fromOffset + i, to: toOffset + j})
+			i++
+		default:
+			ops = append(ops, lineOp{kind: '+', line: b[j], from: fromOffset + i, to: toOffset + j})
+			j++
+		}
+	}
+	return ops
+}
@@ -0,0 +1,452 @@
+package files
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"path"
+	"strings"
+
+	"github.com/rzago/ssh-secret-keeper/internal/analyzer"
+	"github.com/rzago/ssh-secret-keeper/internal/ssh"
+)
+
+// MergeResult is a local file with the entries it lacked added from the backup
+type MergeResult struct {
+	Content []byte
+	Added   []string // Short descriptions of the entries taken from the backup
+}
+
+// Changed reports whether the backup contributed anything
+func (r *MergeResult) Changed() bool {
+	return len(r.Added) > 0
+}
+
+// mergeFunc merges backed-up content into local content
+type mergeFunc func(local, backup []byte) *MergeResult
+
+// mergeFuncs are the line-level merges by file type
+var mergeFuncs = map[analyzer.KeyType]mergeFunc{
+	analyzer.KeyTypeHosts:      MergeKnownHosts,
+	analyzer.KeyTypeAuthorized: MergeAuthorizedKeys,
+	analyzer.KeyTypeConfig:     MergeSSHConfig,
+}
+
+// mergeNames identify the same files in backups without key information
+var mergeNames = map[string]analyzer.KeyType{
+	"known_hosts":     analyzer.KeyTypeHosts,
+	"authorized_keys": analyzer.KeyTypeAuthorized,
+	"config":          analyzer.KeyTypeConfig,
+}
+
+// CanMerge reports whether a file can be merged line by line with an existing
+// local copy: known_hosts, authorized_keys and ssh config files
+func CanMerge(fileData *ssh.FileData) bool {
+	return mergeFuncFor(fileData) != nil
+}
+
+// Merge merges a backed-up file into the content of the existing local file
+func Merge(fileData *ssh.FileData, local []byte) (*MergeResult, bool) {
+	merge := mergeFuncFor(fileData)
+	if merge == nil {
+		return nil, false
+	}
+	return merge(local, fileData.Content), true
+}
+
+func mergeFuncFor(fileData *ssh.FileData) mergeFunc {
+	if fileData == nil || fileData.IsSymlink() {
+		return nil
+	}
+	if fileData.KeyInfo != nil && fileData.KeyInfo.Type != "" {
+		return mergeFuncs[fileData.KeyInfo.Type]
+	}
+	return mergeFuncs[mergeNames[path.Base(fileData.Filename)]]
+}
+
+// MergeKnownHosts adds the host keys of the backup that the local file lacks.
+// Entries are compared per host name, marker, key type and key, so a backup
+// line is only added for the hosts not already known with that key. Hashed
+// host names are matched against local plain names with the same key; since
+// two hashes of one name cannot be compared, a hashed name is also taken as
+// known when the local file has a hashed name with the same key.
+func MergeKnownHosts(local, backup []byte) *MergeResult {
+	known := make(map[string]bool)
+	localHosts := make(map[string][]string) // Local host names by key
+	for _, line := range splitLines(local) {
+		entry, ok := parseKnownHost(line)
+		if !ok {
+			continue
+		}
+		for _, host := range entry.hosts {
+			known[entry.identity(host)] = true
+		}
+		localHosts[entry.keyIdentity()] = append(localHosts[entry.keyIdentity()], entry.hosts...)
+	}
+
+	var added []string
+	var additions []string
+	for _, line := range splitLines(backup) {
+		entry, ok := parseKnownHost(line)
+		if !ok {
+			continue
+		}
+
+		var missing []string
+		for _, host := range entry.hosts {
+			if known[entry.identity(host)] || knownHashedHost(host, localHosts[entry.keyIdentity()]) {
+				continue
+			}
+			known[entry.identity(host)] = true
+			missing = append(missing, host)
+		}
+		if len(missing) == 0 {
+			continue
+		}
+
+		if len(missing) < len(entry.hosts) {
+			line = entry.withHosts(missing)
+		}
+		additions = append(additions, line)
+		added = append(added, strings.Join(missing, ",")+" "+entry.keyType)
+	}
+
+	return &MergeResult{Content: appendLines(local, additions), Added: added}
+}
+
+// knownHost is one known_hosts entry
+type knownHost struct {
+	marker  string // @cert-authority or @revoked
+	hosts   []string
+	keyType string
+	key     string
+	comment []string
+}
+
+func parseKnownHost(line string) (knownHost, bool) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+		return knownHost{}, false
+	}
+
+	var entry knownHost
+	if strings.HasPrefix(fields[0], "@") {
+		entry.marker = fields[0]
+		fields = fields[1:]
+	}
+	if len(fields) < 3 {
+		return knownHost{}, false
+	}
+
+	entry.hosts = strings.Split(fields[0], ",")
+	entry.keyType = fields[1]
+	entry.key = fields[2]
+	entry.comment = fields[3:]
+	return entry, true
+}
+
+func (e knownHost) identity(host string) string {
+	return strings.Join([]string{e.marker, host, e.keyType, e.key}, " ")
+}
+
+// keyIdentity identifies the entry's key regardless of its hosts
+func (e knownHost) keyIdentity() string {
+	return strings.Join([]string{e.marker, e.keyType, e.key}, " ")
+}
+
+// knownHashedHost reports whether host, of which one side is hashed, is among
+// the hosts known with the same key: a hashed name matching a plain one, or
+// two hashed names, which cannot be told apart and are taken to be the same
+func knownHashedHost(host string, hosts []string) bool {
+	for _, other := range hosts {
+		switch {
+		case isHashedHost(host) && isHashedHost(other):
+			return true
+		case hashedHostMatches(host, other), hashedHostMatches(other, host):
+			return true
+		}
+	}
+	return false
+}
+
+// isHashedHost recognises a name hashed by HashKnownHosts, |1|salt|hash
+func isHashedHost(host string) bool {
+	return strings.HasPrefix(host, "|1|")
+}
+
+// hashedHostMatches reports whether hashed is the HMAC-SHA1 of the plain name
+// keyed with its salt, as ssh computes it
+func hashedHostMatches(hashed, plain string) bool {
+	if !isHashedHost(hashed) || isHashedHost(plain) {
+		return false
+	}
+	parts := strings.Split(strings.TrimPrefix(hashed, "|1|"), "|")
+	if len(parts) != 2 {
+		return false
+	}
+	salt, err := base64.StdEncoding.DecodeString(parts[0])
+	if err != nil {
+		return false
+	}
+	sum, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte(plain))
+	return hmac.Equal(mac.Sum(nil), sum)
+}
+
+// withHosts formats the entry for a subset of its hosts
+func (e knownHost) withHosts(hosts []string) string {
+	var fields []string
+	if e.marker != "" {
+		fields = append(fields, e.marker)
+	}
+	fields = append(fields, strings.Join(hosts, ","), e.keyType, e.key)
+	return strings.Join(append(fields, e.comment...), " ")
+}
+
+// MergeAuthorizedKeys adds the keys of the backup that the local file lacks.
+// Keys are compared by type and key material; the local line wins when the
+// same key appears with different options or comments.
+func MergeAuthorizedKeys(local, backup []byte) *MergeResult {
+	present := make(map[string]bool)
+	for _, line := range splitLines(local) {
+		if identity, _, ok := authorizedKeyIdentity(line); ok {
+			present[identity] = true
+		}
+	}
+
+	var added []string
+	var additions []string
+	for _, line := range splitLines(backup) {
+		identity, description, ok := authorizedKeyIdentity(line)
+		if !ok || present[identity] {
+			continue
+		}
+		present[identity] = true
+		additions = append(additions, line)
+		added = append(added, description)
+	}
+
+	return &MergeResult{Content: appendLines(local, additions), Added: added}
+}
+
+// authorizedKeyIdentity returns the key type and material of an
+// authorized_keys line, skipping any leading options. Lines without a
+// recognisable key are identified by their text.
+func authorizedKeyIdentity(line string) (identity, description string, ok bool) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		return "", "", false
+	}
+
+	fields := splitQuoted(trimmed)
+	for i := 0; i+1 < len(fields); i++ {
+		if isKeyType(fields[i]) {
+			description = fields[i]
+			if i+2 < len(fields) {
+				description += " " + strings.Join(fields[i+2:], " ")
+			}
+			return fields[i] + " " + fields[i+1], description, true
+		}
+	}
+	return "line " + trimmed, trimmed, true
+}
+
+// isKeyType recognises the key type field of an authorized_keys line
+func isKeyType(field string) bool {
+	for _, prefix := range []string{"ssh-", "ecdsa-sha2-", "sk-ssh-", "sk-ecdsa-sha2-"} {
+		if strings.HasPrefix(field, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitQuoted splits on whitespace outside double quotes, as sshd does for
+// options such as command="..."
+func splitQuoted(line string) []string {
+	var fields []string
+	var current strings.Builder
+	quoted, escaped := false, false
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && quoted:
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case (r == ' ' || r == '\t') && !quoted:
+			if current.Len() > 0 {
+				fields = append(fields, current.String())
+				current.Reset()
+			}
+			continue
+		}
+		current.WriteRune(r)
+	}
+	if current.Len() > 0 {
+		fields = append(fields, current.String())
+	}
+	return fields
+}
+
+// MergeSSHConfig adds the Host and Match blocks of the backup that the local
+// config lacks, compared by their header. Local blocks are kept as they are,
+// even when the backup has different settings for them. Global settings before
+// the first block are added after the local ones when the local config does
+// not contain the same line.
+func MergeSSHConfig(local, backup []byte) *MergeResult {
+	localConfig := parseSSHConfig(splitLines(local))
+	backupConfig := parseSSHConfig(splitLines(backup))
+
+	globals := make(map[string]bool)
+	for _, line := range localConfig.preamble {
+		if identity, ok := configDirective(line); ok {
+			globals[identity] = true
+		}
+	}
+	blocks := make(map[string]bool)
+	for _, block := range localConfig.blocks {
+		blocks[block.identity] = true
+	}
+
+	var added []string
+	var newGlobals []string
+	for _, line := range backupConfig.preamble {
+		identity, ok := configDirective(line)
+		if !ok || globals[identity] {
+			continue
+		}
+		globals[identity] = true
+		newGlobals = append(newGlobals, line)
+		added = append(added, strings.TrimSpace(line))
+	}
+
+	var newBlocks [][]string
+	for _, block := range backupConfig.blocks {
+		if blocks[block.identity] {
+			continue
+		}
+		blocks[block.identity] = true
+		newBlocks = append(newBlocks, trimBlankLines(block.lines))
+		added = append(added, strings.TrimSpace(block.lines[block.header]))
+	}
+
+	if len(added) == 0 {
+		return &MergeResult{Content: local}
+	}
+
+	// Globals must stay before the first block to apply to every host
+	lines := splitLines(local)
+	insertAt := len(localConfig.preamble)
+	for insertAt > 0 && strings.TrimSpace(lines[insertAt-1]) == "" {
+		insertAt--
+	}
+	merged := make([]string, 0, len(lines)+len(newGlobals)+1)
+	merged = append(merged, lines[:insertAt]...)
+	merged = append(merged, newGlobals...)
+	if len(newGlobals) > 0 && insertAt == 0 && insertAt < len(lines) {
+		merged = append(merged, "")
+	}
+	merged = append(merged, lines[insertAt:]...)
+
+	for _, block := range newBlocks {
+		if len(merged) > 0 && strings.TrimSpace(merged[len(merged)-1]) != "" {
+			merged = append(merged, "")
+		}
+		merged = append(merged, block...)
+	}
+
+	return &MergeResult{Content: []byte(strings.Join(merged, "\n") + "\n"), Added: added}
+}
+
+// sshConfig is an ssh config split into global lines and Host/Match blocks
+type sshConfig struct {
+	preamble []string
+	blocks   []configBlock
+}
+
+// configBlock is a Host or Match block with the comments directly above it
+type configBlock struct {
+	identity string
+	header   int // Index of the Host or Match line in lines
+	lines    []string
+}
+
+func parseSSHConfig(lines []string) sshConfig {
+	var config sshConfig
+	current := &config.preamble
+
+	for _, line := range lines {
+		identity, ok := configDirective(line)
+		keyword, _, _ := strings.Cut(identity, " ")
+		if !ok || (keyword != "host" && keyword != "match") {
+			*current = append(*current, line)
+			continue
+		}
+
+		// Comments directly above a header describe the block it starts
+		start := len(*current)
+		for start > 0 && strings.HasPrefix(strings.TrimSpace((*current)[start-1]), "#") {
+			start--
+		}
+		leading := append([]string(nil), (*current)[start:]...)
+		*current = (*current)[:start]
+
+		config.blocks = append(config.blocks, configBlock{
+			identity: identity,
+			header:   len(leading),
+			lines:    append(leading, line),
+		})
+		current = &config.blocks[len(config.blocks)-1].lines
+	}
+	return config
+}
+
+// configDirective normalises a directive line to its lower-cased keyword and
+// arguments separated by single spaces; blank lines and comments are not directives
+func configDirective(line string) (string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		return "", false
+	}
+
+	keyword, rest := trimmed, ""
+	if i := strings.IndexAny(trimmed, " \t="); i >= 0 {
+		keyword, rest = trimmed[:i], strings.TrimLeft(trimmed[i:], " \t=")
+	}
+	return strings.Join(append([]string{strings.ToLower(keyword)}, strings.Fields(rest)...), " "), true
+}
+
+// splitLines splits content into lines without their terminators
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+}
+
+// appendLines appends lines to content, terminating its last line first
+func appendLines(content []byte, lines []string) []byte {
+	if len(lines) == 0 {
+		return content
+	}
+	merged := append([]byte(nil), content...)
+	if len(merged) > 0 && merged[len(merged)-1] != '\n' {
+		merged = append(merged, '\n')
+	}
+	return append(merged, strings.Join(lines, "\n")+"\n"...)
+}
+
+func trimBlankLines(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
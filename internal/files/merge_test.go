@@ -0,0 +1,196 @@
+package files
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"reflect"
+	"testing"
+
+	"github.com/rzago/ssh-secret-keeper/internal/analyzer"
+	"github.com/rzago/ssh-secret-keeper/internal/ssh"
+)
+
+func TestMergeKnownHosts(t *testing.T) {
+	local := "github.com,140.82.121.4 ssh-ed25519 AAAAgithub\n" +
+		"# a comment\n" +
+		"gitlab.com ssh-ed25519 AAAAgitlab"
+	backup := "github.com ssh-ed25519 AAAAgithub\n" +
+		"github.com,140.82.121.3 ssh-ed25519 AAAAgithub old-ip\n" +
+		"gitlab.com ssh-rsa AAAAgitlabrsa\n" +
+		"@cert-authority *.example.com ssh-ed25519 AAAAca\n" +
+		"@cert-authority *.example.com ssh-ed25519 AAAAca\n"
+
+	result := MergeKnownHosts([]byte(local), []byte(backup))
+
+	want := local + "\n" +
+		"140.82.121.3 ssh-ed25519 AAAAgithub old-ip\n" +
+		"gitlab.com ssh-rsa AAAAgitlabrsa\n" +
+		"@cert-authority *.example.com ssh-ed25519 AAAAca\n"
+	if string(result.Content) != want {
+		t.Errorf("merged content =\n%s\nwant\n%s", result.Content, want)
+	}
+	wantAdded := []string{"140.82.121.3 ssh-ed25519", "gitlab.com ssh-rsa", "*.example.com ssh-ed25519"}
+	if !reflect.DeepEqual(result.Added, wantAdded) {
+		t.Errorf("Added = %q, want %q", result.Added, wantAdded)
+	}
+
+	// Merging again adds nothing
+	again := MergeKnownHosts(result.Content, []byte(backup))
+	if again.Changed() || string(again.Content) != want {
+		t.Errorf("second merge changed the file: %q", again.Added)
+	}
+}
+
+// hashHost hashes a host name as HashKnownHosts does, with the given salt
+func hashHost(host, salt string) string {
+	mac := hmac.New(sha1.New, []byte(salt))
+	mac.Write([]byte(host))
+	return "|1|" + base64.StdEncoding.EncodeToString([]byte(salt)) + "|" + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestMergeKnownHosts_Hashed(t *testing.T) {
+	hashed := func(salt string) string {
+		return hashHost("github.com", salt) + " ssh-ed25519 AAAAgithub\n" +
+			hashHost("140.82.121.4", salt) + " ssh-ed25519 AAAAgithub\n" +
+			hashHost("gitlab.com", salt) + " ssh-ed25519 AAAAgitlab\n"
+	}
+
+	// The same hashed file merged twice adds nothing
+	local := hashed("salt-one-20-bytes...")
+	if result := MergeKnownHosts([]byte(local), []byte(local)); result.Changed() {
+		t.Errorf("merging a hashed file into itself added %q", result.Added)
+	}
+
+	// Nor does the same file hashed again with other salts
+	if result := MergeKnownHosts([]byte(local), []byte(hashed("salt-two-20-bytes..."))); result.Changed() {
+		t.Errorf("merging a rehashed file added %q", result.Added)
+	}
+
+	// Hashed names match plain ones with the same key, both ways
+	plain := "github.com,140.82.121.4 ssh-ed25519 AAAAgithub\ngitlab.com ssh-ed25519 AAAAgitlab\n"
+	if result := MergeKnownHosts([]byte(plain), []byte(local)); result.Changed() {
+		t.Errorf("merging hashed names of known plain hosts added %q", result.Added)
+	}
+	if result := MergeKnownHosts([]byte(local), []byte(plain)); result.Changed() {
+		t.Errorf("merging plain names of known hashed hosts added %q", result.Added)
+	}
+
+	// A hashed host with a key the local file lacks is added
+	other := hashHost("example.com", "salt-three-20-bytes.") + " ssh-ed25519 AAAAexample\n"
+	result := MergeKnownHosts([]byte(plain), []byte(local+other))
+	if string(result.Content) != plain+other {
+		t.Errorf("merged content =\n%s\nwant\n%s", result.Content, plain+other)
+	}
+}
+
+func TestMergeAuthorizedKeys(t *testing.T) {
+	local := "ssh-ed25519 AAAAlaptop me@laptop\n"
+	backup := "no-pty,command=\"echo ssh-rsa AAAAfake\" ssh-ed25519 AAAAlaptop old comment\n" +
+		"from=\"10.0.0.0/8\" ssh-ed25519 AAAAci ci@runner\n" +
+		"# disabled\n" +
+		"ssh-rsa AAAAdesktop\n"
+
+	result := MergeAuthorizedKeys([]byte(local), []byte(backup))
+
+	want := local +
+		"from=\"10.0.0.0/8\" ssh-ed25519 AAAAci ci@runner\n" +
+		"ssh-rsa AAAAdesktop\n"
+	if string(result.Content) != want {
+		t.Errorf("merged content =\n%s\nwant\n%s", result.Content, want)
+	}
+	wantAdded := []string{"ssh-ed25519 ci@runner", "ssh-rsa"}
+	if !reflect.DeepEqual(result.Added, wantAdded) {
+		t.Errorf("Added = %q, want %q", result.Added, wantAdded)
+	}
+}
+
+func TestMergeSSHConfig(t *testing.T) {
+	local := `Include config.d/*
+ServerAliveInterval 30
+
+Host github.com
+  User git
+  IdentityFile ~/.ssh/id_local
+`
+	backup := `ServerAliveInterval 60
+AddKeysToAgent yes
+
+host   github.com
+  User git
+  IdentityFile ~/.ssh/id_backup
+
+# Work bastion
+Host bastion
+  HostName bastion.example.com
+  User ops
+
+Match host *.internal
+  ProxyJump bastion
+`
+
+	result := MergeSSHConfig([]byte(local), []byte(backup))
+
+	want := `Include config.d/*
+ServerAliveInterval 30
+ServerAliveInterval 60
+AddKeysToAgent yes
+
+Host github.com
+  User git
+  IdentityFile ~/.ssh/id_local
+
+# Work bastion
+Host bastion
+  HostName bastion.example.com
+  User ops
+
+Match host *.internal
+  ProxyJump bastion
+`
+	if string(result.Content) != want {
+		t.Errorf("merged content =\n%s\nwant\n%s", result.Content, want)
+	}
+	wantAdded := []string{"ServerAliveInterval 60", "AddKeysToAgent yes", "Host bastion", "Match host *.internal"}
+	if !reflect.DeepEqual(result.Added, wantAdded) {
+		t.Errorf("Added = %q, want %q", result.Added, wantAdded)
+	}
+
+	again := MergeSSHConfig(result.Content, []byte(backup))
+	if again.Changed() {
+		t.Errorf("second merge added %q", again.Added)
+	}
+}
+
+func TestMergeSSHConfig_NoGlobals(t *testing.T) {
+	local := "Host a\n  User x\n"
+	backup := "Compression yes\nHost b\n  User y\n"
+
+	result := MergeSSHConfig([]byte(local), []byte(backup))
+
+	want := "Compression yes\n\nHost a\n  User x\n\nHost b\n  User y\n"
+	if string(result.Content) != want {
+		t.Errorf("merged content =\n%q\nwant\n%q", result.Content, want)
+	}
+}
+
+func TestCanMerge(t *testing.T) {
+	tests := []struct {
+		name string
+		file *ssh.FileData
+		want bool
+	}{
+		{"known_hosts by type", &ssh.FileData{Filename: "hosts", KeyInfo: &analyzer.KeyInfo{Type: analyzer.KeyTypeHosts}}, true},
+		{"config by name", &ssh.FileData{Filename: "config"}, true},
+		{"private key named config", &ssh.FileData{Filename: "config", KeyInfo: &analyzer.KeyInfo{Type: analyzer.KeyTypePrivate}}, false},
+		{"symlink", &ssh.FileData{Filename: "known_hosts", LinkTarget: "/elsewhere"}, false},
+		{"public key", &ssh.FileData{Filename: "id_ed25519.pub"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CanMerge(tt.file); got != tt.want {
+				t.Errorf("CanMerge() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package files
 import (
 	"crypto/md5"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"time"
@@ -20,35 +21,58 @@ func NewReadService() *ReadService {
 	return &ReadService{}
 }
 
-// ReadSSHDirectory reads all files from an SSH directory
+// ReadSSHDirectory reads all files from an SSH directory tree. Keys are
+// slash-separated paths relative to sshDir; symlinks are recorded as links
+// and sockets, pipes and devices are skipped.
 func (s *ReadService) ReadSSHDirectory(sshDir string) (map[string]*ssh.FileData, error) {
 	if err := s.ValidateDirectory(sshDir); err != nil {
 		return nil, fmt.Errorf("directory validation failed: %w", err)
 	}
 
-	files, err := os.ReadDir(sshDir)
-	if err != nil {
-		return nil, fmt.Errorf("cannot read directory: %w", err)
-	}
-
 	fileData := make(map[string]*ssh.FileData)
 
-	for _, file := range files {
-		if file.IsDir() {
-			continue
+	err := filepath.WalkDir(sshDir, func(filePath string, entry fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			if filePath == sshDir {
+				return walkErr
+			}
+			log.Warn().Err(walkErr).Str("path", filePath).Msg("Cannot access path, skipping")
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
 		}
 
-		filePath := filepath.Join(sshDir, file.Name())
-		data, err := s.readSingleFile(filePath)
+		relPath, err := filepath.Rel(sshDir, filePath)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		var data *ssh.FileData
+		switch {
+		case entry.Type()&os.ModeSymlink != 0:
+			data, err = s.readSymlink(filePath)
+		case entry.Type().IsRegular():
+			data, err = s.readSingleFile(filePath)
+		default:
+			log.Debug().Str("file", relPath).Msg("Skipping special file")
+			return nil
+		}
 		if err != nil {
 			log.Warn().
 				Err(err).
-				Str("file", file.Name()).
+				Str("file", relPath).
 				Msg("Failed to read file, skipping")
-			continue
+			return nil
 		}
 
-		fileData[file.Name()] = data
+		data.Filename = relPath
+		fileData[relPath] = data
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot read directory: %w", err)
 	}
 
 	log.Info().
@@ -123,6 +147,27 @@ func (s *ReadService) VerifyFilePermissions(filePath string, expectedMode os.Fil
 
 // Private helper methods
 
+func (s *ReadService) readSymlink(filePath string) (*ssh.FileData, error) {
+	stat, err := os.Lstat(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot stat symlink: %w", err)
+	}
+
+	target, err := os.Readlink(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read symlink: %w", err)
+	}
+
+	return &ssh.FileData{
+		Filename:    filepath.Base(filePath),
+		Content:     []byte{},
+		Permissions: stat.Mode(),
+		ModTime:     stat.ModTime(),
+		Checksum:    s.CalculateChecksum([]byte{}),
+		LinkTarget:  target,
+	}, nil
+}
+
 func (s *ReadService) readSingleFile(filePath string) (*ssh.FileData, error) {
 	// Get file information first
 	stat, err := os.Stat(filePath)
@@ -261,20 +261,37 @@ NhAAAAAwEAAQAAAQEA1234567890
 		os.WriteFile(filepath.Join(tmpDir, "id_rsa"), []byte("private key"), 0600)
 		os.MkdirAll(filepath.Join(tmpDir, "subdir"), 0755)
 		os.WriteFile(filepath.Join(tmpDir, "subdir", "nested_key"), []byte("nested"), 0600)
+		os.Symlink("subdir/nested_key", filepath.Join(tmpDir, "nested_link"))
 
 		files, err := service.ReadSSHDirectory(tmpDir)
 		if err != nil {
 			t.Errorf("ReadSSHDirectory() unexpected error: %v", err)
 		}
 
-		// Should only read files, not subdirectories
-		if len(files) != 1 {
-			t.Errorf("ReadSSHDirectory() expected 1 file, got %d", len(files))
+		// Should read nested files by relative path and record symlinks as links
+		if len(files) != 3 {
+			t.Errorf("ReadSSHDirectory() expected 3 files, got %d", len(files))
 		}
 
 		if _, exists := files["id_rsa"]; !exists {
 			t.Error("Expected id_rsa file not found")
 		}
+
+		nested, exists := files["subdir/nested_key"]
+		if !exists {
+			t.Fatal("Expected subdir/nested_key file not found")
+		}
+		if nested.Filename != "subdir/nested_key" || string(nested.Content) != "nested" {
+			t.Errorf("nested file = %q with content %q", nested.Filename, nested.Content)
+		}
+
+		link, exists := files["nested_link"]
+		if !exists {
+			t.Fatal("Expected nested_link symlink not found")
+		}
+		if !link.IsSymlink() || link.LinkTarget != "subdir/nested_key" {
+			t.Errorf("nested_link target = %q, want subdir/nested_key", link.LinkTarget)
+		}
 	})
 
 	t.Run("nonexistent directory", func(t *testing.T) {
@@ -0,0 +1,138 @@
+package files
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/rzago/ssh-secret-keeper/internal/analyzer"
+	"github.com/rzago/ssh-secret-keeper/internal/ssh"
+)
+
+// FilterMatch lists the backup files one restore filter matched
+type FilterMatch struct {
+	Filter string   // Flag name, e.g. "type"
+	Values []string // Values the filter was given
+	Files  []string // Matching backup files, sorted
+}
+
+// restoreFilter is one filter of a restore; a file passes when it matches any
+// of the values
+type restoreFilter struct {
+	name    string
+	values  []string
+	matches func(filename string, fileData *ssh.FileData, value string) bool
+}
+
+// restoreFilters returns the filters set in options. Every filter but --files
+// is driven by the file's stored KeyInfo, so files without one never match.
+func restoreFilters(options ssh.RestoreOptions) []restoreFilter {
+	all := []restoreFilter{
+		{"files", options.FileFilter, func(filename string, _ *ssh.FileData, pattern string) bool {
+			matched, _ := filepath.Match(pattern, filename)
+			return matched
+		}},
+		{"type", options.TypeFilter, func(_ string, fileData *ssh.FileData, keyType string) bool {
+			return fileData.KeyInfo != nil && string(fileData.KeyInfo.Type) == keyType
+		}},
+		{"purpose", options.PurposeFilter, func(_ string, fileData *ssh.FileData, purpose string) bool {
+			return fileData.KeyInfo != nil && strings.EqualFold(string(fileData.KeyInfo.Purpose), purpose)
+		}},
+		{"service", options.ServiceFilter, func(_ string, fileData *ssh.FileData, service string) bool {
+			return fileData.KeyInfo != nil && strings.EqualFold(fileData.KeyInfo.Service, service)
+		}},
+		{"pair", options.PairFilter, matchesPair},
+	}
+
+	var filters []restoreFilter
+	for _, filter := range all {
+		if len(filter.values) > 0 {
+			filters = append(filters, filter)
+		}
+	}
+	return filters
+}
+
+func (f restoreFilter) match(filename string, fileData *ssh.FileData) bool {
+	for _, value := range f.values {
+		if f.matches(filename, fileData, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesPair reports whether a file is the private key, public key or
+// certificate of the key pair named by pattern, a base name such as id_work or
+// work/id_work, or a glob matching one
+func matchesPair(filename string, fileData *ssh.FileData, pattern string) bool {
+	if fileData.KeyInfo == nil {
+		return false
+	}
+	switch fileData.KeyInfo.Type {
+	case analyzer.KeyTypePrivate, analyzer.KeyTypePublic, analyzer.KeyTypeCertificate:
+	default:
+		return false
+	}
+
+	base := PairBaseName(filename)
+	if fileData.KeyInfo.KeyPair != nil && fileData.KeyInfo.KeyPair.BaseName != "" {
+		base = fileData.KeyInfo.KeyPair.BaseName
+	}
+	if matched, _ := path.Match(pattern, base); matched {
+		return true
+	}
+	matched, _ := path.Match(pattern, path.Base(base))
+	return matched
+}
+
+// PairBaseName is the key pair a key file belongs to: id_work, id_work.pub and
+// id_work-cert.pub all belong to id_work
+func PairBaseName(filename string) string {
+	for _, suffix := range []string{"-cert.pub", ".pub"} {
+		if base := strings.TrimSuffix(filename, suffix); base != filename && base != "" {
+			return base
+		}
+	}
+	return filename
+}
+
+// FilterMatches reports the backup files each filter set in options matches,
+// so a dry run can explain what a filtered restore selects
+func (s *RestoreService) FilterMatches(backup *ssh.BackupData, options ssh.RestoreOptions) []FilterMatch {
+	var matches []FilterMatch
+	for _, filter := range restoreFilters(options) {
+		match := FilterMatch{Filter: filter.name, Values: filter.values}
+		for _, filename := range backup.FileNames() {
+			if filter.match(filename, backup.Files[filename]) {
+				match.Files = append(match.Files, filename)
+			}
+		}
+		matches = append(matches, match)
+	}
+	return matches
+}
+
+// SelectedFiles returns the backup files that pass every filter in options,
+// sorted by name
+func (s *RestoreService) SelectedFiles(backup *ssh.BackupData, options ssh.RestoreOptions) []string {
+	var selected []string
+	for _, filename := range backup.FileNames() {
+		if s.shouldRestoreFile(filename, backup.Files[filename], options) {
+			selected = append(selected, filename)
+		}
+	}
+	return selected
+}
+
+// SelectedBackup returns a copy of backup holding only the files that pass
+// every filter in options, so what a filtered restore wrote can be verified
+// and counted without looking at files it left alone
+func (s *RestoreService) SelectedBackup(backup *ssh.BackupData, options ssh.RestoreOptions) *ssh.BackupData {
+	selected := *backup
+	selected.Files = make(map[string]*ssh.FileData)
+	for _, filename := range s.SelectedFiles(backup, options) {
+		selected.Files[filename] = backup.Files[filename]
+	}
+	return &selected
+}
@@ -0,0 +1,166 @@
+package files
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/rzago/ssh-secret-keeper/internal/analyzer"
+	"github.com/rzago/ssh-secret-keeper/internal/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func filterTestBackup() *ssh.BackupData {
+	file := func(name string, keyType analyzer.KeyType, purpose analyzer.KeyPurpose, service string) *ssh.FileData {
+		return &ssh.FileData{
+			Filename:    name,
+			Permissions: 0600,
+			KeyInfo:     &analyzer.KeyInfo{Filename: name, Type: keyType, Purpose: purpose, Service: service},
+		}
+	}
+	return &ssh.BackupData{
+		Files: map[string]*ssh.FileData{
+			"id_ed25519":            file("id_ed25519", analyzer.KeyTypePrivate, analyzer.PurposePersonal, ""),
+			"id_ed25519.pub":        file("id_ed25519.pub", analyzer.KeyTypePublic, analyzer.PurposePersonal, ""),
+			"github_rsa":            file("github_rsa", analyzer.KeyTypePrivate, analyzer.PurposeService, "github"),
+			"work/id_work":          file("work/id_work", analyzer.KeyTypePrivate, analyzer.PurposeWork, ""),
+			"work/id_work.pub":      file("work/id_work.pub", analyzer.KeyTypePublic, analyzer.PurposeWork, ""),
+			"work/id_work-cert.pub": file("work/id_work-cert.pub", analyzer.KeyTypeCertificate, analyzer.PurposeWork, ""),
+			"config":                file("config", analyzer.KeyTypeConfig, analyzer.PurposePersonal, ""),
+			"notes.txt":             {Filename: "notes.txt", Permissions: 0644},
+		},
+	}
+}
+
+func TestRestoreService_SelectedFiles(t *testing.T) {
+	service := NewRestoreService()
+	backup := filterTestBackup()
+
+	tests := []struct {
+		name    string
+		options ssh.RestoreOptions
+		want    []string
+	}{
+		{"no filters", ssh.RestoreOptions{}, backup.FileNames()},
+		{"type", ssh.RestoreOptions{TypeFilter: []string{"private_key", "config"}}, []string{"config", "github_rsa", "id_ed25519", "work/id_work"}},
+		{"purpose", ssh.RestoreOptions{PurposeFilter: []string{"Work"}}, []string{"work/id_work", "work/id_work-cert.pub", "work/id_work.pub"}},
+		{"service", ssh.RestoreOptions{ServiceFilter: []string{"github"}}, []string{"github_rsa"}},
+		{"pair by base name", ssh.RestoreOptions{PairFilter: []string{"id_work"}}, []string{"work/id_work", "work/id_work-cert.pub", "work/id_work.pub"}},
+		{"pair by path", ssh.RestoreOptions{PairFilter: []string{"id_ed25519"}}, []string{"id_ed25519", "id_ed25519.pub"}},
+		{"filters combine", ssh.RestoreOptions{PurposeFilter: []string{"work"}, TypeFilter: []string{"public_key"}}, []string{"work/id_work.pub"}},
+		{"nothing matches", ssh.RestoreOptions{ServiceFilter: []string{"gitlab"}}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := service.SelectedFiles(backup, tt.options); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SelectedFiles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestoreService_FilterMatches(t *testing.T) {
+	service := NewRestoreService()
+	options := ssh.RestoreOptions{
+		FileFilter: []string{"id_*"},
+		PairFilter: []string{"github*"},
+	}
+
+	matches := service.FilterMatches(filterTestBackup(), options)
+	if len(matches) != 2 {
+		t.Fatalf("FilterMatches() = %+v, want the files and pair filters", matches)
+	}
+	if matches[0].Filter != "files" || !reflect.DeepEqual(matches[0].Files, []string{"id_ed25519", "id_ed25519.pub"}) {
+		t.Errorf("files match = %+v", matches[0])
+	}
+	if matches[1].Filter != "pair" || !reflect.DeepEqual(matches[1].Files, []string{"github_rsa"}) {
+		t.Errorf("pair match = %+v", matches[1])
+	}
+}
+
+func TestRestoreService_SelectedBackup_VerifiesFilteredRestore(t *testing.T) {
+	service := NewRestoreService()
+	handler := ssh.New()
+	dir := t.TempDir()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := gossh.MarshalPrivateKey(key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := gossh.NewPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := func(name string, content []byte, mode os.FileMode, keyType analyzer.KeyType) *ssh.FileData {
+		return &ssh.FileData{
+			Filename:    name,
+			Content:     content,
+			Size:        int64(len(content)),
+			Permissions: mode,
+			KeyInfo:     &analyzer.KeyInfo{Filename: name, Type: keyType},
+		}
+	}
+	backup := &ssh.BackupData{
+		Files: map[string]*ssh.FileData{
+			"id_ed25519":     file("id_ed25519", pem.EncodeToMemory(block), 0600, analyzer.KeyTypePrivate),
+			"id_ed25519.pub": file("id_ed25519.pub", gossh.MarshalAuthorizedKey(publicKey), 0644, analyzer.KeyTypePublic),
+			"id_rsa":         file("id_rsa", []byte("backed-up key"), 0600, analyzer.KeyTypePrivate),
+		},
+	}
+
+	// A local key the filter leaves alone, with a mode and content the
+	// backup's copy would fail verification on
+	if err := os.WriteFile(filepath.Join(dir, "id_rsa"), []byte("local key"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	options := ssh.RestoreOptions{PairFilter: []string{"id_ed25519"}, Overwrite: true}
+	if err := service.RestoreFiles(backup, dir, options); err != nil {
+		t.Fatalf("RestoreFiles() error = %v", err)
+	}
+
+	restored := service.SelectedBackup(backup, options)
+	if want := []string{"id_ed25519", "id_ed25519.pub"}; !reflect.DeepEqual(restored.FileNames(), want) {
+		t.Errorf("SelectedBackup() files = %v, want %v", restored.FileNames(), want)
+	}
+	if len(backup.Files) != 3 {
+		t.Errorf("SelectedBackup() changed the backup, %d files left", len(backup.Files))
+	}
+
+	if err := handler.VerifyRestorePermissions(restored, dir, nil); err != nil {
+		t.Errorf("VerifyRestorePermissions() error = %v", err)
+	}
+	report := handler.VerifyKeyPairs(restored, dir)
+	if !report.OK() || report.Valid != 1 || len(report.Pairs) != 1 {
+		t.Errorf("VerifyKeyPairs() = %+v, want only the restored pair, valid", report)
+	}
+
+	// The whole backup would flag the local key the restore did not touch
+	if err := handler.VerifyRestorePermissions(backup, dir, nil); err == nil {
+		t.Error("VerifyRestorePermissions() of the whole backup should flag the untouched key")
+	}
+}
+
+func TestPairBaseName(t *testing.T) {
+	tests := map[string]string{
+		"id_ed25519":            "id_ed25519",
+		"id_ed25519.pub":        "id_ed25519",
+		"work/id_work-cert.pub": "work/id_work",
+		".pub":                  ".pub",
+	}
+	for filename, want := range tests {
+		if got := PairBaseName(filename); got != want {
+			t.Errorf("PairBaseName(%q) = %q, want %q", filename, got, want)
+		}
+	}
+}
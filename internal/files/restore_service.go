@@ -3,6 +3,7 @@ package files
 import (
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
@@ -10,6 +11,7 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/rzago/ssh-secret-keeper/internal/analyzer"
 	"github.com/rzago/ssh-secret-keeper/internal/ssh"
+	"github.com/rzago/ssh-secret-keeper/internal/undo"
 	"github.com/rzago/ssh-secret-keeper/internal/utils"
 )
 
@@ -21,8 +23,47 @@ func NewRestoreService() *RestoreService {
 	return &RestoreService{}
 }
 
-// RestoreFiles restores files from backup to target directory
+// RestoreFiles restores files from backup to target directory. Every file it
+// replaces or creates is recorded in options.Undo first; without one, a
+// temporary transaction is used so a failed restore is rolled back here.
 func (s *RestoreService) RestoreFiles(backup *ssh.BackupData, targetDir string, options ssh.RestoreOptions) error {
+	if options.DryRun || options.Undo != nil {
+		return s.restoreFiles(backup, targetDir, options)
+	}
+
+	undoRoot, err := os.MkdirTemp("", "sshsk-undo-")
+	if err != nil {
+		return fmt.Errorf("failed to create undo area: %w", err)
+	}
+	defer os.RemoveAll(undoRoot)
+
+	options.Undo, err = undo.Begin(undoRoot, "")
+	if err != nil {
+		return err
+	}
+	if err := s.restoreFiles(backup, targetDir, options); err != nil {
+		return RollbackOnError(options.Undo, err)
+	}
+	return nil
+}
+
+// RollbackOnError rolls back a failed restore and returns its error, noting
+// whether the rollback succeeded
+func RollbackOnError(tx *undo.Transaction, restoreErr error) error {
+	reverted, err := tx.Rollback()
+	if err != nil {
+		log.Error().Err(err).Msg("Rollback of failed restore incomplete")
+		return fmt.Errorf("%w (rollback failed: %v)", restoreErr, err)
+	}
+
+	log.Warn().
+		Err(restoreErr).
+		Int("reverted", len(reverted)).
+		Msg("Restore failed, previous files put back")
+	return fmt.Errorf("%w (changes rolled back)", restoreErr)
+}
+
+func (s *RestoreService) restoreFiles(backup *ssh.BackupData, targetDir string, options ssh.RestoreOptions) error {
 	if backup == nil {
 		return fmt.Errorf("backup data is nil")
 	}
@@ -44,11 +85,34 @@ func (s *RestoreService) RestoreFiles(backup *ssh.BackupData, targetDir string,
 		Int("total_files", len(backup.Files)).
 		Msg("Starting file restoration")
 
-	// Create SSH directory if it doesn't exist
+	// Recorded subdirectories behind a symlink are left alone, like the files in them
+	directories := s.restorableDirectories(backup, resolvedTargetDir)
+
+	// Create SSH directory and its recorded subdirectories if they don't exist
 	if !options.DryRun {
-		if err := s.CreateSSHDirectory(resolvedTargetDir); err != nil {
+		createDir := s.CreateSSHDirectory
+		switch {
+		case options.System:
+			createDir = s.CreateSystemDirectory
+		case options.DirMode != 0:
+			createDir = func(dir string) error { return s.CreateDirectory(dir, options.DirMode) }
+		}
+		if err := options.Undo.SaveDirectory(resolvedTargetDir); err != nil {
+			return err
+		}
+		if err := createDir(resolvedTargetDir); err != nil {
 			return fmt.Errorf("failed to create SSH directory: %w", err)
 		}
+		for dir := range directories {
+			if dirPath, err := utils.SafeJoin(resolvedTargetDir, dir); err == nil {
+				if err := options.Undo.SaveDirectory(dirPath); err != nil {
+					return err
+				}
+			}
+		}
+		if err := ssh.CreateDirectories(resolvedTargetDir, directories); err != nil {
+			return err
+		}
 	}
 
 	restoredCount := 0
@@ -61,7 +125,17 @@ func (s *RestoreService) RestoreFiles(backup *ssh.BackupData, targetDir string,
 			continue
 		}
 
-		targetPath := filepath.Join(resolvedTargetDir, filename)
+		targetPath, err := utils.SafeJoin(resolvedTargetDir, filename)
+		if err != nil {
+			log.Error().Err(err).Str("file", filename).Msg("Refusing to restore file outside the target directory")
+			skippedFiles = append(skippedFiles, filename)
+			continue
+		}
+		if s.belowSymlink(backup, resolvedTargetDir, filename, targetPath) {
+			log.Error().Str("file", filename).Msg("Refusing to restore file behind a symlink")
+			skippedFiles = append(skippedFiles, filename)
+			continue
+		}
 
 		if options.DryRun {
 			s.logDryRunRestore(filename, targetPath, fileData)
@@ -71,7 +145,7 @@ func (s *RestoreService) RestoreFiles(backup *ssh.BackupData, targetDir string,
 
 		// Handle existing files
 		if s.fileExists(targetPath) {
-			action, err := s.handleExistingFile(filename, targetPath, options)
+			action, err := s.handleExistingFile(filename, targetPath, fileData, options)
 			if err != nil {
 				return fmt.Errorf("error handling existing file %s: %w", filename, err)
 			}
@@ -80,12 +154,46 @@ func (s *RestoreService) RestoreFiles(backup *ssh.BackupData, targetDir string,
 				skippedFiles = append(skippedFiles, filename)
 				continue
 			}
+
+			if action == "merge" {
+				merged, err := s.mergeExistingFile(fileData, targetPath, options.Undo)
+				if err != nil {
+					return fmt.Errorf("failed to merge file %s: %w", filename, err)
+				}
+				if err := s.setOwnership(targetPath, options); err != nil {
+					return err
+				}
+				if !merged {
+					skippedFiles = append(skippedFiles, filename)
+					continue
+				}
+				restoredCount++
+				continue
+			}
+		}
+
+		if err := options.Undo.Save(targetPath); err != nil {
+			return fmt.Errorf("failed to save %s for undo: %w", filename, err)
+		}
+
+		// Restore the file or symlink
+		if fileData.IsSymlink() {
+			if err := s.restoreSymlink(fileData, targetPath); err != nil {
+				return fmt.Errorf("failed to restore symlink %s: %w", filename, err)
+			}
+			if err := s.setOwnership(targetPath, options); err != nil {
+				return err
+			}
+			restoredCount++
+			continue
 		}
 
-		// Restore the file
 		if err := s.restoreSingleFile(fileData, targetPath); err != nil {
 			return fmt.Errorf("failed to restore file %s: %w", filename, err)
 		}
+		if err := s.setOwnership(targetPath, options); err != nil {
+			return err
+		}
 
 		restoredCount++
 
@@ -96,6 +204,24 @@ func (s *RestoreService) RestoreFiles(backup *ssh.BackupData, targetDir string,
 			Msg("File restored successfully")
 	}
 
+	if !options.DryRun {
+		if err := ssh.ApplyDirectoryPermissions(resolvedTargetDir, directories); err != nil {
+			log.Warn().Err(err).Msg("Failed to restore directory permissions")
+		}
+		if err := s.setOwnership(resolvedTargetDir, options); err != nil {
+			return err
+		}
+		for dir := range directories {
+			dirPath, err := utils.SafeJoin(resolvedTargetDir, dir)
+			if err != nil {
+				continue
+			}
+			if err := s.setOwnership(dirPath, options); err != nil {
+				return err
+			}
+		}
+	}
+
 	log.Info().
 		Int("restored", restoredCount).
 		Int("skipped", len(skippedFiles)).
@@ -196,6 +322,42 @@ func (s *RestoreService) CreateSSHDirectory(targetDir string) error {
 	return nil
 }
 
+// CreateSystemDirectory creates the host SSH directory (/etc/ssh) with mode 0755.
+// Unlike CreateSSHDirectory it never tightens an existing directory to 0700,
+// which would hide ssh_config and host public keys from unprivileged users.
+func (s *RestoreService) CreateSystemDirectory(targetDir string) error {
+	cleanDir := filepath.Clean(targetDir)
+	if err := os.MkdirAll(cleanDir, ssh.SystemDirMode); err != nil {
+		return fmt.Errorf("cannot create directory: %w", err)
+	}
+
+	log.Info().
+		Str("directory", cleanDir).
+		Str("permissions", fmt.Sprintf("%04o", ssh.SystemDirMode)).
+		Msg("System SSH directory ready")
+
+	return nil
+}
+
+// CreateDirectory creates the target directory of an additional source and
+// gives it mode, the directory's mode when it was backed up
+func (s *RestoreService) CreateDirectory(targetDir string, mode os.FileMode) error {
+	cleanDir := filepath.Clean(targetDir)
+	if err := os.MkdirAll(cleanDir, mode.Perm()); err != nil {
+		return fmt.Errorf("cannot create directory: %w", err)
+	}
+	if err := os.Chmod(cleanDir, mode.Perm()); err != nil {
+		return fmt.Errorf("cannot set directory permissions: %w", err)
+	}
+
+	log.Info().
+		Str("directory", cleanDir).
+		Str("permissions", fmt.Sprintf("%04o", mode.Perm())).
+		Msg("Source directory ready")
+
+	return nil
+}
+
 // VerifyRestorePermissions verifies that restored files have correct permissions
 func (s *RestoreService) VerifyRestorePermissions(backup *ssh.BackupData, targetDir string) error {
 	log.Info().
@@ -219,7 +381,11 @@ func (s *RestoreService) VerifyRestorePermissions(backup *ssh.BackupData, target
 
 	// Verify each restored file
 	for filename, fileData := range backup.Files {
-		targetPath := filepath.Join(resolvedTargetDir, filename)
+		if fileData.IsSymlink() {
+			continue
+		}
+
+		targetPath := filepath.Join(resolvedTargetDir, filepath.FromSlash(filename))
 
 		if err := s.verifyFilePermissions(targetPath, fileData); err != nil {
 			if s.isCriticalPermissionError(err, fileData) {
@@ -250,39 +416,25 @@ func (s *RestoreService) VerifyRestorePermissions(backup *ssh.BackupData, target
 // Private helper methods
 
 func (s *RestoreService) shouldRestoreFile(filename string, fileData *ssh.FileData, options ssh.RestoreOptions) bool {
-	// Check file filter
-	if len(options.FileFilter) > 0 {
-		matched := false
-		for _, pattern := range options.FileFilter {
-			if match, _ := filepath.Match(pattern, filename); match {
-				matched = true
-				break
-			}
-		}
-		if !matched {
+	// Every filter set must match; within a filter, any value may
+	for _, filter := range restoreFilters(options) {
+		if !filter.match(filename, fileData) {
 			return false
 		}
 	}
-
-	// Check type filter
-	if len(options.TypeFilter) > 0 && fileData.KeyInfo != nil {
-		matched := false
-		keyType := string(fileData.KeyInfo.Type)
-		for _, filterType := range options.TypeFilter {
-			if keyType == filterType {
-				matched = true
-				break
-			}
-		}
-		if !matched {
-			return false
-		}
-	}
-
 	return true
 }
 
 func (s *RestoreService) logDryRunRestore(filename, targetPath string, fileData *ssh.FileData) {
+	if fileData.IsSymlink() {
+		log.Info().
+			Str("file", filename).
+			Str("target", targetPath).
+			Str("link_target", fileData.LinkTarget).
+			Msg("[DRY RUN] Would restore symlink")
+		return
+	}
+
 	log.Info().
 		Str("file", filename).
 		Str("target", targetPath).
@@ -292,11 +444,17 @@ func (s *RestoreService) logDryRunRestore(filename, targetPath string, fileData
 }
 
 func (s *RestoreService) fileExists(path string) bool {
-	_, err := os.Stat(path)
+	_, err := os.Lstat(path)
 	return err == nil
 }
 
-func (s *RestoreService) handleExistingFile(filename, targetPath string, options ssh.RestoreOptions) (string, error) {
+func (s *RestoreService) handleExistingFile(filename, targetPath string, fileData *ssh.FileData, options ssh.RestoreOptions) (string, error) {
+	// Merging only applies to line-based files over a regular local file;
+	// everything else falls back to the other strategies
+	if options.Merge && CanMerge(fileData) && s.isRegularFile(targetPath) {
+		return "merge", nil
+	}
+
 	if options.Overwrite {
 		return "overwrite", nil
 	}
@@ -312,6 +470,138 @@ func (s *RestoreService) handleExistingFile(filename, targetPath string, options
 	return "skip", nil
 }
 
+// isRegularFile reports whether path is a regular file, not following symlinks
+func (s *RestoreService) isRegularFile(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+func (s *RestoreService) isSymlink(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.Mode()&os.ModeSymlink != 0
+}
+
+// belowSymlink reports whether writing a backup file at targetPath would go
+// through a symlink: a parent directory below root that is a symlink on disk
+// or one the backup itself restores. Such files are refused, as they are when
+// extracting archives, since the link may point outside the target directory.
+func (s *RestoreService) belowSymlink(backup *ssh.BackupData, root, filename, targetPath string) bool {
+	for dir := path.Dir(path.Clean(filename)); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if parent := backup.Files[dir]; parent != nil && parent.IsSymlink() {
+			return true
+		}
+	}
+
+	root = filepath.Clean(root)
+	for dir := filepath.Dir(targetPath); dir != root && len(dir) > len(root); dir = filepath.Dir(dir) {
+		if s.isSymlink(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// restorableDirectories returns the recorded subdirectories of a backup that
+// are neither symlinks nor behind one, so creating them and setting their
+// mode and owner cannot reach outside root
+func (s *RestoreService) restorableDirectories(backup *ssh.BackupData, root string) map[string]os.FileMode {
+	directories := make(map[string]os.FileMode, len(backup.Directories))
+	for dir, mode := range backup.Directories {
+		dirPath, err := utils.SafeJoin(root, dir)
+		if err != nil {
+			continue
+		}
+		if entry := backup.Files[path.Clean(dir)]; (entry != nil && entry.IsSymlink()) ||
+			s.isSymlink(dirPath) || s.belowSymlink(backup, root, dir, dirPath) {
+			log.Error().Str("directory", dir).Msg("Refusing to restore directory behind a symlink")
+			continue
+		}
+		directories[dir] = mode
+	}
+	return directories
+}
+
+// mergeExistingFile merges the backed-up entries missing from the local file
+// into it, keeping the local file's permissions and owner. It reports whether
+// anything was added; an up-to-date file is left untouched.
+func (s *RestoreService) mergeExistingFile(fileData *ssh.FileData, targetPath string, tx *undo.Transaction) (bool, error) {
+	result, err := s.mergeWithLocal(fileData, targetPath)
+	if err != nil {
+		return false, err
+	}
+	if !result.Changed() {
+		log.Info().
+			Str("file", fileData.Filename).
+			Msg("Local file already has every entry of the backup, nothing to merge")
+		return false, nil
+	}
+
+	info, err := os.Stat(targetPath)
+	if err != nil {
+		return false, fmt.Errorf("cannot stat file: %w", err)
+	}
+	if err := tx.Save(targetPath); err != nil {
+		return false, fmt.Errorf("failed to save %s for undo: %w", fileData.Filename, err)
+	}
+
+	if err := utils.WriteFileAtomic(targetPath, result.Content, info.Mode().Perm()); err != nil {
+		return false, err
+	}
+	if owner := ssh.DirectoryOwner(info); owner != nil && owner.UID != os.Geteuid() {
+		if err := os.Chown(targetPath, owner.UID, owner.GID); err != nil {
+			return false, fmt.Errorf("cannot keep file ownership: %w", err)
+		}
+	}
+
+	log.Info().
+		Str("file", fileData.Filename).
+		Str("target", targetPath).
+		Int("added", len(result.Added)).
+		Msg("Merged backup entries into existing file")
+	return true, nil
+}
+
+// mergeWithLocal merges a backed-up file with the current content of targetPath
+func (s *RestoreService) mergeWithLocal(fileData *ssh.FileData, targetPath string) (*MergeResult, error) {
+	local, err := os.ReadFile(targetPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read existing file: %w", err)
+	}
+	result, ok := Merge(fileData, local)
+	if !ok {
+		return nil, fmt.Errorf("%s cannot be merged", fileData.Filename)
+	}
+	return result, nil
+}
+
+// PlanMerges previews a restore with options.Merge: for every file that would
+// be merged into an existing local file, the entries the backup would add.
+// Nothing is written.
+func (s *RestoreService) PlanMerges(backup *ssh.BackupData, targetDir string, options ssh.RestoreOptions) (map[string]*MergeResult, error) {
+	resolvedTargetDir, err := s.resolveTargetDirectory(backup, targetDir, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve target directory: %w", err)
+	}
+
+	plans := make(map[string]*MergeResult)
+	for filename, fileData := range backup.Files {
+		if !CanMerge(fileData) || !s.shouldRestoreFile(filename, fileData, options) {
+			continue
+		}
+		targetPath, err := utils.SafeJoin(resolvedTargetDir, filename)
+		if err != nil || s.belowSymlink(backup, resolvedTargetDir, filename, targetPath) || !s.isRegularFile(targetPath) {
+			continue
+		}
+
+		result, err := s.mergeWithLocal(fileData, targetPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to merge file %s: %w", filename, err)
+		}
+		plans[filename] = result
+	}
+	return plans, nil
+}
+
 func (s *RestoreService) promptOverwrite(filename string) (string, error) {
 	fmt.Printf("File %s already exists. Overwrite? [y/N]: ", filename)
 
@@ -328,11 +618,33 @@ func (s *RestoreService) promptOverwrite(filename string) (string, error) {
 	return "skip", nil
 }
 
+func (s *RestoreService) restoreSymlink(fileData *ssh.FileData, targetPath string) error {
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0700); err != nil {
+		return fmt.Errorf("cannot create parent directory: %w", err)
+	}
+
+	if err := ssh.RestoreSymlink(targetPath, fileData.LinkTarget); err != nil {
+		return err
+	}
+
+	log.Info().
+		Str("file", fileData.Filename).
+		Str("target", targetPath).
+		Str("link_target", fileData.LinkTarget).
+		Msg("Symlink restored successfully")
+	return nil
+}
+
 func (s *RestoreService) restoreSingleFile(fileData *ssh.FileData, targetPath string) error {
 	if fileData.Content == nil {
 		return fmt.Errorf("file content is nil")
 	}
 
+	// Older backups carry no directory list, so make sure the parent exists
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0700); err != nil {
+		return fmt.Errorf("cannot create parent directory: %w", err)
+	}
+
 	// Check if file is empty and warn
 	if len(fileData.Content) == 0 {
 		log.Warn().
@@ -343,31 +655,13 @@ func (s *RestoreService) restoreSingleFile(fileData *ssh.FileData, targetPath st
 	// Determine appropriate permissions for the file
 	permissions := s.getAppropriatePermissions(fileData)
 
-	// If file exists, fix its permissions first to allow overwriting
-	if s.fileExists(targetPath) {
-		log.Info().
-			Str("file", filepath.Base(targetPath)).
-			Msg("Fixing permissions of existing file before overwrite")
-
-		// Use 0666 to make file writable, then set correct permissions later
-		if err := os.Chmod(targetPath, 0666); err != nil {
-			log.Warn().
-				Err(err).
-				Str("file", filepath.Base(targetPath)).
-				Msg("Failed to fix permissions of existing file before overwrite")
-		} else {
-			log.Info().
-				Str("file", filepath.Base(targetPath)).
-				Msg("Successfully fixed permissions of existing file")
-		}
-	}
-
-	// Write file with appropriate permissions
-	if err := os.WriteFile(targetPath, fileData.Content, permissions); err != nil {
+	// Replace the file in one step so a failure never leaves it truncated;
+	// read-only existing files need no permission fix-up this way
+	if err := utils.WriteFileAtomic(targetPath, fileData.Content, permissions); err != nil {
 		return fmt.Errorf("cannot write file: %w", err)
 	}
 
-	// Ensure permissions are set correctly (os.WriteFile only sets permissions for new files)
+	// Ensure permissions are set correctly and verify them
 	log.Debug().
 		Str("file", filepath.Base(targetPath)).
 		Str("permissions", fmt.Sprintf("%04o", permissions&os.ModePerm)).
@@ -415,6 +709,22 @@ func (s *RestoreService) restoreSingleFile(fileData *ssh.FileData, targetPath st
 	return nil
 }
 
+// setOwnership gives a restored path to root when restoring a system backup,
+// or to options.Owner when restoring for another user
+func (s *RestoreService) setOwnership(path string, options ssh.RestoreOptions) error {
+	switch {
+	case options.System:
+		if err := os.Lchown(path, 0, 0); err != nil {
+			return fmt.Errorf("cannot set root ownership on %s: %w", path, err)
+		}
+	case options.Owner != nil:
+		if err := os.Lchown(path, options.Owner.UID, options.Owner.GID); err != nil {
+			return fmt.Errorf("cannot give %s to uid %d: %w", path, options.Owner.UID, err)
+		}
+	}
+	return nil
+}
+
 // getAppropriatePermissions determines the correct permissions for a file
 func (s *RestoreService) getAppropriatePermissions(fileData *ssh.FileData) os.FileMode {
 	originalPerms := fileData.Permissions & os.ModePerm
@@ -467,7 +777,7 @@ func (s *RestoreService) resolveTargetDirectory(backup *ssh.BackupData, targetDi
 		}
 
 		// It's a relative path, resolve it
-		resolvedPath, err := pathNormalizer.ResolvePath(targetDir)
+		resolvedPath, err := pathNormalizer.ResolvePathWithHome(targetDir, options.HomeDir)
 		if err != nil {
 			return "", fmt.Errorf("failed to resolve target directory: %w", err)
 		}
@@ -489,7 +799,7 @@ func (s *RestoreService) resolveTargetDirectory(backup *ssh.BackupData, targetDi
 			Msg("Using normalized path from backup for cross-user compatibility")
 
 		// Resolve normalized path to current user's context
-		resolvedPath, err := pathNormalizer.ResolvePath(backup.SSHDirNorm)
+		resolvedPath, err := pathNormalizer.ResolvePathWithHome(backup.SSHDirNorm, options.HomeDir)
 		if err != nil {
 			log.Warn().
 				Err(err).
@@ -497,13 +807,16 @@ func (s *RestoreService) resolveTargetDirectory(backup *ssh.BackupData, targetDi
 				Msg("Failed to resolve normalized path, falling back to target directory")
 
 			if targetDir != "" {
-				return pathNormalizer.ResolvePath(targetDir)
+				return pathNormalizer.ResolvePathWithHome(targetDir, options.HomeDir)
 			}
-			return pathNormalizer.ResolvePath("~/.ssh") // Default fallback
+			return pathNormalizer.ResolvePathWithHome("~/.ssh", options.HomeDir) // Default fallback
 		}
 
 		// Warn if restoring across different users
 		currentUser := os.Getenv("USER")
+		if options.Owner != nil && options.Owner.User != "" {
+			currentUser = options.Owner.User
+		}
 		if currentUser == "" {
 			currentUser = "unknown"
 		}
@@ -521,7 +834,7 @@ func (s *RestoreService) resolveTargetDirectory(backup *ssh.BackupData, targetDi
 
 	// Legacy backup without normalized paths - use target directory or default
 	if targetDir != "" {
-		resolvedPath, err := pathNormalizer.ResolvePath(targetDir)
+		resolvedPath, err := pathNormalizer.ResolvePathWithHome(targetDir, options.HomeDir)
 		if err != nil {
 			return "", fmt.Errorf("failed to resolve target directory: %w", err)
 		}
@@ -535,7 +848,7 @@ func (s *RestoreService) resolveTargetDirectory(backup *ssh.BackupData, targetDi
 	}
 
 	// Default to current user's SSH directory
-	defaultPath, err := pathNormalizer.ResolvePath("~/.ssh")
+	defaultPath, err := pathNormalizer.ResolvePathWithHome("~/.ssh", options.HomeDir)
 	if err != nil {
 		return "", fmt.Errorf("failed to resolve default SSH directory: %w", err)
 	}
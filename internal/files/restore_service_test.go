@@ -6,11 +6,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/rzago/ssh-secret-keeper/internal/analyzer"
 	"github.com/rzago/ssh-secret-keeper/internal/ssh"
+	"github.com/rzago/ssh-secret-keeper/internal/undo"
 )
 
 func TestNewRestoreService(t *testing.T) {
@@ -252,6 +254,244 @@ func TestRestoreService_RestoreFiles_Actual(t *testing.T) {
 	}
 }
 
+func TestRestoreService_RestoreFiles_Tree(t *testing.T) {
+	service := NewRestoreService()
+	tmpDir := t.TempDir()
+
+	content := []byte("Host work\n")
+	backup := &ssh.BackupData{
+		Files: map[string]*ssh.FileData{
+			"config.d/work": {
+				Filename:    "config.d/work",
+				Content:     content,
+				Permissions: 0600,
+				Size:        int64(len(content)),
+			},
+			"config": {
+				Filename:    "config",
+				Content:     []byte{},
+				Permissions: os.ModeSymlink | 0777,
+				LinkTarget:  "config.d/work",
+			},
+			"../escape": {
+				Filename:    "../escape",
+				Content:     []byte("nope"),
+				Permissions: 0600,
+			},
+		},
+		Directories: map[string]os.FileMode{
+			"config.d": 0750,
+		},
+	}
+
+	if err := service.RestoreFiles(backup, tmpDir, ssh.RestoreOptions{}); err != nil {
+		t.Fatalf("RestoreFiles() failed: %v", err)
+	}
+
+	stat, err := os.Stat(filepath.Join(tmpDir, "config.d"))
+	if err != nil {
+		t.Fatalf("config.d was not created: %v", err)
+	}
+	if perm := stat.Mode().Perm(); perm != 0750 {
+		t.Errorf("config.d permissions = %04o, want 0750", perm)
+	}
+
+	restored, err := os.ReadFile(filepath.Join(tmpDir, "config.d", "work"))
+	if err != nil || string(restored) != string(content) {
+		t.Errorf("config.d/work = %q, %v", restored, err)
+	}
+
+	target, err := os.Readlink(filepath.Join(tmpDir, "config"))
+	if err != nil || target != "config.d/work" {
+		t.Errorf("config symlink target = %q, %v; want config.d/work", target, err)
+	}
+
+	if _, err := os.Lstat(filepath.Join(filepath.Dir(tmpDir), "escape")); err == nil {
+		t.Error("file outside the target directory was restored")
+	}
+
+	if err := service.VerifyRestorePermissions(backup, tmpDir); err != nil {
+		t.Errorf("VerifyRestorePermissions() error = %v", err)
+	}
+}
+
+func TestRestoreService_RestoreFiles_BehindSymlink(t *testing.T) {
+	service := NewRestoreService()
+	tmpDir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Chmod(outside, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// A symlink already in the target directory
+	if err := os.Symlink(outside, filepath.Join(tmpDir, "local")); err != nil {
+		t.Fatal(err)
+	}
+
+	file := func(name string) *ssh.FileData {
+		return &ssh.FileData{Filename: name, Content: []byte("pwned"), Permissions: 0600, Size: 5}
+	}
+	backup := &ssh.BackupData{
+		Files: map[string]*ssh.FileData{
+			"linked": {
+				Filename:    "linked",
+				Content:     []byte{},
+				Permissions: os.ModeSymlink | 0777,
+				LinkTarget:  outside,
+			},
+			"linked/from-backup": file("linked/from-backup"),
+			"local/from-disk":    file("local/from-disk"),
+			"local/sub/nested":   file("local/sub/nested"),
+			"config":             file("config"),
+		},
+		Directories: map[string]os.FileMode{
+			"local":     0700,
+			"local/sub": 0700,
+		},
+	}
+
+	if err := service.RestoreFiles(backup, tmpDir, ssh.RestoreOptions{Overwrite: true}); err != nil {
+		t.Fatalf("RestoreFiles() failed: %v", err)
+	}
+
+	entries, err := os.ReadDir(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		t.Errorf("restore wrote %s through a symlink", entry.Name())
+	}
+	if stat, err := os.Stat(outside); err != nil || stat.Mode().Perm() != 0755 {
+		t.Errorf("symlinked directory mode changed: %v, %v", stat, err)
+	}
+
+	if target, err := os.Readlink(filepath.Join(tmpDir, "linked")); err != nil || target != outside {
+		t.Errorf("linked symlink target = %q, %v; want %s", target, err, outside)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "config")); err != nil {
+		t.Errorf("config was not restored: %v", err)
+	}
+}
+
+func TestRestoreService_RestoreFiles_System(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("giving files root ownership requires root")
+	}
+
+	service := NewRestoreService()
+	tmpDir := t.TempDir()
+	if err := os.Chmod(tmpDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	backup := &ssh.BackupData{
+		Files: map[string]*ssh.FileData{
+			"ssh_host_ed25519_key": {
+				Filename:    "ssh_host_ed25519_key",
+				Content:     []byte("private"),
+				Permissions: 0600,
+			},
+			"sshd_config.d/50-hardening.conf": {
+				Filename:    "sshd_config.d/50-hardening.conf",
+				Content:     []byte("PasswordAuthentication no\n"),
+				Permissions: 0644,
+			},
+		},
+		Directories: map[string]os.FileMode{"sshd_config.d": 0755},
+	}
+
+	if err := service.RestoreFiles(backup, tmpDir, ssh.RestoreOptions{System: true}); err != nil {
+		t.Fatalf("RestoreFiles() failed: %v", err)
+	}
+
+	stat, err := os.Stat(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := stat.Mode().Perm(); perm != 0755 {
+		t.Errorf("system directory permissions = %04o, want 0755 to be kept", perm)
+	}
+
+	if err := ssh.VerifySystemFiles(backup, tmpDir); err != nil {
+		t.Errorf("VerifySystemFiles() error = %v", err)
+	}
+}
+
+func TestRestoreService_RestoreFiles_Owner(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("giving files to another user requires root")
+	}
+
+	service := NewRestoreService()
+	homeDir := t.TempDir()
+	owner := &ssh.Owner{User: "nobody", UID: 65534, GID: 65534}
+
+	backup := &ssh.BackupData{
+		Files: map[string]*ssh.FileData{
+			"id_ed25519": {
+				Filename:    "id_ed25519",
+				Content:     []byte("private"),
+				Permissions: 0600,
+			},
+			"config.d/work": {
+				Filename:    "config.d/work",
+				Content:     []byte("Host work\n"),
+				Permissions: 0600,
+			},
+		},
+		Directories: map[string]os.FileMode{"config.d": 0700},
+	}
+
+	// ~ expands to the owner's home, not the restoring user's
+	options := ssh.RestoreOptions{Owner: owner, HomeDir: homeDir}
+	if err := service.RestoreFiles(backup, "~/.ssh", options); err != nil {
+		t.Fatalf("RestoreFiles() failed: %v", err)
+	}
+	sshDir := filepath.Join(homeDir, ".ssh")
+
+	if err := ssh.New().VerifyRestorePermissions(backup, sshDir, owner); err != nil {
+		t.Errorf("VerifyRestorePermissions() error = %v", err)
+	}
+	if err := ssh.New().VerifyRestorePermissions(backup, sshDir, &ssh.Owner{UID: 0, GID: 0}); err == nil {
+		t.Error("VerifyRestorePermissions() should report files not owned by root")
+	}
+}
+
+func TestRestoreService_RestoreFiles_Source(t *testing.T) {
+	service := NewRestoreService()
+	targetDir := filepath.Join(t.TempDir(), ".kube")
+
+	source := &ssh.SourceData{
+		Name:    "kube",
+		Dir:     targetDir,
+		DirMode: 0750,
+		Files: map[string]*ssh.FileData{
+			"config": {Filename: "config", Content: []byte("apiVersion: v1\n"), Permissions: 0600},
+		},
+	}
+
+	options := ssh.RestoreOptions{Overwrite: true, DirMode: source.DirMode}
+	if err := service.RestoreFiles(source.AsBackup(), targetDir, options); err != nil {
+		t.Fatalf("RestoreFiles() failed: %v", err)
+	}
+
+	stat, err := os.Stat(targetDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := stat.Mode().Perm(); perm != 0750 {
+		t.Errorf("source directory permissions = %04o, want the recorded 0750", perm)
+	}
+
+	stat, err = os.Stat(filepath.Join(targetDir, "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := stat.Mode().Perm(); perm != 0600 {
+		t.Errorf("config permissions = %04o, want 0600", perm)
+	}
+}
+
 func TestRestoreService_RestoreFiles_WithFilters(t *testing.T) {
 	service := NewRestoreService()
 	tmpDir := t.TempDir()
@@ -413,6 +653,157 @@ func TestRestoreService_RestoreFiles_ExistingFiles(t *testing.T) {
 	}
 }
 
+func TestRestoreService_RestoreFiles_Merge(t *testing.T) {
+	service := NewRestoreService()
+	tmpDir := t.TempDir()
+
+	knownHosts := filepath.Join(tmpDir, "known_hosts")
+	if err := os.WriteFile(knownHosts, []byte("local.example ssh-ed25519 AAAAlocal\n"), 0640); err != nil {
+		t.Fatalf("Failed to create known_hosts: %v", err)
+	}
+	privateKey := filepath.Join(tmpDir, "id_ed25519")
+	if err := os.WriteFile(privateKey, []byte("local key"), 0600); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+
+	backup := &ssh.BackupData{
+		Files: map[string]*ssh.FileData{
+			"known_hosts": {
+				Filename:    "known_hosts",
+				Content:     []byte("backup.example ssh-ed25519 AAAAbackup\nlocal.example ssh-ed25519 AAAAlocal\n"),
+				Permissions: 0644,
+				KeyInfo:     &analyzer.KeyInfo{Type: analyzer.KeyTypeHosts},
+			},
+			"id_ed25519": {
+				Filename:    "id_ed25519",
+				Content:     []byte("backup key"),
+				Permissions: 0600,
+				KeyInfo:     &analyzer.KeyInfo{Type: analyzer.KeyTypePrivate},
+			},
+		},
+	}
+
+	// The preview reports the entries without writing them
+	plans, err := service.PlanMerges(backup, tmpDir, ssh.RestoreOptions{Merge: true})
+	if err != nil {
+		t.Fatalf("PlanMerges() failed: %v", err)
+	}
+	if len(plans) != 1 || len(plans["known_hosts"].Added) != 1 || plans["known_hosts"].Added[0] != "backup.example ssh-ed25519" {
+		t.Errorf("PlanMerges() = %+v, want one entry for known_hosts", plans)
+	}
+
+	if err := service.RestoreFiles(backup, tmpDir, ssh.RestoreOptions{Merge: true}); err != nil {
+		t.Fatalf("RestoreFiles() failed: %v", err)
+	}
+
+	content, _ := os.ReadFile(knownHosts)
+	want := "local.example ssh-ed25519 AAAAlocal\nbackup.example ssh-ed25519 AAAAbackup\n"
+	if string(content) != want {
+		t.Errorf("known_hosts = %q, want %q", content, want)
+	}
+	if info, err := os.Stat(knownHosts); err != nil || info.Mode().Perm() != 0640 {
+		t.Errorf("merged file should keep its local mode 0640, got %v (%v)", info.Mode().Perm(), err)
+	}
+
+	// Files that cannot be merged follow the default strategy and are skipped
+	if content, _ := os.ReadFile(privateKey); string(content) != "local key" {
+		t.Errorf("private key was replaced: %q", content)
+	}
+
+	// With --overwrite they are replaced while known_hosts is still merged
+	if err := service.RestoreFiles(backup, tmpDir, ssh.RestoreOptions{Merge: true, Overwrite: true}); err != nil {
+		t.Fatalf("RestoreFiles() failed: %v", err)
+	}
+	if content, _ := os.ReadFile(privateKey); string(content) != "backup key" {
+		t.Errorf("private key = %q, want the backup's", content)
+	}
+	if content, _ := os.ReadFile(knownHosts); string(content) != want {
+		t.Errorf("known_hosts changed by a second merge: %q", content)
+	}
+}
+
+func TestRestoreService_RestoreFiles_RollbackOnError(t *testing.T) {
+	service := NewRestoreService()
+	tmpDir := t.TempDir()
+
+	existingFile := filepath.Join(tmpDir, "id_rsa")
+	if err := os.WriteFile(existingFile, []byte("existing content"), 0600); err != nil {
+		t.Fatalf("Failed to create existing file: %v", err)
+	}
+	// A directory where the backup has a file makes the restore fail
+	if err := os.Mkdir(filepath.Join(tmpDir, "blocked"), 0700); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	backup := &ssh.BackupData{
+		Files: map[string]*ssh.FileData{
+			"id_rsa":  {Filename: "id_rsa", Content: []byte("new content"), Permissions: 0600},
+			"config":  {Filename: "config", Content: []byte("Host *\n"), Permissions: 0600},
+			"blocked": {Filename: "blocked", Content: []byte("file"), Permissions: 0600},
+		},
+	}
+
+	err := service.RestoreFiles(backup, tmpDir, ssh.RestoreOptions{Overwrite: true})
+	if err == nil {
+		t.Fatal("RestoreFiles() should fail when a file cannot be written")
+	}
+	if !strings.Contains(err.Error(), "rolled back") {
+		t.Errorf("error should mention the rollback: %v", err)
+	}
+
+	if content, _ := os.ReadFile(existingFile); string(content) != "existing content" {
+		t.Errorf("overwritten file not rolled back: %q", content)
+	}
+	if _, err := os.Lstat(filepath.Join(tmpDir, "config")); !os.IsNotExist(err) {
+		t.Errorf("file created by the failed restore should be removed, got %v", err)
+	}
+}
+
+func TestRestoreService_RestoreFiles_Undo(t *testing.T) {
+	service := NewRestoreService()
+	tmpDir := t.TempDir()
+	undoRoot := filepath.Join(t.TempDir(), "undo")
+
+	existingFile := filepath.Join(tmpDir, "known_hosts")
+	if err := os.WriteFile(existingFile, []byte("old\n"), 0644); err != nil {
+		t.Fatalf("Failed to create existing file: %v", err)
+	}
+
+	backup := &ssh.BackupData{
+		Files: map[string]*ssh.FileData{
+			"known_hosts": {Filename: "known_hosts", Content: []byte("new\n"), Permissions: 0600},
+		},
+	}
+
+	tx, err := undo.Begin(undoRoot, "backup-1")
+	if err != nil {
+		t.Fatalf("Begin() failed: %v", err)
+	}
+	if err := service.RestoreFiles(backup, tmpDir, ssh.RestoreOptions{Overwrite: true, Undo: tx}); err != nil {
+		t.Fatalf("RestoreFiles() failed: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit() failed: %v", err)
+	}
+	if content, _ := os.ReadFile(existingFile); string(content) != "new\n" {
+		t.Fatalf("file not restored: %q", content)
+	}
+
+	latest, err := undo.Latest(undoRoot)
+	if err != nil {
+		t.Fatalf("Latest() failed: %v", err)
+	}
+	if _, err := latest.Rollback(); err != nil {
+		t.Fatalf("Rollback() failed: %v", err)
+	}
+	if content, _ := os.ReadFile(existingFile); string(content) != "old\n" {
+		t.Errorf("undo did not put back the previous file: %q", content)
+	}
+	if info, _ := os.Stat(existingFile); info.Mode().Perm() != 0644 {
+		t.Errorf("undo did not put back the previous mode: %04o", info.Mode().Perm())
+	}
+}
+
 func TestRestoreService_VerifyRestorePermissions(t *testing.T) {
 	service := NewRestoreService()
 	tmpDir := t.TempDir()
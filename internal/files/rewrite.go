@@ -0,0 +1,230 @@
+package files
+
+import (
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rzago/ssh-secret-keeper/internal/analyzer"
+	"github.com/rzago/ssh-secret-keeper/internal/ssh"
+)
+
+// PathRewrite is a home directory path in a restored ssh config that was
+// rewritten to ~/ because it pointed into the home of the backed-up account
+type PathRewrite struct {
+	File      string // Config file, relative to the SSH directory
+	Line      int    // 1-based line number
+	Directive string // Keyword as written, e.g. IdentityFile
+	From      string
+	To        string
+}
+
+// pathDirectives are the ssh config keywords taking file paths in which ssh
+// expands ~
+var pathDirectives = map[string]bool{
+	"certificatefile":     true,
+	"controlpath":         true,
+	"identityagent":       true,
+	"identityfile":        true,
+	"include":             true,
+	"revokedhostkeys":     true,
+	"userknownhostsfile":  true,
+	"securitykeyprovider": true,
+}
+
+// BackupHomes returns the home directories the paths in a backup may refer to
+// that differ from newHome: the parent of its absolute SSH directory and the
+// conventional Linux and macOS homes of its recorded users. Paths under these
+// are rewritten on restore.
+func BackupHomes(backup *ssh.BackupData, newHome string) []string {
+	var candidates []string
+	if filepath.IsAbs(backup.SSHDir) && filepath.Base(backup.SSHDir) == ".ssh" {
+		candidates = append(candidates, filepath.Dir(backup.SSHDir))
+	}
+
+	users := []string{backup.Username, backup.OriginalUser}
+	if backup.Owner != nil {
+		users = append(users, backup.Owner.User)
+	}
+	for _, user := range users {
+		if user == "" || user == "unknown" || strings.Contains(user, "/") {
+			continue
+		}
+		if user == "root" {
+			candidates = append(candidates, "/root", "/var/root")
+			continue
+		}
+		candidates = append(candidates, "/home/"+user, "/Users/"+user)
+	}
+
+	newHome = filepath.Clean(newHome)
+	seen := make(map[string]bool)
+	var homes []string
+	for _, home := range candidates {
+		home = filepath.Clean(home)
+		if home == "/" || home == newHome || seen[home] {
+			continue
+		}
+		seen[home] = true
+		homes = append(homes, home)
+	}
+	sort.Strings(homes)
+	return homes
+}
+
+// RewriteConfigHomes rewrites the paths under oldHomes in the ssh config files
+// of a backup to ~/, updating their content, size and checksum in place. The
+// rewrites are returned in file and line order.
+func RewriteConfigHomes(backup *ssh.BackupData, oldHomes []string) []PathRewrite {
+	if len(oldHomes) == 0 {
+		return nil
+	}
+
+	var rewrites []PathRewrite
+	for _, filename := range backup.FileNames() {
+		fileData := backup.Files[filename]
+		if !isSSHConfig(filename, fileData) {
+			continue
+		}
+		content, changes := RewriteHomePaths(fileData.Content, oldHomes)
+		if len(changes) == 0 {
+			continue
+		}
+		for i := range changes {
+			changes[i].File = filename
+		}
+		fileData.Content = content
+		fileData.Size = int64(len(content))
+		fileData.Checksum = ssh.Checksum(content)
+		rewrites = append(rewrites, changes...)
+	}
+	return rewrites
+}
+
+// isSSHConfig reports whether a backup file is an ssh client config, by its
+// analysis or, without one, its name. Included files such as config.d drop-ins
+// are recognised by the analyzer from their content.
+func isSSHConfig(filename string, fileData *ssh.FileData) bool {
+	if fileData == nil || fileData.IsSymlink() {
+		return false
+	}
+	if fileData.KeyInfo != nil && fileData.KeyInfo.Type != "" {
+		return fileData.KeyInfo.Type == analyzer.KeyTypeConfig && fileData.KeyInfo.Format != analyzer.FormatSSHD
+	}
+	return path.Base(filename) == "config"
+}
+
+// RewriteHomePaths rewrites the arguments of path-taking directives of an ssh
+// config that lie under one of oldHomes to ~/. The rest of each line, including
+// quoting and comments, is kept as written.
+func RewriteHomePaths(content []byte, oldHomes []string) ([]byte, []PathRewrite) {
+	lines := splitLines(content)
+	var rewrites []PathRewrite
+
+	for i, line := range lines {
+		keyword, args := configArguments(line)
+		if !pathDirectives[strings.ToLower(keyword)] {
+			continue
+		}
+
+		var changes []PathRewrite
+		// Replace from the end so earlier offsets stay valid
+		for j := len(args) - 1; j >= 0; j-- {
+			arg := args[j]
+			rewritten, ok := rewriteHomePath(arg.value, oldHomes)
+			if !ok {
+				continue
+			}
+			line = line[:arg.start] + strings.Replace(line[arg.start:arg.end], arg.value, rewritten, 1) + line[arg.end:]
+			changes = append([]PathRewrite{{
+				Line:      i + 1,
+				Directive: keyword,
+				From:      arg.value,
+				To:        rewritten,
+			}}, changes...)
+		}
+		lines[i] = line
+		rewrites = append(rewrites, changes...)
+	}
+	if len(rewrites) == 0 {
+		return content, nil
+	}
+
+	rewritten := strings.Join(lines, "\n")
+	if strings.HasSuffix(string(content), "\n") {
+		rewritten += "\n"
+	}
+	return []byte(rewritten), rewrites
+}
+
+// rewriteHomePath turns a path under one of homes into the same path under ~/
+func rewriteHomePath(value string, homes []string) (string, bool) {
+	for _, home := range homes {
+		if value == home {
+			return "~", true
+		}
+		if rest := strings.TrimPrefix(value, home+"/"); rest != value {
+			return "~/" + rest, true
+		}
+	}
+	return "", false
+}
+
+// configArgument is one argument of an ssh config line, without its quotes
+type configArgument struct {
+	value      string
+	start, end int // Byte offsets in the line, including any quotes
+}
+
+// configArguments splits an ssh config line into its keyword and arguments,
+// stopping at a comment. Lines without a keyword return an empty one.
+func configArguments(line string) (string, []configArgument) {
+	trimmed := strings.TrimLeft(line, " \t")
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		return "", nil
+	}
+	offset := len(line) - len(trimmed)
+
+	end := strings.IndexAny(trimmed, " \t=")
+	if end < 0 {
+		return trimmed, nil
+	}
+	keyword := trimmed[:end]
+
+	// The keyword is separated by whitespace and at most one =
+	pos := offset + end
+	for pos < len(line) && (line[pos] == ' ' || line[pos] == '\t') {
+		pos++
+	}
+	if pos < len(line) && line[pos] == '=' {
+		pos++
+	}
+
+	var args []configArgument
+	for pos < len(line) {
+		for pos < len(line) && (line[pos] == ' ' || line[pos] == '\t') {
+			pos++
+		}
+		if pos >= len(line) || line[pos] == '#' {
+			break
+		}
+
+		start := pos
+		if line[pos] == '"' {
+			closing := strings.IndexByte(line[pos+1:], '"')
+			if closing < 0 {
+				// Unterminated quote; ssh rejects the line, leave it alone
+				return keyword, nil
+			}
+			pos += closing + 2
+			args = append(args, configArgument{value: line[start+1 : pos-1], start: start, end: pos})
+			continue
+		}
+		for pos < len(line) && line[pos] != ' ' && line[pos] != '\t' {
+			pos++
+		}
+		args = append(args, configArgument{value: line[start:pos], start: start, end: pos})
+	}
+	return keyword, args
+}
@@ -0,0 +1,115 @@
+package files
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rzago/ssh-secret-keeper/internal/analyzer"
+	"github.com/rzago/ssh-secret-keeper/internal/ssh"
+)
+
+func TestRewriteHomePaths(t *testing.T) {
+	config := "Include /home/olduser/.ssh/config.d/*\n" +
+		"# IdentityFile /home/olduser/.ssh/commented\n" +
+		"Host work\n" +
+		"    IdentityFile /home/olduser/.ssh/id_work\n" +
+		"    CertificateFile=\"/home/olduser/.ssh/id_work-cert.pub\"\n" +
+		"    ControlPath /home/olduser/.ssh/cm-%r@%h:%p # shared\n" +
+		"    UserKnownHostsFile /home/olduser/.ssh/known_hosts /etc/ssh/known_hosts /home/olduser/.ssh/known_hosts2\n" +
+		"    ProxyCommand /home/olduser/bin/proxy %h\n" +
+		"    IdentityFile /home/oldusers/.ssh/id_other\n" +
+		"Host legacy\n" +
+		"    IdentityFile ~/.ssh/id_legacy"
+
+	content, rewrites := RewriteHomePaths([]byte(config), []string{"/home/olduser"})
+
+	want := "Include ~/.ssh/config.d/*\n" +
+		"# IdentityFile /home/olduser/.ssh/commented\n" +
+		"Host work\n" +
+		"    IdentityFile ~/.ssh/id_work\n" +
+		"    CertificateFile=\"~/.ssh/id_work-cert.pub\"\n" +
+		"    ControlPath ~/.ssh/cm-%r@%h:%p # shared\n" +
+		"    UserKnownHostsFile ~/.ssh/known_hosts /etc/ssh/known_hosts ~/.ssh/known_hosts2\n" +
+		"    ProxyCommand /home/olduser/bin/proxy %h\n" +
+		"    IdentityFile /home/oldusers/.ssh/id_other\n" +
+		"Host legacy\n" +
+		"    IdentityFile ~/.ssh/id_legacy"
+	if string(content) != want {
+		t.Errorf("rewritten config =\n%s\nwant\n%s", content, want)
+	}
+
+	wantRewrites := []PathRewrite{
+		{Line: 1, Directive: "Include", From: "/home/olduser/.ssh/config.d/*", To: "~/.ssh/config.d/*"},
+		{Line: 4, Directive: "IdentityFile", From: "/home/olduser/.ssh/id_work", To: "~/.ssh/id_work"},
+		{Line: 5, Directive: "CertificateFile", From: "/home/olduser/.ssh/id_work-cert.pub", To: "~/.ssh/id_work-cert.pub"},
+		{Line: 6, Directive: "ControlPath", From: "/home/olduser/.ssh/cm-%r@%h:%p", To: "~/.ssh/cm-%r@%h:%p"},
+		{Line: 7, Directive: "UserKnownHostsFile", From: "/home/olduser/.ssh/known_hosts", To: "~/.ssh/known_hosts"},
+		{Line: 7, Directive: "UserKnownHostsFile", From: "/home/olduser/.ssh/known_hosts2", To: "~/.ssh/known_hosts2"},
+	}
+	if !reflect.DeepEqual(rewrites, wantRewrites) {
+		t.Errorf("rewrites = %+v, want %+v", rewrites, wantRewrites)
+	}
+
+	// Nothing to rewrite leaves the content untouched
+	again, rewrites := RewriteHomePaths(content, []string{"/home/olduser"})
+	if len(rewrites) != 0 || string(again) != want {
+		t.Errorf("second rewrite changed the config: %+v", rewrites)
+	}
+}
+
+func TestBackupHomes(t *testing.T) {
+	backup := &ssh.BackupData{
+		SSHDir:   "/srv/users/olduser/.ssh",
+		Username: "olduser",
+		Owner:    &ssh.Owner{User: "olduser"},
+	}
+	got := BackupHomes(backup, "/home/newuser")
+	want := []string{"/Users/olduser", "/home/olduser", "/srv/users/olduser"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BackupHomes() = %q, want %q", got, want)
+	}
+
+	// The restoring home is never rewritten
+	backup = &ssh.BackupData{SSHDir: "~/.ssh", Username: "alice"}
+	got = BackupHomes(backup, "/home/alice/")
+	if want := []string{"/Users/alice"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("BackupHomes() same user = %q, want %q", got, want)
+	}
+}
+
+func TestRewriteConfigHomes(t *testing.T) {
+	config := []byte("Host work\n    IdentityFile /Users/olduser/.ssh/id_work\n")
+	backup := &ssh.BackupData{
+		Files: map[string]*ssh.FileData{
+			"config": {
+				Filename: "config",
+				Content:  config,
+				Size:     int64(len(config)),
+				Checksum: ssh.Checksum(config),
+				KeyInfo:  &analyzer.KeyInfo{Type: analyzer.KeyTypeConfig, Format: analyzer.FormatConfig},
+			},
+			"notes.txt": {
+				Filename: "notes.txt",
+				Content:  []byte("IdentityFile /Users/olduser/.ssh/id_work\n"),
+				KeyInfo:  &analyzer.KeyInfo{Type: analyzer.KeyTypeUnknown},
+			},
+		},
+	}
+
+	rewrites := RewriteConfigHomes(backup, []string{"/Users/olduser"})
+	if len(rewrites) != 1 || rewrites[0].File != "config" || rewrites[0].Line != 2 {
+		t.Fatalf("rewrites = %+v, want one in config line 2", rewrites)
+	}
+
+	fileData := backup.Files["config"]
+	want := "Host work\n    IdentityFile ~/.ssh/id_work\n"
+	if string(fileData.Content) != want {
+		t.Errorf("config = %q, want %q", fileData.Content, want)
+	}
+	if fileData.Size != int64(len(want)) || fileData.Checksum != ssh.Checksum([]byte(want)) {
+		t.Errorf("size and checksum not updated: %d %s", fileData.Size, fileData.Checksum)
+	}
+	if string(backup.Files["notes.txt"].Content) != "IdentityFile /Users/olduser/.ssh/id_work\n" {
+		t.Error("non-config file was rewritten")
+	}
+}
@@ -0,0 +1,208 @@
+package filter
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// IgnoreFileName is the per-directory ignore file read from the SSH directory
+const IgnoreFileName = ".sshskignore"
+
+// Rule is a single gitignore-style pattern
+type Rule struct {
+	Pattern  string // Pattern without the leading '!', leading '/' or trailing '/'
+	Negate   bool   // '!pattern' re-includes a previously excluded path
+	DirOnly  bool   // 'pattern/' only matches directories
+	Anchored bool   // Pattern contains a '/' and is matched from the root
+	Source   string // Where the rule came from, e.g. "exclude_patterns" or ".sshskignore:3"
+	text     string
+}
+
+// String returns the rule as written together with its source
+func (r Rule) String() string {
+	return fmt.Sprintf("%s (%s)", r.text, r.Source)
+}
+
+// Result is the outcome of matching a path against a Matcher
+type Result struct {
+	Excluded bool
+	Rule     *Rule // Last rule that matched, nil when no rule matched
+}
+
+// Matcher evaluates paths against an ordered list of gitignore-style rules.
+// As in gitignore the last matching rule wins, negated rules re-include paths,
+// and a path inside an excluded directory cannot be re-included.
+type Matcher struct {
+	rules []Rule
+}
+
+// New creates an empty matcher that excludes nothing
+func New() *Matcher {
+	return &Matcher{}
+}
+
+// ParseRule parses one line of an ignore file. Blank lines and comments yield false.
+func ParseRule(line, source string) (Rule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return Rule{}, false
+	}
+
+	rule := Rule{Source: source, text: line}
+
+	if strings.HasPrefix(line, "!") {
+		rule.Negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		rule.DirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	if strings.Contains(line, "/") {
+		rule.Anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+
+	if line == "" {
+		return Rule{}, false
+	}
+
+	rule.Pattern = line
+	return rule, true
+}
+
+// AddExcludes appends exclude patterns; patterns starting with '!' re-include
+func (m *Matcher) AddExcludes(patterns []string, source string) {
+	for _, pattern := range patterns {
+		if rule, ok := ParseRule(pattern, source); ok {
+			m.rules = append(m.rules, rule)
+		}
+	}
+}
+
+// AddIncludes appends include patterns, which re-include paths excluded by earlier rules
+func (m *Matcher) AddIncludes(patterns []string, source string) {
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "!") {
+			pattern = pattern[1:]
+		}
+		if rule, ok := ParseRule("!"+pattern, source); ok {
+			rule.text = pattern
+			m.rules = append(m.rules, rule)
+		}
+	}
+}
+
+// LoadIgnoreFile appends the rules of an ignore file. A missing file is not an error.
+func (m *Matcher) LoadIgnoreFile(filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to open ignore file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		source := fmt.Sprintf("%s:%d", path.Base(filePath), lineNumber)
+		if rule, ok := ParseRule(scanner.Text(), source); ok {
+			m.rules = append(m.rules, rule)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read ignore file: %w", err)
+	}
+	return nil
+}
+
+// Rules returns the rules in evaluation order
+func (m *Matcher) Rules() []Rule {
+	return m.rules
+}
+
+// Match reports whether relPath (slash-separated, relative to the backup root)
+// is excluded and which rule decided it
+func (m *Matcher) Match(relPath string, isDir bool) Result {
+	if m == nil || len(m.rules) == 0 {
+		return Result{}
+	}
+
+	relPath = strings.Trim(path.Clean("/"+relPath), "/")
+
+	// A path inside an excluded directory stays excluded
+	segments := strings.Split(relPath, "/")
+	for i := 1; i < len(segments); i++ {
+		if parent := m.matchSelf(strings.Join(segments[:i], "/"), true); parent.Excluded {
+			return parent
+		}
+	}
+
+	return m.matchSelf(relPath, isDir)
+}
+
+// matchSelf applies the rules to a single path without looking at its parents
+func (m *Matcher) matchSelf(relPath string, isDir bool) Result {
+	var result Result
+
+	for i := range m.rules {
+		rule := &m.rules[i]
+		if rule.DirOnly && !isDir {
+			continue
+		}
+		if !rule.matches(relPath) {
+			continue
+		}
+		result = Result{Excluded: !rule.Negate, Rule: rule}
+	}
+
+	return result
+}
+
+// matches reports whether the rule's pattern matches relPath
+func (r *Rule) matches(relPath string) bool {
+	if !r.Anchored {
+		matched, _ := path.Match(r.Pattern, path.Base(relPath))
+		return matched
+	}
+	return matchSegments(strings.Split(r.Pattern, "/"), strings.Split(relPath, "/"))
+}
+
+// matchSegments matches path segments against pattern segments, where "**"
+// matches zero or more segments
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(segments) == 0 {
+			return false
+		}
+		if matched, _ := path.Match(pattern[0], segments[0]); !matched {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+
+	return len(segments) == 0
+}
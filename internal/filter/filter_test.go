@@ -0,0 +1,122 @@
+package filter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		line     string
+		ok       bool
+		pattern  string
+		negate   bool
+		dirOnly  bool
+		anchored bool
+	}{
+		{"", false, "", false, false, false},
+		{"# comment", false, "", false, false, false},
+		{"*.tmp", true, "*.tmp", false, false, false},
+		{"!id_rsa.old", true, "id_rsa.old", true, false, false},
+		{"sockets/", true, "sockets", false, true, false},
+		{"/config", true, "config", false, false, true},
+		{"keys/**/*.pem", true, "keys/**/*.pem", false, false, true},
+		{"\\#hash", true, "#hash", false, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			rule, ok := ParseRule(tt.line, "test")
+			if ok != tt.ok {
+				t.Fatalf("ParseRule(%q) ok = %v, want %v", tt.line, ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if rule.Pattern != tt.pattern || rule.Negate != tt.negate || rule.DirOnly != tt.dirOnly || rule.Anchored != tt.anchored {
+				t.Errorf("ParseRule(%q) = %+v", tt.line, rule)
+			}
+		})
+	}
+}
+
+func TestMatcher_Match(t *testing.T) {
+	matcher := New()
+	matcher.AddExcludes([]string{"*.tmp", "*.bak", "sockets/", "/known_hosts.old"}, "exclude_patterns")
+	matcher.AddIncludes([]string{"id_rsa*"}, "include_patterns")
+	matcher.AddExcludes([]string{"keys/**/*.pem"}, "--exclude")
+
+	tests := []struct {
+		path     string
+		isDir    bool
+		excluded bool
+		rule     string
+	}{
+		{"id_ed25519", false, false, ""},
+		{"scratch.tmp", false, true, "*.tmp (exclude_patterns)"},
+		{"config.d/old.bak", false, true, "*.bak (exclude_patterns)"},
+		{"id_rsa.bak", false, false, "id_rsa* (include_patterns)"},
+		{"sockets", true, true, "sockets/ (exclude_patterns)"},
+		{"sockets", false, false, ""},
+		{"sockets/id_rsa-control", false, true, "sockets/ (exclude_patterns)"},
+		{"known_hosts.old", false, true, "/known_hosts.old (exclude_patterns)"},
+		{"backup/known_hosts.old", false, false, ""},
+		{"keys/pem", false, false, ""},
+		{"keys/client/a.pem", false, true, "keys/**/*.pem (--exclude)"},
+		{"keys/a.pem", false, true, "keys/**/*.pem (--exclude)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result := matcher.Match(tt.path, tt.isDir)
+			if result.Excluded != tt.excluded {
+				t.Errorf("Match(%q).Excluded = %v, want %v", tt.path, result.Excluded, tt.excluded)
+			}
+
+			rule := ""
+			if result.Rule != nil {
+				rule = result.Rule.String()
+			}
+			if rule != tt.rule {
+				t.Errorf("Match(%q).Rule = %q, want %q", tt.path, rule, tt.rule)
+			}
+		})
+	}
+}
+
+func TestMatcher_NilAndEmpty(t *testing.T) {
+	var matcher *Matcher
+	if matcher.Match("id_rsa", false).Excluded {
+		t.Error("nil matcher must not exclude anything")
+	}
+	if New().Match("id_rsa", false).Excluded {
+		t.Error("empty matcher must not exclude anything")
+	}
+}
+
+func TestMatcher_LoadIgnoreFile(t *testing.T) {
+	dir := t.TempDir()
+	ignorePath := filepath.Join(dir, IgnoreFileName)
+	content := "# local scratch files\n*.swp\n\n!keep.swp\n"
+	if err := os.WriteFile(ignorePath, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write ignore file: %v", err)
+	}
+
+	matcher := New()
+	if err := matcher.LoadIgnoreFile(ignorePath); err != nil {
+		t.Fatalf("LoadIgnoreFile() error = %v", err)
+	}
+
+	result := matcher.Match("notes.swp", false)
+	if !result.Excluded || result.Rule.String() != "*.swp (.sshskignore:2)" {
+		t.Errorf("Match(notes.swp) = %+v", result)
+	}
+	if matcher.Match("keep.swp", false).Excluded {
+		t.Error("keep.swp should be re-included by the negated rule")
+	}
+
+	if err := New().LoadIgnoreFile(filepath.Join(dir, "missing")); err != nil {
+		t.Errorf("missing ignore file should not be an error, got %v", err)
+	}
+}
@@ -0,0 +1,206 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/user"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/rzago/ssh-secret-keeper/internal/config"
+)
+
+// DefaultTimeout applies to each hook command when hooks.timeout is not set
+const DefaultTimeout = 60 * time.Second
+
+// Operation is the sshsk operation hooks run around
+type Operation string
+
+const (
+	OperationBackup  Operation = "backup"
+	OperationRestore Operation = "restore"
+	OperationDelete  Operation = "delete"
+)
+
+// Results reported in events
+const (
+	ResultSuccess   = "success"
+	ResultFailure   = "failure"
+	ResultUnchanged = "unchanged" // Backup skipped because nothing changed
+)
+
+// OnFailure is the hook run when an operation fails
+const OnFailure = "on_failure"
+
+// Event is written as JSON to each hook's stdin
+type Event struct {
+	Hook      string    `json:"hook"`
+	Operation Operation `json:"operation"`
+	Backup    string    `json:"backup,omitempty"`
+	Backups   []string  `json:"backups,omitempty"` // Set when an operation covers several backups
+	Hostname  string    `json:"hostname"`
+	Username  string    `json:"username"`
+	Directory string    `json:"directory,omitempty"` // SSH directory backed up or restore target
+	Files     []string  `json:"files,omitempty"`
+	Result    string    `json:"result,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+
+	started bool // The pre hook ran and the operation went ahead
+}
+
+// NewEvent creates an event for an operation on backup, which may be empty if not yet known
+func NewEvent(op Operation, backup string) *Event {
+	event := &Event{
+		Operation: op,
+		Backup:    backup,
+	}
+	event.Hostname, _ = os.Hostname()
+	if current, err := user.Current(); err == nil {
+		event.Username = current.Username
+	}
+	return event
+}
+
+// Runner runs the configured hook commands. A nil Runner runs nothing, which
+// is how dry runs disable hooks.
+type Runner struct {
+	hooks   map[string][]string
+	timeout time.Duration
+	shell   string
+	stdout  io.Writer
+	stderr  io.Writer
+}
+
+// New creates a runner for the hooks configuration
+func New(cfg config.HooksConfig) *Runner {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	return &Runner{
+		hooks: map[string][]string{
+			"pre_backup":   cfg.PreBackup,
+			"post_backup":  cfg.PostBackup,
+			"pre_restore":  cfg.PreRestore,
+			"post_restore": cfg.PostRestore,
+			"pre_delete":   cfg.PreDelete,
+			"post_delete":  cfg.PostDelete,
+			OnFailure:      cfg.OnFailure,
+		},
+		timeout: timeout,
+		shell:   "/bin/sh",
+		stdout:  os.Stdout,
+		stderr:  os.Stderr,
+	}
+}
+
+// SetOutput redirects the output of hook commands
+func (r *Runner) SetOutput(stdout, stderr io.Writer) {
+	r.stdout = stdout
+	r.stderr = stderr
+}
+
+// Before runs the pre hook of the event's operation. An error means the
+// operation must be aborted.
+func (r *Runner) Before(ctx context.Context, event *Event) error {
+	if r == nil {
+		return nil
+	}
+
+	hook := "pre_" + string(event.Operation)
+	if err := r.Run(ctx, hook, event); err != nil {
+		return fmt.Errorf("%s hook failed, %s aborted: %w", hook, event.Operation, err)
+	}
+	event.started = true
+	return nil
+}
+
+// After reports the outcome of an operation. On failure the on_failure hooks
+// run; otherwise the post hook runs if the operation went past its pre hook.
+// Hook failures are logged and do not change the outcome. A result already set
+// on the event (such as ResultUnchanged) is kept.
+func (r *Runner) After(ctx context.Context, event *Event, opErr error) {
+	if r == nil {
+		return
+	}
+
+	hook := "post_" + string(event.Operation)
+	if opErr != nil {
+		hook = OnFailure
+		event.Result = ResultFailure
+		event.Error = opErr.Error()
+	} else if !event.started {
+		return
+	} else if event.Result == "" {
+		event.Result = ResultSuccess
+	}
+
+	if err := r.Run(ctx, hook, event); err != nil {
+		log.Warn().Err(err).Str("hook", hook).Msg("Hook failed")
+		fmt.Printf("⚠️  %s hook failed: %v\n", hook, err)
+	}
+}
+
+// Run runs every command of a hook with the event on stdin and stops at the
+// first failure
+func (r *Runner) Run(ctx context.Context, hook string, event *Event) error {
+	if r == nil {
+		return nil
+	}
+
+	commands := r.hooks[hook]
+	if len(commands) == 0 {
+		return nil
+	}
+
+	event.Hook = hook
+	event.Timestamp = time.Now().UTC()
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode hook event: %w", err)
+	}
+
+	for _, command := range commands {
+		if err := r.runCommand(ctx, hook, command, event, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Runner) runCommand(ctx context.Context, hook, command string, event *Event, payload []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	log.Debug().Str("hook", hook).Str("command", command).Msg("Running hook")
+
+	cmd := exec.CommandContext(ctx, r.shell, "-c", command)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = r.stdout
+	cmd.Stderr = r.stderr
+	cmd.Env = append(os.Environ(),
+		"SSHSK_HOOK="+hook,
+		"SSHSK_OPERATION="+string(event.Operation),
+		"SSHSK_BACKUP_NAME="+event.Backup,
+		"SSHSK_RESULT="+event.Result,
+	)
+	// Do not wait for background children holding the output open
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%q timed out after %s", command, r.timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%q: %w", command, err)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rzago/ssh-secret-keeper/internal/config"
+)
+
+func newTestRunner(cfg config.HooksConfig) (*Runner, *bytes.Buffer) {
+	runner := New(cfg)
+	var output bytes.Buffer
+	runner.SetOutput(&output, &output)
+	return runner, &output
+}
+
+func TestRunner_EventOnStdin(t *testing.T) {
+	eventFile := filepath.Join(t.TempDir(), "event.json")
+	runner, _ := newTestRunner(config.HooksConfig{
+		PostBackup: []string{"cat > " + eventFile},
+	})
+
+	ctx := context.Background()
+	event := NewEvent(OperationBackup, "nightly")
+	event.Files = []string{"config", "id_ed25519"}
+
+	if err := runner.Before(ctx, event); err != nil {
+		t.Fatalf("Before() error = %v", err)
+	}
+	runner.After(ctx, event, nil)
+
+	data, err := os.ReadFile(eventFile)
+	if err != nil {
+		t.Fatalf("post_backup hook did not run: %v", err)
+	}
+
+	var got Event
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("hook received invalid JSON %q: %v", data, err)
+	}
+	if got.Hook != "post_backup" || got.Operation != OperationBackup || got.Backup != "nightly" {
+		t.Errorf("unexpected event: %+v", got)
+	}
+	if got.Result != ResultSuccess || len(got.Files) != 2 {
+		t.Errorf("unexpected result or files: %+v", got)
+	}
+}
+
+func TestRunner_FailingPreHookAborts(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "failed")
+	runner, _ := newTestRunner(config.HooksConfig{
+		PreRestore:  []string{"exit 3", "touch " + marker + ".second"},
+		PostRestore: []string{"touch " + marker + ".post"},
+		OnFailure:   []string{"grep -q pre_restore && touch " + marker},
+	})
+
+	ctx := context.Background()
+	event := NewEvent(OperationRestore, "latest")
+
+	err := runner.Before(ctx, event)
+	if err == nil {
+		t.Fatal("Before() should fail when a pre hook fails")
+	}
+	if !strings.Contains(err.Error(), "pre_restore hook failed") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	runner.After(ctx, event, err)
+
+	if _, err := os.Stat(marker + ".second"); err == nil {
+		t.Error("commands after a failing pre hook command should not run")
+	}
+	if _, err := os.Stat(marker + ".post"); err == nil {
+		t.Error("post hook should not run after a failure")
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Error("on_failure hook did not run with the failure event")
+	}
+}
+
+func TestRunner_AfterWithoutStart(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "post")
+	runner, _ := newTestRunner(config.HooksConfig{
+		PostDelete: []string{"touch " + marker},
+	})
+
+	// An operation that ended before its pre hook (e.g. cancelled) has no post hook
+	runner.After(context.Background(), NewEvent(OperationDelete, "old"), nil)
+
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("post hook ran for an operation that never started")
+	}
+}
+
+func TestRunner_Timeout(t *testing.T) {
+	runner, _ := newTestRunner(config.HooksConfig{
+		PreBackup: []string{"sleep 5"},
+		Timeout:   100 * time.Millisecond,
+	})
+
+	start := time.Now()
+	err := runner.Before(context.Background(), NewEvent(OperationBackup, ""))
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Before() error = %v, want timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("timeout took %s", elapsed)
+	}
+}
+
+func TestRunner_Environment(t *testing.T) {
+	runner, output := newTestRunner(config.HooksConfig{
+		OnFailure: []string{`echo "$SSHSK_HOOK $SSHSK_OPERATION $SSHSK_BACKUP_NAME $SSHSK_RESULT"`},
+	})
+
+	runner.After(context.Background(), NewEvent(OperationBackup, "b1"), errors.New("vault unreachable"))
+
+	if got := strings.TrimSpace(output.String()); got != "on_failure backup b1 failure" {
+		t.Errorf("hook environment = %q", got)
+	}
+}
+
+func TestRunner_Nil(t *testing.T) {
+	var runner *Runner
+	event := NewEvent(OperationBackup, "")
+
+	if err := runner.Before(context.Background(), event); err != nil {
+		t.Errorf("nil runner Before() error = %v", err)
+	}
+	runner.After(context.Background(), event, errors.New("ignored"))
+}
@@ -2,8 +2,13 @@ package interfaces
 
 import (
 	"context"
+	"errors"
 )
 
+// ErrBackupNotFound is returned by GetBackup for backups that do not exist,
+// including listed names whose data was deleted
+var ErrBackupNotFound = errors.New("backup not found")
+
 // StorageProvider defines the interface for secret storage backends
 type StorageProvider interface {
 	// Connection management
@@ -16,9 +21,18 @@ type StorageProvider interface {
 	ListBackups(ctx context.Context) ([]string, error)
 	DeleteBackup(ctx context.Context, backupName string) error
 
+	// Blob operations for content-addressed file storage shared between backups
+	StoreBlob(ctx context.Context, hash string, data map[string]interface{}) error
+	GetBlob(ctx context.Context, hash string) (map[string]interface{}, error)
+	ListBlobs(ctx context.Context) ([]string, error)
+	DeleteBlob(ctx context.Context, hash string) error
+
 	// Metadata operations
 	StoreMetadata(ctx context.Context, metadata map[string]interface{}) error
 	GetMetadata(ctx context.Context) (map[string]interface{}, error)
+	// UpdateMetadata atomically applies update to the metadata document,
+	// retrying on concurrent modification
+	UpdateMetadata(ctx context.Context, update func(metadata map[string]interface{}) error) error
 
 	// Provider info
 	GetProviderType() string
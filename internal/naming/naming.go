@@ -0,0 +1,153 @@
+package naming
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/rzago/ssh-secret-keeper/internal/utils"
+)
+
+// DefaultTemplate produces names such as backup-20250102-150405
+const DefaultTemplate = "backup-{{.Date}}-{{.Time}}"
+
+// maxSeq bounds the search for a free sequence number
+const maxSeq = 9999
+
+// Vars are the values available to a name template
+type Vars struct {
+	Hostname string
+	Username string
+	Now      time.Time
+}
+
+// templateData is what templates are executed against. Hostname and Username
+// are sanitized so they cannot introduce path separators into the name.
+type templateData struct {
+	Hostname  string
+	Username  string
+	Date      string // 20060102
+	Time      string // 150405
+	Timestamp string // 20060102-150405
+	Seq       int    // 1-based, incremented until the name is free
+}
+
+// Template renders backup names
+type Template struct {
+	source       string
+	tmpl         *template.Template
+	usesSeq      bool
+	usesUsername bool
+}
+
+// Parse parses a name template such as "{{.Hostname}}-{{.Date}}-{{.Seq}}".
+// An empty string selects DefaultTemplate.
+func Parse(source string) (*Template, error) {
+	if strings.TrimSpace(source) == "" {
+		source = DefaultTemplate
+	}
+
+	tmpl, err := template.New("name").Option("missingkey=error").Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid name template %q: %w", source, err)
+	}
+
+	t := &Template{source: source, tmpl: tmpl}
+
+	// Catch unknown fields and invalid literals now rather than at backup time
+	sample := Vars{Hostname: "host", Username: "user", Now: time.Date(2000, 1, 2, 3, 4, 5, 0, time.UTC)}
+	first, err := t.render(sample, 1)
+	if err != nil {
+		return nil, err
+	}
+	second, err := t.render(sample, 2)
+	if err != nil {
+		return nil, err
+	}
+	t.usesSeq = first != second
+
+	other := sample
+	other.Username = "other"
+	third, err := t.render(other, 1)
+	if err != nil {
+		return nil, err
+	}
+	t.usesUsername = first != third
+
+	return t, nil
+}
+
+// String returns the template source
+func (t *Template) String() string {
+	return t.source
+}
+
+// UsesUsername reports whether the template references {{.Username}}, which
+// keeps names of different users apart in shared storage
+func (t *Template) UsesUsername() bool {
+	return t.usesUsername
+}
+
+// Render renders the name for a sequence number
+func (t *Template) Render(vars Vars, seq int) (string, error) {
+	return t.render(vars, seq)
+}
+
+// Generate renders a name that is not taken. Templates using {{.Seq}} get the
+// lowest free sequence number; other templates are rendered once and the
+// caller decides what to do about a collision.
+func (t *Template) Generate(vars Vars, taken func(name string) bool) (string, error) {
+	if !t.usesSeq {
+		return t.render(vars, 1)
+	}
+
+	for seq := 1; seq <= maxSeq; seq++ {
+		name, err := t.render(vars, seq)
+		if err != nil {
+			return "", err
+		}
+		if !taken(name) {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("no free backup name for template %q after %d attempts", t.source, maxSeq)
+}
+
+func (t *Template) render(vars Vars, seq int) (string, error) {
+	data := templateData{
+		Hostname:  utils.SanitizePathComponent(vars.Hostname),
+		Username:  utils.SanitizePathComponent(vars.Username),
+		Date:      vars.Now.Format("20060102"),
+		Time:      vars.Now.Format("150405"),
+		Timestamp: vars.Now.Format("20060102-150405"),
+		Seq:       seq,
+	}
+
+	var b strings.Builder
+	if err := t.tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render name template %q: %w", t.source, err)
+	}
+
+	name := b.String()
+	if err := ValidateName(name); err != nil {
+		return "", fmt.Errorf("name template %q: %w", t.source, err)
+	}
+	return name, nil
+}
+
+// ValidateName checks that a backup name is usable as a single storage path component
+func ValidateName(name string) error {
+	switch {
+	case strings.TrimSpace(name) == "":
+		return fmt.Errorf("backup name cannot be empty")
+	case name == "." || name == "..":
+		return fmt.Errorf("invalid backup name %q", name)
+	case strings.ContainsAny(name, `/\`):
+		return fmt.Errorf("backup name %q cannot contain path separators", name)
+	case strings.IndexFunc(name, unicode.IsControl) >= 0:
+		return fmt.Errorf("backup name %q cannot contain control characters", name)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package naming
+
+import (
+	"testing"
+	"time"
+)
+
+var testVars = Vars{
+	Hostname: "web01.example.com",
+	Username: "alice",
+	Now:      time.Date(2026, 10, 18, 9, 5, 3, 0, time.UTC),
+}
+
+func TestParse_DefaultTemplate(t *testing.T) {
+	tmpl, err := Parse("")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	name, err := tmpl.Generate(testVars, func(string) bool { return false })
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if name != "backup-20261018-090503" {
+		t.Errorf("Generate() = %q", name)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, source := range []string{
+		"{{.Hostname",       // syntax error
+		"{{.Unknown}}",      // unknown field
+		"backups/{{.Date}}", // path separator
+	} {
+		if _, err := Parse(source); err == nil {
+			t.Errorf("Parse(%q) should fail", source)
+		}
+	}
+}
+
+func TestTemplate_SanitizesVariables(t *testing.T) {
+	tmpl, err := Parse("{{.Hostname}}-{{.Username}}")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	name, err := tmpl.Render(Vars{Hostname: "evil/../host", Username: "a b", Now: testVars.Now}, 1)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if name != "evil_.._host-a_b" {
+		t.Errorf("Render() = %q", name)
+	}
+}
+
+func TestTemplate_GenerateSeq(t *testing.T) {
+	tmpl, err := Parse("{{.Hostname}}-{{.Date}}-{{.Seq}}")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if tmpl.UsesUsername() {
+		t.Error("UsesUsername() = true for a template without {{.Username}}")
+	}
+
+	taken := map[string]bool{
+		"web01.example.com-20261018-1": true,
+		"web01.example.com-20261018-2": true,
+	}
+	name, err := tmpl.Generate(testVars, func(name string) bool { return taken[name] })
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if name != "web01.example.com-20261018-3" {
+		t.Errorf("Generate() = %q", name)
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	valid := []string{"backup-1", "my.backup", "host_user-2026"}
+	invalid := []string{"", " ", ".", "..", "a/b", `a\b`, "a\nb"}
+
+	for _, name := range valid {
+		if err := ValidateName(name); err != nil {
+			t.Errorf("ValidateName(%q) error = %v", name, err)
+		}
+	}
+	for _, name := range invalid {
+		if err := ValidateName(name); err == nil {
+			t.Errorf("ValidateName(%q) should fail", name)
+		}
+	}
+}
+
+func TestTemplate_UsesUsername(t *testing.T) {
+	tmpl, err := Parse("{{.Username}}-{{.Date}}-{{.Time}}")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if !tmpl.UsesUsername() {
+		t.Error("UsesUsername() = false")
+	}
+}
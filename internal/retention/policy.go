@@ -0,0 +1,171 @@
+package retention
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Policy describes which backups to keep. Each rule keeps the newest backup of
+// its period (grandfather-father-son); a backup kept by any rule is retained.
+type Policy struct {
+	KeepLast    int
+	KeepDaily   int
+	KeepWeekly  int
+	KeepMonthly int
+	KeepYearly  int
+}
+
+// Backup is the information retention needs about a stored backup
+type Backup struct {
+	Name      string
+	Timestamp time.Time
+	Hostname  string
+}
+
+// Decision records whether a backup is kept and why
+type Decision struct {
+	Backup  Backup
+	Keep    bool
+	Reasons []string
+}
+
+// IsEmpty reports whether the policy has no rules, in which case nothing may be pruned
+func (p Policy) IsEmpty() bool {
+	return p.KeepLast <= 0 && p.KeepDaily <= 0 && p.KeepWeekly <= 0 && p.KeepMonthly <= 0 && p.KeepYearly <= 0
+}
+
+// Validate checks that no rule is negative
+func (p Policy) Validate() error {
+	rules := map[string]int{
+		"keep-last":    p.KeepLast,
+		"keep-daily":   p.KeepDaily,
+		"keep-weekly":  p.KeepWeekly,
+		"keep-monthly": p.KeepMonthly,
+		"keep-yearly":  p.KeepYearly,
+	}
+	for name, value := range rules {
+		if value < 0 {
+			return fmt.Errorf("%s cannot be negative: %d", name, value)
+		}
+	}
+	return nil
+}
+
+// String returns a short human-readable description of the policy
+func (p Policy) String() string {
+	var parts []string
+	add := func(label string, value int) {
+		if value > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d", label, value))
+		}
+	}
+	add("last", p.KeepLast)
+	add("daily", p.KeepDaily)
+	add("weekly", p.KeepWeekly)
+	add("monthly", p.KeepMonthly)
+	add("yearly", p.KeepYearly)
+
+	if len(parts) == 0 {
+		return "keep everything"
+	}
+	return "keep " + strings.Join(parts, ", ")
+}
+
+// Apply decides for every backup whether it is kept. Backups are grouped by
+// hostname and each group is evaluated independently, newest first.
+// Backups without a timestamp are always kept, as is everything when the policy is empty.
+func (p Policy) Apply(backups []Backup) []Decision {
+	groups := make(map[string][]Backup)
+	for _, backup := range backups {
+		groups[backup.Hostname] = append(groups[backup.Hostname], backup)
+	}
+
+	hostnames := make([]string, 0, len(groups))
+	for hostname := range groups {
+		hostnames = append(hostnames, hostname)
+	}
+	sort.Strings(hostnames)
+
+	var decisions []Decision
+	for _, hostname := range hostnames {
+		decisions = append(decisions, p.applyToGroup(groups[hostname])...)
+	}
+	return decisions
+}
+
+// applyToGroup evaluates the policy for backups of a single hostname
+func (p Policy) applyToGroup(backups []Backup) []Decision {
+	sorted := make([]Backup, len(backups))
+	copy(sorted, backups)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Timestamp.Equal(sorted[j].Timestamp) {
+			return sorted[i].Name > sorted[j].Name
+		}
+		return sorted[i].Timestamp.After(sorted[j].Timestamp)
+	})
+
+	rules := []struct {
+		label  string
+		limit  int
+		bucket func(t time.Time) string
+	}{
+		{"daily", p.KeepDaily, func(t time.Time) string { return t.Format("2006-01-02") }},
+		{"weekly", p.KeepWeekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{"monthly", p.KeepMonthly, func(t time.Time) string { return t.Format("2006-01") }},
+		{"yearly", p.KeepYearly, func(t time.Time) string { return t.Format("2006") }},
+	}
+
+	seen := make([]map[string]bool, len(rules))
+	for i := range rules {
+		seen[i] = make(map[string]bool)
+	}
+
+	decisions := make([]Decision, 0, len(sorted))
+	lastKept := 0
+
+	for _, backup := range sorted {
+		decision := Decision{Backup: backup}
+
+		if p.IsEmpty() || backup.Timestamp.IsZero() {
+			decision.Keep = true
+			if p.IsEmpty() {
+				decision.Reasons = append(decision.Reasons, "no retention rules configured")
+			} else {
+				decision.Reasons = append(decision.Reasons, "unknown timestamp")
+			}
+			decisions = append(decisions, decision)
+			continue
+		}
+
+		if lastKept < p.KeepLast {
+			lastKept++
+			decision.Reasons = append(decision.Reasons, fmt.Sprintf("last %d/%d", lastKept, p.KeepLast))
+		}
+
+		for i, rule := range rules {
+			if rule.limit <= 0 || len(seen[i]) >= rule.limit {
+				continue
+			}
+			bucket := rule.bucket(backup.Timestamp)
+			if seen[i][bucket] {
+				continue
+			}
+			seen[i][bucket] = true
+			decision.Reasons = append(decision.Reasons, fmt.Sprintf("%s %s", rule.label, bucket))
+		}
+
+		decision.Keep = len(decision.Reasons) > 0
+		if !decision.Keep {
+			decision.Reasons = append(decision.Reasons, "not selected by any retention rule")
+		}
+
+		decisions = append(decisions, decision)
+	}
+
+	return decisions
+}
@@ -0,0 +1,118 @@
+package retention
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func daysAgo(base time.Time, days int) time.Time {
+	return base.AddDate(0, 0, -days)
+}
+
+func keptNames(decisions []Decision) []string {
+	var names []string
+	for _, decision := range decisions {
+		if decision.Keep {
+			names = append(names, decision.Backup.Name)
+		}
+	}
+	return names
+}
+
+func TestPolicy_Apply_KeepLast(t *testing.T) {
+	base := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	backups := []Backup{
+		{Name: "b1", Timestamp: daysAgo(base, 3), Hostname: "laptop"},
+		{Name: "b2", Timestamp: daysAgo(base, 2), Hostname: "laptop"},
+		{Name: "b3", Timestamp: daysAgo(base, 1), Hostname: "laptop"},
+		{Name: "b4", Timestamp: base, Hostname: "laptop"},
+	}
+
+	decisions := Policy{KeepLast: 2}.Apply(backups)
+
+	if got := keptNames(decisions); !reflect.DeepEqual(got, []string{"b4", "b3"}) {
+		t.Errorf("kept = %v, want [b4 b3]", got)
+	}
+	if decisions[0].Reasons[0] != "last 1/2" {
+		t.Errorf("reason = %q, want %q", decisions[0].Reasons[0], "last 1/2")
+	}
+	if decisions[3].Keep || decisions[3].Reasons[0] != "not selected by any retention rule" {
+		t.Errorf("oldest backup decision = %+v, want delete with explanation", decisions[3])
+	}
+}
+
+func TestPolicy_Apply_GFS(t *testing.T) {
+	base := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	backups := []Backup{
+		{Name: "today-late", Timestamp: base.Add(2 * time.Hour), Hostname: "h"},
+		{Name: "today-early", Timestamp: base, Hostname: "h"},
+		{Name: "yesterday", Timestamp: daysAgo(base, 1), Hostname: "h"},
+		{Name: "last-month", Timestamp: base.AddDate(0, -1, 0), Hostname: "h"},
+		{Name: "last-year", Timestamp: base.AddDate(-1, 0, 0), Hostname: "h"},
+	}
+
+	tests := []struct {
+		name   string
+		policy Policy
+		want   []string
+	}{
+		{"daily keeps newest per day", Policy{KeepDaily: 2}, []string{"today-late", "yesterday"}},
+		{"monthly", Policy{KeepMonthly: 2}, []string{"today-late", "last-month"}},
+		{"yearly", Policy{KeepYearly: 5}, []string{"today-late", "last-year"}},
+		{"combined rules", Policy{KeepLast: 1, KeepDaily: 1, KeepMonthly: 3}, []string{"today-late", "last-month", "last-year"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := keptNames(tt.policy.Apply(backups)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("kept = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicy_Apply_PerHostname(t *testing.T) {
+	base := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	backups := []Backup{
+		{Name: "laptop-old", Timestamp: daysAgo(base, 5), Hostname: "laptop"},
+		{Name: "laptop-new", Timestamp: base, Hostname: "laptop"},
+		{Name: "server-old", Timestamp: daysAgo(base, 30), Hostname: "server"},
+	}
+
+	got := keptNames(Policy{KeepLast: 1}.Apply(backups))
+	want := []string{"laptop-new", "server-old"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("kept = %v, want %v", got, want)
+	}
+}
+
+func TestPolicy_Apply_SafeDefaults(t *testing.T) {
+	backups := []Backup{
+		{Name: "a", Timestamp: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{Name: "no-timestamp"},
+	}
+
+	if got := keptNames(Policy{}.Apply(backups)); len(got) != 2 {
+		t.Errorf("empty policy kept %v, want everything", got)
+	}
+
+	decisions := Policy{KeepLast: 1}.Apply([]Backup{{Name: "no-timestamp"}})
+	if !decisions[0].Keep || decisions[0].Reasons[0] != "unknown timestamp" {
+		t.Errorf("decision = %+v, want kept for unknown timestamp", decisions[0])
+	}
+}
+
+func TestPolicy_ValidateAndString(t *testing.T) {
+	if err := (Policy{KeepDaily: -1}).Validate(); err == nil {
+		t.Error("expected error for negative rule")
+	}
+
+	policy := Policy{KeepLast: 3, KeepWeekly: 4}
+	if err := policy.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	if got := policy.String(); got != "keep last 3, weekly 4" {
+		t.Errorf("String() = %q", got)
+	}
+}
@@ -0,0 +1,249 @@
+package schedule
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Backend is the scheduler a job is installed with
+type Backend string
+
+const (
+	BackendSystemd Backend = "systemd"
+	BackendCron    Backend = "cron"
+)
+
+// Runner executes an external command with optional stdin and returns its stdout
+type Runner func(stdin string, name string, args ...string) (string, error)
+
+// Status describes the installed schedule
+type Status struct {
+	Systemd     bool   // Unit files are installed
+	TimerState  string // Output of 'systemctl --user is-active' for the timer
+	NextRun     string // Output of 'systemctl --user list-timers' for the timer
+	Cron        bool   // A managed crontab entry exists
+	CronLine    string
+	UnitDir     string
+	ServicePath string
+	TimerPath   string
+}
+
+// Installed reports whether any schedule is installed
+func (s *Status) Installed() bool {
+	return s.Systemd || s.Cron
+}
+
+// Installer installs, inspects and removes scheduled backups
+type Installer struct {
+	unitDir string
+	run     Runner
+}
+
+// NewInstaller creates an installer for the current user's systemd units and crontab
+func NewInstaller() (*Installer, error) {
+	unitDir, err := UserUnitDir()
+	if err != nil {
+		return nil, err
+	}
+	return &Installer{unitDir: unitDir, run: execRunner}, nil
+}
+
+// NewInstallerWithRunner creates an installer using unitDir and runner, for tests
+func NewInstallerWithRunner(unitDir string, runner Runner) *Installer {
+	return &Installer{unitDir: unitDir, run: runner}
+}
+
+// UserUnitDir returns the systemd user unit directory
+func UserUnitDir() (string, error) {
+	if configHome := os.Getenv("XDG_CONFIG_HOME"); configHome != "" {
+		return filepath.Join(configHome, "systemd", "user"), nil
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".config", "systemd", "user"), nil
+}
+
+// UnitDir returns the directory the systemd units are written to
+func (i *Installer) UnitDir() string {
+	return i.unitDir
+}
+
+// SystemdAvailable reports whether a systemd user manager is reachable
+func (i *Installer) SystemdAvailable() bool {
+	_, err := i.run("", "systemctl", "--user", "show-environment")
+	return err == nil
+}
+
+// DetectBackend prefers systemd user timers and falls back to cron
+func (i *Installer) DetectBackend() Backend {
+	if i.SystemdAvailable() {
+		return BackendSystemd
+	}
+	return BackendCron
+}
+
+// Install installs job with backend, replacing an existing schedule of the same backend
+func (i *Installer) Install(job Job, backend Backend) error {
+	if err := job.Validate(); err != nil {
+		return err
+	}
+
+	switch backend {
+	case BackendSystemd:
+		return i.installSystemd(job)
+	case BackendCron:
+		return i.installCron(job)
+	default:
+		return fmt.Errorf("unknown scheduler backend %q (use systemd or cron)", backend)
+	}
+}
+
+// Status reports what is installed
+func (i *Installer) Status() (*Status, error) {
+	status := &Status{
+		UnitDir:     i.unitDir,
+		ServicePath: i.unitPath("service"),
+		TimerPath:   i.unitPath("timer"),
+	}
+
+	if _, err := os.Stat(status.ServicePath); err == nil {
+		status.Systemd = true
+
+		state, _ := i.run("", "systemctl", "--user", "is-active", UnitName+".timer")
+		status.TimerState = strings.TrimSpace(state)
+
+		next, err := i.run("", "systemctl", "--user", "list-timers", UnitName+".timer", "--no-pager")
+		if err == nil {
+			status.NextRun = strings.TrimSpace(next)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to check %s: %w", status.ServicePath, err)
+	}
+
+	crontab, err := i.readCrontab()
+	if err != nil {
+		return nil, err
+	}
+	status.CronLine, status.Cron = FindInCrontab(crontab)
+
+	return status, nil
+}
+
+// Remove removes every installed schedule and returns what was removed
+func (i *Installer) Remove() ([]string, error) {
+	var removed []string
+
+	if _, err := os.Stat(i.unitPath("timer")); err == nil {
+		// The timer may already be stopped; removal continues either way
+		i.run("", "systemctl", "--user", "disable", "--now", UnitName+".timer")
+	}
+
+	for _, kind := range []string{"timer", "service"} {
+		path := i.unitPath(kind)
+		if err := os.Remove(path); err == nil {
+			removed = append(removed, path)
+		} else if !os.IsNotExist(err) {
+			return removed, fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+	if len(removed) > 0 {
+		i.run("", "systemctl", "--user", "daemon-reload")
+	}
+
+	crontab, err := i.readCrontab()
+	if err != nil {
+		return removed, err
+	}
+	if updated, found := RemoveFromCrontab(crontab); found {
+		if _, err := i.run(updated, "crontab", "-"); err != nil {
+			return removed, fmt.Errorf("failed to update crontab: %w", err)
+		}
+		removed = append(removed, "crontab entry")
+	}
+
+	return removed, nil
+}
+
+func (i *Installer) installSystemd(job Job) error {
+	if err := os.MkdirAll(i.unitDir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", i.unitDir, err)
+	}
+
+	// The service may carry Vault settings, so keep it private to the user
+	if err := os.WriteFile(i.unitPath("service"), []byte(job.ServiceUnit()), 0600); err != nil {
+		return fmt.Errorf("failed to write service unit: %w", err)
+	}
+	if err := os.WriteFile(i.unitPath("timer"), []byte(job.TimerUnit()), 0644); err != nil {
+		return fmt.Errorf("failed to write timer unit: %w", err)
+	}
+
+	if _, err := i.run("", "systemctl", "--user", "daemon-reload"); err != nil {
+		return fmt.Errorf("systemctl daemon-reload failed: %w", err)
+	}
+	if _, err := i.run("", "systemctl", "--user", "enable", "--now", UnitName+".timer"); err != nil {
+		return fmt.Errorf("failed to enable %s.timer: %w", UnitName, err)
+	}
+	return nil
+}
+
+func (i *Installer) installCron(job Job) error {
+	line, err := job.CronLine()
+	if err != nil {
+		return err
+	}
+
+	crontab, err := i.readCrontab()
+	if err != nil {
+		return err
+	}
+
+	if _, err := i.run(AddToCrontab(crontab, line), "crontab", "-"); err != nil {
+		return fmt.Errorf("failed to install crontab: %w", err)
+	}
+	return nil
+}
+
+// readCrontab returns the user's crontab, or "" when there is none or cron is missing
+func (i *Installer) readCrontab() (string, error) {
+	crontab, err := i.run("", "crontab", "-l")
+	if err != nil {
+		// 'crontab -l' fails when the user has no crontab yet
+		if strings.Contains(err.Error(), "no crontab") || errors.Is(err, exec.ErrNotFound) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read crontab: %w", err)
+	}
+	return crontab, nil
+}
+
+func (i *Installer) unitPath(kind string) string {
+	return filepath.Join(i.unitDir, UnitName+"."+kind)
+}
+
+// execRunner runs a command and includes its stderr in the error
+func execRunner(stdin string, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return stdout.String(), fmt.Errorf("%s: %w: %s", name, err, msg)
+		}
+		return stdout.String(), fmt.Errorf("%s: %w", name, err)
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,253 @@
+package schedule
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UnitName is the name of the generated systemd service and timer units
+const UnitName = "sshsk-backup"
+
+// cronMarker tags the crontab line managed by 'sshsk schedule'
+const cronMarker = "# sshsk-backup: managed by 'sshsk schedule', do not edit"
+
+// PassthroughEnv lists the environment variables copied into the scheduled job
+// when they are set at install time. VAULT_TOKEN is deliberately not copied:
+// unit files and crontabs are not secret stores, so scheduled jobs read the
+// token from the token file instead.
+var PassthroughEnv = []string{
+	"VAULT_ADDR",
+	"VAULT_NAMESPACE",
+	"VAULT_CACERT",
+	"VAULT_CAPATH",
+	"SSHSK_VAULT_TOKEN_FILE",
+}
+
+// Job describes a periodic backup
+type Job struct {
+	Every   time.Duration
+	Command []string          // Binary followed by its arguments
+	Env     map[string]string // Environment for the job
+}
+
+// ParseInterval parses an interval such as "30m", "6h" or "1d" (days)
+func ParseInterval(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+
+	var every time.Duration
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid interval %q", value)
+		}
+		every = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid interval %q: %w", value, err)
+		}
+		every = d
+	}
+
+	if every < time.Minute {
+		return 0, fmt.Errorf("interval %q is shorter than one minute", value)
+	}
+	if every%time.Minute != 0 {
+		return 0, fmt.Errorf("interval %q must be a whole number of minutes", value)
+	}
+	return every, nil
+}
+
+// EnvFromEnvironment collects the PassthroughEnv variables that are set
+func EnvFromEnvironment(getenv func(string) string) map[string]string {
+	env := make(map[string]string)
+	for _, key := range PassthroughEnv {
+		if value := getenv(key); value != "" {
+			env[key] = value
+		}
+	}
+	return env
+}
+
+// Validate checks that the job can be scheduled
+func (j Job) Validate() error {
+	if j.Every < time.Minute {
+		return fmt.Errorf("interval must be at least one minute")
+	}
+	if len(j.Command) == 0 {
+		return fmt.Errorf("command is required")
+	}
+	if j.Env["VAULT_ADDR"] == "" {
+		return fmt.Errorf("VAULT_ADDR must be set so the scheduled backup can reach Vault")
+	}
+	return nil
+}
+
+// ServiceUnit renders the systemd user service that runs one backup
+func (j Job) ServiceUnit() string {
+	var b strings.Builder
+
+	b.WriteString("[Unit]\n")
+	b.WriteString("Description=SSH Secret Keeper backup\n")
+	b.WriteString("Documentation=https://github.com/rzago/ssh-secret-keeper\n")
+	b.WriteString("\n[Service]\n")
+	b.WriteString("Type=oneshot\n")
+	for _, key := range sortedKeys(j.Env) {
+		fmt.Fprintf(&b, "Environment=%s\n", systemdQuote(key+"="+j.Env[key]))
+	}
+
+	args := make([]string, len(j.Command))
+	for i, arg := range j.Command {
+		args[i] = systemdQuote(arg)
+	}
+	fmt.Fprintf(&b, "ExecStart=%s\n", strings.Join(args, " "))
+
+	// Exit status 3 means nothing changed since the latest backup
+	b.WriteString("SuccessExitStatus=3\n")
+
+	return b.String()
+}
+
+// TimerUnit renders the systemd user timer that triggers the service
+func (j Job) TimerUnit() string {
+	var b strings.Builder
+
+	b.WriteString("[Unit]\n")
+	fmt.Fprintf(&b, "Description=Run SSH Secret Keeper backup every %s\n", formatInterval(j.Every))
+	b.WriteString("\n[Timer]\n")
+	b.WriteString("OnBootSec=5min\n")
+	fmt.Fprintf(&b, "OnUnitActiveSec=%s\n", formatInterval(j.Every))
+	b.WriteString("RandomizedDelaySec=60\n")
+	fmt.Fprintf(&b, "Unit=%s.service\n", UnitName)
+	b.WriteString("\n[Install]\n")
+	b.WriteString("WantedBy=timers.target\n")
+
+	return b.String()
+}
+
+// CronLine renders the crontab entry for the job. Cron can only express
+// intervals that divide an hour or a day evenly.
+func (j Job) CronLine() (string, error) {
+	spec, err := CronSpec(j.Every)
+	if err != nil {
+		return "", err
+	}
+
+	var parts []string
+	for _, key := range sortedKeys(j.Env) {
+		parts = append(parts, key+"="+shellQuote(j.Env[key]))
+	}
+	for _, arg := range j.Command {
+		parts = append(parts, shellQuote(arg))
+	}
+
+	command := strings.Join(parts, " ")
+	if strings.Contains(command, "%") {
+		// cron turns unescaped % into newlines
+		command = strings.ReplaceAll(command, "%", `\%`)
+	}
+
+	// Errors go to stderr, which cron mails to the user
+	return fmt.Sprintf("%s %s >/dev/null %s", spec, command, cronMarker), nil
+}
+
+// CronSpec converts an interval into the five cron time fields
+func CronSpec(every time.Duration) (string, error) {
+	minutes := int(every / time.Minute)
+
+	switch {
+	case minutes < 1 || every%time.Minute != 0:
+		return "", fmt.Errorf("cron needs a whole number of minutes, got %s", every)
+	case minutes < 60 && 60%minutes == 0:
+		return fmt.Sprintf("*/%d * * * *", minutes), nil
+	case minutes == 60:
+		return "0 * * * *", nil
+	case minutes%60 == 0 && minutes < 24*60 && (24*60)%minutes == 0:
+		return fmt.Sprintf("0 */%d * * *", minutes/60), nil
+	case minutes == 24*60:
+		return "0 0 * * *", nil
+	default:
+		return "", fmt.Errorf("cron cannot run every %s; use an interval that divides an hour or a day evenly", formatInterval(every))
+	}
+}
+
+// AddToCrontab returns the crontab with line added, replacing any managed entry
+func AddToCrontab(crontab, line string) string {
+	crontab, _ = RemoveFromCrontab(crontab)
+	if crontab != "" && !strings.HasSuffix(crontab, "\n") {
+		crontab += "\n"
+	}
+	return crontab + line + "\n"
+}
+
+// RemoveFromCrontab returns the crontab without the managed entry and whether one was found
+func RemoveFromCrontab(crontab string) (string, bool) {
+	if crontab == "" {
+		return "", false
+	}
+
+	lines := strings.SplitAfter(crontab, "\n")
+	kept := make([]string, 0, len(lines))
+	found := false
+	for _, line := range lines {
+		if strings.Contains(line, cronMarker) {
+			found = true
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return strings.Join(kept, ""), found
+}
+
+// FindInCrontab returns the managed entry of a crontab
+func FindInCrontab(crontab string) (string, bool) {
+	for _, line := range strings.Split(crontab, "\n") {
+		if strings.Contains(line, cronMarker) {
+			return line, true
+		}
+	}
+	return "", false
+}
+
+// formatInterval renders an interval in systemd time span syntax, e.g. "6h" or "1d"
+func formatInterval(every time.Duration) string {
+	switch {
+	case every%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", every/(24*time.Hour))
+	case every%time.Hour == 0:
+		return fmt.Sprintf("%dh", every/time.Hour)
+	default:
+		return fmt.Sprintf("%dmin", every/time.Minute)
+	}
+}
+
+// systemdQuote quotes a value for Environment= and ExecStart= lines
+func systemdQuote(value string) string {
+	value = strings.ReplaceAll(value, "%", "%%")
+	if !strings.ContainsAny(value, " \t\"'\\;") {
+		return value
+	}
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return `"` + value + `"`
+}
+
+// shellQuote quotes a value for /bin/sh
+func shellQuote(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\n\"'\\$`;&|<>()*?[]#~!{}") {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,329 @@
+package schedule
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testJob() Job {
+	return Job{
+		Every:   6 * time.Hour,
+		Command: []string{"/usr/local/bin/sshsk", "backup"},
+		Env: map[string]string{
+			"VAULT_ADDR":      "https://vault.example.com:8200",
+			"VAULT_NAMESPACE": "team a",
+		},
+	}
+}
+
+func TestParseInterval(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"6h", 6 * time.Hour, false},
+		{"30m", 30 * time.Minute, false},
+		{"1d", 24 * time.Hour, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"30s", 0, true},
+		{"90s", 0, true},
+		{"xd", 0, true},
+		{"often", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseInterval(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseInterval(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseInterval(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestEnvFromEnvironment(t *testing.T) {
+	values := map[string]string{
+		"VAULT_ADDR":  "https://vault:8200",
+		"VAULT_TOKEN": "s.secret",
+	}
+
+	env := EnvFromEnvironment(func(key string) string { return values[key] })
+
+	if env["VAULT_ADDR"] != "https://vault:8200" {
+		t.Errorf("VAULT_ADDR not passed through: %v", env)
+	}
+	if _, ok := env["VAULT_TOKEN"]; ok {
+		t.Error("VAULT_TOKEN must not be written into scheduled jobs")
+	}
+	if len(env) != 1 {
+		t.Errorf("unexpected variables: %v", env)
+	}
+}
+
+func TestJob_Validate(t *testing.T) {
+	job := testJob()
+	if err := job.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	delete(job.Env, "VAULT_ADDR")
+	if err := job.Validate(); err == nil {
+		t.Error("Validate() should require VAULT_ADDR")
+	}
+}
+
+func TestJob_ServiceUnit(t *testing.T) {
+	unit := testJob().ServiceUnit()
+
+	for _, want := range []string{
+		"Type=oneshot\n",
+		"Environment=\"VAULT_NAMESPACE=team a\"\n",
+		"Environment=VAULT_ADDR=https://vault.example.com:8200\n",
+		"ExecStart=/usr/local/bin/sshsk backup\n",
+		"SuccessExitStatus=3\n",
+	} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("service unit missing %q:\n%s", want, unit)
+		}
+	}
+}
+
+func TestJob_TimerUnit(t *testing.T) {
+	unit := testJob().TimerUnit()
+
+	for _, want := range []string{
+		"OnUnitActiveSec=6h\n",
+		"Unit=sshsk-backup.service\n",
+		"WantedBy=timers.target\n",
+	} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("timer unit missing %q:\n%s", want, unit)
+		}
+	}
+}
+
+func TestSystemdQuote(t *testing.T) {
+	tests := map[string]string{
+		"plain":      "plain",
+		"with space": `"with space"`,
+		"50%":        "50%%",
+		`say "hi"`:   `"say \"hi\""`,
+		"$HOME/.ssh": "$HOME/.ssh",
+		`back\slash`: `"back\\slash"`,
+	}
+
+	for value, want := range tests {
+		if got := systemdQuote(value); got != want {
+			t.Errorf("systemdQuote(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestCronSpec(t *testing.T) {
+	tests := []struct {
+		every   time.Duration
+		want    string
+		wantErr bool
+	}{
+		{15 * time.Minute, "*/15 * * * *", false},
+		{time.Hour, "0 * * * *", false},
+		{6 * time.Hour, "0 */6 * * *", false},
+		{24 * time.Hour, "0 0 * * *", false},
+		{7 * time.Minute, "", true},
+		{5 * time.Hour, "", true},
+		{48 * time.Hour, "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := CronSpec(tt.every)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("CronSpec(%s) error = %v, wantErr %v", tt.every, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("CronSpec(%s) = %q, want %q", tt.every, got, tt.want)
+		}
+	}
+}
+
+func TestJob_CronLine(t *testing.T) {
+	line, err := testJob().CronLine()
+	if err != nil {
+		t.Fatalf("CronLine() error = %v", err)
+	}
+
+	want := "0 */6 * * * VAULT_ADDR=https://vault.example.com:8200 VAULT_NAMESPACE='team a' /usr/local/bin/sshsk backup >/dev/null " + cronMarker
+	if line != want {
+		t.Errorf("CronLine() =\n%s\nwant\n%s", line, want)
+	}
+}
+
+func TestCrontabEditing(t *testing.T) {
+	existing := "MAILTO=me\n0 3 * * * /usr/bin/other\n"
+
+	added := AddToCrontab(existing, "0 * * * * sshsk backup "+cronMarker)
+	if !strings.HasPrefix(added, existing) {
+		t.Errorf("existing entries were not preserved:\n%s", added)
+	}
+
+	replaced := AddToCrontab(added, "*/30 * * * * sshsk backup "+cronMarker)
+	if strings.Count(replaced, cronMarker) != 1 {
+		t.Errorf("managed entry was duplicated:\n%s", replaced)
+	}
+	if line, ok := FindInCrontab(replaced); !ok || !strings.HasPrefix(line, "*/30") {
+		t.Errorf("FindInCrontab() = %q, %v", line, ok)
+	}
+
+	removed, found := RemoveFromCrontab(replaced)
+	if !found || removed != existing {
+		t.Errorf("RemoveFromCrontab() = %q, %v; want %q", removed, found, existing)
+	}
+
+	if _, found := RemoveFromCrontab(existing); found {
+		t.Error("RemoveFromCrontab() found an entry in an unmanaged crontab")
+	}
+}
+
+// fakeSystem records commands and simulates systemctl and crontab
+type fakeSystem struct {
+	systemd  bool
+	crontab  string
+	commands []string
+}
+
+func (f *fakeSystem) run(stdin string, name string, args ...string) (string, error) {
+	f.commands = append(f.commands, strings.Join(append([]string{name}, args...), " "))
+
+	switch name {
+	case "systemctl":
+		if !f.systemd {
+			return "", fmt.Errorf("systemctl: Failed to connect to bus")
+		}
+		if len(args) > 1 && args[1] == "is-active" {
+			return "active\n", nil
+		}
+		return "", nil
+	case "crontab":
+		if args[0] == "-l" {
+			if f.crontab == "" {
+				return "", fmt.Errorf("crontab: exit status 1: no crontab for user")
+			}
+			return f.crontab, nil
+		}
+		f.crontab = stdin
+		return "", nil
+	}
+	return "", fmt.Errorf("unexpected command %s", name)
+}
+
+func (f *fakeSystem) ran(command string) bool {
+	for _, c := range f.commands {
+		if c == command {
+			return true
+		}
+	}
+	return false
+}
+
+func TestInstaller_Systemd(t *testing.T) {
+	unitDir := filepath.Join(t.TempDir(), "systemd", "user")
+	system := &fakeSystem{systemd: true}
+	installer := NewInstallerWithRunner(unitDir, system.run)
+
+	if backend := installer.DetectBackend(); backend != BackendSystemd {
+		t.Fatalf("DetectBackend() = %s, want systemd", backend)
+	}
+	if err := installer.Install(testJob(), BackendSystemd); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+
+	for _, kind := range []string{"service", "timer"} {
+		if _, err := os.Stat(filepath.Join(unitDir, UnitName+"."+kind)); err != nil {
+			t.Errorf("%s unit not written: %v", kind, err)
+		}
+	}
+	if !system.ran("systemctl --user enable --now sshsk-backup.timer") {
+		t.Errorf("timer was not enabled: %v", system.commands)
+	}
+
+	status, err := installer.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if !status.Systemd || status.Cron || status.TimerState != "active" {
+		t.Errorf("Status() = %+v", status)
+	}
+
+	removed, err := installer.Remove()
+	if err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if len(removed) != 2 {
+		t.Errorf("Remove() = %v, want both unit files", removed)
+	}
+	if !system.ran("systemctl --user disable --now sshsk-backup.timer") {
+		t.Errorf("timer was not disabled: %v", system.commands)
+	}
+
+	status, err = installer.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if status.Installed() {
+		t.Errorf("schedule still installed after Remove(): %+v", status)
+	}
+}
+
+func TestInstaller_CronFallback(t *testing.T) {
+	system := &fakeSystem{crontab: "0 3 * * * /usr/bin/other\n"}
+	installer := NewInstallerWithRunner(t.TempDir(), system.run)
+
+	if backend := installer.DetectBackend(); backend != BackendCron {
+		t.Fatalf("DetectBackend() = %s, want cron", backend)
+	}
+	if err := installer.Install(testJob(), BackendCron); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+
+	status, err := installer.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if !status.Cron || status.Systemd {
+		t.Errorf("Status() = %+v", status)
+	}
+
+	removed, err := installer.Remove()
+	if err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if len(removed) != 1 || system.crontab != "0 3 * * * /usr/bin/other\n" {
+		t.Errorf("Remove() = %v, crontab = %q", removed, system.crontab)
+	}
+}
+
+func TestInstaller_CronWithoutCrontab(t *testing.T) {
+	system := &fakeSystem{}
+	installer := NewInstallerWithRunner(t.TempDir(), system.run)
+
+	job := testJob()
+	job.Every = 5 * time.Hour
+	if err := installer.Install(job, BackendCron); err == nil {
+		t.Error("Install() should reject intervals cron cannot express")
+	}
+
+	job.Every = time.Hour
+	if err := installer.Install(job, BackendCron); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	if !strings.HasPrefix(system.crontab, "0 * * * * ") {
+		t.Errorf("crontab = %q", system.crontab)
+	}
+}
@@ -0,0 +1,67 @@
+package ssh
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"os"
+	"sort"
+)
+
+// Fingerprint returns a deterministic SHA-256 over the backed-up trees: file
+// paths, permission bits, contents, symlink targets and directory modes of the
+// SSH directory and any additional sources.
+// Timestamps and host details are left out, so two backups of an unchanged
+// SSH directory have the same fingerprint.
+func (b *BackupData) Fingerprint() string {
+	h := sha256.New()
+	writeTree(h, b.Files, b.Directories)
+
+	// Sources are only hashed when present, so fingerprints of backups without
+	// sources are unchanged
+	for _, name := range b.SourceNames() {
+		source := b.Sources[name]
+		writeField(h, "source", name, source.Dir, fmt.Sprintf("%04o", source.DirMode.Perm()))
+		writeTree(h, source.Files, source.Directories)
+	}
+
+	return fmt.Sprintf("sha256:%x", h.Sum(nil))
+}
+
+// writeTree hashes files and directories in sorted order
+func writeTree(h hash.Hash, files map[string]*FileData, directories map[string]os.FileMode) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		file := files[name]
+		if file.IsSymlink() {
+			writeField(h, "link", name, file.LinkTarget)
+			continue
+		}
+		contentHash := sha256.Sum256(file.Content)
+		writeField(h, "file", name, fmt.Sprintf("%04o", file.Permissions&os.ModePerm), fmt.Sprintf("%x", contentHash))
+	}
+
+	dirs := make([]string, 0, len(directories))
+	for dir := range directories {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	for _, dir := range dirs {
+		writeField(h, "dir", dir, fmt.Sprintf("%04o", directories[dir].Perm()))
+	}
+}
+
+// writeField writes length-prefixed values so that no two different trees
+// produce the same byte stream
+func writeField(h hash.Hash, values ...string) {
+	for _, value := range values {
+		fmt.Fprintf(h, "%d:%s;", len(value), value)
+	}
+	h.Write([]byte{'\n'})
+}
@@ -0,0 +1,58 @@
+package ssh
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestBackupData_Fingerprint(t *testing.T) {
+	newBackup := func() *BackupData {
+		return &BackupData{
+			Timestamp: time.Now(),
+			Hostname:  "laptop",
+			Files: map[string]*FileData{
+				"id_ed25519": {Content: []byte("private"), Permissions: 0600, ModTime: time.Now()},
+				"config":     {Content: []byte{}, Permissions: os.ModeSymlink | 0777, LinkTarget: "config.d/work"},
+			},
+			Directories: map[string]os.FileMode{"config.d": 0700},
+		}
+	}
+
+	base := newBackup().Fingerprint()
+
+	later := newBackup()
+	later.Timestamp = later.Timestamp.Add(time.Hour)
+	later.Hostname = "other"
+	later.Files["id_ed25519"].ModTime = time.Now().Add(time.Hour)
+	if got := later.Fingerprint(); got != base {
+		t.Errorf("timestamps and host details changed the fingerprint: %s != %s", got, base)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(b *BackupData)
+	}{
+		{"content", func(b *BackupData) { b.Files["id_ed25519"].Content = []byte("rotated") }},
+		{"permissions", func(b *BackupData) { b.Files["id_ed25519"].Permissions = 0644 }},
+		{"link target", func(b *BackupData) { b.Files["config"].LinkTarget = "config.d/home" }},
+		{"directory mode", func(b *BackupData) { b.Directories["config.d"] = 0755 }},
+		{"new file", func(b *BackupData) { b.Files["known_hosts"] = &FileData{Permissions: 0644} }},
+		{"removed file", func(b *BackupData) { delete(b.Files, "id_ed25519") }},
+		{"source", func(b *BackupData) {
+			b.Sources = map[string]*SourceData{"kube": {Dir: "~/.kube", DirMode: 0700, Files: map[string]*FileData{
+				"config": {Content: []byte("apiVersion: v1\n"), Permissions: 0600},
+			}}}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backup := newBackup()
+			tt.modify(backup)
+			if backup.Fingerprint() == base {
+				t.Errorf("changing %s should change the fingerprint", tt.name)
+			}
+		})
+	}
+}
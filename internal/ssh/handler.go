@@ -4,13 +4,18 @@ import (
 	"crypto/md5"
 	"fmt"
 	"os"
+	"os/user"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/rzago/ssh-secret-keeper/internal/analyzer"
 	"github.com/rzago/ssh-secret-keeper/internal/crypto"
+	"github.com/rzago/ssh-secret-keeper/internal/filter"
+	"github.com/rzago/ssh-secret-keeper/internal/undo"
 	"github.com/rzago/ssh-secret-keeper/internal/utils"
 )
 
@@ -24,6 +29,17 @@ type FileData struct {
 	Checksum    string                `json:"checksum"`
 	KeyInfo     *analyzer.KeyInfo     `json:"key_info,omitempty"`
 	Encrypted   *crypto.EncryptedData `json:"encrypted_data,omitempty"`
+	LinkTarget  string                `json:"link_target,omitempty"` // Set for symlinks, which have no content
+}
+
+// Checksum returns the MD5 checksum recorded for file content
+func Checksum(content []byte) string {
+	return fmt.Sprintf("%x", md5.Sum(content))
+}
+
+// IsSymlink reports whether the entry is a symbolic link rather than a regular file
+func (f *FileData) IsSymlink() bool {
+	return f.LinkTarget != ""
 }
 
 // BackupData represents a complete SSH backup
@@ -36,11 +52,33 @@ type BackupData struct {
 	SSHDirNorm   string                    `json:"ssh_dir_normalized,omitempty"` // New normalized path for cross-user compatibility
 	OriginalUser string                    `json:"original_user,omitempty"`      // For informational purposes
 	PathVersion  string                    `json:"path_version,omitempty"`       // Track path normalization version
+	Tags         []string                  `json:"tags,omitempty"`
+	Description  string                    `json:"description,omitempty"`
+	Owner        *Owner                    `json:"owner,omitempty"` // Owner of the SSH directory when it was backed up
 	Files        map[string]*FileData      `json:"files"`
+	Directories  map[string]os.FileMode    `json:"directories,omitempty"` // Subdirectory permissions keyed by relative path
 	Analysis     *analyzer.DetectionResult `json:"analysis"`
+	Sources      map[string]*SourceData    `json:"sources,omitempty"` // Additional directories keyed by source name
 	Metadata     map[string]interface{}    `json:"metadata"`
 }
 
+// Owner identifies the account owning a backed up SSH directory
+type Owner struct {
+	User string `json:"user,omitempty"`
+	UID  int    `json:"uid"`
+	GID  int    `json:"gid"`
+}
+
+// FileNames returns the names of the files in the backup in sorted order
+func (b *BackupData) FileNames() []string {
+	names := make([]string, 0, len(b.Files))
+	for name := range b.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // Handler manages SSH file operations
 type Handler struct {
 	analyzer  *analyzer.Analyzer
@@ -55,12 +93,26 @@ func New() *Handler {
 	}
 }
 
+// NewWithAnalyzer creates an SSH handler that classifies files with a, for
+// reading additional sources that are not SSH directories
+func NewWithAnalyzer(a *analyzer.Analyzer) *Handler {
+	h := New()
+	h.analyzer = a
+	return h
+}
+
+// SetFilter sets the include/exclude rules applied when reading a directory
+func (h *Handler) SetFilter(matcher *filter.Matcher) {
+	h.analyzer.SetFilter(matcher)
+}
+
 // ReadDirectory reads and analyzes an SSH directory
 func (h *Handler) ReadDirectory(sshDir string) (*BackupData, error) {
 	log.Info().Str("dir", sshDir).Msg("Reading SSH directory")
 
 	// Verify directory exists
-	if _, err := os.Stat(sshDir); os.IsNotExist(err) {
+	dirInfo, err := os.Stat(sshDir)
+	if os.IsNotExist(err) {
 		return nil, fmt.Errorf("SSH directory does not exist: %s", sshDir)
 	}
 
@@ -76,6 +128,16 @@ func (h *Handler) ReadDirectory(sshDir string) (*BackupData, error) {
 		return nil, fmt.Errorf("failed to read SSH files: %w", err)
 	}
 
+	// Symlinks are stored as links, never followed
+	for _, link := range analysis.Symlinks {
+		files[link.Path] = newSymlinkFileData(link)
+	}
+
+	directories := make(map[string]os.FileMode, len(analysis.Directories))
+	for _, dir := range analysis.Directories {
+		directories[dir.Path] = dir.Permissions
+	}
+
 	// Get system information
 	hostname, _ := os.Hostname()
 	username := os.Getenv("USER")
@@ -103,12 +165,17 @@ func (h *Handler) ReadDirectory(sshDir string) (*BackupData, error) {
 		SSHDirNorm:   normalizedSSHDir, // New normalized path
 		OriginalUser: username,         // Store original user for reference
 		PathVersion:  "2.0",            // Version indicating path normalization support
+		Owner:        DirectoryOwner(dirInfo),
 		Files:        files,
+		Directories:  directories,
 		Analysis:     analysis,
 		Metadata: map[string]interface{}{
 			"total_files":           len(files),
 			"total_size":            h.calculateTotalSize(files),
 			"key_pair_count":        len(analysis.KeyPairs),
+			"excluded_files":        len(analysis.Excluded),
+			"directory_count":       len(directories),
+			"symlink_count":         len(analysis.Symlinks),
 			"service_count":         len(analysis.Categories["service"]),
 			"normalized_path":       normalizedSSHDir,
 			"cross_user_compatible": true,
@@ -123,12 +190,30 @@ func (h *Handler) ReadDirectory(sshDir string) (*BackupData, error) {
 	return backup, nil
 }
 
+// DirectoryOwner returns the owner of a directory, or nil where the platform
+// does not record one
+func DirectoryOwner(info os.FileInfo) *Owner {
+	if info == nil {
+		return nil
+	}
+	uid, gid, ok := fileOwner(info)
+	if !ok {
+		return nil
+	}
+	owner := &Owner{UID: uid, GID: gid}
+	if u, err := user.LookupId(strconv.Itoa(uid)); err == nil {
+		owner.User = u.Username
+	}
+	return owner
+}
+
 // readFiles reads the content of SSH files
 func (h *Handler) readFiles(sshDir string, keys []analyzer.KeyInfo) (map[string]*FileData, error) {
 	files := make(map[string]*FileData)
 
 	for _, keyInfo := range keys {
-		filePath := filepath.Join(sshDir, keyInfo.Filename)
+		keyInfo := keyInfo // Each file keeps its own KeyInfo
+		filePath := filepath.Join(sshDir, filepath.FromSlash(keyInfo.Filename))
 
 		content, err := os.ReadFile(filePath)
 		if err != nil {
@@ -139,8 +224,7 @@ func (h *Handler) readFiles(sshDir string, keys []analyzer.KeyInfo) (map[string]
 			continue
 		}
 
-		// Calculate MD5 checksum
-		checksum := fmt.Sprintf("%x", md5.Sum(content))
+		checksum := Checksum(content)
 
 		fileData := &FileData{
 			Filename:    keyInfo.Filename,
@@ -164,6 +248,28 @@ func (h *Handler) readFiles(sshDir string, keys []analyzer.KeyInfo) (map[string]
 	return files, nil
 }
 
+// newSymlinkFileData records a symlink as a content-less file entry
+func newSymlinkFileData(link analyzer.SymlinkInfo) *FileData {
+	content := []byte{}
+	return &FileData{
+		Filename:    link.Path,
+		Content:     content,
+		Permissions: os.ModeSymlink | 0777,
+		ModTime:     link.ModTime,
+		Checksum:    Checksum(content),
+		LinkTarget:  link.Target,
+		KeyInfo: &analyzer.KeyInfo{
+			Filename:    link.Path,
+			Type:        analyzer.KeyTypeSymlink,
+			Format:      analyzer.FormatUnknown,
+			Purpose:     analyzer.PurposeSystem,
+			Permissions: os.ModeSymlink | 0777,
+			ModTime:     link.ModTime,
+			Metadata:    map[string]interface{}{"link_target": link.Target},
+		},
+	}
+}
+
 // EncryptBackup encrypts a backup with the given passphrase
 func (h *Handler) EncryptBackup(backup *BackupData, passphrase string) error {
 	log.Info().Msg("Encrypting backup data")
@@ -234,6 +340,10 @@ func (h *Handler) RestoreFiles(backup *BackupData, targetDir string, options Res
 		if err := h.verifySSHDirectoryPermissions(targetDir); err != nil {
 			log.Warn().Err(err).Msg("SSH directory permission warning")
 		}
+
+		if err := CreateDirectories(targetDir, backup.Directories); err != nil {
+			return err
+		}
 	}
 
 	for filename, fileData := range backup.Files {
@@ -243,7 +353,11 @@ func (h *Handler) RestoreFiles(backup *BackupData, targetDir string, options Res
 			continue
 		}
 
-		targetPath := filepath.Join(targetDir, filename)
+		targetPath, err := utils.SafeJoin(targetDir, filename)
+		if err != nil {
+			log.Error().Err(err).Str("file", filename).Msg("Refusing to restore file outside the target directory")
+			continue
+		}
 
 		if options.DryRun {
 			log.Info().
@@ -264,7 +378,7 @@ func (h *Handler) RestoreFiles(backup *BackupData, targetDir string, options Res
 			Msg("Processing file for restore")
 
 		// Check if file exists and handle conflicts
-		if _, err := os.Stat(targetPath); err == nil && !options.Overwrite {
+		if _, err := os.Lstat(targetPath); err == nil && !options.Overwrite {
 			if options.Interactive {
 				if !h.promptOverwrite(filename) {
 					log.Info().Str("file", filename).Msg("Skipped by user")
@@ -276,6 +390,18 @@ func (h *Handler) RestoreFiles(backup *BackupData, targetDir string, options Res
 			}
 		}
 
+		if err := os.MkdirAll(filepath.Dir(targetPath), 0700); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", filename, err)
+		}
+
+		if fileData.IsSymlink() {
+			if err := RestoreSymlink(targetPath, fileData.LinkTarget); err != nil {
+				return fmt.Errorf("failed to restore symlink %s: %w", filename, err)
+			}
+			log.Info().Str("file", filename).Str("link_target", fileData.LinkTarget).Msg("Symlink restored")
+			continue
+		}
+
 		// Check for empty content
 		if fileData.Content == nil {
 			log.Error().Str("file", filename).Msg("CRITICAL: File content is nil - cannot restore file")
@@ -338,17 +464,126 @@ func (h *Handler) RestoreFiles(backup *BackupData, targetDir string, options Res
 			Msg("File restored with appropriate permissions")
 	}
 
+	if !options.DryRun {
+		if err := ApplyDirectoryPermissions(targetDir, backup.Directories); err != nil {
+			log.Warn().Err(err).Msg("Failed to restore directory permissions")
+		}
+	}
+
 	log.Info().Msg("File restoration completed")
 	return nil
 }
 
+// CreateDirectories creates the recorded subdirectories below targetDir. They are
+// created owner-writable so files can be restored into them; ApplyDirectoryPermissions
+// sets the recorded modes once all files are in place.
+func CreateDirectories(targetDir string, directories map[string]os.FileMode) error {
+	paths := make([]string, 0, len(directories))
+	for path := range directories {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		dirPath, err := utils.SafeJoin(targetDir, path)
+		if err != nil {
+			log.Error().Err(err).Str("directory", path).Msg("Refusing to create directory outside the target directory")
+			continue
+		}
+		if err := os.MkdirAll(dirPath, 0700); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// ApplyDirectoryPermissions sets the recorded modes on restored subdirectories,
+// deepest first so restrictive parents never block their children
+func ApplyDirectoryPermissions(targetDir string, directories map[string]os.FileMode) error {
+	paths := make([]string, 0, len(directories))
+	for path := range directories {
+		paths = append(paths, path)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(paths)))
+
+	var failed []string
+	for _, path := range paths {
+		dirPath, err := utils.SafeJoin(targetDir, path)
+		if err != nil {
+			continue
+		}
+
+		mode := directories[path].Perm()
+		if mode == 0 {
+			mode = 0700
+		}
+
+		if err := os.Chmod(dirPath, mode); err != nil {
+			log.Warn().Err(err).Str("directory", path).Msg("Failed to set directory permissions")
+			failed = append(failed, path)
+			continue
+		}
+
+		log.Debug().
+			Str("directory", path).
+			Str("permissions", fmt.Sprintf("%04o", mode)).
+			Msg("Directory permissions restored")
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to set permissions on %d directories: %s", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// RestoreSymlink creates a symlink at targetPath, replacing an existing file or link
+func RestoreSymlink(targetPath, linkTarget string) error {
+	if info, err := os.Lstat(targetPath); err == nil {
+		if info.IsDir() {
+			return fmt.Errorf("cannot replace directory %s with a symlink", targetPath)
+		}
+		if existing, err := os.Readlink(targetPath); err == nil && existing == linkTarget {
+			return nil
+		}
+		if err := os.Remove(targetPath); err != nil {
+			return fmt.Errorf("cannot remove existing file: %w", err)
+		}
+	}
+
+	if err := os.Symlink(linkTarget, targetPath); err != nil {
+		return fmt.Errorf("cannot create symlink: %w", err)
+	}
+	return nil
+}
+
 // RestoreOptions configure the restoration process
 type RestoreOptions struct {
-	DryRun      bool
-	Overwrite   bool
-	Interactive bool
-	FileFilter  []string // Only restore these files
-	TypeFilter  []string // Only restore these file types
+	DryRun        bool
+	Overwrite     bool
+	Merge         bool // Merge known_hosts, authorized_keys and ssh config into existing files line by line
+	Interactive   bool
+	FileFilter    []string          // Only restore these files
+	TypeFilter    []string          // Only restore these file types
+	PurposeFilter []string          // Only restore files analyzed with these purposes
+	ServiceFilter []string          // Only restore files analyzed as belonging to these services
+	PairFilter    []string          // Only restore the private key, public key and certificate of these key pairs
+	System        bool              // Restore host keys into SystemSSHDir: directories keep 0755 and everything is owned by root
+	DirMode       os.FileMode       // Mode of the target directory for additional sources; zero means the SSH directory's 0700
+	Undo          *undo.Transaction // Records replaced files so the caller can roll back; nil makes each restore use its own
+	Owner         *Owner            // Owner given to restored files and directories; nil keeps the restoring user's
+	HomeDir       string            // Home directory ~ expands to; empty means the current user's
+}
+
+// WithoutFilters returns the options with every file filter cleared, for the
+// additional sources of a backup, which the filters do not apply to
+func (o RestoreOptions) WithoutFilters() RestoreOptions {
+	o.FileFilter = nil
+	o.TypeFilter = nil
+	o.PurposeFilter = nil
+	o.ServiceFilter = nil
+	o.PairFilter = nil
+	return o
 }
 
 // shouldRestoreFile checks if a file should be restored based on options
@@ -395,7 +630,7 @@ func (h *Handler) VerifyBackup(backup *BackupData) error {
 		}
 
 		// Verify MD5 checksum
-		currentChecksum := fmt.Sprintf("%x", md5.Sum(fileData.Content))
+		currentChecksum := Checksum(fileData.Content)
 		if currentChecksum != fileData.Checksum {
 			return fmt.Errorf("MD5 checksum mismatch for file %s: expected %s, got %s",
 				filename, fileData.Checksum, currentChecksum)
@@ -404,6 +639,12 @@ func (h *Handler) VerifyBackup(backup *BackupData) error {
 		log.Debug().Str("file", filename).Str("md5", currentChecksum[:8]).Msg("MD5 checksum verified")
 	}
 
+	for _, name := range backup.SourceNames() {
+		if err := h.VerifyBackup(backup.Sources[name].AsBackup()); err != nil {
+			return fmt.Errorf("source %s: %w", name, err)
+		}
+	}
+
 	log.Info().Msg("Backup integrity verified with MD5 checksums")
 	return nil
 }
@@ -528,8 +769,10 @@ func permissionsToStrings(perms []os.FileMode) []string {
 	return result
 }
 
-// VerifyRestorePermissions performs post-restore permission verification
-func (h *Handler) VerifyRestorePermissions(backup *BackupData, targetDir string) error {
+// VerifyRestorePermissions performs post-restore permission verification.
+// When owner is set, the directory, its subdirectories and every restored file
+// must also belong to owner.
+func (h *Handler) VerifyRestorePermissions(backup *BackupData, targetDir string, owner *Owner) error {
 	log.Info().Str("target", targetDir).Msg("Verifying restored file permissions")
 
 	permissionIssues := 0
@@ -539,10 +782,50 @@ func (h *Handler) VerifyRestorePermissions(backup *BackupData, targetDir string)
 		log.Error().Err(err).Msg("SSH directory permission issue")
 		permissionIssues++
 	}
+	if err := verifyOwner(targetDir, owner); err != nil {
+		log.Error().Err(err).Msg("SSH directory ownership issue")
+		permissionIssues++
+	}
+
+	// Check restored subdirectories
+	for path, mode := range backup.Directories {
+		stat, err := os.Stat(filepath.Join(targetDir, filepath.FromSlash(path)))
+		if err != nil {
+			log.Warn().Err(err).Str("directory", path).Msg("Cannot verify directory permissions (directory not found)")
+			continue
+		}
+		if err := verifyOwner(filepath.Join(targetDir, filepath.FromSlash(path)), owner); err != nil {
+			log.Error().Err(err).Str("directory", path).Msg("Directory ownership mismatch after restore")
+			permissionIssues++
+		}
+		if stat.Mode().Perm() != mode.Perm() {
+			log.Error().
+				Str("directory", path).
+				Str("expected", fmt.Sprintf("%04o", mode.Perm())).
+				Str("actual", fmt.Sprintf("%04o", stat.Mode().Perm())).
+				Msg("Directory permission mismatch after restore")
+			permissionIssues++
+		}
+	}
 
 	// Check each restored file
 	for filename, fileData := range backup.Files {
-		targetPath := filepath.Join(targetDir, filename)
+		targetPath := filepath.Join(targetDir, filepath.FromSlash(filename))
+
+		if _, err := os.Lstat(targetPath); err == nil {
+			if err := verifyOwner(targetPath, owner); err != nil {
+				log.Error().Err(err).Str("file", filename).Msg("Ownership mismatch after restore")
+				permissionIssues++
+			}
+		}
+
+		// Symlinks have no permissions of their own; only check the link target
+		if fileData.IsSymlink() {
+			if target, err := os.Readlink(targetPath); err != nil || target != fileData.LinkTarget {
+				log.Warn().Str("file", filename).Str("expected_target", fileData.LinkTarget).Msg("Symlink not restored as recorded")
+			}
+			continue
+		}
 
 		// Check if file exists
 		stat, err := os.Stat(targetPath)
@@ -582,6 +865,22 @@ func (h *Handler) VerifyRestorePermissions(backup *BackupData, targetDir string)
 	return nil
 }
 
+// verifyOwner checks that path, not following symlinks, belongs to owner; a
+// nil owner, or a platform recording none, passes
+func verifyOwner(path string, owner *Owner) error {
+	if owner == nil {
+		return nil
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return fmt.Errorf("cannot verify ownership: %w", err)
+	}
+	if uid, gid, ok := fileOwner(info); ok && (uid != owner.UID || gid != owner.GID) {
+		return fmt.Errorf("%s is owned by %d:%d, not %d:%d", path, uid, gid, owner.UID, owner.GID)
+	}
+	return nil
+}
+
 // getAppropriatePermissions determines the correct permissions for a file
 func (h *Handler) getAppropriatePermissions(fileData *FileData) os.FileMode {
 	originalPerms := fileData.Permissions & os.ModePerm
@@ -527,7 +527,7 @@ func TestHandler_VerifyRestorePermissions(t *testing.T) {
 		},
 	}
 
-	err = handler.VerifyRestorePermissions(backup, tmpDir)
+	err = handler.VerifyRestorePermissions(backup, tmpDir, nil)
 	if err != nil {
 		t.Errorf("VerifyRestorePermissions() failed: %v", err)
 	}
@@ -547,12 +547,39 @@ func TestHandler_VerifyRestorePermissions_Errors(t *testing.T) {
 		Files: map[string]*FileData{},
 	}
 
-	err = handler.VerifyRestorePermissions(backup, tmpDir)
+	err = handler.VerifyRestorePermissions(backup, tmpDir, nil)
 	if err == nil {
 		t.Error("VerifyRestorePermissions() should fail with wrong SSH directory permissions")
 	}
 }
 
+func TestHandler_VerifyRestorePermissions_Owner(t *testing.T) {
+	handler := New()
+	tmpDir := t.TempDir()
+	if err := os.Chmod(tmpDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "config"), []byte("Host *\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	backup := &BackupData{
+		Files: map[string]*FileData{
+			"config": {Filename: "config", Permissions: 0600},
+		},
+	}
+
+	owner := &Owner{UID: os.Getuid(), GID: os.Getgid()}
+	if err := handler.VerifyRestorePermissions(backup, tmpDir, owner); err != nil {
+		t.Errorf("VerifyRestorePermissions() error = %v", err)
+	}
+
+	other := &Owner{UID: os.Getuid() + 1, GID: os.Getgid()}
+	if err := handler.VerifyRestorePermissions(backup, tmpDir, other); err == nil {
+		t.Error("VerifyRestorePermissions() should fail when files belong to someone else")
+	}
+}
+
 func TestHandler_GetAppropriatePermissions(t *testing.T) {
 	handler := New()
 
@@ -780,7 +807,7 @@ func TestHandler_VerifyRestorePermissions_Issue17_DefensiveFix(t *testing.T) {
 			}
 
 			// Test the validation
-			err := handler.VerifyRestorePermissions(backup, tmpDir)
+			err := handler.VerifyRestorePermissions(backup, tmpDir, nil)
 
 			if tc.expectError {
 				if err == nil {
@@ -0,0 +1,225 @@
+package ssh
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/rs/zerolog/log"
+	"github.com/rzago/ssh-secret-keeper/internal/analyzer"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// KeyPairStatus is the outcome of checking a restored key pair
+type KeyPairStatus string
+
+const (
+	KeyPairValid      KeyPairStatus = "valid"      // Every part parses and derives the same public key
+	KeyPairMismatch   KeyPairStatus = "mismatch"   // The public key or certificate belongs to another key
+	KeyPairCorrupt    KeyPairStatus = "corrupt"    // A part cannot be parsed, e.g. it was truncated
+	KeyPairUnverified KeyPairStatus = "unverified" // Passphrase-protected without a public key to compare
+)
+
+// KeyPairCheck is the result of checking one restored key pair
+type KeyPairCheck struct {
+	BaseName        string        `json:"base_name"`
+	PrivateKeyFile  string        `json:"private_key_file"`
+	PublicKeyFile   string        `json:"public_key_file,omitempty"`
+	CertificateFile string        `json:"certificate_file,omitempty"`
+	Fingerprint     string        `json:"fingerprint,omitempty"` // SHA256 fingerprint of the private key's public key
+	Encrypted       bool          `json:"encrypted"`
+	Status          KeyPairStatus `json:"status"`
+	Problems        []string      `json:"problems,omitempty"`
+}
+
+// KeyPairReport is the result of checking the key pairs of a restore
+type KeyPairReport struct {
+	Pairs      []KeyPairCheck `json:"pairs"`
+	Valid      int            `json:"valid"`
+	Invalid    int            `json:"invalid"` // Mismatched or corrupt
+	Unverified int            `json:"unverified"`
+}
+
+// OK reports whether no key pair is mismatched or corrupt
+func (r *KeyPairReport) OK() bool {
+	return r.Invalid == 0
+}
+
+// VerifyKeyPairs checks the restored private keys of a backup in targetDir:
+// each must parse, and the public key it derives must match the restored .pub
+// and -cert.pub of its pair. Passphrase-protected keys are not decrypted; the
+// public key OpenSSH stores unencrypted is used instead, and keys without one
+// only have their .pub and certificate compared. Pairs whose private key was
+// not restored are left out.
+func (h *Handler) VerifyKeyPairs(backup *BackupData, targetDir string) *KeyPairReport {
+	log.Info().Str("target", targetDir).Msg("Verifying restored key pairs")
+
+	report := &KeyPairReport{Pairs: []KeyPairCheck{}}
+	for _, pair := range backupKeyPairs(backup) {
+		read := func(filename string) []byte {
+			if filename == "" {
+				return nil
+			}
+			content, err := os.ReadFile(filepath.Join(targetDir, filepath.FromSlash(filename)))
+			if err != nil {
+				return nil
+			}
+			return content
+		}
+
+		private := read(pair.PrivateKeyFile)
+		if private == nil {
+			log.Debug().Str("key", pair.PrivateKeyFile).Msg("Private key not restored, skipping pair check")
+			continue
+		}
+		public, certificate := read(pair.PublicKeyFile), read(pair.CertificateFile)
+		if public == nil {
+			pair.PublicKeyFile = ""
+		}
+		if certificate == nil {
+			pair.CertificateFile = ""
+		}
+
+		checkKeyPair(&pair, private, public, certificate)
+		switch pair.Status {
+		case KeyPairValid:
+			report.Valid++
+		case KeyPairUnverified:
+			report.Unverified++
+		default:
+			report.Invalid++
+			log.Error().
+				Str("key", pair.PrivateKeyFile).
+				Str("status", string(pair.Status)).
+				Strs("problems", pair.Problems).
+				Msg("Restored key pair failed verification")
+		}
+		report.Pairs = append(report.Pairs, pair)
+	}
+
+	log.Info().
+		Int("valid", report.Valid).
+		Int("invalid", report.Invalid).
+		Int("unverified", report.Unverified).
+		Msg("Key pair verification completed")
+	return report
+}
+
+// backupKeyPairs lists the key pairs of a backup by private key: its .pub,
+// or the public key the analysis paired it with, and its -cert.pub
+func backupKeyPairs(backup *BackupData) []KeyPairCheck {
+	publicKeys := make(map[string]string)
+	if backup.Analysis != nil {
+		for _, pair := range backup.Analysis.KeyPairs {
+			if pair.PrivateKeyFile != "" && pair.PublicKeyFile != "" {
+				publicKeys[pair.PrivateKeyFile] = pair.PublicKeyFile
+			}
+		}
+	}
+
+	var pairs []KeyPairCheck
+	for _, filename := range backup.FileNames() {
+		fileData := backup.Files[filename]
+		if fileData.IsSymlink() || fileData.KeyInfo == nil || fileData.KeyInfo.Type != analyzer.KeyTypePrivate {
+			continue
+		}
+
+		pair := KeyPairCheck{BaseName: filename, PrivateKeyFile: filename}
+		if public, ok := publicKeys[filename]; ok && backup.Files[public] != nil {
+			pair.PublicKeyFile = public
+		} else if _, ok := backup.Files[filename+".pub"]; ok {
+			pair.PublicKeyFile = filename + ".pub"
+		}
+		if _, ok := backup.Files[filename+"-cert.pub"]; ok {
+			pair.CertificateFile = filename + "-cert.pub"
+		}
+		pairs = append(pairs, pair)
+	}
+
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].BaseName < pairs[j].BaseName })
+	return pairs
+}
+
+// checkKeyPair compares the public key derived from a private key with its
+// public key and certificate, either of which may be nil, and records the
+// outcome in pair
+func checkKeyPair(pair *KeyPairCheck, private, public, certificate []byte) {
+	var problems []string
+	corrupt, mismatch := false, false
+
+	// The public key everything else is compared with
+	var reference gossh.PublicKey
+	referenceName := pair.PrivateKeyFile
+
+	signer, err := gossh.ParsePrivateKey(private)
+	var missing *gossh.PassphraseMissingError
+	switch {
+	case err == nil:
+		reference = signer.PublicKey()
+	case errors.As(err, &missing):
+		pair.Encrypted = true
+		reference = missing.PublicKey
+	default:
+		corrupt = true
+		problems = append(problems, fmt.Sprintf("%s cannot be parsed: %v", pair.PrivateKeyFile, err))
+	}
+	if reference != nil {
+		pair.Fingerprint = gossh.FingerprintSHA256(reference)
+	}
+
+	if public != nil {
+		publicKey, _, _, _, err := gossh.ParseAuthorizedKey(public)
+		switch {
+		case err != nil:
+			corrupt = true
+			problems = append(problems, fmt.Sprintf("%s cannot be parsed: %v", pair.PublicKeyFile, err))
+		case reference == nil:
+			// A passphrase-protected key without a stored public key
+			reference, referenceName = publicKey, pair.PublicKeyFile
+		case !sameKey(publicKey, reference):
+			mismatch = true
+			problems = append(problems, fmt.Sprintf("%s (%s) does not match %s (%s)",
+				pair.PublicKeyFile, gossh.FingerprintSHA256(publicKey), referenceName, gossh.FingerprintSHA256(reference)))
+		}
+	}
+
+	if certificate != nil {
+		parsed, _, _, _, err := gossh.ParseAuthorizedKey(certificate)
+		cert, isCert := parsed.(*gossh.Certificate)
+		switch {
+		case err != nil:
+			corrupt = true
+			problems = append(problems, fmt.Sprintf("%s cannot be parsed: %v", pair.CertificateFile, err))
+		case !isCert:
+			corrupt = true
+			problems = append(problems, fmt.Sprintf("%s is not a certificate", pair.CertificateFile))
+		case reference == nil:
+			reference, referenceName = cert.Key, pair.CertificateFile
+		case !sameKey(cert.Key, reference):
+			mismatch = true
+			problems = append(problems, fmt.Sprintf("%s certifies %s, not %s (%s)",
+				pair.CertificateFile, gossh.FingerprintSHA256(cert.Key), referenceName, gossh.FingerprintSHA256(reference)))
+		}
+	}
+
+	pair.Problems = problems
+	switch {
+	case corrupt:
+		pair.Status = KeyPairCorrupt
+	case mismatch:
+		pair.Status = KeyPairMismatch
+	case pair.Encrypted && (referenceName != pair.PrivateKeyFile || reference == nil):
+		// Only the public parts could be compared, or nothing at all
+		pair.Status = KeyPairUnverified
+	default:
+		pair.Status = KeyPairValid
+	}
+}
+
+// sameKey reports whether two public keys are the same key
+func sameKey(a, b gossh.PublicKey) bool {
+	return bytes.Equal(a.Marshal(), b.Marshal())
+}
@@ -0,0 +1,147 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rzago/ssh-secret-keeper/internal/analyzer"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// testKeyPair returns a new ed25519 private key, optionally encrypted, and its
+// authorized_keys line
+func testKeyPair(t *testing.T, passphrase string) (ed25519.PrivateKey, []byte, []byte) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var block *pem.Block
+	if passphrase == "" {
+		block, err = gossh.MarshalPrivateKey(key, "")
+	} else {
+		block, err = gossh.MarshalPrivateKeyWithPassphrase(key, "", []byte(passphrase))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := gossh.NewPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, pem.EncodeToMemory(block), gossh.MarshalAuthorizedKey(publicKey)
+}
+
+// testCertificate returns a user certificate for key signed by a new CA
+func testCertificate(t *testing.T, key ed25519.PrivateKey) []byte {
+	t.Helper()
+	_, caKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := gossh.NewSignerFromKey(caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := gossh.NewPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := &gossh.Certificate{Key: publicKey, CertType: gossh.UserCert, ValidBefore: gossh.CertTimeInfinity}
+	if err := cert.SignCert(rand.Reader, signer); err != nil {
+		t.Fatal(err)
+	}
+	return gossh.MarshalAuthorizedKey(cert)
+}
+
+func TestCheckKeyPair(t *testing.T) {
+	key, private, public := testKeyPair(t, "")
+	_, _, otherPublic := testKeyPair(t, "")
+	encryptedKey, encrypted, encryptedPublic := testKeyPair(t, "secret")
+	cert := testCertificate(t, key)
+
+	tests := []struct {
+		name        string
+		private     []byte
+		public      []byte
+		certificate []byte
+		want        KeyPairStatus
+		encrypted   bool
+		problems    int
+	}{
+		{"matching pair and certificate", private, public, cert, KeyPairValid, false, 0},
+		{"private key only", private, nil, nil, KeyPairValid, false, 0},
+		{"public key of another key", private, otherPublic, nil, KeyPairMismatch, false, 1},
+		{"certificate of another key", encrypted, encryptedPublic, cert, KeyPairMismatch, true, 1},
+		{"truncated private key", private[:len(private)/2], public, nil, KeyPairCorrupt, false, 1},
+		{"corrupt public key", private, []byte("ssh-ed25519 AAAAnotbase64!\n"), nil, KeyPairCorrupt, false, 1},
+		{"public key as certificate", private, public, public, KeyPairCorrupt, false, 1},
+		{"passphrase-protected pair", encrypted, encryptedPublic, testCertificate(t, encryptedKey), KeyPairValid, true, 0},
+		{"passphrase-protected mismatch", encrypted, public, nil, KeyPairMismatch, true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pair := KeyPairCheck{BaseName: "id", PrivateKeyFile: "id", PublicKeyFile: "id.pub", CertificateFile: "id-cert.pub"}
+			checkKeyPair(&pair, tt.private, tt.public, tt.certificate)
+			if pair.Status != tt.want {
+				t.Errorf("status = %s, want %s (problems: %q)", pair.Status, tt.want, pair.Problems)
+			}
+			if pair.Encrypted != tt.encrypted {
+				t.Errorf("encrypted = %v, want %v", pair.Encrypted, tt.encrypted)
+			}
+			if len(pair.Problems) != tt.problems {
+				t.Errorf("problems = %q, want %d", pair.Problems, tt.problems)
+			}
+		})
+	}
+}
+
+func TestHandler_VerifyKeyPairs(t *testing.T) {
+	key, private, public := testKeyPair(t, "")
+	_, work, _ := testKeyPair(t, "")
+	_, _, otherPublic := testKeyPair(t, "")
+
+	dir := t.TempDir()
+	restored := map[string][]byte{
+		"id_ed25519":          private,
+		"id_ed25519.pub":      public,
+		"id_ed25519-cert.pub": testCertificate(t, key),
+		"id_work":             work,
+		"id_work.pub":         otherPublic,
+	}
+	for name, content := range restored {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keyFile := func(keyType analyzer.KeyType) *FileData {
+		return &FileData{Permissions: 0600, KeyInfo: &analyzer.KeyInfo{Type: keyType}}
+	}
+	backup := &BackupData{Files: map[string]*FileData{
+		"id_ed25519":          keyFile(analyzer.KeyTypePrivate),
+		"id_ed25519.pub":      keyFile(analyzer.KeyTypePublic),
+		"id_ed25519-cert.pub": keyFile(analyzer.KeyTypeCertificate),
+		"id_work":             keyFile(analyzer.KeyTypePrivate),
+		"id_work.pub":         keyFile(analyzer.KeyTypePublic),
+		"id_skipped":          keyFile(analyzer.KeyTypePrivate), // Filtered out of the restore
+	}}
+
+	report := New().VerifyKeyPairs(backup, dir)
+	if len(report.Pairs) != 2 || report.Valid != 1 || report.Invalid != 1 || report.OK() {
+		t.Fatalf("report = %+v, want one valid and one invalid pair", report)
+	}
+
+	first, second := report.Pairs[0], report.Pairs[1]
+	if first.BaseName != "id_ed25519" || first.Status != KeyPairValid || first.CertificateFile != "id_ed25519-cert.pub" {
+		t.Errorf("id_ed25519 = %+v, want a valid pair with its certificate", first)
+	}
+	if second.BaseName != "id_work" || second.Status != KeyPairMismatch || second.CertificateFile != "" {
+		t.Errorf("id_work = %+v, want a mismatch without certificate", second)
+	}
+}
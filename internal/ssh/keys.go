@@ -0,0 +1,28 @@
+package ssh
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// KeyFingerprint identifies private key content without revealing it. Keys
+// that can be parsed, including passphrase-protected OpenSSH keys, get the
+// SHA256 fingerprint ssh-keygen -l prints; anything else gets a SHA-256 of the
+// content, prefixed with "content:".
+func KeyFingerprint(content []byte) string {
+	signer, err := gossh.ParsePrivateKey(content)
+	if err == nil {
+		return gossh.FingerprintSHA256(signer.PublicKey())
+	}
+
+	var missing *gossh.PassphraseMissingError
+	if errors.As(err, &missing) && missing.PublicKey != nil {
+		return gossh.FingerprintSHA256(missing.PublicKey)
+	}
+
+	sum := sha256.Sum256(content)
+	return "content:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
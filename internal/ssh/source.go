@@ -0,0 +1,100 @@
+package ssh
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/rs/zerolog/log"
+	"github.com/rzago/ssh-secret-keeper/internal/analyzer"
+	"github.com/rzago/ssh-secret-keeper/internal/utils"
+)
+
+// SourceData is an additional directory, such as ~/.kube, backed up alongside
+// the SSH directory. Files are keyed by their path relative to Dir.
+type SourceData struct {
+	Name        string                    `json:"name"`
+	Dir         string                    `json:"dir"` // As configured, e.g. ~/.kube, and resolved for the restoring user
+	DirMode     os.FileMode               `json:"dir_mode"`
+	DefaultMode os.FileMode               `json:"default_mode,omitempty"` // For files without recorded permissions
+	Analyzer    string                    `json:"analyzer,omitempty"`
+	Files       map[string]*FileData      `json:"files"`
+	Directories map[string]os.FileMode    `json:"directories,omitempty"`
+	Analysis    *analyzer.DetectionResult `json:"analysis,omitempty"`
+}
+
+// SourceNames returns the names of the sources in the backup in sorted order
+func (b *BackupData) SourceNames() []string {
+	names := make([]string, 0, len(b.Sources))
+	for name := range b.Sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AsBackup returns the source as backup data, so it can be verified and
+// restored with the same machinery as the SSH directory
+func (s *SourceData) AsBackup() *BackupData {
+	return &BackupData{
+		SSHDir:      s.Dir,
+		Files:       s.Files,
+		Directories: s.Directories,
+		Analysis:    s.Analysis,
+		Metadata:    map[string]interface{}{"source": s.Name},
+	}
+}
+
+// ReadSource reads an additional source directory. dir may start with ~/ and
+// is recorded as given; the handler's analyzer and filter decide which files
+// are included and how they are classified.
+func (h *Handler) ReadSource(name, dir string) (*SourceData, error) {
+	resolved, err := utils.NewPathNormalizer().ResolvePath(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve source %s: %w", name, err)
+	}
+
+	info, err := os.Stat(resolved)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("source directory does not exist: %s", resolved)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot access source directory %s: %w", resolved, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("source %s is not a directory: %s", name, resolved)
+	}
+
+	analysis, err := h.analyzer.AnalyzeDirectory(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze source %s: %w", name, err)
+	}
+
+	files, err := h.readFiles(resolved, analysis.Keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source %s: %w", name, err)
+	}
+	for _, link := range analysis.Symlinks {
+		files[link.Path] = newSymlinkFileData(link)
+	}
+
+	directories := make(map[string]os.FileMode, len(analysis.Directories))
+	for _, d := range analysis.Directories {
+		directories[d.Path] = d.Permissions
+	}
+
+	log.Info().
+		Str("source", name).
+		Str("dir", resolved).
+		Int("files", len(files)).
+		Msg("Source directory read completed")
+
+	return &SourceData{
+		Name:        name,
+		Dir:         dir,
+		DirMode:     info.Mode().Perm(),
+		Files:       files,
+		Directories: directories,
+		Analysis:    analysis,
+	}, nil
+}
@@ -0,0 +1,143 @@
+package ssh
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/rzago/ssh-secret-keeper/internal/analyzer"
+)
+
+// SystemSSHDir holds the sshd host keys and server configuration backed up by --system
+const SystemSSHDir = "/etc/ssh"
+
+// SystemDirMode is the mode of SystemSSHDir and its subdirectories
+const SystemDirMode os.FileMode = 0755
+
+// SystemModeProblem reports why sshd would reject, or an administrator would not
+// expect, a file in SystemSSHDir with the given mode. Private host keys must not
+// be accessible by group or others, public keys and sshd configuration must be
+// 0644 or stricter, and nothing may be writable by group or others.
+func SystemModeProblem(filename string, mode os.FileMode, keyInfo *analyzer.KeyInfo) error {
+	perm := mode.Perm()
+
+	switch {
+	case isPrivateHostKey(filename, keyInfo):
+		if perm&0077 != 0 {
+			return fmt.Errorf("private host key %s has mode %04o; sshd refuses host keys accessible by group or others (use 0600)", filename, perm)
+		}
+	case isSSHDFile(filename, keyInfo):
+		if perm&^0644 != 0 {
+			return fmt.Errorf("%s has mode %04o; sshd expects 0644 or stricter", filename, perm)
+		}
+	default:
+		if perm&0022 != 0 {
+			return fmt.Errorf("%s has mode %04o; files in %s must not be writable by group or others", filename, perm, SystemSSHDir)
+		}
+	}
+	return nil
+}
+
+// CheckSystemPermissions checks the recorded modes of a system backup before
+// anything is written, so a backup sshd would reject is never restored
+func CheckSystemPermissions(backup *BackupData) error {
+	var problems []string
+	for _, filename := range backup.FileNames() {
+		fileData := backup.Files[filename]
+		if fileData.IsSymlink() {
+			continue
+		}
+		if err := SystemModeProblem(filename, fileData.Permissions, fileData.KeyInfo); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	for dir, mode := range backup.Directories {
+		if mode.Perm()&0022 != 0 {
+			problems = append(problems, fmt.Sprintf("directory %s has mode %04o; it must not be writable by group or others", dir, mode.Perm()))
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("backup cannot be restored as a system backup:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// VerifySystemFiles checks that the files of a system backup below targetDir are
+// owned by root and have modes sshd accepts. Files that were not restored, for
+// example because of --files, are skipped.
+func VerifySystemFiles(backup *BackupData, targetDir string) error {
+	var problems []string
+
+	check := func(name, path string, keyInfo *analyzer.KeyInfo, isDir bool) {
+		info, err := os.Lstat(path)
+		if os.IsNotExist(err) && !isDir {
+			return // Filtered out of the restore
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+			return
+		}
+		if uid, gid, ok := fileOwner(info); ok && (uid != 0 || gid != 0) {
+			problems = append(problems, fmt.Sprintf("%s is owned by %d:%d, not root", name, uid, gid))
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return
+		}
+		if isDir {
+			if info.Mode().Perm()&0022 != 0 {
+				problems = append(problems, fmt.Sprintf("directory %s has mode %04o; it must not be writable by group or others", name, info.Mode().Perm()))
+			}
+			return
+		}
+		if err := SystemModeProblem(name, info.Mode(), keyInfo); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	check(targetDir, targetDir, nil, true)
+	for dir := range backup.Directories {
+		check(dir, filepath.Join(targetDir, filepath.FromSlash(dir)), nil, true)
+	}
+	for _, filename := range backup.FileNames() {
+		check(filename, filepath.Join(targetDir, filepath.FromSlash(filename)), backup.Files[filename].KeyInfo, false)
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("found %d problems with restored system files:\n  %s", len(problems), strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// isPrivateHostKey reports whether a file is an sshd private host key. Backups
+// taken before host key detection are recognised by name.
+func isPrivateHostKey(filename string, keyInfo *analyzer.KeyInfo) bool {
+	if keyInfo != nil && keyInfo.Purpose == analyzer.PurposeHost {
+		return keyInfo.Type == analyzer.KeyTypePrivate
+	}
+	base := filepath.Base(filename)
+	return strings.HasPrefix(base, "ssh_host_") && strings.HasSuffix(base, "_key")
+}
+
+// isSSHDFile reports whether a file is a host public key, certificate or sshd configuration
+func isSSHDFile(filename string, keyInfo *analyzer.KeyInfo) bool {
+	if keyInfo != nil && keyInfo.Purpose == analyzer.PurposeHost {
+		return true
+	}
+	return strings.HasPrefix(filepath.Base(filename), "ssh_host_") ||
+		filename == "sshd_config" || strings.HasPrefix(filename, "sshd_config.d/")
+}
+
+// fileOwner returns the numeric owner of a file where the platform records one
+func fileOwner(info os.FileInfo) (uid, gid int, ok bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(stat.Uid), int(stat.Gid), true
+}
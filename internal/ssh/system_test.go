@@ -0,0 +1,104 @@
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rzago/ssh-secret-keeper/internal/analyzer"
+)
+
+func TestSystemModeProblem(t *testing.T) {
+	hostKey := &analyzer.KeyInfo{Type: analyzer.KeyTypePrivate, Purpose: analyzer.PurposeHost}
+	hostPub := &analyzer.KeyInfo{Type: analyzer.KeyTypePublic, Purpose: analyzer.PurposeHost}
+
+	tests := []struct {
+		filename string
+		mode     os.FileMode
+		keyInfo  *analyzer.KeyInfo
+		wantErr  bool
+	}{
+		{"ssh_host_ed25519_key", 0600, hostKey, false},
+		{"ssh_host_ed25519_key", 0640, hostKey, true},
+		{"ssh_host_ed25519_key", 0644, nil, true}, // Recognised by name without key info
+		{"ssh_host_ed25519_key.pub", 0644, hostPub, false},
+		{"ssh_host_ed25519_key.pub", 0664, hostPub, true},
+		{"sshd_config", 0600, nil, false},
+		{"sshd_config.d/50-hardening.conf", 0755, nil, true},
+		{"moduli", 0644, nil, false},
+		{"ssh_config", 0666, nil, true},
+	}
+
+	for _, tt := range tests {
+		err := SystemModeProblem(tt.filename, tt.mode, tt.keyInfo)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SystemModeProblem(%s, %04o) error = %v, wantErr %v", tt.filename, tt.mode, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCheckSystemPermissions(t *testing.T) {
+	backup := &BackupData{
+		Files: map[string]*FileData{
+			"ssh_host_rsa_key":     {Filename: "ssh_host_rsa_key", Permissions: 0644},
+			"ssh_host_rsa_key.pub": {Filename: "ssh_host_rsa_key.pub", Permissions: 0644},
+			"sshd_config":          {Filename: "sshd_config", Permissions: 0644},
+		},
+		Directories: map[string]os.FileMode{"sshd_config.d": 0777},
+	}
+
+	err := CheckSystemPermissions(backup)
+	if err == nil {
+		t.Fatal("CheckSystemPermissions() should reject a readable private host key")
+	}
+	for _, want := range []string{"ssh_host_rsa_key has mode 0644", "sshd_config.d has mode 0777"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+
+	backup.Files["ssh_host_rsa_key"].Permissions = 0600
+	backup.Directories["sshd_config.d"] = 0755
+	if err := CheckSystemPermissions(backup); err != nil {
+		t.Errorf("CheckSystemPermissions() error = %v", err)
+	}
+}
+
+func TestVerifySystemFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Chmod(tmpDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	backup := &BackupData{
+		Files: map[string]*FileData{
+			"ssh_host_ed25519_key": {Filename: "ssh_host_ed25519_key", Permissions: 0600},
+			"not_restored":         {Filename: "not_restored", Permissions: 0644},
+		},
+	}
+
+	keyPath := filepath.Join(tmpDir, "ssh_host_ed25519_key")
+	if err := os.WriteFile(keyPath, []byte("key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if os.Geteuid() == 0 {
+		if err := VerifySystemFiles(backup, tmpDir); err != nil {
+			t.Errorf("VerifySystemFiles() error = %v", err)
+		}
+		if err := os.Chown(keyPath, 1000, 1000); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := VerifySystemFiles(backup, tmpDir); err == nil || !strings.Contains(err.Error(), "not root") {
+		t.Errorf("VerifySystemFiles() error = %v, want ownership problem", err)
+	}
+
+	if err := os.Chmod(keyPath, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySystemFiles(backup, tmpDir); err == nil || !strings.Contains(err.Error(), "ssh_host_ed25519_key has mode 0644") {
+		t.Errorf("VerifySystemFiles() error = %v, want mode problem", err)
+	}
+}
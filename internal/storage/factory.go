@@ -42,3 +42,17 @@ func (f *Factory) CreateStorage(cfg *config.Config) (interfaces.StorageProvider,
 		return nil, fmt.Errorf("unsupported storage provider: %s", cfg.Storage.Provider)
 	}
 }
+
+// CreateSystemStorage creates the storage provider for host-level backups taken
+// with --system, stored under systems/<hostname> whatever storage strategy is
+// configured
+func (f *Factory) CreateSystemStorage(cfg *config.Config) (interfaces.StorageProvider, error) {
+	if cfg.Storage.Provider != "" && cfg.Storage.Provider != "vault" {
+		return nil, fmt.Errorf("--system is only supported with the vault storage provider, not %s", cfg.Storage.Provider)
+	}
+	if cfg.Vault.Address == "" {
+		return nil, fmt.Errorf("vault address not configured - set VAULT_ADDR environment variable or configure vault.address")
+	}
+
+	return NewSystemVaultProvider(&cfg.Vault)
+}
@@ -24,6 +24,19 @@ func NewVaultProvider(cfg *config.VaultConfig) (*VaultProvider, error) {
 	}, nil
 }
 
+// NewSystemVaultProvider creates a Vault provider for host-level backups taken
+// with --system, stored under systems/<hostname>
+func NewSystemVaultProvider(cfg *config.VaultConfig) (*VaultProvider, error) {
+	service, err := vault.NewSystemStorageService(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vault storage service: %w", err)
+	}
+
+	return &VaultProvider{
+		service: service,
+	}, nil
+}
+
 func (v *VaultProvider) TestConnection(ctx context.Context) error {
 	return v.service.TestConnection(ctx)
 }
@@ -49,6 +62,22 @@ func (v *VaultProvider) DeleteBackup(ctx context.Context, backupName string) err
 	return v.service.DeleteBackup(ctx, backupName)
 }
 
+func (v *VaultProvider) StoreBlob(ctx context.Context, hash string, data map[string]interface{}) error {
+	return v.service.StoreBlob(ctx, hash, data)
+}
+
+func (v *VaultProvider) GetBlob(ctx context.Context, hash string) (map[string]interface{}, error) {
+	return v.service.GetBlob(ctx, hash)
+}
+
+func (v *VaultProvider) ListBlobs(ctx context.Context) ([]string, error) {
+	return v.service.ListBlobs(ctx)
+}
+
+func (v *VaultProvider) DeleteBlob(ctx context.Context, hash string) error {
+	return v.service.DeleteBlob(ctx, hash)
+}
+
 func (v *VaultProvider) StoreMetadata(ctx context.Context, metadata map[string]interface{}) error {
 	return v.service.StoreMetadata(ctx, metadata)
 }
@@ -57,6 +86,10 @@ func (v *VaultProvider) GetMetadata(ctx context.Context) (map[string]interface{}
 	return v.service.GetMetadata(ctx)
 }
 
+func (v *VaultProvider) UpdateMetadata(ctx context.Context, update func(metadata map[string]interface{}) error) error {
+	return v.service.UpdateMetadata(ctx, update)
+}
+
 func (v *VaultProvider) GetProviderType() string {
 	return "vault"
 }
@@ -0,0 +1,407 @@
+// Package undo saves the files a restore is about to replace so the restore
+// can be rolled back, automatically when it fails or later with restore --undo.
+package undo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/rzago/ssh-secret-keeper/internal/utils"
+)
+
+const (
+	manifestFile = "manifest.json"
+	filesDir     = "files"
+
+	// Keep is the number of restores kept for restore --undo
+	Keep = 5
+
+	// areaMode keeps saved private keys readable by their owner only
+	areaMode os.FileMode = 0700
+)
+
+// ErrNothingToUndo is returned when the undo area holds no restore
+var ErrNothingToUndo = errors.New("no restore to undo")
+
+// DefaultDir is the undo area of the current user, ~/.ssh-secret-keeper/undo
+func DefaultDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".ssh-secret-keeper", "undo"), nil
+}
+
+// Entry records the state of one path before the restore changed it
+type Entry struct {
+	Path       string      `json:"path"`
+	Existed    bool        `json:"existed"`
+	Mode       os.FileMode `json:"mode,omitempty"`
+	LinkTarget string      `json:"link_target,omitempty"`
+	UID        int         `json:"uid"`
+	GID        int         `json:"gid"`
+	Snapshot   string      `json:"snapshot,omitempty"` // Saved content under files/
+}
+
+// manifest describes a transaction; it is rewritten after every change so an
+// interrupted restore can still be undone
+type manifest struct {
+	Backup      string    `json:"backup"`
+	Timestamp   time.Time `json:"timestamp"`
+	Completed   bool      `json:"completed"`
+	Entries     []Entry   `json:"entries"`
+	Directories []string  `json:"directories,omitempty"` // Created by the restore
+	// DirectoryModes are existing directories whose mode and owner the
+	// restore may change
+	DirectoryModes []Entry `json:"directory_modes,omitempty"`
+}
+
+// Transaction is one restore's snapshot in a timestamped directory of the undo
+// area. Methods on a nil Transaction do nothing, so dry runs can pass none.
+type Transaction struct {
+	dir      string
+	manifest manifest
+	saved    map[string]bool
+}
+
+// Begin starts a transaction for restoring backupName in the undo area root
+func Begin(root, backupName string) (*Transaction, error) {
+	if err := os.MkdirAll(root, areaMode); err != nil {
+		return nil, fmt.Errorf("cannot create undo area: %w", err)
+	}
+	if err := os.Chmod(root, areaMode); err != nil {
+		return nil, fmt.Errorf("cannot secure undo area: %w", err)
+	}
+
+	now := time.Now()
+	dir := filepath.Join(root, now.UTC().Format("20060102T150405.000000000Z"))
+	if err := os.Mkdir(dir, areaMode); err != nil {
+		return nil, fmt.Errorf("cannot create undo snapshot: %w", err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, filesDir), areaMode); err != nil {
+		return nil, fmt.Errorf("cannot create undo snapshot: %w", err)
+	}
+
+	t := &Transaction{
+		dir:      dir,
+		manifest: manifest{Backup: backupName, Timestamp: now},
+		saved:    make(map[string]bool),
+	}
+	if err := t.writeManifest(); err != nil {
+		return nil, err
+	}
+
+	log.Debug().Str("dir", dir).Str("backup", backupName).Msg("Started restore transaction")
+	return t, nil
+}
+
+// Latest opens the most recent transaction in the undo area, including one
+// left behind by an interrupted restore
+func Latest(root string) (*Transaction, error) {
+	dirs, err := transactionDirs(root)
+	if err != nil {
+		return nil, err
+	}
+	if len(dirs) == 0 {
+		return nil, ErrNothingToUndo
+	}
+	return open(dirs[len(dirs)-1])
+}
+
+func open(dir string) (*Transaction, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read undo snapshot: %w", err)
+	}
+	t := &Transaction{dir: dir, saved: make(map[string]bool)}
+	if err := json.Unmarshal(data, &t.manifest); err != nil {
+		return nil, fmt.Errorf("invalid undo snapshot %s: %w", dir, err)
+	}
+	for _, entry := range t.manifest.Entries {
+		t.saved[entry.Path] = true
+	}
+	for _, entry := range t.manifest.DirectoryModes {
+		t.saved[entry.Path] = true
+	}
+	return t, nil
+}
+
+// Backup is the name of the restored backup
+func (t *Transaction) Backup() string { return t.manifest.Backup }
+
+// Timestamp is when the restore started
+func (t *Transaction) Timestamp() time.Time { return t.manifest.Timestamp }
+
+// Completed reports whether the restore finished
+func (t *Transaction) Completed() bool { return t.manifest.Completed }
+
+// Entries lists the paths the restore changed, in order
+func (t *Transaction) Entries() []Entry { return t.manifest.Entries }
+
+// Save records path before it is written. Existing files and symlinks are
+// copied into the undo area; paths that do not exist yet are removed on
+// rollback. Only the first save of a path counts.
+func (t *Transaction) Save(path string) error {
+	if t == nil || t.saved[path] {
+		return nil
+	}
+
+	entry := Entry{Path: path}
+	info, err := os.Lstat(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return fmt.Errorf("cannot inspect %s: %w", path, err)
+	case info.IsDir():
+		return fmt.Errorf("%s is a directory", path)
+	default:
+		entry.Existed = true
+		entry.Mode = info.Mode().Perm()
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			entry.UID, entry.GID = int(stat.Uid), int(stat.Gid)
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			if entry.LinkTarget, err = os.Readlink(path); err != nil {
+				return fmt.Errorf("cannot read symlink %s: %w", path, err)
+			}
+			break
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("cannot save %s: %w", path, err)
+		}
+		entry.Snapshot = strconv.Itoa(len(t.manifest.Entries))
+		if err := os.WriteFile(filepath.Join(t.dir, filesDir, entry.Snapshot), content, 0600); err != nil {
+			return fmt.Errorf("cannot save %s: %w", path, err)
+		}
+	}
+
+	t.saved[path] = true
+	t.manifest.Entries = append(t.manifest.Entries, entry)
+	return t.writeManifest()
+}
+
+// SaveDirectory records a directory before the restore creates it or changes
+// its mode or owner. A created directory is removed on rollback when empty;
+// an existing one gets its mode and owner back. Only the first save counts.
+func (t *Transaction) SaveDirectory(path string) error {
+	if t == nil || t.saved[path] {
+		return nil
+	}
+
+	info, err := os.Lstat(path)
+	switch {
+	case os.IsNotExist(err):
+		t.manifest.Directories = append(t.manifest.Directories, path)
+	case err != nil:
+		return fmt.Errorf("cannot inspect %s: %w", path, err)
+	case !info.IsDir():
+		return fmt.Errorf("%s is not a directory", path)
+	default:
+		t.manifest.DirectoryModes = append(t.manifest.DirectoryModes, directoryEntry(path, info))
+	}
+
+	t.saved[path] = true
+	return t.writeManifest()
+}
+
+// directoryEntry records the mode and owner of an existing directory
+func directoryEntry(path string, info os.FileInfo) Entry {
+	entry := Entry{Path: path, Existed: true, Mode: info.Mode().Perm()}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		entry.UID, entry.GID = int(stat.Uid), int(stat.Gid)
+	}
+	return entry
+}
+
+// Commit marks the restore as finished and removes the oldest transactions
+// beyond Keep. A restore that changed nothing is discarded instead, so
+// restore --undo still reverts the last one that did.
+func (t *Transaction) Commit() error {
+	if t == nil {
+		return nil
+	}
+	if !t.changed() {
+		log.Debug().Str("dir", t.dir).Msg("Restore changed nothing, discarding its transaction")
+		if err := os.RemoveAll(t.dir); err != nil {
+			return fmt.Errorf("cannot remove undo snapshot: %w", err)
+		}
+		return nil
+	}
+	t.manifest.Completed = true
+	if err := t.writeManifest(); err != nil {
+		return err
+	}
+
+	dirs, err := transactionDirs(filepath.Dir(t.dir))
+	if err != nil {
+		return err
+	}
+	for len(dirs) > Keep {
+		if err := os.RemoveAll(dirs[0]); err != nil {
+			log.Warn().Err(err).Str("dir", dirs[0]).Msg("Failed to remove old undo snapshot")
+		}
+		dirs = dirs[1:]
+	}
+	return nil
+}
+
+// Rollback puts every saved path back as it was, removes files and empty
+// directories the restore created, gives existing directories their saved
+// mode and owner back, and deletes the transaction. It returns
+// the paths it reverted.
+func (t *Transaction) Rollback() ([]string, error) {
+	if t == nil {
+		return nil, nil
+	}
+
+	var reverted []string
+	var failed []error
+	for i := len(t.manifest.Entries) - 1; i >= 0; i-- {
+		entry := t.manifest.Entries[i]
+		if err := t.revert(entry); err != nil {
+			failed = append(failed, err)
+			continue
+		}
+		reverted = append(reverted, entry.Path)
+	}
+
+	// Deepest first, so parents are empty by the time they are removed
+	dirs := append([]string(nil), t.manifest.Directories...)
+	sort.Slice(dirs, func(i, j int) bool { return len(dirs[i]) > len(dirs[j]) })
+	for _, dir := range dirs {
+		if err := os.Remove(dir); err != nil && !os.IsNotExist(err) {
+			log.Debug().Err(err).Str("dir", dir).Msg("Keeping directory created by restore")
+		}
+	}
+
+	// Deepest first too, so restrictive parents never block their children
+	modes := append([]Entry(nil), t.manifest.DirectoryModes...)
+	sort.Slice(modes, func(i, j int) bool { return len(modes[i].Path) > len(modes[j].Path) })
+	for _, entry := range modes {
+		if err := revertDirectory(entry); err != nil {
+			failed = append(failed, err)
+		}
+	}
+
+	if len(failed) > 0 {
+		// Keep the snapshot so the remaining files can be recovered by hand
+		return reverted, fmt.Errorf("rollback incomplete, snapshot kept in %s: %w", t.dir, errors.Join(failed...))
+	}
+	if err := os.RemoveAll(t.dir); err != nil {
+		log.Warn().Err(err).Str("dir", t.dir).Msg("Failed to remove undo snapshot")
+	}
+	return reverted, nil
+}
+
+// revert restores one path to its saved state
+func (t *Transaction) revert(entry Entry) error {
+	if !entry.Existed {
+		if err := os.Remove(entry.Path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("cannot remove %s: %w", entry.Path, err)
+		}
+		return nil
+	}
+
+	if entry.LinkTarget != "" {
+		if err := os.Remove(entry.Path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("cannot remove %s: %w", entry.Path, err)
+		}
+		if err := os.Symlink(entry.LinkTarget, entry.Path); err != nil {
+			return fmt.Errorf("cannot restore symlink %s: %w", entry.Path, err)
+		}
+	} else {
+		content, err := os.ReadFile(filepath.Join(t.dir, filesDir, entry.Snapshot))
+		if err != nil {
+			return fmt.Errorf("cannot read saved copy of %s: %w", entry.Path, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(entry.Path), areaMode); err != nil {
+			return fmt.Errorf("cannot recreate directory of %s: %w", entry.Path, err)
+		}
+		if err := utils.WriteFileAtomic(entry.Path, content, entry.Mode); err != nil {
+			return fmt.Errorf("cannot restore %s: %w", entry.Path, err)
+		}
+	}
+
+	// Only root can give files back to another owner
+	if os.Geteuid() == 0 {
+		if err := os.Lchown(entry.Path, entry.UID, entry.GID); err != nil {
+			return fmt.Errorf("cannot restore ownership of %s: %w", entry.Path, err)
+		}
+	}
+	return nil
+}
+
+// revertDirectory gives an existing directory its saved mode and owner back
+func revertDirectory(entry Entry) error {
+	if err := os.Chmod(entry.Path, entry.Mode); err != nil {
+		return fmt.Errorf("cannot restore mode of %s: %w", entry.Path, err)
+	}
+	if os.Geteuid() == 0 {
+		if err := os.Lchown(entry.Path, entry.UID, entry.GID); err != nil {
+			return fmt.Errorf("cannot restore ownership of %s: %w", entry.Path, err)
+		}
+	}
+	return nil
+}
+
+// changed reports whether the restore wrote a file, created a directory or
+// changed the mode or owner of an existing one
+func (t *Transaction) changed() bool {
+	if len(t.manifest.Entries) > 0 || len(t.manifest.Directories) > 0 {
+		return true
+	}
+	for _, saved := range t.manifest.DirectoryModes {
+		info, err := os.Lstat(saved.Path)
+		if err != nil || directoryEntry(saved.Path, info) != saved {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Transaction) writeManifest() error {
+	data, err := json.MarshalIndent(t.manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot encode undo snapshot: %w", err)
+	}
+	if err := utils.WriteFileAtomic(filepath.Join(t.dir, manifestFile), data, 0600); err != nil {
+		return fmt.Errorf("cannot write undo snapshot: %w", err)
+	}
+	return nil
+}
+
+// transactionDirs lists the transactions in root, oldest first; their names
+// are UTC timestamps, which sort chronologically
+func transactionDirs(root string) ([]string, error) {
+	entries, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read undo area: %w", err)
+	}
+
+	var dirs []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(root, entry.Name())
+		if _, err := os.Stat(filepath.Join(dir, manifestFile)); err == nil {
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
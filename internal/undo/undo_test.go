@@ -0,0 +1,243 @@
+package undo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile(%s) error = %v", path, err)
+	}
+	return string(content)
+}
+
+func TestTransaction_Rollback(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "undo")
+	target := t.TempDir()
+
+	existing := filepath.Join(target, "id_ed25519")
+	if err := os.WriteFile(existing, []byte("old key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(target, "current")
+	if err := os.Symlink("id_ed25519", link); err != nil {
+		t.Fatal(err)
+	}
+	newDir := filepath.Join(target, "config.d")
+	newFile := filepath.Join(newDir, "work")
+
+	tx, err := Begin(root, "laptop-1")
+	if err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	if info, _ := os.Stat(root); info.Mode().Perm() != 0700 {
+		t.Errorf("undo area mode = %04o, want 0700", info.Mode().Perm())
+	}
+
+	// Simulate a restore
+	for _, path := range []string{existing, link, newFile, existing} {
+		if err := tx.Save(path); err != nil {
+			t.Fatalf("Save(%s) error = %v", path, err)
+		}
+	}
+	if err := tx.SaveDirectory(newDir); err != nil {
+		t.Fatalf("SaveDirectory() error = %v", err)
+	}
+	os.WriteFile(existing, []byte("new key"), 0644)
+	os.Remove(link)
+	os.Symlink("elsewhere", link)
+	os.Mkdir(newDir, 0700)
+	os.WriteFile(newFile, []byte("Host work\n"), 0600)
+
+	if len(tx.Entries()) != 3 {
+		t.Errorf("Entries() = %d, want 3 (repeated saves ignored)", len(tx.Entries()))
+	}
+
+	reverted, err := tx.Rollback()
+	if err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	if len(reverted) != 3 {
+		t.Errorf("reverted %v, want 3 paths", reverted)
+	}
+
+	if got := readFile(t, existing); got != "old key" {
+		t.Errorf("key content = %q, want the saved one", got)
+	}
+	if info, _ := os.Stat(existing); info.Mode().Perm() != 0600 {
+		t.Errorf("key mode = %04o, want 0600", info.Mode().Perm())
+	}
+	if got, _ := os.Readlink(link); got != "id_ed25519" {
+		t.Errorf("symlink target = %q, want id_ed25519", got)
+	}
+	if _, err := os.Lstat(newDir); !os.IsNotExist(err) {
+		t.Errorf("created directory should be removed, got %v", err)
+	}
+	if _, err := Latest(root); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("rolled back transaction should be gone, Latest() error = %v", err)
+	}
+}
+
+func TestLatest(t *testing.T) {
+	root := t.TempDir()
+	target := filepath.Join(t.TempDir(), "known_hosts")
+
+	if _, err := Latest(filepath.Join(root, "missing")); !errors.Is(err, ErrNothingToUndo) {
+		t.Fatalf("Latest() on an empty area error = %v", err)
+	}
+
+	for i, backup := range []string{"first", "second"} {
+		tx, err := Begin(root, backup)
+		if err != nil {
+			t.Fatalf("Begin() error = %v", err)
+		}
+		if i == 0 {
+			if err := tx.Commit(); err != nil {
+				t.Fatalf("Commit() error = %v", err)
+			}
+			time.Sleep(time.Millisecond)
+			continue
+		}
+		// The second restore is interrupted before committing
+		if err := tx.Save(target); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+
+	latest, err := Latest(root)
+	if err != nil {
+		t.Fatalf("Latest() error = %v", err)
+	}
+	if latest.Backup() != "second" || latest.Completed() {
+		t.Errorf("Latest() = %s (completed %v), want the interrupted second restore", latest.Backup(), latest.Completed())
+	}
+	if entries := latest.Entries(); len(entries) != 1 || entries[0].Path != target || entries[0].Existed {
+		t.Errorf("Entries() = %+v", entries)
+	}
+}
+
+func TestCommit_KeepsRecentTransactions(t *testing.T) {
+	root := t.TempDir()
+	target := filepath.Join(t.TempDir(), "known_hosts")
+
+	for i := 0; i < Keep+2; i++ {
+		tx, err := Begin(root, "backup")
+		if err != nil {
+			t.Fatalf("Begin() error = %v", err)
+		}
+		if err := tx.Save(target); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Commit() error = %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	dirs, err := transactionDirs(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) != Keep {
+		t.Errorf("kept %d transactions, want %d", len(dirs), Keep)
+	}
+}
+
+func TestCommit_DiscardsUnchangedRestore(t *testing.T) {
+	root := t.TempDir()
+	target := t.TempDir()
+
+	first, err := Begin(root, "first")
+	if err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	if err := first.Save(filepath.Join(target, "id_ed25519")); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := first.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	time.Sleep(time.Millisecond)
+
+	// A restore that only saw an existing directory, unchanged, is not kept
+	second, err := Begin(root, "second")
+	if err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	if err := second.SaveDirectory(target); err != nil {
+		t.Fatalf("SaveDirectory() error = %v", err)
+	}
+	if err := second.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	latest, err := Latest(root)
+	if err != nil {
+		t.Fatalf("Latest() error = %v", err)
+	}
+	if latest.Backup() != "first" {
+		t.Errorf("Latest() = %s, want the first restore, which changed files", latest.Backup())
+	}
+}
+
+func TestRollback_DirectoryModes(t *testing.T) {
+	root := t.TempDir()
+	target := t.TempDir()
+	sub := filepath.Join(target, "config.d")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(target, 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := Begin(root, "laptop-1")
+	if err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	for _, dir := range []string{target, sub, target} {
+		if err := tx.SaveDirectory(dir); err != nil {
+			t.Fatalf("SaveDirectory(%s) error = %v", dir, err)
+		}
+	}
+	os.Chmod(sub, 0700)
+	os.Chmod(target, 0700)
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	latest, err := Latest(root)
+	if err != nil {
+		t.Fatalf("Latest() error = %v, want the committed mode changes", err)
+	}
+	if _, err := latest.Rollback(); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	for dir, want := range map[string]os.FileMode{target: 0750, sub: 0755} {
+		if info, _ := os.Stat(dir); info.Mode().Perm() != want {
+			t.Errorf("%s mode = %04o, want %04o", dir, info.Mode().Perm(), want)
+		}
+	}
+}
+
+func TestNilTransaction(t *testing.T) {
+	var tx *Transaction
+	if err := tx.Save("/nonexistent"); err != nil {
+		t.Errorf("Save() on nil = %v", err)
+	}
+	if err := tx.SaveDirectory("/nonexistent"); err != nil {
+		t.Errorf("SaveDirectory() on nil = %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Errorf("Commit() on nil = %v", err)
+	}
+	if _, err := tx.Rollback(); err != nil {
+		t.Errorf("Rollback() on nil = %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// WriteFileAtomic writes content to a temporary file next to path and renames
+// it into place, so readers see either the old or the new file and an
+// interrupted write never leaves a truncated one. An existing file or symlink
+// at path is replaced; the result has mode perm and belongs to the caller.
+func WriteFileAtomic(path string, content []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("cannot create temporary file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // No-op once renamed
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("cannot write temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("cannot write temporary file: %w", err)
+	}
+	if err := os.Chmod(tmpPath, perm.Perm()); err != nil {
+		return fmt.Errorf("cannot set permissions: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("cannot replace %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
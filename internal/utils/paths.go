@@ -153,21 +153,22 @@ func (p *PathNormalizer) normalizeWindowsPath(absolutePath string) (string, erro
 
 // ResolvePath converts relative paths to absolute paths for current user
 func (p *PathNormalizer) ResolvePath(relativePath string) (string, error) {
-	if strings.HasPrefix(relativePath, "~/") {
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			return "", fmt.Errorf("cannot resolve home directory: %w", err)
-		}
-		return filepath.Join(homeDir, relativePath[2:]), nil
-	}
+	return p.ResolvePathWithHome(relativePath, "")
+}
 
-	// Handle just ~ (home directory)
-	if relativePath == "~" {
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			return "", fmt.Errorf("cannot resolve home directory: %w", err)
+// ResolvePathWithHome converts relative paths to absolute paths, expanding ~ to
+// homeDir, such as another user's home when restoring for them. An empty
+// homeDir means the current user's home.
+func (p *PathNormalizer) ResolvePathWithHome(relativePath, homeDir string) (string, error) {
+	if strings.HasPrefix(relativePath, "~/") || relativePath == "~" {
+		if homeDir == "" {
+			var err error
+			homeDir, err = os.UserHomeDir()
+			if err != nil {
+				return "", fmt.Errorf("cannot resolve home directory: %w", err)
+			}
 		}
-		return homeDir, nil
+		return filepath.Join(homeDir, strings.TrimPrefix(relativePath[1:], "/")), nil
 	}
 
 	// Already absolute or relative to current directory
@@ -201,6 +202,21 @@ func (p *PathNormalizer) IsRelativePath(path string) bool {
 	return strings.HasPrefix(path, "~/") || path == "~"
 }
 
+// SafeJoin joins a slash-separated relative path from a backup onto root and
+// rejects absolute paths and paths that would escape root
+func SafeJoin(root, relPath string) (string, error) {
+	if relPath == "" || strings.HasPrefix(relPath, "/") || filepath.IsAbs(relPath) {
+		return "", fmt.Errorf("invalid relative path: %q", relPath)
+	}
+
+	cleaned := filepath.Clean(filepath.FromSlash(relPath))
+	if cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path escapes target directory: %q", relPath)
+	}
+
+	return filepath.Join(root, cleaned), nil
+}
+
 // SanitizePathComponent removes or replaces characters that could cause issues in paths
 func SanitizePathComponent(component string) string {
 	// Replace problematic characters with underscores
@@ -153,6 +153,30 @@ func TestPathNormalizer_ResolvePath(t *testing.T) {
 	}
 }
 
+func TestPathNormalizer_ResolvePathWithHome(t *testing.T) {
+	normalizer := NewPathNormalizer()
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"~/.ssh", "/home/alice/.ssh"},
+		{"~", "/home/alice"},
+		{"/etc/ssh", "/etc/ssh"},
+	}
+
+	for _, tt := range tests {
+		got, err := normalizer.ResolvePathWithHome(tt.input, "/home/alice")
+		if err != nil {
+			t.Errorf("ResolvePathWithHome(%q) unexpected error: %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ResolvePathWithHome(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
 func TestPathNormalizer_DetectHomeDirectoryPattern(t *testing.T) {
 	normalizer := NewPathNormalizer()
 
@@ -441,3 +465,31 @@ func BenchmarkSanitizePathComponent(b *testing.B) {
 		_ = SanitizePathComponent(testComponent)
 	}
 }
+
+func TestSafeJoin(t *testing.T) {
+	tests := []struct {
+		relPath string
+		want    string
+		wantErr bool
+	}{
+		{"id_rsa", "/home/alice/.ssh/id_rsa", false},
+		{"config.d/work", "/home/alice/.ssh/config.d/work", false},
+		{"keys/../id_rsa", "/home/alice/.ssh/id_rsa", false},
+		{"../authorized_keys", "", true},
+		{"keys/../../etc/passwd", "", true},
+		{"/etc/passwd", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.relPath, func(t *testing.T) {
+			got, err := SafeJoin("/home/alice/.ssh", tt.relPath)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SafeJoin(%q) error = %v, wantErr %v", tt.relPath, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("SafeJoin(%q) = %q, want %q", tt.relPath, got, tt.want)
+			}
+		})
+	}
+}
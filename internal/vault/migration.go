@@ -55,11 +55,59 @@ func NewMigrationService(cfg *config.VaultConfig, fromStrategy, toStrategy Stora
 func (m *MigrationService) ListBackupsToMigrate(ctx context.Context) ([]string, error) {
 	sourcePath := fmt.Sprintf("%s/metadata/%s/backups", m.mountPath, m.fromPath)
 
-	secret, err := m.client.Logical().ListWithContext(ctx, sourcePath)
+	backups, err := m.listKeys(ctx, sourcePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list source backups: %w", err)
 	}
 
+	return backups, nil
+}
+
+// ListBlobsToMigrate lists all deduplicated file content blobs in the source location
+func (m *MigrationService) ListBlobsToMigrate(ctx context.Context) ([]string, error) {
+	sourcePath := fmt.Sprintf("%s/metadata/%s/blobs", m.mountPath, m.fromPath)
+
+	blobs, err := m.listKeys(ctx, sourcePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list source blobs: %w", err)
+	}
+
+	return blobs, nil
+}
+
+// MigrateBlob copies a content blob to the destination. Blobs are immutable and
+// shared between backups, so they are copied as-is.
+func (m *MigrationService) MigrateBlob(ctx context.Context, hash string) error {
+	sourcePath := fmt.Sprintf("%s/data/%s/blobs/%s", m.mountPath, m.fromPath, hash)
+	secret, err := m.client.Logical().ReadWithContext(ctx, sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to read source blob %s: %w", hash, err)
+	}
+
+	if secret == nil {
+		return fmt.Errorf("blob %s not found at source location", hash)
+	}
+
+	data, ok := secret.Data["data"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("invalid blob data format for %s", hash)
+	}
+
+	destPath := fmt.Sprintf("%s/data/%s/blobs/%s", m.mountPath, m.toPath, hash)
+	if _, err := m.client.Logical().WriteWithContext(ctx, destPath, map[string]interface{}{"data": data}); err != nil {
+		return fmt.Errorf("failed to write blob %s to destination: %w", hash, err)
+	}
+
+	return nil
+}
+
+// listKeys lists the keys under a KV v2 metadata path
+func (m *MigrationService) listKeys(ctx context.Context, path string) ([]string, error) {
+	secret, err := m.client.Logical().ListWithContext(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
 	if secret == nil {
 		return []string{}, nil
 	}
@@ -69,14 +117,14 @@ func (m *MigrationService) ListBackupsToMigrate(ctx context.Context) ([]string,
 		return []string{}, nil
 	}
 
-	var backups []string
+	var result []string
 	for _, key := range keys {
 		if keyStr, ok := key.(string); ok {
-			backups = append(backups, keyStr)
+			result = append(result, keyStr)
 		}
 	}
 
-	return backups, nil
+	return result, nil
 }
 
 // MigrateBackup migrates a single backup from source to destination
@@ -171,6 +219,26 @@ func (m *MigrationService) MigrateAllBackups(ctx context.Context, dryRun bool) (
 		Bool("dry_run", dryRun).
 		Msg("Starting migration of all backups")
 
+	// Deduplicated backups reference shared content blobs, which must exist at
+	// the destination before any backup referencing them is migrated
+	blobs, err := m.ListBlobsToMigrate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if dryRun {
+		result.BlobsToMigrate = len(blobs)
+		log.Info().
+			Int("blobs", len(blobs)).
+			Msg("[DRY RUN] Would migrate shared file contents")
+	} else {
+		for _, hash := range blobs {
+			if err := m.MigrateBlob(ctx, hash); err != nil {
+				return nil, fmt.Errorf("failed to migrate file contents: %w", err)
+			}
+			result.MigratedBlobs++
+		}
+	}
+
 	for _, backupName := range backups {
 		if dryRun {
 			log.Info().
@@ -322,6 +390,8 @@ type MigrationResult struct {
 	TotalBackups    int
 	MigratedBackups []string
 	FailedBackups   []string
+	MigratedBlobs   int
+	BlobsToMigrate  int // Shared file contents a dry run would copy
 	DryRun          bool
 	StartTime       time.Time
 	EndTime         time.Time
@@ -347,6 +417,12 @@ func (r *MigrationResult) GetMigrationSummary() string {
 	summary.WriteString(fmt.Sprintf("  Total backups: %d\n", r.TotalBackups))
 	summary.WriteString(fmt.Sprintf("  Successfully migrated: %d\n", len(r.MigratedBackups)))
 	summary.WriteString(fmt.Sprintf("  Failed: %d\n", len(r.FailedBackups)))
+	if r.MigratedBlobs > 0 {
+		summary.WriteString(fmt.Sprintf("  Shared file contents copied: %d\n", r.MigratedBlobs))
+	}
+	if r.BlobsToMigrate > 0 {
+		summary.WriteString(fmt.Sprintf("  Shared file contents that would be copied: %d\n", r.BlobsToMigrate))
+	}
 	summary.WriteString(fmt.Sprintf("  Duration: %v\n", r.Duration))
 
 	if r.DryRun {
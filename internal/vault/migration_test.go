@@ -1,9 +1,13 @@
 package vault
 
 import (
+	"context"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/hashicorp/vault/api"
 	"github.com/rzago/ssh-secret-keeper/internal/config"
 )
 
@@ -331,3 +335,45 @@ func BenchmarkGetMigrationInfo(b *testing.B) {
 		_ = GetMigrationInfo(StrategyMachineUser, StrategyUniversal, "users/host-user", "shared")
 	}
 }
+
+func TestMigrationService_MigrateAllBackups_DryRun(t *testing.T) {
+	kv := &fakeKVEngine{secrets: map[string]map[string]interface{}{
+		"users/host-alice/backups/laptop-1": {"hostname": "laptop"},
+		"users/host-alice/blobs/aaa":        {"content": "YQ=="},
+		"users/host-alice/blobs/bbb":        {"content": "Yg=="},
+	}}
+	server := httptest.NewServer(kv)
+	defer server.Close()
+
+	client, err := api.NewClient(&api.Config{Address: server.URL})
+	if err != nil {
+		t.Fatalf("api.NewClient() error = %v", err)
+	}
+	client.SetToken("test-token")
+	service := &MigrationService{
+		client:       client,
+		mountPath:    "ssh-backups",
+		fromPath:     "users/host-alice",
+		toPath:       "shared",
+		fromStrategy: StrategyMachineUser,
+		toStrategy:   StrategyUniversal,
+	}
+
+	result, err := service.MigrateAllBackups(context.Background(), true)
+	if err != nil {
+		t.Fatalf("MigrateAllBackups() error = %v", err)
+	}
+	if result.MigratedBlobs != 0 || result.BlobsToMigrate != 2 {
+		t.Errorf("MigratedBlobs = %d, BlobsToMigrate = %d; want 0 and 2 on a dry run", result.MigratedBlobs, result.BlobsToMigrate)
+	}
+	for key := range kv.secrets {
+		if strings.HasPrefix(key, "shared/") {
+			t.Errorf("dry run wrote %s", key)
+		}
+	}
+
+	summary := result.GetMigrationSummary()
+	if !strings.Contains(summary, "would be copied: 2") || strings.Contains(summary, "contents copied") {
+		t.Errorf("summary should report contents that would be copied:\n%s", summary)
+	}
+}
@@ -27,6 +27,11 @@ const (
 	// StrategyCustom uses a user-defined prefix
 	// Path: {custom-prefix}/backups/{backup-name}
 	StrategyCustom StorageStrategy = "custom"
+
+	// StrategySystem isolates host-level backups (/etc/ssh) by hostname. It is
+	// selected by --system rather than configured, whatever storage_strategy says.
+	// Path: systems/{hostname}/backups/{backup-name}
+	StrategySystem StorageStrategy = "system"
 )
 
 // PathGenerator generates Vault storage paths based on configured strategy
@@ -34,6 +39,7 @@ type PathGenerator struct {
 	strategy     StorageStrategy
 	customPrefix string
 	namespace    string
+	username     string
 }
 
 // NewPathGenerator creates a new path generator with the specified strategy
@@ -45,6 +51,12 @@ func NewPathGenerator(strategy StorageStrategy, customPrefix, namespace string)
 	}
 }
 
+// SetUsername makes user-scoped paths use username instead of the current user.
+// An empty username restores the default.
+func (p *PathGenerator) SetUsername(username string) {
+	p.username = username
+}
+
 // GenerateBasePath creates the base path for backup storage based on the configured strategy
 func (p *PathGenerator) GenerateBasePath() (string, error) {
 	switch p.strategy {
@@ -56,6 +68,8 @@ func (p *PathGenerator) GenerateBasePath() (string, error) {
 		return p.generateMachineUserPath()
 	case StrategyCustom:
 		return p.generateCustomPath()
+	case StrategySystem:
+		return p.generateSystemPath()
 	default:
 		return "", fmt.Errorf("unknown storage strategy: %s", p.strategy)
 	}
@@ -103,8 +117,20 @@ func (p *PathGenerator) generateCustomPath() (string, error) {
 	return sanitizedPrefix, nil
 }
 
+// generateSystemPath creates the hostname-scoped path for host-level backups
+func (p *PathGenerator) generateSystemPath() (string, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown-host"
+	}
+	return fmt.Sprintf("systems/%s", utils.SanitizePathComponent(hostname)), nil
+}
+
 // getCurrentUsername gets the current username with fallback
 func (p *PathGenerator) getCurrentUsername() string {
+	if p.username != "" {
+		return p.username
+	}
 	username := os.Getenv("USER")
 	if username == "" {
 		username = os.Getenv("USERNAME") // Windows fallback
@@ -129,6 +155,8 @@ func (p *PathGenerator) GetStrategyDescription() string {
 		return "Machine-user scoped storage (isolated by hostname and username)"
 	case StrategyCustom:
 		return fmt.Sprintf("Custom storage with prefix '%s'", p.customPrefix)
+	case StrategySystem:
+		return "System storage (host keys and sshd configuration, isolated by hostname)"
 	default:
 		return "Unknown storage strategy"
 	}
@@ -137,7 +165,7 @@ func (p *PathGenerator) GetStrategyDescription() string {
 // ValidateStrategy checks if the strategy configuration is valid
 func (p *PathGenerator) ValidateStrategy() error {
 	switch p.strategy {
-	case StrategyUniversal, StrategyUser, StrategyMachineUser:
+	case StrategyUniversal, StrategyUser, StrategyMachineUser, StrategySystem:
 		// These strategies don't require additional configuration
 		return nil
 	case StrategyCustom:
@@ -153,7 +181,8 @@ func (p *PathGenerator) ValidateStrategy() error {
 	}
 }
 
-// ParseStrategy converts a string to StorageStrategy with validation
+// ParseStrategy converts a configured storage strategy to StorageStrategy with
+// validation. StrategySystem is refused: only --system selects it.
 func ParseStrategy(strategy string) (StorageStrategy, error) {
 	switch strings.ToLower(strings.TrimSpace(strategy)) {
 	case "universal", "shared":
@@ -164,12 +193,15 @@ func ParseStrategy(strategy string) (StorageStrategy, error) {
 		return StrategyMachineUser, nil
 	case "custom":
 		return StrategyCustom, nil
+	case "system":
+		return "", fmt.Errorf("storage strategy system cannot be configured; it is selected by --system")
 	default:
 		return "", fmt.Errorf("invalid storage strategy: %s (valid options: universal, user, machine-user, custom)", strategy)
 	}
 }
 
-// GetAllStrategies returns all available storage strategies with descriptions
+// GetAllStrategies returns the storage strategies that can be configured, with
+// descriptions. StrategySystem is left out because --system selects it.
 func GetAllStrategies() map[StorageStrategy]string {
 	return map[StorageStrategy]string{
 		StrategyUniversal:   "Universal storage - shared across machines and users (recommended)",
@@ -94,6 +94,12 @@ func TestPathGenerator_GenerateBasePath(t *testing.T) {
 			wantContains: "shared/personal",
 			wantErr:      false,
 		},
+		{
+			name:         "system strategy",
+			strategy:     StrategySystem,
+			wantContains: "systems/",
+			wantErr:      false,
+		},
 		{
 			name:         "user strategy",
 			strategy:     StrategyUser,
@@ -155,6 +161,28 @@ func TestPathGenerator_GenerateBasePath(t *testing.T) {
 	}
 }
 
+func TestPathGenerator_SetUsername(t *testing.T) {
+	originalUser := os.Getenv("USER")
+	defer os.Setenv("USER", originalUser)
+	os.Setenv("USER", "root")
+
+	generator := NewPathGenerator(StrategyUser, "", "")
+	generator.SetUsername("alice")
+
+	path, err := generator.GenerateBasePath()
+	if err != nil {
+		t.Fatalf("GenerateBasePath() error = %v", err)
+	}
+	if path != "users/alice" {
+		t.Errorf("GenerateBasePath() = %q, want users/alice", path)
+	}
+
+	generator.SetUsername("")
+	if path, _ := generator.GenerateBasePath(); path != "users/root" {
+		t.Errorf("GenerateBasePath() without username = %q, want users/root", path)
+	}
+}
+
 func TestPathGenerator_ValidateStrategy(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -255,6 +283,12 @@ func TestParseStrategy(t *testing.T) {
 			expected: StrategyUser,
 			wantErr:  false,
 		},
+		{
+			name:     "system is selected by --system only",
+			input:    "system",
+			expected: "",
+			wantErr:  true,
+		},
 		{
 			name:     "machine-user",
 			input:    "machine-user",
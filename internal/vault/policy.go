@@ -0,0 +1,286 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/rs/zerolog/log"
+	"github.com/rzago/ssh-secret-keeper/internal/config"
+	"github.com/rzago/ssh-secret-keeper/internal/utils"
+)
+
+// IdentityTemplate is the Vault ACL template resolving to the caller's entity name
+const IdentityTemplate = "{{identity.entity.name}}"
+
+// capabilityProbeName replaces trailing globs when testing a policy path
+const capabilityProbeName = "sshsk-capability-probe"
+
+// PolicyOptions controls how a least-privilege Vault policy is generated
+type PolicyOptions struct {
+	Strategy     StorageStrategy
+	MountPath    string
+	CustomPrefix string
+	Namespace    string // Backup namespace for the universal strategy
+	Username     string // Empty uses an identity template (user) or the current user (machine-user)
+	Hostname     string // Empty uses the current hostname (machine-user and system)
+	ReadOnly     bool
+}
+
+// PolicyRule is a single path stanza of a Vault ACL policy
+type PolicyRule struct {
+	Path         string
+	Capabilities []string
+	Description  string
+}
+
+// PolicyBasePath returns the base path a policy should be scoped to.
+// Unlike PathGenerator it can emit templated identity paths for the user strategy.
+func PolicyBasePath(opts PolicyOptions) (string, error) {
+	switch opts.Strategy {
+	case StrategyUser:
+		if opts.Username == "" {
+			return fmt.Sprintf("users/%s", IdentityTemplate), nil
+		}
+		return fmt.Sprintf("users/%s", utils.SanitizePathComponent(opts.Username)), nil
+
+	case StrategyMachineUser:
+		hostname := policyHostname(opts)
+		username := opts.Username
+		if username == "" {
+			username = (&PathGenerator{}).getCurrentUsername()
+		}
+		return fmt.Sprintf("users/%s-%s",
+			utils.SanitizePathComponent(hostname),
+			utils.SanitizePathComponent(username)), nil
+
+	case StrategySystem:
+		return fmt.Sprintf("systems/%s", utils.SanitizePathComponent(policyHostname(opts))), nil
+
+	default:
+		generator := NewPathGenerator(opts.Strategy, opts.CustomPrefix, opts.Namespace)
+		if err := generator.ValidateStrategy(); err != nil {
+			return "", err
+		}
+		return generator.GenerateBasePath()
+	}
+}
+
+// policyHostname returns the hostname a policy is scoped to, defaulting to the current host
+func policyHostname(opts PolicyOptions) string {
+	if opts.Hostname != "" {
+		return opts.Hostname
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "unknown-host"
+	}
+	return hostname
+}
+
+// PolicyRules returns the paths and capabilities sshsk needs under basePath
+func PolicyRules(mountPath, basePath string, readOnly bool) []PolicyRule {
+	if readOnly {
+		return []PolicyRule{
+			{
+				Path:         fmt.Sprintf("%s/data/%s/backups/*", mountPath, basePath),
+				Capabilities: []string{"read"},
+				Description:  "Read backups (restore, status, diff)",
+			},
+			{
+				Path:         fmt.Sprintf("%s/data/%s/metadata", mountPath, basePath),
+				Capabilities: []string{"read"},
+				Description:  "Read the backup metadata index",
+			},
+			{
+				Path:         fmt.Sprintf("%s/metadata/%s/backups/*", mountPath, basePath),
+				Capabilities: []string{"list"},
+				Description:  "List backups",
+			},
+			{
+				Path:         fmt.Sprintf("%s/data/%s/blobs/*", mountPath, basePath),
+				Capabilities: []string{"read"},
+				Description:  "Read deduplicated file contents",
+			},
+			{
+				Path:         "auth/token/lookup-self",
+				Capabilities: []string{"read"},
+				Description:  "Connection test",
+			},
+		}
+	}
+
+	return []PolicyRule{
+		{
+			Path:         fmt.Sprintf("%s/data/%s/backups/*", mountPath, basePath),
+			Capabilities: []string{"create", "read", "update", "delete"},
+			Description:  "Store, read and delete backups",
+		},
+		{
+			Path:         fmt.Sprintf("%s/data/%s/metadata", mountPath, basePath),
+			Capabilities: []string{"create", "read", "update"},
+			Description:  "Maintain the backup metadata index",
+		},
+		{
+			Path:         fmt.Sprintf("%s/metadata/%s/backups/*", mountPath, basePath),
+			Capabilities: []string{"list", "delete"},
+			Description:  "List backups and remove their version history",
+		},
+		{
+			Path:         fmt.Sprintf("%s/data/%s/blobs/*", mountPath, basePath),
+			Capabilities: []string{"create", "read", "update"},
+			Description:  "Store and read deduplicated file contents",
+		},
+		{
+			Path:         fmt.Sprintf("%s/metadata/%s/blobs/*", mountPath, basePath),
+			Capabilities: []string{"list", "delete"},
+			Description:  "List file contents and garbage-collect unreferenced ones",
+		},
+		{
+			Path:         "auth/token/lookup-self",
+			Capabilities: []string{"read"},
+			Description:  "Connection test",
+		},
+	}
+}
+
+// GeneratePolicy renders a least-privilege HCL policy for the given options
+func GeneratePolicy(opts PolicyOptions) (string, error) {
+	if opts.MountPath == "" {
+		return "", fmt.Errorf("mount path is required")
+	}
+
+	basePath, err := PolicyBasePath(opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to determine policy base path: %w", err)
+	}
+
+	access := "read-write"
+	if opts.ReadOnly {
+		access = "read-only"
+	}
+
+	var policy strings.Builder
+	policy.WriteString(fmt.Sprintf("# SSH Secret Keeper %s policy\n", access))
+	policy.WriteString(fmt.Sprintf("# Strategy: %s\n", opts.Strategy))
+	policy.WriteString(fmt.Sprintf("# Base path: %s/%s\n", opts.MountPath, basePath))
+	if strings.Contains(basePath, IdentityTemplate) {
+		policy.WriteString("# The identity template must resolve to the same name as $USER on each machine\n")
+	}
+
+	for _, rule := range PolicyRules(opts.MountPath, basePath, opts.ReadOnly) {
+		policy.WriteString("\n")
+		policy.WriteString(fmt.Sprintf("# %s\n", rule.Description))
+		policy.WriteString(fmt.Sprintf("path %q {\n", rule.Path))
+		policy.WriteString(fmt.Sprintf("  capabilities = [%s]\n", quoteList(rule.Capabilities)))
+		policy.WriteString("}\n")
+	}
+
+	return policy.String(), nil
+}
+
+// CapabilityCheck is the result of testing one policy rule against the current token
+type CapabilityCheck struct {
+	Rule    PolicyRule
+	Probe   string
+	Granted []string
+	Missing []string
+}
+
+// OK reports whether the token holds every required capability
+func (c CapabilityCheck) OK() bool {
+	return len(c.Missing) == 0
+}
+
+// PolicyChecker tests the current token's capabilities via sys/capabilities-self
+type PolicyChecker struct {
+	client *api.Client
+}
+
+// NewPolicyChecker creates a policy checker using the configured Vault token
+func NewPolicyChecker(cfg *config.VaultConfig) (*PolicyChecker, error) {
+	client, err := createVaultClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Vault client: %w", err)
+	}
+
+	return &PolicyChecker{client: client}, nil
+}
+
+// Check queries the token's capabilities for every rule
+func (c *PolicyChecker) Check(ctx context.Context, rules []PolicyRule) ([]CapabilityCheck, error) {
+	checks := make([]CapabilityCheck, 0, len(rules))
+
+	for _, rule := range rules {
+		probe := probePath(rule.Path)
+
+		granted, err := c.client.Sys().CapabilitiesSelfWithContext(ctx, probe)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query capabilities for %s: %w", probe, err)
+		}
+
+		check := CapabilityCheck{
+			Rule:    rule,
+			Probe:   probe,
+			Granted: granted,
+			Missing: missingCapabilities(rule.Capabilities, granted),
+		}
+
+		log.Debug().
+			Str("path", probe).
+			Strs("granted", granted).
+			Strs("missing", check.Missing).
+			Msg("Checked token capabilities")
+
+		checks = append(checks, check)
+	}
+
+	return checks, nil
+}
+
+// Close clears the token from memory
+func (c *PolicyChecker) Close() {
+	if c.client != nil {
+		c.client.SetToken("")
+	}
+}
+
+// probePath turns a policy glob into a concrete path that capabilities-self can evaluate
+func probePath(policyPath string) string {
+	if strings.HasSuffix(policyPath, "*") {
+		return strings.TrimSuffix(policyPath, "*") + capabilityProbeName
+	}
+	return policyPath
+}
+
+// missingCapabilities returns the required capabilities not present in granted
+func missingCapabilities(required, granted []string) []string {
+	have := make(map[string]bool, len(granted))
+	for _, capability := range granted {
+		if capability == "root" {
+			return nil
+		}
+		have[capability] = true
+	}
+
+	var missing []string
+	for _, capability := range required {
+		if !have[capability] {
+			missing = append(missing, capability)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// quoteList renders a list of strings as an HCL array body
+func quoteList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = fmt.Sprintf("%q", value)
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,146 @@
+package vault
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPolicyBasePath(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    PolicyOptions
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "universal",
+			opts: PolicyOptions{Strategy: StrategyUniversal},
+			want: "shared",
+		},
+		{
+			name: "universal with namespace",
+			opts: PolicyOptions{Strategy: StrategyUniversal, Namespace: "team"},
+			want: "shared/team",
+		},
+		{
+			name: "user templated",
+			opts: PolicyOptions{Strategy: StrategyUser},
+			want: "users/{{identity.entity.name}}",
+		},
+		{
+			name: "user explicit",
+			opts: PolicyOptions{Strategy: StrategyUser, Username: "alice"},
+			want: "users/alice",
+		},
+		{
+			name: "machine-user explicit",
+			opts: PolicyOptions{Strategy: StrategyMachineUser, Username: "alice", Hostname: "laptop"},
+			want: "users/laptop-alice",
+		},
+		{
+			name: "system explicit",
+			opts: PolicyOptions{Strategy: StrategySystem, Hostname: "web-01"},
+			want: "systems/web-01",
+		},
+		{
+			name: "custom",
+			opts: PolicyOptions{Strategy: StrategyCustom, CustomPrefix: "devops"},
+			want: "devops",
+		},
+		{
+			name:    "custom without prefix",
+			opts:    PolicyOptions{Strategy: StrategyCustom},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PolicyBasePath(tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PolicyBasePath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("PolicyBasePath() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeneratePolicy(t *testing.T) {
+	t.Run("read-write universal", func(t *testing.T) {
+		policy, err := GeneratePolicy(PolicyOptions{Strategy: StrategyUniversal, MountPath: "ssh-backups"})
+		if err != nil {
+			t.Fatalf("GeneratePolicy() error = %v", err)
+		}
+
+		wantStanzas := []string{
+			"path \"ssh-backups/data/shared/backups/*\" {\n  capabilities = [\"create\", \"read\", \"update\", \"delete\"]\n}",
+			"path \"ssh-backups/data/shared/metadata\" {\n  capabilities = [\"create\", \"read\", \"update\"]\n}",
+			"path \"ssh-backups/metadata/shared/backups/*\" {\n  capabilities = [\"list\", \"delete\"]\n}",
+		}
+		for _, stanza := range wantStanzas {
+			if !strings.Contains(policy, stanza) {
+				t.Errorf("policy missing stanza:\n%s\n\ngot:\n%s", stanza, policy)
+			}
+		}
+
+		if strings.Contains(policy, "ssh-backups/*") {
+			t.Error("policy must not grant access to the whole mount")
+		}
+	})
+
+	t.Run("read-only user templated", func(t *testing.T) {
+		policy, err := GeneratePolicy(PolicyOptions{Strategy: StrategyUser, MountPath: "kv", ReadOnly: true})
+		if err != nil {
+			t.Fatalf("GeneratePolicy() error = %v", err)
+		}
+
+		if !strings.Contains(policy, `path "kv/data/users/{{identity.entity.name}}/backups/*"`) {
+			t.Errorf("expected templated identity path, got:\n%s", policy)
+		}
+		for _, capability := range []string{`"create"`, `"update"`, `"delete"`} {
+			if strings.Contains(policy, capability) {
+				t.Errorf("read-only policy grants %s:\n%s", capability, policy)
+			}
+		}
+	})
+
+	t.Run("missing mount", func(t *testing.T) {
+		if _, err := GeneratePolicy(PolicyOptions{Strategy: StrategyUniversal}); err == nil {
+			t.Error("expected error for empty mount path")
+		}
+	})
+}
+
+func TestProbePath(t *testing.T) {
+	if got := probePath("kv/data/shared/backups/*"); got != "kv/data/shared/backups/"+capabilityProbeName {
+		t.Errorf("probePath() = %q", got)
+	}
+	if got := probePath("kv/data/shared/metadata"); got != "kv/data/shared/metadata" {
+		t.Errorf("probePath() = %q", got)
+	}
+}
+
+func TestMissingCapabilities(t *testing.T) {
+	tests := []struct {
+		name     string
+		required []string
+		granted  []string
+		want     []string
+	}{
+		{"all granted", []string{"read", "list"}, []string{"list", "read", "update"}, nil},
+		{"some missing", []string{"create", "read", "update"}, []string{"read"}, []string{"create", "update"}},
+		{"root grants everything", []string{"create", "delete"}, []string{"root"}, nil},
+		{"deny", []string{"read"}, []string{"deny"}, []string{"read"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := missingCapabilities(tt.required, tt.granted); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("missingCapabilities() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
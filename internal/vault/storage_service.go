@@ -2,12 +2,17 @@ package vault
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/vault/api"
 	"github.com/rs/zerolog/log"
 	"github.com/rzago/ssh-secret-keeper/internal/config"
+	"github.com/rzago/ssh-secret-keeper/internal/interfaces"
 )
 
 // StorageService provides Vault storage functionality following SRP
@@ -19,12 +24,6 @@ type StorageService struct {
 
 // NewStorageService creates a new Vault storage service
 func NewStorageService(cfg *config.VaultConfig) (*StorageService, error) {
-	// Create Vault client with proper configuration
-	client, err := createVaultClient(cfg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create Vault client: %w", err)
-	}
-
 	// Generate base path using the new path strategy system
 	strategy, err := ParseStrategy(cfg.StorageStrategy)
 	if err != nil {
@@ -35,7 +34,26 @@ func NewStorageService(cfg *config.VaultConfig) (*StorageService, error) {
 		strategy = StrategyMachineUser
 	}
 
+	return newStorageService(cfg, strategy)
+}
+
+// NewSystemStorageService creates a Vault storage service for host-level
+// backups taken with --system, stored under systems/<hostname> whatever
+// storage strategy is configured
+func NewSystemStorageService(cfg *config.VaultConfig) (*StorageService, error) {
+	return newStorageService(cfg, StrategySystem)
+}
+
+// newStorageService creates a Vault storage service storing backups according to strategy
+func newStorageService(cfg *config.VaultConfig, strategy StorageStrategy) (*StorageService, error) {
+	// Create Vault client with proper configuration
+	client, err := createVaultClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Vault client: %w", err)
+	}
+
 	pathGenerator := NewPathGenerator(strategy, cfg.CustomPrefix, cfg.BackupNamespace)
+	pathGenerator.SetUsername(cfg.Username)
 	if err := pathGenerator.ValidateStrategy(); err != nil {
 		return nil, fmt.Errorf("invalid path strategy configuration: %w", err)
 	}
@@ -138,8 +156,9 @@ func (s *StorageService) GetBackup(ctx context.Context, backupName string) (map[
 		return nil, fmt.Errorf("failed to read backup %s: %w", backupName, err)
 	}
 
-	if secret == nil {
-		return nil, fmt.Errorf("backup %s not found", backupName)
+	// A deleted latest version still answers, with null data
+	if secret == nil || secret.Data["data"] == nil {
+		return nil, fmt.Errorf("%w: %s", interfaces.ErrBackupNotFound, backupName)
 	}
 
 	// Extract data from KV v2 format
@@ -187,9 +206,11 @@ func (s *StorageService) ListBackups(ctx context.Context) ([]string, error) {
 	return backups, nil
 }
 
-// DeleteBackup deletes a backup
+// DeleteBackup permanently deletes a backup including its version history.
+// Deleting only the data path would soft-delete the latest version and leave
+// the name listed by ListBackups, with nothing left to read.
 func (s *StorageService) DeleteBackup(ctx context.Context, backupName string) error {
-	path := s.buildBackupPath(backupName)
+	path := s.buildBackupMetadataPath(backupName)
 
 	_, err := s.client.Logical().DeleteWithContext(ctx, path)
 	if err != nil {
@@ -203,6 +224,85 @@ func (s *StorageService) DeleteBackup(ctx context.Context, backupName string) er
 	return nil
 }
 
+// StoreBlob stores a content-addressed blob. Blobs are immutable, so an
+// existing blob with the same hash is simply overwritten with identical data.
+func (s *StorageService) StoreBlob(ctx context.Context, hash string, data map[string]interface{}) error {
+	path := s.buildBlobPath(hash)
+
+	_, err := s.client.Logical().WriteWithContext(ctx, path, map[string]interface{}{"data": data})
+	if err != nil {
+		return fmt.Errorf("failed to store blob %s: %w", hash, err)
+	}
+
+	log.Debug().
+		Str("blob", hash).
+		Msg("Blob stored successfully")
+
+	return nil
+}
+
+// GetBlob retrieves a content-addressed blob
+func (s *StorageService) GetBlob(ctx context.Context, hash string) (map[string]interface{}, error) {
+	secret, err := s.client.Logical().ReadWithContext(ctx, s.buildBlobPath(hash))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob %s: %w", hash, err)
+	}
+
+	if secret == nil {
+		return nil, fmt.Errorf("blob %s not found", hash)
+	}
+
+	data, ok := secret.Data["data"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid blob data format for %s", hash)
+	}
+
+	return data, nil
+}
+
+// ListBlobs lists the hashes of all stored blobs
+func (s *StorageService) ListBlobs(ctx context.Context) ([]string, error) {
+	secret, err := s.client.Logical().ListWithContext(ctx, s.buildBlobListPath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list blobs: %w", err)
+	}
+
+	if secret == nil {
+		return []string{}, nil
+	}
+
+	keys, ok := secret.Data["keys"].([]interface{})
+	if !ok {
+		return []string{}, nil
+	}
+
+	blobs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if keyStr, ok := key.(string); ok {
+			blobs = append(blobs, keyStr)
+		}
+	}
+
+	return blobs, nil
+}
+
+// DeleteBlob permanently deletes a blob including its version history, so
+// unreferenced content does not linger after garbage collection
+func (s *StorageService) DeleteBlob(ctx context.Context, hash string) error {
+	path := fmt.Sprintf("%s/metadata/%s/blobs/%s", s.mountPath, s.basePath, hash)
+
+	_, err := s.client.Logical().DeleteWithContext(ctx, path)
+	if err != nil {
+		return fmt.Errorf("failed to delete blob %s: %w", hash, err)
+	}
+
+	log.Debug().
+		Str("blob", hash).
+		Msg("Blob deleted successfully")
+
+	return nil
+}
+
 // StoreMetadata stores backup metadata
 func (s *StorageService) StoreMetadata(ctx context.Context, metadata map[string]interface{}) error {
 	path := s.buildMetadataPath()
@@ -242,6 +342,57 @@ func (s *StorageService) GetMetadata(ctx context.Context) (map[string]interface{
 	return data, nil
 }
 
+// UpdateMetadata applies update to the metadata document using KV v2 check-and-set.
+// The document is re-read and update is re-applied whenever another writer changed
+// it in between, so concurrent backups from different machines never lose entries.
+func (s *StorageService) UpdateMetadata(ctx context.Context, update func(metadata map[string]interface{}) error) error {
+	path := s.buildMetadataPath()
+
+	for attempt := 1; attempt <= maxMetadataCASAttempts; attempt++ {
+		metadata, version, err := s.readMetadataWithVersion(ctx)
+		if err != nil {
+			return err
+		}
+
+		if err := update(metadata); err != nil {
+			return err
+		}
+
+		secretData := map[string]interface{}{
+			"data": metadata,
+			"options": map[string]interface{}{
+				"cas": version,
+			},
+		}
+
+		_, err = s.client.Logical().WriteWithContext(ctx, path, secretData)
+		if err == nil {
+			log.Debug().
+				Int("attempt", attempt).
+				Int64("previous_version", version).
+				Msg("Metadata updated with check-and-set")
+			return nil
+		}
+
+		if !isCASConflict(err) {
+			return fmt.Errorf("failed to store metadata: %w", err)
+		}
+
+		log.Debug().
+			Int("attempt", attempt).
+			Int64("version", version).
+			Msg("Metadata changed concurrently, retrying update")
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt) * metadataCASRetryDelay):
+		}
+	}
+
+	return fmt.Errorf("failed to update metadata after %d attempts: concurrent modifications kept conflicting", maxMetadataCASAttempts)
+}
+
 // GetBasePath returns the base path for this client
 func (s *StorageService) GetBasePath() string {
 	return s.basePath
@@ -258,14 +409,91 @@ func (s *StorageService) Close() {
 
 // Private helper methods
 
+const (
+	// maxMetadataCASAttempts bounds the check-and-set retry loop in UpdateMetadata
+	maxMetadataCASAttempts = 8
+	// metadataCASRetryDelay is multiplied by the attempt number between retries
+	metadataCASRetryDelay = 50 * time.Millisecond
+)
+
+// readMetadataWithVersion reads the metadata document together with its KV v2 version.
+// A version of 0 means the document does not exist yet.
+func (s *StorageService) readMetadataWithVersion(ctx context.Context) (map[string]interface{}, int64, error) {
+	secret, err := s.client.Logical().ReadWithContext(ctx, s.buildMetadataPath())
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read metadata: %w", err)
+	}
+
+	metadata := make(map[string]interface{})
+	if secret == nil {
+		return metadata, 0, nil
+	}
+
+	if data, ok := secret.Data["data"].(map[string]interface{}); ok {
+		metadata = data
+	}
+
+	var version int64
+	if kvMetadata, ok := secret.Data["metadata"].(map[string]interface{}); ok {
+		version = parseKVVersion(kvMetadata["version"])
+	}
+
+	return metadata, version, nil
+}
+
+// parseKVVersion converts the version field of a KV v2 response to int64
+func parseKVVersion(value interface{}) int64 {
+	switch v := value.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+	case float64:
+		return int64(v)
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	}
+	return 0
+}
+
+// isCASConflict reports whether err is Vault rejecting a write because the
+// check-and-set version no longer matches the current version
+func isCASConflict(err error) bool {
+	var respErr *api.ResponseError
+	if !errors.As(err, &respErr) || respErr.StatusCode != http.StatusBadRequest {
+		return false
+	}
+
+	for _, msg := range respErr.Errors {
+		if strings.Contains(msg, "check-and-set") {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *StorageService) buildBackupPath(backupName string) string {
 	return fmt.Sprintf("%s/data/%s/backups/%s", s.mountPath, s.basePath, backupName)
 }
 
+func (s *StorageService) buildBackupMetadataPath(backupName string) string {
+	return fmt.Sprintf("%s/metadata/%s/backups/%s", s.mountPath, s.basePath, backupName)
+}
+
 func (s *StorageService) buildBackupListPath() string {
 	return fmt.Sprintf("%s/metadata/%s/backups", s.mountPath, s.basePath)
 }
 
+func (s *StorageService) buildBlobPath(hash string) string {
+	return fmt.Sprintf("%s/data/%s/blobs/%s", s.mountPath, s.basePath, hash)
+}
+
+func (s *StorageService) buildBlobListPath() string {
+	return fmt.Sprintf("%s/metadata/%s/blobs", s.mountPath, s.basePath)
+}
+
 func (s *StorageService) buildMetadataPath() string {
 	return fmt.Sprintf("%s/data/%s/metadata", s.mountPath, s.basePath)
 }
@@ -1,8 +1,18 @@
 package vault
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/rzago/ssh-secret-keeper/internal/config"
 )
 
 // Note: Mock client code removed as we're only testing pure unit logic without Vault dependencies
@@ -415,3 +425,291 @@ func BenchmarkStorageService_StrategyPathGeneration(b *testing.B) {
 		})
 	}
 }
+
+func TestIsCASConflict(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "check-and-set mismatch",
+			err: &api.ResponseError{
+				StatusCode: http.StatusBadRequest,
+				Errors:     []string{"check-and-set parameter did not match the current version"},
+			},
+			want: true,
+		},
+		{
+			name: "other bad request",
+			err: &api.ResponseError{
+				StatusCode: http.StatusBadRequest,
+				Errors:     []string{"no data provided"},
+			},
+			want: false,
+		},
+		{
+			name: "permission denied",
+			err: &api.ResponseError{
+				StatusCode: http.StatusForbidden,
+				Errors:     []string{"permission denied"},
+			},
+			want: false,
+		},
+		{
+			name: "wrapped conflict",
+			err: fmt.Errorf("write failed: %w", &api.ResponseError{
+				StatusCode: http.StatusBadRequest,
+				Errors:     []string{"check-and-set parameter did not match the current version"},
+			}),
+			want: true,
+		},
+		{
+			name: "plain error",
+			err:  errors.New("connection refused"),
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isCASConflict(tt.err); got != tt.want {
+				t.Errorf("isCASConflict() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeKVv2 emulates the check-and-set behaviour of a single KV v2 secret
+type fakeKVv2 struct {
+	mu      sync.Mutex
+	version int64
+	data    map[string]interface{}
+	// beforeWrite runs once before the first write is applied, simulating a concurrent writer
+	beforeWrite func(k *fakeKVv2)
+}
+
+func (k *fakeKVv2) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		if k.version == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"data":     k.data,
+				"metadata": map[string]interface{}{"version": k.version},
+			},
+		})
+	case http.MethodPut, http.MethodPost:
+		if k.beforeWrite != nil {
+			hook := k.beforeWrite
+			k.beforeWrite = nil
+			hook(k)
+		}
+
+		var body struct {
+			Data    map[string]interface{} `json:"data"`
+			Options struct {
+				CAS *int64 `json:"cas"`
+			} `json:"options"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+
+		if body.Options.CAS != nil && *body.Options.CAS != k.version {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":["check-and-set parameter did not match the current version"]}`))
+			return
+		}
+
+		k.version++
+		k.data = body.Data
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{"version": k.version},
+		})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestStorageService_UpdateMetadata_RetriesOnConflict(t *testing.T) {
+	kv := &fakeKVv2{}
+	kv.beforeWrite = func(k *fakeKVv2) {
+		// Another machine registers its backup between our read and write
+		k.version++
+		k.data = map[string]interface{}{
+			"backups": map[string]interface{}{"other-machine": map[string]interface{}{}},
+		}
+	}
+
+	server := httptest.NewServer(kv)
+	defer server.Close()
+
+	client, err := api.NewClient(&api.Config{Address: server.URL})
+	if err != nil {
+		t.Fatalf("api.NewClient() error = %v", err)
+	}
+	client.SetToken("test-token")
+
+	service := &StorageService{
+		client:    client,
+		mountPath: "ssh-backups",
+		basePath:  "shared",
+	}
+
+	calls := 0
+	err = service.UpdateMetadata(context.Background(), func(metadata map[string]interface{}) error {
+		calls++
+		backups, ok := metadata["backups"].(map[string]interface{})
+		if !ok {
+			backups = make(map[string]interface{})
+			metadata["backups"] = backups
+		}
+		backups["this-machine"] = map[string]interface{}{}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateMetadata() error = %v", err)
+	}
+
+	if calls != 2 {
+		t.Errorf("update function called %d times, want 2 (one retry after conflict)", calls)
+	}
+
+	backups, _ := kv.data["backups"].(map[string]interface{})
+	for _, name := range []string{"other-machine", "this-machine"} {
+		if _, ok := backups[name]; !ok {
+			t.Errorf("entry %q lost after concurrent update, index = %v", name, backups)
+		}
+	}
+}
+
+// fakeKVEngine emulates the deletion semantics of a KV v2 mount: deleting the
+// data path only soft-deletes the latest version, which stays listed, while
+// deleting the metadata path removes the key with its version history
+type fakeKVEngine struct {
+	mu      sync.Mutex
+	secrets map[string]map[string]interface{} // Key below the mount -> data, nil once soft-deleted
+}
+
+func (k *fakeKVEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	path := strings.TrimPrefix(r.URL.Path, "/v1/ssh-backups/")
+	kind, key, _ := strings.Cut(path, "/")
+
+	switch {
+	case kind == "metadata" && (r.Method == "LIST" || r.URL.Query().Get("list") == "true"):
+		prefix := strings.TrimSuffix(key, "/") + "/"
+		var keys []string
+		for name := range k.secrets {
+			if rest := strings.TrimPrefix(name, prefix); rest != name && !strings.Contains(rest, "/") {
+				keys = append(keys, rest)
+			}
+		}
+		if len(keys) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"keys": keys}})
+	case kind == "metadata" && r.Method == http.MethodDelete:
+		delete(k.secrets, key)
+		w.WriteHeader(http.StatusNoContent)
+	case kind == "data" && r.Method == http.MethodDelete:
+		if _, ok := k.secrets[key]; ok {
+			k.secrets[key] = nil
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case kind == "data" && r.Method == http.MethodGet:
+		data := k.secrets[key]
+		if data == nil {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"data": data}})
+	case kind == "data" && (r.Method == http.MethodPut || r.Method == http.MethodPost):
+		var body struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		k.secrets[key] = body.Data
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"version": 1}})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestStorageService_DeleteBackup_RemovesFromList(t *testing.T) {
+	kv := &fakeKVEngine{secrets: make(map[string]map[string]interface{})}
+	server := httptest.NewServer(kv)
+	defer server.Close()
+
+	client, err := api.NewClient(&api.Config{Address: server.URL})
+	if err != nil {
+		t.Fatalf("api.NewClient() error = %v", err)
+	}
+	client.SetToken("test-token")
+	service := &StorageService{client: client, mountPath: "ssh-backups", basePath: "shared"}
+
+	ctx := context.Background()
+	for _, name := range []string{"laptop-1", "laptop-2"} {
+		if err := service.StoreBackup(ctx, name, map[string]interface{}{"hostname": "laptop"}); err != nil {
+			t.Fatalf("StoreBackup(%s) error = %v", name, err)
+		}
+	}
+
+	// Pruned and deleted backups must not linger as unreadable names
+	if err := service.DeleteBackup(ctx, "laptop-1"); err != nil {
+		t.Fatalf("DeleteBackup() error = %v", err)
+	}
+
+	names, err := service.ListBackups(ctx)
+	if err != nil {
+		t.Fatalf("ListBackups() error = %v", err)
+	}
+	if len(names) != 1 || names[0] != "laptop-2" {
+		t.Errorf("ListBackups() after delete = %v, want [laptop-2]", names)
+	}
+	if _, err := service.GetBackup(ctx, "laptop-2"); err != nil {
+		t.Errorf("GetBackup() of the remaining backup error = %v", err)
+	}
+}
+
+func TestNewSystemStorageService(t *testing.T) {
+	t.Setenv("VAULT_TOKEN", "test-token")
+	cfg := &config.VaultConfig{
+		Address:         "http://localhost:8200",
+		MountPath:       "ssh-backups",
+		StorageStrategy: "user",
+		Username:        "alice",
+	}
+
+	service, err := NewSystemStorageService(cfg)
+	if err != nil {
+		t.Fatalf("NewSystemStorageService() error = %v", err)
+	}
+	if !strings.HasPrefix(service.GetBasePath(), "systems/") {
+		t.Errorf("base path = %s, want systems/<hostname> whatever strategy is configured", service.GetBasePath())
+	}
+
+	// Configuring the system strategy is refused like any invalid strategy
+	cfg.StorageStrategy = "system"
+	service, err = NewStorageService(cfg)
+	if err != nil {
+		t.Fatalf("NewStorageService() error = %v", err)
+	}
+	if strings.HasPrefix(service.GetBasePath(), "systems/") {
+		t.Errorf("base path = %s, configured system strategy should not be honoured", service.GetBasePath())
+	}
+}
@@ -0,0 +1,187 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+	"github.com/rzago/ssh-secret-keeper/internal/filter"
+)
+
+// DefaultDebounce is how long the directory has to stay quiet before a change is reported
+const DefaultDebounce = 5 * time.Second
+
+// Handler is called once a burst of changes has settled, with the changed
+// paths relative to the watched directory
+type Handler func(ctx context.Context, changed []string) error
+
+// Watcher watches a directory tree and calls a Handler after changes settle
+type Watcher struct {
+	dir      string
+	debounce time.Duration
+	filter   *filter.Matcher
+	handler  Handler
+}
+
+// New creates a watcher for dir that calls handler after debounce of inactivity
+func New(dir string, debounce time.Duration, handler Handler) *Watcher {
+	if debounce <= 0 {
+		debounce = DefaultDebounce
+	}
+	return &Watcher{
+		dir:      dir,
+		debounce: debounce,
+		handler:  handler,
+	}
+}
+
+// SetFilter ignores changes to paths the matcher excludes, such as swap files
+func (w *Watcher) SetFilter(matcher *filter.Matcher) {
+	w.filter = matcher
+}
+
+// Run watches until ctx is cancelled. Handler errors are logged and do not stop watching.
+func (w *Watcher) Run(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	if err := w.addTree(watcher, w.dir); err != nil {
+		return err
+	}
+
+	log.Info().
+		Str("event", "watch_started").
+		Str("dir", w.dir).
+		Dur("debounce", w.debounce).
+		Msg("Watching SSH directory for changes")
+
+	pending := make(map[string]bool)
+	var timer *time.Timer
+	var settled <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			log.Info().Str("event", "watch_stopped").Str("dir", w.dir).Msg("Stopped watching SSH directory")
+			return nil
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return fmt.Errorf("file watcher closed unexpectedly")
+			}
+
+			relPath, relevant := w.relevant(event)
+			if !relevant {
+				continue
+			}
+
+			// New subdirectories have to be watched too
+			if event.Has(fsnotify.Create) {
+				if err := w.addTree(watcher, event.Name); err != nil {
+					log.Debug().Err(err).Str("path", relPath).Msg("Cannot watch new path")
+				}
+			}
+
+			log.Debug().
+				Str("event", "change_detected").
+				Str("path", relPath).
+				Str("op", event.Op.String()).
+				Msg("Change detected")
+
+			pending[relPath] = true
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.NewTimer(w.debounce)
+			settled = timer.C
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return fmt.Errorf("file watcher closed unexpectedly")
+			}
+			log.Warn().Err(err).Str("event", "watch_error").Msg("File watcher error")
+
+		case <-settled:
+			settled = nil
+			changed := make([]string, 0, len(pending))
+			for path := range pending {
+				changed = append(changed, path)
+			}
+			sort.Strings(changed)
+			pending = make(map[string]bool)
+
+			log.Info().
+				Str("event", "changes_settled").
+				Strs("paths", changed).
+				Msg("SSH directory changed")
+
+			if err := w.handler(ctx, changed); err != nil {
+				log.Error().Err(err).Str("event", "handler_failed").Msg("Handling SSH directory change failed")
+			}
+		}
+	}
+}
+
+// relevant reports whether an event should trigger the handler, and the
+// path relative to the watched directory
+func (w *Watcher) relevant(event fsnotify.Event) (string, bool) {
+	relPath, err := filepath.Rel(w.dir, event.Name)
+	if err != nil || relPath == "." {
+		return "", false
+	}
+	relPath = filepath.ToSlash(relPath)
+
+	// Removed paths cannot be stat'ed, so match them as files
+	isDir := false
+	if event.Has(fsnotify.Create) {
+		isDir = isDirectory(event.Name)
+	}
+	if w.filter.Match(relPath, isDir).Excluded {
+		return relPath, false
+	}
+
+	return relPath, true
+}
+
+// addTree watches root and every directory below it without following symlinks
+func (w *Watcher) addTree(watcher *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+
+		if relPath, err := filepath.Rel(w.dir, path); err == nil && relPath != "." {
+			if w.filter.Match(filepath.ToSlash(relPath), true).Excluded {
+				return filepath.SkipDir
+			}
+		}
+
+		if err := watcher.Add(path); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		return nil
+	})
+}
+
+func isDirectory(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.IsDir()
+}
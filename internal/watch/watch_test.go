@@ -0,0 +1,64 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rzago/ssh-secret-keeper/internal/filter"
+)
+
+func TestWatcher_DebouncesBursts(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "config.d"), 0700); err != nil {
+		t.Fatalf("Failed to create config.d: %v", err)
+	}
+
+	calls := make(chan []string, 10)
+	watcher := New(dir, 100*time.Millisecond, func(ctx context.Context, changed []string) error {
+		calls <- changed
+		return nil
+	})
+
+	matcher := filter.New()
+	matcher.AddExcludes([]string{"*.swp"}, "exclude_patterns")
+	watcher.SetFilter(matcher)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- watcher.Run(ctx) }()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Run() error = %v", err)
+		}
+	}()
+
+	// Give the watcher time to register the directories
+	time.Sleep(100 * time.Millisecond)
+
+	for _, name := range []string{"id_ed25519", "id_ed25519.pub", "config.d/work", ".config.swp"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("data"), 0600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	select {
+	case changed := <-calls:
+		want := []string{"config.d/work", "id_ed25519", "id_ed25519.pub"}
+		if !reflect.DeepEqual(changed, want) {
+			t.Errorf("changed = %v, want %v", changed, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler was not called")
+	}
+
+	select {
+	case changed := <-calls:
+		t.Errorf("burst triggered a second call with %v", changed)
+	case <-time.After(300 * time.Millisecond):
+	}
+}